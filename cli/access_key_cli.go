@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+)
+
+func ListAccessKeys() error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var authKeys []*model.AuthKey
+	if err := client.get("/core/auth-keys", &authKeys); err != nil {
+		return err
+	}
+	table := newTable("Name", "Prefix", "Created By", "Created")
+	for _, authKey := range authKeys {
+		table.row(authKey.Name, authKey.Prefix, authKey.CreatedBy, authKey.CreatedAt.Local().Format(time.DateTime))
+	}
+	return table.flush()
+}
+
+// AddAccessKey creates an access key for spread release, the key is only shown here
+func AddAccessKey(name string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var key string
+	if err := client.post("/core/auth-key/create", types.CreateAuthKeyRequest{Name: name}, &key); err != nil {
+		return err
+	}
+	log.Println("✦ Created access key " + name + ", it is not shown again:")
+	fmt.Println(key)
+	return nil
+}
+
+// RemoveAccessKey deletes the access key with the name, or the prefix when several have the name
+func RemoveAccessKey(nameOrPrefix string, yes bool) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var authKeys []*model.AuthKey
+	if err := client.get("/core/auth-keys", &authKeys); err != nil {
+		return err
+	}
+	var matches []*model.AuthKey
+	for _, authKey := range authKeys {
+		if authKey.Name == nameOrPrefix || authKey.Prefix == nameOrPrefix {
+			matches = append(matches, authKey)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return fmt.Errorf("access key %s not found", nameOrPrefix)
+	case 1:
+	default:
+		return fmt.Errorf("%d access keys are named %s, remove one by its prefix", len(matches), nameOrPrefix)
+	}
+	authKey := matches[0]
+	if !yes && !confirm("Remove access key "+authKey.Name+" ("+authKey.Prefix+")? Releases made with it will fail") {
+		return fmt.Errorf("cancelled")
+	}
+	if err := client.delete("/core/auth-key/"+authKey.Id.Hex(), nil); err != nil {
+		return err
+	}
+	log.Println("✦ Removed access key " + authKey.Name)
+	return nil
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SwishHQ/spread/types"
+)
+
+// apiClient calls the /core endpoints as the logged in user. An expired access token is refreshed
+// once per request and the rotated tokens are saved
+type apiClient struct {
+	session    *Session
+	httpClient *http.Client
+}
+
+type apiResponse struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data"`
+	// a string, or a list of validation errors
+	Message json.RawMessage `json:"message"`
+}
+
+func newAPIClient() (*apiClient, error) {
+	session, err := loadSession()
+	if err != nil {
+		return nil, err
+	}
+	return &apiClient{session: session, httpClient: &http.Client{Timeout: 2 * time.Minute}}, nil
+}
+
+func (c *apiClient) get(path string, out interface{}) error {
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+func (c *apiClient) post(path string, body interface{}, out interface{}) error {
+	return c.do(http.MethodPost, path, body, out)
+}
+
+func (c *apiClient) put(path string, body interface{}, out interface{}) error {
+	return c.do(http.MethodPut, path, body, out)
+}
+
+func (c *apiClient) delete(path string, out interface{}) error {
+	return c.do(http.MethodDelete, path, nil, out)
+}
+
+func (c *apiClient) do(method string, path string, body interface{}, out interface{}) error {
+	resp, err := c.send(method, path, body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		if err := c.refresh(); err != nil {
+			return err
+		}
+		if resp, err = c.send(method, path, body); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+func (c *apiClient) send(method string, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(content)
+	}
+	req, err := http.NewRequest(method, c.session.Server+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+c.session.AccessToken)
+	return c.httpClient.Do(req)
+}
+
+// refresh trades the refresh token for new tokens, the old refresh token stops working
+func (c *apiClient) refresh() error {
+	tokens, err := postTokens(c.httpClient, c.session.Server+"/token/refresh", types.RefreshTokenRequest{RefreshToken: c.session.RefreshToken})
+	if err != nil {
+		return fmt.Errorf("session expired, run spread login again: %w", err)
+	}
+	c.session.AccessToken = tokens.AccessToken
+	c.session.RefreshToken = tokens.RefreshToken
+	return saveSession(c.session)
+}
+
+func postTokens(httpClient *http.Client, url string, body interface{}) (*types.TokenResponse, error) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var tokens types.TokenResponse
+	if err := decodeResponse(resp, &tokens); err != nil {
+		return nil, err
+	}
+	return &tokens, nil
+}
+
+func decodeResponse(resp *http.Response, out interface{}) error {
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var response apiResponse
+	if err := json.Unmarshal(content, &response); err != nil {
+		return fmt.Errorf("unexpected response %s from the server: %s", resp.Status, strings.TrimSpace(string(content)))
+	}
+	if !response.Success || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", responseMessage(response.Message, resp.Status))
+	}
+	if out == nil || len(response.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(response.Data, out)
+}
+
+func responseMessage(message json.RawMessage, status string) string {
+	var text string
+	if json.Unmarshal(message, &text) == nil && text != "" {
+		return text
+	}
+	var errors []string
+	if json.Unmarshal(message, &errors) == nil && len(errors) > 0 {
+		return strings.Join(errors, ", ")
+	}
+	return status
+}
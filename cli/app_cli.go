@@ -0,0 +1,241 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+)
+
+func ListApps() error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var apps []*model.App
+	if err := client.get("/core/app", &apps); err != nil {
+		return err
+	}
+	table := newTable("Name", "OS", "Deployments")
+	for _, app := range apps {
+		var environments []*model.Environment
+		if err := client.get("/core/environment/"+app.Id.Hex(), &environments); err != nil {
+			return err
+		}
+		names := make([]string, 0, len(environments))
+		for _, environment := range environments {
+			names = append(names, environment.Name)
+		}
+		table.row(app.Name, app.OS, strings.Join(names, ", "))
+	}
+	return table.flush()
+}
+
+// AddApp creates an app and its deployments, Staging and Production like code-push unless others are given
+func AddApp(name string, osName string, deployments []string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	if err := client.post("/core/app", types.CreateAppRequest{AppName: name, OS: osName}, nil); err != nil {
+		return err
+	}
+	log.Println("✦ Created app " + name)
+	if len(deployments) == 0 {
+		return nil
+	}
+	table := newTable("Deployment", "Key")
+	for _, deployment := range deployments {
+		var environment model.Environment
+		if err := client.post("/core/environment", types.CreateEnvironmentRequest{EnvironmentName: deployment, AppName: name}, &environment); err != nil {
+			return err
+		}
+		table.row(environment.Name, environment.Key)
+	}
+	return table.flush()
+}
+
+func RemoveApp(name string, yes bool) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	app, err := findApp(client, name)
+	if err != nil {
+		return err
+	}
+	return deleteResource(client, "/core/app/"+app.Id.Hex(), yes)
+}
+
+func RenameApp(name string, newName string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	app, err := findApp(client, name)
+	if err != nil {
+		return err
+	}
+	if err := client.put("/core/app/"+app.Id.Hex(), types.RenameAppRequest{AppName: newName}, nil); err != nil {
+		return err
+	}
+	log.Println("✦ Renamed app " + name + " to " + newName)
+	return nil
+}
+
+// ListDeployments lists the deployments of an app with the app versions they have releases for,
+// the deployment keys only when asked
+func ListDeployments(appName string, displayKeys bool) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	app, err := findApp(client, appName)
+	if err != nil {
+		return err
+	}
+	var environments []*model.Environment
+	if err := client.get("/core/environment/"+app.Id.Hex(), &environments); err != nil {
+		return err
+	}
+	columns := []string{"Name", "App Versions"}
+	if displayKeys {
+		columns = append(columns, "Key")
+	}
+	table := newTable(columns...)
+	for _, environment := range environments {
+		versions, err := getVersions(client, environment)
+		if err != nil {
+			return err
+		}
+		appVersions := make([]string, 0, len(versions))
+		for _, version := range versions {
+			appVersions = append(appVersions, version.AppVersion)
+		}
+		row := []string{environment.Name, strings.Join(appVersions, ", ")}
+		if displayKeys {
+			row = append(row, environment.Key)
+		}
+		table.row(row...)
+	}
+	return table.flush()
+}
+
+func AddDeployment(appName string, name string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var environment model.Environment
+	if err := client.post("/core/environment", types.CreateEnvironmentRequest{EnvironmentName: name, AppName: appName}, &environment); err != nil {
+		return err
+	}
+	log.Println("✦ Created deployment " + environment.Name + " with key " + environment.Key)
+	return nil
+}
+
+func RemoveDeployment(appName string, name string, yes bool) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	_, environment, err := findDeployment(client, appName, name)
+	if err != nil {
+		return err
+	}
+	return deleteResource(client, "/core/environment/"+environment.Id.Hex(), yes)
+}
+
+// deleteResource deletes an app, deployment or version with the confirmation token the server
+// hands out, after showing what goes with it
+func deleteResource(client *apiClient, path string, yes bool) error {
+	var confirmation types.DeletionConfirmation
+	if err := client.post(path+"/deletion-token", nil, &confirmation); err != nil {
+		return err
+	}
+	log.Println("✦ Deleting " + confirmation.Resource + " " + confirmation.Name + " removes " + impactSummary(confirmation.Impact))
+	if !yes && !confirm("Delete "+confirmation.Name+"?") {
+		return fmt.Errorf("cancelled")
+	}
+	if err := client.delete(path+"?confirmationToken="+url.QueryEscape(confirmation.ConfirmationToken), nil); err != nil {
+		return err
+	}
+	log.Println("✦ Deleted " + confirmation.Resource + " " + confirmation.Name + ", it can be restored until it is purged")
+	return nil
+}
+
+func impactSummary(impact types.DeletionImpact) string {
+	var parts []string
+	for _, count := range []struct {
+		n    int
+		noun string
+	}{{impact.Environments, "deployments"}, {impact.Versions, "app versions"}, {impact.Bundles, "releases"}} {
+		if count.n > 0 {
+			parts = append(parts, strconv.Itoa(count.n)+" "+count.noun)
+		}
+	}
+	if len(parts) == 0 {
+		return "nothing else"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func findApp(client *apiClient, name string) (*model.App, error) {
+	var apps []*model.App
+	if err := client.get("/core/app", &apps); err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.Name == name {
+			return app, nil
+		}
+	}
+	return nil, fmt.Errorf("app %s not found", name)
+}
+
+func findDeployment(client *apiClient, appName string, name string) (*model.App, *model.Environment, error) {
+	app, err := findApp(client, appName)
+	if err != nil {
+		return nil, nil, err
+	}
+	var environments []*model.Environment
+	if err := client.get("/core/environment/"+app.Id.Hex(), &environments); err != nil {
+		return nil, nil, err
+	}
+	for _, environment := range environments {
+		if environment.Name == name {
+			return app, environment, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("deployment %s not found in app %s", name, appName)
+}
+
+func getVersions(client *apiClient, environment *model.Environment) ([]*model.Version, error) {
+	var versions []*model.Version
+	err := client.get("/core/version?environmentId="+environment.Id.Hex(), &versions)
+	return versions, err
+}
+
+type table struct {
+	writer *tabwriter.Writer
+}
+
+func newTable(columns ...string) *table {
+	t := &table{writer: tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)}
+	t.row(columns...)
+	return t
+}
+
+func (t *table) row(cells ...string) {
+	fmt.Fprintln(t.writer, strings.Join(cells, "\t"))
+}
+
+func (t *table) flush() error {
+	return t.writer.Flush()
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"golang.org/x/term"
+)
+
+// Login signs in with a username and password and saves the session, the password is asked for
+// when it is not given
+func Login(server string, username string, password string) error {
+	server = strings.TrimSuffix(server, "/")
+	if username == "" {
+		username = prompt("Username: ")
+	}
+	if password == "" {
+		var err error
+		if password, err = promptPassword("Password: "); err != nil {
+			return err
+		}
+	}
+	httpClient := &http.Client{Timeout: time.Minute}
+	tokens, err := postTokens(httpClient, server+"/login", types.LoginUserRequest{Username: username, Password: password})
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	session := &Session{Server: server, Username: username, AccessToken: tokens.AccessToken, RefreshToken: tokens.RefreshToken}
+	if err := saveSession(session); err != nil {
+		return err
+	}
+	log.Println("✦ Logged in to " + server + " as " + username)
+	return nil
+}
+
+// Logout ends the session on the server and removes it, also when the server cannot be reached
+func Logout() error {
+	session, err := loadSession()
+	if err != nil {
+		return err
+	}
+	_, err = postTokens(&http.Client{Timeout: time.Minute}, session.Server+"/logout", types.RefreshTokenRequest{RefreshToken: session.RefreshToken})
+	if err != nil {
+		log.Println("✦ Warning: could not end the session on the server: " + err.Error())
+	}
+	if err := removeSession(); err != nil {
+		return err
+	}
+	log.Println("✦ Logged out of " + session.Server)
+	return nil
+}
+
+func Whoami() error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	var user model.User
+	if err := client.get("/core/user", &user); err != nil {
+		return err
+	}
+	fmt.Println(user.Username + " (" + strings.Join(user.Roles, ", ") + ") on " + client.session.Server)
+	return nil
+}
+
+// one reader for every prompt, answers piped in together are not lost to a discarded buffer
+var stdin = bufio.NewReader(os.Stdin)
+
+func prompt(label string) string {
+	fmt.Print(label)
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// promptPassword reads without echo from a terminal, and a line from a pipe
+func promptPassword(label string) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return prompt(label), nil
+	}
+	fmt.Print(label)
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
+// confirm asks a yes or no question, anything but y or yes is a no
+func confirm(question string) bool {
+	answer := strings.ToLower(prompt(question + " (y/N): "))
+	return answer == "y" || answer == "yes"
+}
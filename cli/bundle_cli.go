@@ -12,8 +12,9 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"runtime"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/SwishHQ/spread/types"
 	"github.com/SwishHQ/spread/utils"
@@ -33,6 +34,29 @@ type BundleConfig struct {
 	IsTypescriptProject string
 	DisableMinify       bool
 	Hermes              bool
+	// DetectHermes reads whether the project compiles to Hermes from its Android or iOS settings
+	// when Hermes is not set
+	DetectHermes bool
+	HermescPath  string
+	// SourceMapOutput is where the source map of the bundle is written, composed with the Hermes
+	// map when the bundle is compiled
+	SourceMapOutput string
+	ScheduledAt     *time.Time
+	OverrideFreeze  string
+	Targeting       *types.TargetingRequest
+	// BundleDir or ZipFile release output built elsewhere instead of running the bundler
+	BundleDir string
+	ZipFile   string
+	// DryRun builds and reports the hash and size without calling the server
+	DryRun bool
+	// UploadSourceMap uploads the source map of the bundle spread builds with the release, the server
+	// symbolicates crash reports of the bundle with it
+	UploadSourceMap bool
+	// SourceMapFile is the map of a prebuilt bundle, uploaded with the release
+	SourceMapFile string
+	// buildDir is the temporary directory the release is staged in, the CodePush directory in it
+	// is zipped. Never the project's build directory, prebuilt output often lives there
+	buildDir string
 }
 
 // PushBundle uploads a new bundle to the server
@@ -41,27 +65,59 @@ func PushBundle(config BundleConfig) error {
 		return fmt.Errorf("invalid configuration: %w", err)
 	}
 
-	if err := prepareBuildDirectory(config.ProjectDir); err != nil {
+	buildDir, err := prepareBuildDirectory()
+	if err != nil {
 		return fmt.Errorf("failed to prepare build directory: %w", err)
 	}
+	config.buildDir = buildDir
+	// zipping removes it, this is for the builds that fail before
+	defer os.RemoveAll(config.buildDir)
 
-	if err := buildBundle(config); err != nil {
-		return fmt.Errorf("failed to build bundle: %w", err)
-	}
-
-	if config.Hermes {
-		if err := processHermesBundle(config); err != nil {
-			return fmt.Errorf("failed to process hermes bundle: %w", err)
+	sourceMap := config.SourceMapFile
+	switch {
+	case config.BundleDir != "":
+		log.Println("✦ Using prebuilt bundle " + config.BundleDir)
+		if err := copyBundleDir(config); err != nil {
+			return fmt.Errorf("failed to copy bundle directory: %w", err)
+		}
+		warnMissingBundle(config)
+	case config.ZipFile != "":
+		log.Println("✦ Using prebuilt zip " + config.ZipFile)
+		if err := extractBundleZip(config); err != nil {
+			return fmt.Errorf("failed to extract zip: %w", err)
+		}
+		warnMissingBundle(config)
+	default:
+		if !config.Hermes && config.DetectHermes {
+			config.Hermes = detectHermes(config)
+		}
+		if config.UploadSourceMap && config.SourceMapOutput == "" {
+			mapDir, err := os.MkdirTemp("", "spread-sourcemap")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(mapDir)
+			config.SourceMapOutput = filepath.Join(mapDir, bundleFileName(config)+".map")
+		}
+		if err := buildAndCompileBundle(config); err != nil {
+			return err
+		}
+		if config.UploadSourceMap {
+			sourceMap = config.SourceMapOutput
 		}
 	}
 
-	hash, err := getHash(config.ProjectDir + "build")
+	hash, err := getHash(config.buildDir)
 	if err != nil {
 		return fmt.Errorf("failed to generate hash: %w", err)
 	}
 
 	fileName := uuid.New().String() + ".zip"
-	if err := createAndUploadBundle(config, fileName, hash); err != nil {
+	zipBundle(config, fileName)
+	if config.DryRun {
+		return reportDryRun(config, fileName, hash, sourceMap)
+	}
+	if err := createAndUploadBundle(config, fileName, hash, sourceMap); err != nil {
 		return fmt.Errorf("failed to create and upload bundle: %w", err)
 	}
 
@@ -72,24 +128,83 @@ func validateConfig(config BundleConfig) error {
 	if config.TargetVersion == "" || config.AppName == "" || config.Environment == "" {
 		return fmt.Errorf("missing required fields: target version, app name, or environment")
 	}
+	if config.BundleDir != "" && config.ZipFile != "" {
+		return fmt.Errorf("--bundle-dir and --zip cannot be used together")
+	}
+	if config.Hermes && (config.BundleDir != "" || config.ZipFile != "") {
+		return fmt.Errorf("--hermes compiles the bundle spread builds, compile a prebuilt bundle where it is built")
+	}
+	if config.SourceMapOutput != "" && (config.BundleDir != "" || config.ZipFile != "") {
+		return fmt.Errorf("--sourcemap-output applies to the bundle spread builds, take the map of a prebuilt bundle from where it is built")
+	}
+	if config.SourceMapFile != "" {
+		if config.BundleDir == "" && config.ZipFile == "" {
+			return fmt.Errorf("--sourcemap is the map of a prebuilt bundle, use --upload-sourcemap for the bundle spread builds")
+		}
+		if _, err := os.Stat(config.SourceMapFile); err != nil {
+			return fmt.Errorf("--sourcemap %s: %w", config.SourceMapFile, err)
+		}
+	}
 	return nil
 }
 
-func prepareBuildDirectory(projectDir string) error {
-	buildPath := projectDir + "build"
-	if exist, _ := utils.PathExists(buildPath); exist {
-		if err := os.RemoveAll(buildPath); err != nil {
-			return err
-		}
+func prepareBuildDirectory() (string, error) {
+	buildDir, err := os.MkdirTemp("", "spread-build")
+	if err != nil {
+		return "", err
 	}
-	return os.MkdirAll(projectDir+"build/CodePush", os.ModePerm)
+	if err := os.MkdirAll(filepath.Join(buildDir, "CodePush"), os.ModePerm); err != nil {
+		os.RemoveAll(buildDir)
+		return "", err
+	}
+	return buildDir, nil
 }
 
-func buildBundle(config BundleConfig) error {
-	jsName := "main.jsbundle"
+// bundleFileName is the name of the JS bundle the SDK loads on the platform
+func bundleFileName(config BundleConfig) string {
 	if config.OSName == "android" {
-		jsName = "index.android.bundle"
+		return "index.android.bundle"
 	}
+	return "main.jsbundle"
+}
+
+// buildAndCompileBundle runs the bundler and, for Hermes projects, compiles its output to bytecode.
+// Source maps are kept out of the build directory, they are not part of the release
+func buildAndCompileBundle(config BundleConfig) error {
+	var packagerMap string
+	if config.SourceMapOutput != "" {
+		mapDir, err := os.MkdirTemp("", "spread-sourcemap")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(mapDir)
+		packagerMap = filepath.Join(mapDir, bundleFileName(config)+".packager.map")
+	}
+	if err := buildBundle(config, packagerMap); err != nil {
+		return fmt.Errorf("failed to build bundle: %w", err)
+	}
+	if !config.Hermes {
+		if packagerMap == "" {
+			return nil
+		}
+		if err := moveFile(packagerMap, config.SourceMapOutput); err != nil {
+			return err
+		}
+		log.Println("✦ Source map written to " + config.SourceMapOutput)
+		return nil
+	}
+	hermesc, err := findHermesc(config)
+	if err != nil {
+		return fmt.Errorf("failed to find hermesc: %w", err)
+	}
+	if err := processHermesBundle(config, hermesc, packagerMap); err != nil {
+		return fmt.Errorf("failed to process hermes bundle: %w", err)
+	}
+	return nil
+}
+
+func buildBundle(config BundleConfig, sourceMapOutput string) error {
+	jsName := bundleFileName(config)
 
 	indexFile := "index.js"
 	if config.IsTypescriptProject == "true" {
@@ -101,10 +216,14 @@ func buildBundle(config BundleConfig) error {
 		minify = "false"
 	}
 
-	buildPath := config.ProjectDir + "build/CodePush"
+	buildPath := filepath.Join(config.buildDir, "CodePush")
 	bundleURL := buildPath + "/" + jsName
 
-	return executeCommand(createBundleCommand(config, buildPath, bundleURL, indexFile, minify))
+	cmd := createBundleCommand(config, buildPath, bundleURL, indexFile, minify)
+	if sourceMapOutput != "" {
+		cmd.Args = append(cmd.Args, "--sourcemap-output", sourceMapOutput)
+	}
+	return executeCommand(cmd)
 }
 
 func getHash(path string) (string, error) {
@@ -199,31 +318,12 @@ func newfileUploadRequest(uri string, authKey string, params map[string]string,
 	return request, err
 }
 
+// executeCommand runs cmd with its output streamed to the terminal
 func executeCommand(cmd *exec.Cmd) error {
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		log.Panic("cmd.StdoutPipe() failed with ", err)
-	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		log.Panic("cmd.StderrPipe() failed with ", err)
-	}
-	if err := cmd.Start(); err != nil {
-		log.Panic("cmd.Start() failed with ", err)
-	}
-
-	go func() {
-		if _, err := io.Copy(os.Stdout, stdout); err != nil {
-			log.Panic("failed to copy stdout: ", err)
-		}
-	}()
-	go func() {
-		if _, err := io.Copy(os.Stderr, stderr); err != nil {
-			log.Panic("failed to copy stderr: ", err)
-		}
-	}()
-	if err := cmd.Wait(); err != nil {
-		log.Panic("cmd.Run() failed with ", err)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", strings.Join(cmd.Args, " "), err)
 	}
 	return nil
 }
@@ -249,56 +349,13 @@ func createBundleCommand(config BundleConfig, buildPath, bundleURL, indexFile, m
 	return cmd
 }
 
-func processHermesBundle(config BundleConfig) error {
-	sysType := runtime.GOOS
-	exc := "/osx-bin/hermesc"
-	if sysType == "linux" {
-		exc = "/linux64-bin/hermesc"
-	}
-	if sysType == "windows" {
-		exc = "/win64-bin/hermesc.exe"
-	}
-	hbcUrl := config.ProjectDir + "build/CodePush/" + "main.jsbundle" + ".hbc"
-	cmd := exec.Command(
-		config.ProjectDir+"node_modules/react-native/sdks/hermesc"+exc,
-		"-emit-binary",
-		"-out",
-		hbcUrl,
-		config.ProjectDir+"build/CodePush/"+"main.jsbundle",
-		// "-output-source-map",
-	)
-
-	cmd.Dir = config.ProjectDir
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		fmt.Printf("combined out:\n%s\n", string(out))
-		log.Panic("cmd.Run() failed with ", err)
-	}
-	err = os.Remove(config.ProjectDir + "build/CodePush/" + "main.jsbundle")
-	if err != nil {
-		panic(err.Error())
-	}
-	data, err := os.ReadFile(hbcUrl)
-	if err != nil {
-		panic(err.Error())
-	}
-	err = os.WriteFile(config.ProjectDir+"build/CodePush/"+"main.jsbundle", data, 0755)
-	if err != nil {
-		panic(err.Error())
-	}
-	err = os.Remove(hbcUrl)
-	if err != nil {
-		panic(err.Error())
-	}
-	return nil
-}
-
-func createAndUploadBundle(config BundleConfig, fileName string, hash string) error {
+func zipBundle(config BundleConfig, fileName string) {
 	log.Println("✦ Zipping bundle"+" "+config.AppName+" "+config.TargetVersion, fileName)
-	utils.Zip(config.ProjectDir+"build", fileName)
+	utils.Zip(config.buildDir, fileName)
+	os.RemoveAll(config.buildDir)
+}
 
-	// exec.Command("open", config.ProjectDir+"build").Run()
-	os.RemoveAll(config.ProjectDir + "build")
+func createAndUploadBundle(config BundleConfig, fileName string, hash string, sourceMap string) error {
 	log.Println("✦ Uploading bundle")
 
 	Url, err := url.Parse(config.RemoteURL + "/bundle/upload")
@@ -328,6 +385,15 @@ func createAndUploadBundle(config BundleConfig, fileName string, hash string) er
 		return fmt.Errorf("upload failed: %s", resp.Status)
 	}
 	log.Println("✦ Bundle has been uploaded successfully.")
+
+	var sourceMapFile string
+	if sourceMap != "" {
+		sourceMapFile = strings.TrimSuffix(fileName, ".zip") + ".map"
+		if err := uploadSourceMap(config, sourceMap, sourceMapFile); err != nil {
+			os.RemoveAll(fileName)
+			return err
+		}
+	}
 	log.Println("✦ Creating a new bundle")
 
 	Url, err = url.Parse(config.RemoteURL + "/bundle/create")
@@ -345,6 +411,11 @@ func createAndUploadBundle(config BundleConfig, fileName string, hash string) er
 		AppVersion:   config.TargetVersion,
 		Size:         size,
 		Hash:         hash,
+
+		SourceMapFile:        sourceMapFile,
+		ScheduledAt:          config.ScheduledAt,
+		OverrideFreezeReason: config.OverrideFreeze,
+		Targeting:            config.Targeting,
 	}
 	jsonByte, _ := json.Marshal(createBundleReq)
 	req, _ = http.NewRequest("POST", Url.String(), bytes.NewBuffer(jsonByte))
@@ -366,7 +437,32 @@ func createAndUploadBundle(config BundleConfig, fileName string, hash string) er
 		os.RemoveAll(fileName)
 		return fmt.Errorf("failed to create bundle: %s", resp.Status)
 	}
-	log.Println("✦ Bundle has been created successfully.")
+	if config.ScheduledAt != nil {
+		log.Println("✦ Bundle has been created, it is released at " + config.ScheduledAt.Format(time.RFC3339))
+	} else {
+		log.Println("✦ Bundle has been created successfully.")
+	}
 	os.RemoveAll(fileName)
 	return nil
 }
+
+// uploadSourceMap uploads the map the bundle is released with under the given storage key
+func uploadSourceMap(config BundleConfig, sourceMap string, key string) error {
+	log.Println("✦ Uploading source map")
+	req, err := newfileUploadRequest(config.RemoteURL+"/bundle/upload", config.AuthKey, map[string]string{"filename": key}, "file", sourceMap)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		body, _ := io.ReadAll(resp.Body)
+		log.Println("✦ Source map upload fail", string(body))
+		return fmt.Errorf("source map upload failed: %s", resp.Status)
+	}
+	log.Println("✦ Source map has been uploaded successfully.")
+	return nil
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+)
+
+// PatchConfig changes a release in place, nil fields are left as they are
+type PatchConfig struct {
+	AppName       string
+	Deployment    string
+	TargetVersion string
+	// the current release of the version when empty
+	Label          string
+	Description    *string
+	Mandatory      *bool
+	Disabled       *bool
+	OverrideFreeze string
+}
+
+// DeploymentHistory lists the releases of a deployment newest first, of one app version when given
+func DeploymentHistory(appName string, deployment string, targetVersion string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	_, environment, err := findDeployment(client, appName, deployment)
+	if err != nil {
+		return err
+	}
+	versions, err := getVersions(client, environment)
+	if err != nil {
+		return err
+	}
+	table := newTable("Label", "App Version", "Released", "Mandatory", "Status", "Installs", "Active", "Failed", "Description")
+	for _, version := range versions {
+		if targetVersion != "" && version.AppVersion != targetVersion {
+			continue
+		}
+		var bundles []*model.Bundle
+		if err := client.get("/core/version/bundle/"+version.Id.Hex(), &bundles); err != nil {
+			return err
+		}
+		for _, bundle := range bundles {
+			mandatory := "No"
+			if bundle.IsMandatory {
+				mandatory = "Yes"
+			}
+			table.row(bundle.Label, version.AppVersion, bundle.CreatedAt.Local().Format(time.DateTime), mandatory, releaseStatus(version, bundle),
+				strconv.Itoa(bundle.Installed), strconv.Itoa(bundle.Active), strconv.Itoa(bundle.Failed), bundle.Description)
+		}
+	}
+	return table.flush()
+}
+
+func releaseStatus(version *model.Version, bundle *model.Bundle) string {
+	switch {
+	case bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_PENDING:
+		return "Awaiting approval"
+	case bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_REJECTED:
+		return "Rejected"
+	case bundle.ScheduledAt != nil:
+		return "Scheduled " + bundle.ScheduledAt.Local().Format(time.DateTime)
+	case !bundle.IsValid:
+		return "Disabled"
+	case bundle.Id == version.CurrentBundleId:
+		return "Current"
+	}
+	return "Enabled"
+}
+
+// ClearDeployment deletes every app version of a deployment with its releases, they can be restored
+// until they are purged
+func ClearDeployment(appName string, deployment string, yes bool) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	_, environment, err := findDeployment(client, appName, deployment)
+	if err != nil {
+		return err
+	}
+	versions, err := getVersions(client, environment)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		log.Println("✦ Deployment " + deployment + " has no releases")
+		return nil
+	}
+	if !yes && !confirm("Delete the release history of "+strconv.Itoa(len(versions))+" app versions of "+deployment+"?") {
+		return fmt.Errorf("cancelled")
+	}
+	for _, version := range versions {
+		if err := deleteResource(client, "/core/version/"+version.Id.Hex(), true); err != nil {
+			return fmt.Errorf("clearing %s: %w", version.AppVersion, err)
+		}
+	}
+	return nil
+}
+
+// Rollback makes the release before the current one of an app version current again
+func Rollback(appName string, deployment string, targetVersion string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	app, environment, err := findDeployment(client, appName, deployment)
+	if err != nil {
+		return err
+	}
+	version, err := findVersion(client, environment, targetVersion)
+	if err != nil {
+		return err
+	}
+	var bundle *model.Bundle
+	request := types.RollbackRequest{AppId: app.Id.Hex(), EnvironmentId: environment.Id.Hex(), VersionId: version.Id.Hex()}
+	if err := client.post("/core/rollback", request, &bundle); err != nil {
+		return err
+	}
+	if bundle == nil || bundle.Label == "" {
+		log.Println("✦ Rolled back " + deployment + " " + version.AppVersion + ", there was no earlier release and the binary's bundle is served")
+		return nil
+	}
+	log.Println("✦ Rolled back " + deployment + " " + version.AppVersion + " to " + bundle.Label)
+	return nil
+}
+
+// Promote releases the current release of an app version of one deployment to another deployment
+func Promote(appName string, source string, destination string, targetVersion string, description string, overrideFreeze string) error {
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	_, sourceEnvironment, err := findDeployment(client, appName, source)
+	if err != nil {
+		return err
+	}
+	_, destinationEnvironment, err := findDeployment(client, appName, destination)
+	if err != nil {
+		return err
+	}
+	version, err := findVersion(client, sourceEnvironment, targetVersion)
+	if err != nil {
+		return err
+	}
+	if version.CurrentBundleId.IsZero() {
+		return fmt.Errorf("%s %s has no current release to promote", source, version.AppVersion)
+	}
+	request := types.PromoteBundleRequest{EnvironmentId: destinationEnvironment.Id.Hex(), Description: description, OverrideFreezeReason: overrideFreeze}
+	var bundle model.Bundle
+	if err := client.post("/core/version/bundle/"+version.CurrentBundleId.Hex()+"/promote", request, &bundle); err != nil {
+		return err
+	}
+	log.Println("✦ Promoted " + source + " " + version.AppVersion + " to " + destination + " as " + bundle.Label)
+	if bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_PENDING {
+		log.Println("✦ " + destination + " requires approval, the release goes out once it is approved")
+	}
+	return nil
+}
+
+// Patch updates the description, mandatory flag or enabled state of a release
+func Patch(config PatchConfig) error {
+	if config.Description == nil && config.Mandatory == nil && config.Disabled == nil {
+		return fmt.Errorf("nothing to patch, pass --description, --mandatory or --disabled")
+	}
+	client, err := newAPIClient()
+	if err != nil {
+		return err
+	}
+	_, environment, err := findDeployment(client, config.AppName, config.Deployment)
+	if err != nil {
+		return err
+	}
+	version, err := findVersion(client, environment, config.TargetVersion)
+	if err != nil {
+		return err
+	}
+	bundle, err := findRelease(client, version, config.Label)
+	if err != nil {
+		return err
+	}
+	path := "/core/version/bundle/" + bundle.Id.Hex()
+	var changes []string
+	if config.Description != nil && *config.Description != bundle.Description {
+		if err := client.put(path+"/description", types.UpdateDescriptionRequest{Description: *config.Description}, nil); err != nil {
+			return err
+		}
+		changes = append(changes, "description")
+	}
+	// the server toggles these, only call it when the state differs
+	if config.Mandatory != nil && *config.Mandatory != bundle.IsMandatory {
+		if err := client.put(path+"/mandatory", nil, nil); err != nil {
+			return err
+		}
+		changes = append(changes, "mandatory="+strconv.FormatBool(*config.Mandatory))
+	}
+	if config.Disabled != nil && *config.Disabled == bundle.IsValid {
+		if err := client.put(path+"/active", types.ToggleActiveRequest{OverrideFreezeReason: config.OverrideFreeze}, nil); err != nil {
+			return err
+		}
+		changes = append(changes, "disabled="+strconv.FormatBool(*config.Disabled))
+	}
+	if len(changes) == 0 {
+		log.Println("✦ " + bundle.Label + " already has these settings")
+		return nil
+	}
+	log.Println("✦ Patched " + bundle.Label + ": " + strings.Join(changes, ", "))
+	return nil
+}
+
+// findVersion returns the app version of the deployment, the only one when none is given
+func findVersion(client *apiClient, environment *model.Environment, targetVersion string) (*model.Version, error) {
+	versions, err := getVersions(client, environment)
+	if err != nil {
+		return nil, err
+	}
+	if targetVersion == "" {
+		switch len(versions) {
+		case 0:
+			return nil, fmt.Errorf("deployment %s has no releases", environment.Name)
+		case 1:
+			return versions[0], nil
+		}
+		appVersions := make([]string, 0, len(versions))
+		for _, version := range versions {
+			appVersions = append(appVersions, version.AppVersion)
+		}
+		return nil, fmt.Errorf("deployment %s has releases for app versions %s, pick one with --target-version", environment.Name, strings.Join(appVersions, ", "))
+	}
+	for _, version := range versions {
+		if version.AppVersion == targetVersion {
+			return version, nil
+		}
+	}
+	return nil, fmt.Errorf("deployment %s has no releases for app version %s", environment.Name, targetVersion)
+}
+
+func findRelease(client *apiClient, version *model.Version, label string) (*model.Bundle, error) {
+	if label == "" && version.CurrentBundleId.IsZero() {
+		return nil, fmt.Errorf("app version %s has no current release, pick one with --label", version.AppVersion)
+	}
+	var bundles []*model.Bundle
+	if err := client.get("/core/version/bundle/"+version.Id.Hex(), &bundles); err != nil {
+		return nil, err
+	}
+	for _, bundle := range bundles {
+		if (label == "" && bundle.Id == version.CurrentBundleId) || (label != "" && bundle.Label == label) {
+			return bundle, nil
+		}
+	}
+	return nil, fmt.Errorf("release %s not found in app version %s", label, version.AppVersion)
+}
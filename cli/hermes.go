@@ -0,0 +1,176 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var (
+	gradleHermesProperty = regexp.MustCompile(`(?m)^\s*hermesEnabled\s*=\s*(true|false)\s*$`)
+	gradleEnableHermes   = regexp.MustCompile(`enableHermes\s*:\s*(true|false)`)
+	podfileHermesEnabled = regexp.MustCompile(`:?hermes_enabled\s*(?:=>|:)\s*(true|false)`)
+)
+
+// detectHermes reads whether the app compiles its bundle to Hermes bytecode, from gradle.properties
+// or the app build.gradle on Android, and from the installed pods or the Podfile on iOS
+func detectHermes(config BundleConfig) bool {
+	enabled, source := hermesSetting(config)
+	if source == "" {
+		log.Println("✦ Hermes setting not found in the project, building a plain JS bundle, pass --hermes to compile it")
+		return false
+	}
+	if enabled {
+		log.Println("✦ Hermes enabled in " + source)
+	}
+	return enabled
+}
+
+// hermesSetting returns the Hermes setting of the project and the file it was read from, an empty
+// source when the project does not say
+func hermesSetting(config BundleConfig) (bool, string) {
+	if config.OSName == "android" {
+		for _, setting := range []struct {
+			file    string
+			pattern *regexp.Regexp
+		}{
+			{"android/gradle.properties", gradleHermesProperty},
+			{"android/app/build.gradle", gradleEnableHermes},
+		} {
+			content, err := os.ReadFile(config.ProjectDir + setting.file)
+			if err != nil {
+				continue
+			}
+			if match := setting.pattern.FindSubmatch(content); match != nil {
+				return string(match[1]) == "true", setting.file
+			}
+		}
+		return false, ""
+	}
+
+	// the lock file says what was installed, whatever the Podfile computes the setting from
+	if content, err := os.ReadFile(config.ProjectDir + "ios/Podfile.lock"); err == nil {
+		return strings.Contains(string(content), "- hermes-engine"), "ios/Podfile.lock"
+	}
+	if content, err := os.ReadFile(config.ProjectDir + "ios/Podfile.properties.json"); err == nil {
+		var properties map[string]interface{}
+		if json.Unmarshal(content, &properties) == nil {
+			if engine, ok := properties["expo.jsEngine"].(string); ok {
+				return engine == "hermes", "ios/Podfile.properties.json"
+			}
+		}
+	}
+	if content, err := os.ReadFile(config.ProjectDir + "ios/Podfile"); err == nil {
+		if match := podfileHermesEnabled.FindSubmatch(content); match != nil {
+			return string(match[1]) == "true", "ios/Podfile"
+		}
+	}
+	return false, ""
+}
+
+// findHermesc returns the hermesc given with --hermesc, or the one shipped with react-native, in
+// react-native/sdks since 0.69 and in hermes-engine before
+func findHermesc(config BundleConfig) (string, error) {
+	if config.HermescPath != "" {
+		if _, err := os.Stat(config.HermescPath); err != nil {
+			return "", fmt.Errorf("--hermesc %s: %w", config.HermescPath, err)
+		}
+		return config.HermescPath, nil
+	}
+	binary := "osx-bin/hermesc"
+	switch runtime.GOOS {
+	case "linux":
+		binary = "linux64-bin/hermesc"
+	case "windows":
+		binary = "win64-bin/hermesc.exe"
+	}
+	candidates := []string{
+		config.ProjectDir + "node_modules/react-native/sdks/hermesc/" + binary,
+		config.ProjectDir + "node_modules/hermes-engine/" + binary,
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("hermesc not found in %s, pass its path with --hermesc", strings.Join(candidates, " or "))
+}
+
+// processHermesBundle compiles the bundle to Hermes bytecode in place. With a packager map the
+// Hermes map is composed with it, so the map resolves bytecode offsets to the original sources
+func processHermesBundle(config BundleConfig, hermesc string, packagerMap string) error {
+	bundlePath, err := filepath.Abs(filepath.Join(config.buildDir, "CodePush", bundleFileName(config)))
+	if err != nil {
+		return err
+	}
+	if hermesc, err = filepath.Abs(hermesc); err != nil {
+		return err
+	}
+	hbcPath := bundlePath + ".hbc"
+	args := []string{"-emit-binary", "-O", "-out", hbcPath, bundlePath}
+	if packagerMap != "" {
+		args = append(args, "-output-source-map")
+	}
+	cmd := exec.Command(hermesc, args...)
+	cmd.Dir = config.ProjectDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("hermesc failed: %w\n%s", err, string(out))
+	}
+	if err := os.Rename(hbcPath, bundlePath); err != nil {
+		return err
+	}
+	if packagerMap == "" {
+		return nil
+	}
+	// hermesc writes its map next to the bytecode, it must not end up in the release
+	hermesMap := filepath.Join(filepath.Dir(packagerMap), filepath.Base(hbcPath)+".map")
+	if err := moveFile(hbcPath+".map", hermesMap); err != nil {
+		return err
+	}
+	return composeSourceMaps(config, packagerMap, hermesMap)
+}
+
+func composeSourceMaps(config BundleConfig, packagerMap string, hermesMap string) error {
+	script := config.ProjectDir + "node_modules/react-native/scripts/compose-source-maps.js"
+	if _, err := os.Stat(script); err != nil {
+		return fmt.Errorf("cannot compose the Hermes source map, %s not found: %w", script, err)
+	}
+	output, err := filepath.Abs(config.SourceMapOutput)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(output), os.ModePerm); err != nil {
+		return err
+	}
+	script, err = filepath.Abs(script)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("node", script, packagerMap, hermesMap, "-o", output)
+	cmd.Dir = config.ProjectDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("composing source maps failed: %w\n%s", err, string(out))
+	}
+	log.Println("✦ Source map written to " + config.SourceMapOutput)
+	return nil
+}
+
+// moveFile renames source to target, copying when they are on different devices
+func moveFile(source string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+	if err := copyFile(source, target); err != nil {
+		return err
+	}
+	return os.Remove(source)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SwishHQ/spread/utils"
+)
+
+// copyBundleDir copies the output of a bundler, the JS bundle and its assets, to where buildBundle
+// writes its own output
+func copyBundleDir(config BundleConfig) error {
+	info, err := os.Stat(config.BundleDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", config.BundleDir)
+	}
+	buildPath := filepath.Join(config.buildDir, "CodePush")
+	return filepath.Walk(config.BundleDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(config.BundleDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(buildPath, relativePath)
+		if info.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+		return copyFile(path, target)
+	})
+}
+
+// extractBundleZip extracts a zip built by another job. A zip of the bundler output is put under
+// CodePush like buildBundle does, one made by spread release already has it at the top
+func extractBundleZip(config BundleConfig) error {
+	extractPath := filepath.Join(config.buildDir, "CodePush")
+	hasCodePush, err := zipHasCodePushDir(config.ZipFile)
+	if err != nil {
+		return err
+	}
+	if hasCodePush {
+		extractPath = config.buildDir
+	}
+	return utils.Unzip(config.ZipFile, extractPath)
+}
+
+func zipHasCodePushDir(zipFile string) (bool, error) {
+	archive, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return false, err
+	}
+	defer archive.Close()
+	if len(archive.File) == 0 {
+		return false, fmt.Errorf("%s is empty", zipFile)
+	}
+	for _, entry := range archive.File {
+		if !strings.HasPrefix(strings.ReplaceAll(entry.Name, "\\", "/"), "CodePush/") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// warnMissingBundle points out prebuilt output without the JS bundle the SDK loads, bundlers
+// configured with another output name still work when the app loads that name
+func warnMissingBundle(config BundleConfig) {
+	jsName := bundleFileName(config)
+	if exist, _ := utils.PathExists(filepath.Join(config.buildDir, "CodePush", jsName)); !exist {
+		log.Println("✦ Warning: no " + jsName + " in the prebuilt bundle, make sure the app loads the bundle it contains")
+	}
+}
+
+func copyFile(source string, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// reportDryRun prints what spread release would upload and removes the zip
+func reportDryRun(config BundleConfig, fileName string, hash string, sourceMap string) error {
+	defer os.RemoveAll(fileName)
+	fileInfo, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	log.Println("✦ Dry run, nothing was uploaded")
+	log.Println("✦ App: " + config.AppName + " " + config.TargetVersion + " (" + config.Environment + ")")
+	log.Println("✦ Hash: " + hash)
+	log.Println("✦ Size: " + fmt.Sprintf("%d bytes", fileInfo.Size()))
+	if sourceMap != "" {
+		log.Println("✦ Source map: " + sourceMap)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// Session is the login spread login saves for the management commands
+type Session struct {
+	Server       string `json:"server"`
+	Username     string `json:"username"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+var errNotLoggedIn = errors.New("not logged in, run spread login first")
+
+// sessionPath is ~/.spread/session.json, SPREAD_SESSION_FILE points elsewhere, for example to keep
+// the logins to two servers apart
+func sessionPath() (string, error) {
+	if path := os.Getenv("SPREAD_SESSION_FILE"); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".spread", "session.json"), nil
+}
+
+func loadSession() (*Session, error) {
+	path, err := sessionPath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errNotLoggedIn
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(content, &session); err != nil {
+		return nil, err
+	}
+	if session.Server == "" || session.RefreshToken == "" {
+		return nil, errNotLoggedIn
+	}
+	return &session, nil
+}
+
+// saveSession writes the session readable by the user only, it holds a refresh token
+func saveSession(session *Session) error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(session, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0600)
+}
+
+func removeSession() error {
+	path, err := sessionPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/SwishHQ/spread/cli"
+	"github.com/spf13/cobra"
+)
+
+var accessKeyYes bool
+
+var accessKeyCmd = &cobra.Command{
+	Use:   "access-key",
+	Short: "Manage the access keys spread release authenticates with",
+}
+
+var accessKeyListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List access keys",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.ListAccessKeys()
+	},
+}
+
+var accessKeyAddCmd = &cobra.Command{
+	Use:          "add <name>",
+	Short:        "Create an access key and print it",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.AddAccessKey(args[0])
+	},
+}
+
+var accessKeyRemoveCmd = &cobra.Command{
+	Use:          "rm <name or prefix>",
+	Aliases:      []string{"remove"},
+	Short:        "Delete an access key",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.RemoveAccessKey(args[0], accessKeyYes)
+	},
+}
+
+func init() {
+	accessKeyRemoveCmd.Flags().BoolVarP(&accessKeyYes, "yes", "y", false, "Do not ask for confirmation (optional)")
+
+	accessKeyCmd.AddCommand(accessKeyListCmd)
+	accessKeyCmd.AddCommand(accessKeyAddCmd)
+	accessKeyCmd.AddCommand(accessKeyRemoveCmd)
+	rootCmd.AddCommand(accessKeyCmd)
+}
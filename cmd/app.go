@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"github.com/SwishHQ/spread/cli"
+	"github.com/spf13/cobra"
+)
+
+var appOS string
+var appDeployments []string
+var appYes bool
+
+var appCmd = &cobra.Command{
+	Use:   "app",
+	Short: "Manage apps",
+}
+
+var appListCmd = &cobra.Command{
+	Use:          "ls",
+	Aliases:      []string{"list"},
+	Short:        "List apps and their deployments",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.ListApps()
+	},
+}
+
+var appAddCmd = &cobra.Command{
+	Use:          "add <appName>",
+	Short:        "Create an app with Staging and Production deployments",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.AddApp(args[0], appOS, appDeployments)
+	},
+}
+
+var appRemoveCmd = &cobra.Command{
+	Use:          "rm <appName>",
+	Aliases:      []string{"remove"},
+	Short:        "Delete an app with its deployments and releases",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.RemoveApp(args[0], appYes)
+	},
+}
+
+var appRenameCmd = &cobra.Command{
+	Use:          "rename <appName> <newAppName>",
+	Short:        "Rename an app",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.RenameApp(args[0], args[1])
+	},
+}
+
+func init() {
+	appAddCmd.Flags().StringVarP(&appOS, "os", "o", "", "OS of the app, ios or android (required)")
+	appAddCmd.Flags().StringSliceVar(&appDeployments, "deployments", []string{"Staging", "Production"}, "Deployments to create with the app, none with --deployments= (optional)")
+	appAddCmd.MarkFlagRequired("os")
+	appRemoveCmd.Flags().BoolVarP(&appYes, "yes", "y", false, "Do not ask for confirmation (optional)")
+
+	appCmd.AddCommand(appListCmd)
+	appCmd.AddCommand(appAddCmd)
+	appCmd.AddCommand(appRemoveCmd)
+	appCmd.AddCommand(appRenameCmd)
+	rootCmd.AddCommand(appCmd)
+}
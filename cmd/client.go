@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/SwishHQ/spread/cli"
+	"github.com/SwishHQ/spread/types"
 	"github.com/spf13/cobra"
 )
 
@@ -18,16 +22,27 @@ var osName string
 var isTypescriptProject string
 var disableMinify bool
 var hermes bool
+var scheduledAt string
+var overrideFreeze string
+var targetingFile string
+var bundleDir string
+var zipFile string
+var dryRun bool
+var hermescPath string
+var sourceMapOutput string
+var uploadSourceMap bool
+var sourceMapFile string
 
 var releaseCmd = &cobra.Command{
 	Use:   "release",
 	Short: "Release a new version of the app",
 	Run: func(cmd *cobra.Command, args []string) {
-		if remoteURL == "" {
+		// a dry run never calls the server, so it runs without the release credentials
+		if remoteURL == "" && !dryRun {
 			fmt.Println("Error: --remote flag is required")
 			return
 		}
-		if authKey == "" {
+		if authKey == "" && !dryRun {
 			fmt.Println("Error: --auth-key flag is required")
 			return
 		}
@@ -48,7 +63,30 @@ var releaseCmd = &cobra.Command{
 			return
 		}
 
-		cli.PushBundle(
+		var releaseAt *time.Time
+		if scheduledAt != "" {
+			at, err := time.Parse(time.RFC3339, scheduledAt)
+			if err != nil {
+				fmt.Println("Error: --scheduled-at must be an RFC 3339 time such as 2026-01-05T09:00:00+01:00")
+				return
+			}
+			releaseAt = &at
+		}
+
+		var targeting *types.TargetingRequest
+		if targetingFile != "" {
+			content, err := os.ReadFile(targetingFile)
+			if err != nil {
+				fmt.Println("Error: could not read --targeting file: " + err.Error())
+				return
+			}
+			if err := json.Unmarshal(content, &targeting); err != nil {
+				fmt.Println("Error: --targeting file is not valid JSON: " + err.Error())
+				return
+			}
+		}
+
+		err := cli.PushBundle(
 			cli.BundleConfig{
 				RemoteURL:           remoteURL,
 				AuthKey:             authKey,
@@ -61,8 +99,23 @@ var releaseCmd = &cobra.Command{
 				IsTypescriptProject: isTypescriptProject,
 				DisableMinify:       disableMinify,
 				Hermes:              hermes,
+				DetectHermes:        !cmd.Flags().Changed("hermes"),
+				HermescPath:         hermescPath,
+				SourceMapOutput:     sourceMapOutput,
+				ScheduledAt:         releaseAt,
+				OverrideFreeze:      overrideFreeze,
+				Targeting:           targeting,
+				BundleDir:           bundleDir,
+				ZipFile:             zipFile,
+				DryRun:              dryRun,
+				UploadSourceMap:     uploadSourceMap,
+				SourceMapFile:       sourceMapFile,
 			},
 		)
+		if err != nil {
+			fmt.Println("Error: " + err.Error())
+			os.Exit(1)
+		}
 	},
 }
 
@@ -78,11 +131,19 @@ func init() {
 	releaseCmd.Flags().StringVarP(&projectDir, "project-dir", "p", "", "Project directory (optional)")
 	releaseCmd.Flags().StringVarP(&isTypescriptProject, "is-typescript", "i", "", "Is typescript project (optional)")
 	releaseCmd.Flags().BoolVarP(&disableMinify, "disable-minify", "m", false, "Disable minify (optional)")
-	releaseCmd.Flags().BoolVarP(&hermes, "hermes", "z", false, "Hermes (optional)")
+	releaseCmd.Flags().BoolVarP(&hermes, "hermes", "z", false, "Compile the bundle to Hermes bytecode, read from the project settings when not given (optional)")
+	releaseCmd.Flags().StringVar(&hermescPath, "hermesc", "", "Path of the hermesc binary, the one in node_modules by default (optional)")
+	releaseCmd.Flags().StringVar(&sourceMapOutput, "sourcemap-output", "", "Write the source map of the bundle to this file (optional)")
+	releaseCmd.Flags().BoolVar(&uploadSourceMap, "upload-sourcemap", false, "Upload the source map of the bundle to symbolicate its crash reports (optional)")
+	releaseCmd.Flags().StringVar(&sourceMapFile, "sourcemap", "", "Source map of the prebuilt bundle, uploaded with it (optional)")
 	releaseCmd.Flags().StringVarP(&description, "description", "d", "", "Description (optional)")
+	releaseCmd.Flags().StringVar(&scheduledAt, "scheduled-at", "", "Release at this RFC 3339 time instead of now (optional)")
+	releaseCmd.Flags().StringVar(&overrideFreeze, "override-freeze", "", "Reason to release during a freeze window, the key owner must be an admin (optional)")
+	releaseCmd.Flags().StringVar(&targetingFile, "targeting", "", "JSON file with the allowlist and rules of the devices to release to (optional)")
+	releaseCmd.Flags().StringVar(&bundleDir, "bundle-dir", "", "Release this prebuilt bundle and assets directory instead of running the bundler (optional)")
+	releaseCmd.Flags().StringVar(&zipFile, "zip", "", "Release this prebuilt zip instead of running the bundler (optional)")
+	releaseCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Build and print the hash and size without uploading (optional)")
 
-	releaseCmd.MarkFlagRequired("remote")         // Mark as required
-	releaseCmd.MarkFlagRequired("auth-key")       // Mark as required
 	releaseCmd.MarkFlagRequired("app-name")       // Mark as required
 	releaseCmd.MarkFlagRequired("environment")    // Mark as required
 	releaseCmd.MarkFlagRequired("target-version") // Mark as required
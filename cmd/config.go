@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the server configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective server configuration with secrets redacted",
+	RunE:  printConfig,
+}
+
+func init() {
+	configPrintCmd.Flags().StringVarP(&configFile, "config", "c", "", "YAML config file, environment variables override it (optional)")
+	configCmd.AddCommand(configPrintCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func printConfig(cmd *cobra.Command, args []string) error {
+	effective, err := config.Load(configFile)
+	if err != nil {
+		return err
+	}
+	out, err := effective.Redacted().YAML()
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(out))
+	// still print an invalid configuration, it is usually why someone looks at it
+	if err := config.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "\nInvalid configuration:\n"+err.Error())
+		os.Exit(1)
+	}
+	return nil
+}
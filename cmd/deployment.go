@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"github.com/SwishHQ/spread/cli"
+	"github.com/spf13/cobra"
+)
+
+var deploymentDisplayKeys bool
+var deploymentTargetVersion string
+var deploymentYes bool
+
+var deploymentCmd = &cobra.Command{
+	Use:   "deployment",
+	Short: "Manage the deployments of an app",
+}
+
+var deploymentListCmd = &cobra.Command{
+	Use:          "ls <appName>",
+	Aliases:      []string{"list"},
+	Short:        "List the deployments of an app",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.ListDeployments(args[0], deploymentDisplayKeys)
+	},
+}
+
+var deploymentAddCmd = &cobra.Command{
+	Use:          "add <appName> <deploymentName>",
+	Short:        "Create a deployment and print its key",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.AddDeployment(args[0], args[1])
+	},
+}
+
+var deploymentRemoveCmd = &cobra.Command{
+	Use:          "rm <appName> <deploymentName>",
+	Aliases:      []string{"remove"},
+	Short:        "Delete a deployment with its releases",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.RemoveDeployment(args[0], args[1], deploymentYes)
+	},
+}
+
+var deploymentHistoryCmd = &cobra.Command{
+	Use:          "history <appName> <deploymentName>",
+	Aliases:      []string{"h"},
+	Short:        "List the releases of a deployment",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.DeploymentHistory(args[0], args[1], deploymentTargetVersion)
+	},
+}
+
+var deploymentClearCmd = &cobra.Command{
+	Use:          "clear <appName> <deploymentName>",
+	Short:        "Delete the release history of a deployment",
+	Long:         "Delete every app version of the deployment with its releases. Devices get the bundle of their binary again. The versions can be restored until they are purged.",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.ClearDeployment(args[0], args[1], deploymentYes)
+	},
+}
+
+func init() {
+	deploymentListCmd.Flags().BoolVarP(&deploymentDisplayKeys, "display-keys", "k", false, "Show the deployment keys (optional)")
+	deploymentHistoryCmd.Flags().StringVarP(&deploymentTargetVersion, "target-version", "t", "", "Only list the releases of this app version (optional)")
+	deploymentRemoveCmd.Flags().BoolVarP(&deploymentYes, "yes", "y", false, "Do not ask for confirmation (optional)")
+	deploymentClearCmd.Flags().BoolVarP(&deploymentYes, "yes", "y", false, "Do not ask for confirmation (optional)")
+
+	deploymentCmd.AddCommand(deploymentListCmd)
+	deploymentCmd.AddCommand(deploymentAddCmd)
+	deploymentCmd.AddCommand(deploymentRemoveCmd)
+	deploymentCmd.AddCommand(deploymentHistoryCmd)
+	deploymentCmd.AddCommand(deploymentClearCmd)
+	rootCmd.AddCommand(deploymentCmd)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/spf13/cobra"
+)
+
+var gcDryRun bool
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Delete orphaned uploads and bundles past the retention policy from storage",
+	Long: `Delete uploaded zips no bundle points at once they are older than GC_ORPHAN_GRACE_PERIOD, and
+bundles beyond the newest GC_KEEP_BUNDLES of each version. The current bundle of a version and
+the bundle a rollback would go back to are always kept.`,
+	RunE: collectGarbage,
+}
+
+func init() {
+	gcCmd.Flags().StringVarP(&configFile, "config", "c", "", "YAML config file, environment variables override it (optional)")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "Report what would be deleted without deleting it")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func collectGarbage(cmd *cobra.Command, args []string) error {
+	if _, err := config.Load(configFile); err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return errors.New("invalid configuration:\n" + err.Error())
+	}
+	if config.CloudflareR2Bucket == "" {
+		return errors.New("no storage configured, set CLOUDFLARE_R2_BUCKET")
+	}
+	policy, err := config.ParseGCPolicy()
+	if err != nil {
+		return err
+	}
+	db, err := pkg.MongoConnection()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		disconnectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = db.Client().Disconnect(disconnectCtx)
+	}()
+	r2Service, err := pkg.NewR2Service()
+	if err != nil {
+		return err
+	}
+
+	gcService := service.NewGCService(repository.NewBundleRepository(db), repository.NewVersionRepository(db), repository.NewBundleMetricRepository(db), repository.NewExperimentRepository(db), r2Service, policy)
+	report, err := gcService.Collect(cmd.Context(), gcDryRun)
+	if report != nil {
+		verb := "Deleted"
+		if report.DryRun {
+			verb = "Would delete"
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(out, "%s %d orphaned uploads:\n", verb, len(report.Orphans))
+		for _, orphan := range report.Orphans {
+			fmt.Fprintf(out, "  %s\t%d bytes\tuploaded %s\n", orphan.Key, orphan.Size, orphan.LastModified.Format(time.RFC3339))
+		}
+		fmt.Fprintf(out, "%s %d superseded bundles:\n", verb, len(report.Superseded))
+		for _, bundle := range report.Superseded {
+			fmt.Fprintf(out, "  %s\tversion %s\tsequence %d\t%s\t%d bytes\n", bundle.Label, bundle.VersionId, bundle.SequenceId, bundle.DownloadFile, bundle.Size)
+		}
+		fmt.Fprintf(out, "%s %d bytes in total\n", verb, report.ReclaimedBytes)
+		out.Flush()
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/SwishHQ/spread/cli"
+	"github.com/spf13/cobra"
+)
+
+var loginServer string
+var loginUsername string
+var loginPassword string
+
+var loginCmd = &cobra.Command{
+	Use:          "login",
+	Short:        "Log in to a spread server for the management commands",
+	Long:         "Log in with a username and password. The session is saved to ~/.spread/session.json, or to SPREAD_SESSION_FILE, and refreshed as it is used.",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.Login(loginServer, loginUsername, loginPassword)
+	},
+}
+
+var logoutCmd = &cobra.Command{
+	Use:          "logout",
+	Short:        "End the saved session",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.Logout()
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:          "whoami",
+	Short:        "Show the logged in user and server",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.Whoami()
+	},
+}
+
+func init() {
+	loginCmd.Flags().StringVarP(&loginServer, "server", "s", "", "API base URL (required)")
+	loginCmd.Flags().StringVarP(&loginUsername, "username", "u", "", "Username, asked for when not given (optional)")
+	loginCmd.Flags().StringVarP(&loginPassword, "password", "p", "", "Password, asked for when not given (optional)")
+	loginCmd.MarkFlagRequired("server")
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
+	rootCmd.AddCommand(whoamiCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"github.com/SwishHQ/spread/cli"
+	"github.com/spf13/cobra"
+)
+
+// rollback, promote and patch change releases already made, by the logged in user
+var releasesTargetVersion string
+var releasesDescription string
+var releasesOverrideFreeze string
+var patchLabel string
+var patchMandatory bool
+var patchDisabled bool
+
+var rollbackCmd = &cobra.Command{
+	Use:          "rollback <appName> <deploymentName>",
+	Short:        "Make the release before the current one current again",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.Rollback(args[0], args[1], releasesTargetVersion)
+	},
+}
+
+var promoteCmd = &cobra.Command{
+	Use:          "promote <appName> <sourceDeploymentName> <destDeploymentName>",
+	Short:        "Release the current release of one deployment to another",
+	Long:         "Release the current release of an app version of the source deployment to the destination deployment. Freeze windows and approvals of the destination apply, like for spread release.",
+	Args:         cobra.ExactArgs(3),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.Promote(args[0], args[1], args[2], releasesTargetVersion, releasesDescription, releasesOverrideFreeze)
+	},
+}
+
+var patchCmd = &cobra.Command{
+	Use:          "patch <appName> <deploymentName>",
+	Short:        "Change the description, mandatory flag or enabled state of a release",
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config := cli.PatchConfig{
+			AppName:        args[0],
+			Deployment:     args[1],
+			TargetVersion:  releasesTargetVersion,
+			Label:          patchLabel,
+			OverrideFreeze: releasesOverrideFreeze,
+		}
+		if cmd.Flags().Changed("description") {
+			config.Description = &releasesDescription
+		}
+		if cmd.Flags().Changed("mandatory") {
+			config.Mandatory = &patchMandatory
+		}
+		if cmd.Flags().Changed("disabled") {
+			config.Disabled = &patchDisabled
+		}
+		return cli.Patch(config)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rollbackCmd, promoteCmd, patchCmd} {
+		cmd.Flags().StringVarP(&releasesTargetVersion, "target-version", "t", "", "App version, needed when the deployment has releases for several (optional)")
+		rootCmd.AddCommand(cmd)
+	}
+	promoteCmd.Flags().StringVarP(&releasesDescription, "description", "d", "", "Description of the promoted release, the source's when not given (optional)")
+	promoteCmd.Flags().StringVar(&releasesOverrideFreeze, "override-freeze", "", "Reason to release during a freeze window of the destination, admins only (optional)")
+	patchCmd.Flags().StringVarP(&patchLabel, "label", "l", "", "Label of the release, the current release when not given (optional)")
+	patchCmd.Flags().StringVarP(&releasesDescription, "description", "d", "", "New description (optional)")
+	patchCmd.Flags().BoolVarP(&patchMandatory, "mandatory", "m", false, "Make the release mandatory, --mandatory=false to make it optional (optional)")
+	patchCmd.Flags().BoolVarP(&patchDisabled, "disabled", "x", false, "Disable the release, --disabled=false to enable it (optional)")
+	patchCmd.Flags().StringVar(&releasesOverrideFreeze, "override-freeze", "", "Reason to enable the release during a freeze window, admins only (optional)")
+}
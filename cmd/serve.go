@@ -1,20 +1,28 @@
 package cmd
 
 import (
-	"fmt"
+	"context"
 	"log"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
 	"github.com/SwishHQ/spread/middleware"
 	"github.com/SwishHQ/spread/pkg"
 	"github.com/SwishHQ/spread/src/controller"
 	"github.com/SwishHQ/spread/src/repository"
 	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/utils"
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	recover "github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 )
 
@@ -24,35 +32,81 @@ var serveCmd = &cobra.Command{
 	Run:   serve,
 }
 
+var configFile string
+
 func init() {
+	serveCmd.Flags().StringVarP(&configFile, "config", "c", "", "YAML config file, environment variables override it (optional)")
 	rootCmd.AddCommand(serveCmd)
 }
 
 func serve(cmd *cobra.Command, args []string) {
-	app := fiber.New()
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET, POST, PUT, DELETE",
-	}))
+	if _, err := config.Load(configFile); err != nil {
+		log.Fatal(err)
+	}
+	if err := config.Validate(); err != nil {
+		log.Fatal("Invalid configuration:\n" + err.Error())
+	}
+	logger.Reload()
+	timeouts, err := config.ParseServerTimeouts()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	loginLimit, err := config.ParseRateLimit("RATE_LIMIT_LOGIN", config.RateLimitLogin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	codePushLimit, err := config.ParseRateLimit("RATE_LIMIT_CODEPUSH", config.RateLimitCodePush)
+	if err != nil {
+		log.Fatal(err)
+	}
+	releaseLimit, err := config.ParseRateLimit("RATE_LIMIT_RELEASE", config.RateLimitRelease)
+	if err != nil {
+		log.Fatal(err)
+	}
+	loginLockout, err := config.ParseLoginLockout()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := fiber.New(fiber.Config{
+		ReadTimeout:  timeouts.Read,
+		WriteTimeout: timeouts.Write,
+		IdleTimeout:  timeouts.Idle,
+		// behind a proxy the client IP rate limits count by comes from this header
+		ProxyHeader:        config.ProxyHeader,
+		EnableIPValidation: true,
+		// bundles and their source maps are uploaded through the API, Hermes maps run to tens of MB
+		BodyLimit: 200 * 1024 * 1024,
+	})
+	app.Use(middleware.NewCORSMiddleware())
+	app.Use(middleware.NewSecurityHeadersMiddleware())
+
+	// outside recover so requests that panic are counted too
+	app.Use(middleware.MetricsMiddleware)
 
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
 	}))
 
+	// set once the migrations are applied, requests before that get 503
+	var migrated atomic.Bool
+	app.Use(middleware.MigrationGateMiddleware(&migrated))
+
 	db, errMongoConnection := pkg.MongoConnection()
 	if errMongoConnection != nil {
 		log.Fatal(errMongoConnection)
 	}
+	mongoChecker := &pkg.MongoChecker{Database: db}
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := mongoChecker.Ping(pingCtx); err != nil {
+		log.Println("MongoDB is not reachable yet, /readyz reports not ready until it is: " + err.Error())
+	}
+	cancelPing()
 
 	// Serve static files from the React app build directory
-	serveStatic := os.Getenv("SERVE_STATIC")
-	fmt.Println("serveStatic", serveStatic)
-	if serveStatic == "true" {
-		staticDir := os.Getenv("STATIC_DIR")
-		fmt.Println("staticDir", staticDir)
-		if staticDir == "" {
-			staticDir = "./web/dist" // Default static directory
-		}
+	if config.ServeStatic == "true" {
+		staticDir := config.StaticDir
 
 		app.Static("/web/", staticDir)
 
@@ -73,8 +127,23 @@ func serve(cmd *cobra.Command, args []string) {
 	})
 
 	userRepository := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepository)
-	userController := controller.NewUserController(userService)
+	refreshTokenRepository := repository.NewRefreshTokenRepository(db)
+	userService := service.NewUserService(userRepository, refreshTokenRepository)
+	rateLimitStore := pkg.NewRateLimitStore(db)
+	loginAttemptService := service.NewLoginAttemptService(rateLimitStore, loginLockout)
+	userController := controller.NewUserController(userService, loginAttemptService)
+
+	oidcSessionRepository := repository.NewOIDCSessionRepository(db)
+	var oidcService service.OIDCService
+	if config.OIDCEnabled() {
+		oidcProvider, err := pkg.NewOIDCProvider(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		roleMapping, _ := config.ParseOIDCRoleMapping(config.OIDCRoleMapping)
+		oidcService = service.NewOIDCService(oidcProvider, oidcSessionRepository, userService, roleMapping)
+	}
+	oidcController := controller.NewOIDCController(oidcService)
 
 	authKeyRepository := repository.NewAuthKeyRepository(db)
 	authKeyService := service.NewAuthKeyService(authKeyRepository)
@@ -92,22 +161,140 @@ func serve(cmd *cobra.Command, args []string) {
 	versionService := service.NewVersionService(versionRepository)
 	versionController := controller.NewVersionController(versionService)
 
+	webhookRepository := repository.NewWebhookRepository(db)
+	webhookDeliveryRepository := repository.NewWebhookDeliveryRepository(db)
+	webhookService := service.NewWebhookService(appService, webhookRepository, webhookDeliveryRepository, config.WebhookAllowPrivateTargets == "true")
+	webhookController := controller.NewWebhookController(webhookService)
+
+	auditEventRepository := repository.NewAuditEventRepository(db)
+	auditService := service.NewAuditService(auditEventRepository)
+	auditController := controller.NewAuditController(auditService)
+
 	bundleRepository := repository.NewBundleRepository(db)
-	bundleService := service.NewBundleService(appService, versionService, environmentService, bundleRepository)
-	bundleController := controller.NewBundleController(bundleService)
+	releaseScheduleService := service.NewReleaseScheduleService(bundleRepository, versionRepository, environmentRepository, webhookService, auditService)
+	notifier := pkg.NewNotifier()
+	approvalService := service.NewApprovalService(bundleRepository, versionRepository, environmentRepository, releaseScheduleService, webhookService, auditService, notifier)
+	approvalController := controller.NewApprovalController(approvalService)
+	bundleService := service.NewBundleService(appService, versionService, environmentService, webhookService, releaseScheduleService, approvalService, bundleRepository)
+	targetingService := service.NewTargetingService(bundleRepository, versionRepository, releaseScheduleService, auditService)
+	bundleController := controller.NewBundleController(bundleService, releaseScheduleService, targetingService, userService)
+
+	deviceRepository := repository.NewDeviceRepository(db)
+	deviceService := service.NewDeviceService(deviceRepository)
+
+	bundleMetricRepository := repository.NewBundleMetricRepository(db)
+	metricService := service.NewMetricService(bundleService, bundleMetricRepository)
+	metricController := controller.NewMetricController(metricService)
+
+	rollbackPolicyService := service.NewRollbackPolicyService(deviceService, bundleService, auditService, webhookService, notifier)
 
-	clientService := service.NewClientService(appService, environmentService, bundleService, versionService)
+	experimentRepository := repository.NewExperimentRepository(db)
+	experimentService := service.NewExperimentService(experimentRepository, versionRepository, bundleRepository, deviceService, releaseScheduleService, auditService)
+	experimentController := controller.NewExperimentController(experimentService)
+
+	clientService := service.NewClientService(appService, environmentService, bundleService, versionService, deviceService, metricService, rollbackPolicyService, targetingService, experimentService)
 	clientController := controller.NewClientController(clientService)
 
+	migrationRepository := repository.NewMigrationRepository(db)
+	migrationService := service.NewMigrationService(migrationRepository)
+	migrationService.Register("0001_hash_auth_keys", authKeyService.HashPlaintextKeys)
+	migrationService.Register("0002_device_indexes", deviceService.EnsureIndexes)
+	migrationService.Register("0003_bundle_metric_indexes", metricService.EnsureIndexes)
+	migrationService.Register("0004_webhook_indexes", webhookService.EnsureIndexes)
+	migrationService.Register("0005_rate_limit_indexes", pkg.NewMongoRateLimitStore(db).EnsureIndexes)
+	migrationService.Register("0006_bundle_schedule_index", releaseScheduleService.EnsureIndexes)
+	migrationService.Register("0007_experiment_indexes", experimentService.EnsureIndexes)
+	migrationService.Register("0008_oidc_session_ttl", oidcSessionRepository.EnsureIndexes)
+	// creating indexes is idempotent, so databases that already ran 0002 get the status index too
+	migrationService.Register("0009_device_status_index", deviceService.EnsureIndexes)
+	migrationService.Register("0010_webhook_release_enabled", webhookService.SubscribePromotedToEnabled)
+	// background workers stop after the last request is drained on shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		if runMigrations(workerCtx, migrationService) {
+			migrated.Store(true)
+			log.Println("Migrations applied, serving requests")
+		}
+	}()
+
+	healthCheckers := map[string]service.HealthChecker{"mongo": mongoChecker}
+	var r2Service *pkg.S3Service
+	var bundleStorage service.BundleStorage
+	var sourceMapStorage service.SourceMapStorage
+	var promotionStorage service.PromotionStorage
+	if config.CloudflareR2Bucket != "" {
+		r2Service, err = pkg.NewR2Service()
+		if err != nil {
+			log.Fatal(err)
+		}
+		healthCheckers["storage"] = r2Service
+		bundleStorage = r2Service
+		sourceMapStorage = r2Service
+		promotionStorage = r2Service
+	}
+	sourceMapService := service.NewSourceMapService(bundleRepository, sourceMapStorage)
+	sourceMapController := controller.NewSourceMapController(sourceMapService)
+	promotionService := service.NewPromotionService(appService, environmentService, bundleService, bundleRepository, versionRepository, webhookService, auditService, promotionStorage)
+	promotionController := controller.NewPromotionController(promotionService)
+	healthService := service.NewHealthService(healthCheckers, migrationService)
+	healthController := controller.NewHealthController(healthService)
+	app.Get("/healthz", healthController.Liveness)
+	app.Get("/readyz", healthController.Readiness)
+
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		webhookService.RunDispatcher(workerCtx)
+	}()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		releaseScheduleService.RunScheduler(workerCtx)
+	}()
+
+	deletionRetention, _ := config.ParseDeletionRetention()
+	deletionService := service.NewDeletionService(appRepository, environmentRepository, versionRepository, bundleRepository, deviceRepository, bundleMetricRepository, webhookService, auditService, bundleStorage, deletionRetention)
+	deletionController := controller.NewDeletionController(deletionService)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		deletionService.RunPurger(workerCtx)
+	}()
+
+	gcPolicy, _ := config.ParseGCPolicy()
+	if gcPolicy.Interval > 0 && r2Service != nil {
+		gcService := service.NewGCService(bundleRepository, versionRepository, bundleMetricRepository, experimentRepository, r2Service, gcPolicy)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			gcService.Run(workerCtx)
+		}()
+	}
+
+	prometheus.MustRegister(pkg.NewActiveDevicesCollector(bundleService.GetBundlesWithActiveDevices))
+	app.Get("/metrics", middleware.MetricsAuthMiddleware, adaptor.HTTPHandler(promhttp.Handler()))
+
 	// public endpoints
-	app.Post("/login", userController.LoginUser)
+	loginLimiter := middleware.RateLimitMiddleware(rateLimitStore, "login", loginLimit, middleware.ByClientIP)
+	app.Post("/login", loginLimiter, userController.LoginUser)
+	app.Post("/token/refresh", userController.RefreshToken)
+	app.Post("/logout", userController.Logout)
 	app.Get("/setup/status", userController.SetupStatus)
-	app.Post("/init-user", userController.InitUser)
+	app.Post("/init-user", loginLimiter, userController.InitUser)
+	app.Get("/oidc/config", oidcController.GetConfig)
+	if config.OIDCEnabled() {
+		app.Get("/oidc/login", loginLimiter, oidcController.Login)
+		app.Get("/oidc/callback", oidcController.Callback)
+	}
 
 	// code-push compatible endpoints
-	app.Get("/v0.1/public/codepush/update_check", clientController.CheckUpdate)
-	app.Post("/v0.1/public/codepush/report_status/deploy", clientController.ReportStatusDeploy)
-	app.Post("/v0.1/public/codepush/report_status/download", clientController.ReportStatusDownload)
+	codePushLimiter := middleware.RateLimitMiddleware(rateLimitStore, "codepush", codePushLimit, middleware.ByDeploymentKey)
+	app.Get("/v0.1/public/codepush/update_check", codePushLimiter, clientController.CheckUpdate)
+	app.Post("/v0.1/public/codepush/report_status/deploy", codePushLimiter, clientController.ReportStatusDeploy)
+	app.Post("/v0.1/public/codepush/report_status/download", codePushLimiter, clientController.ReportStatusDownload)
 
 	// protected endpoints
 	coreGroup := app.Group("/core", func(c *fiber.Ctx) error {
@@ -116,27 +303,114 @@ func serve(cmd *cobra.Command, args []string) {
 	coreGroup.Get("/user", userController.GetUser)
 	coreGroup.Post("/environment", environmentController.CreateEnvironment)
 	coreGroup.Get("/environment/:appId", environmentController.GetAllEnvironmentsByAppId)
+	coreGroup.Post("/environment/:environmentId/rotate-key", environmentController.RotateEnvironmentKey)
+	coreGroup.Put("/environment/:environmentId/rollback-policy", environmentController.UpdateRollbackPolicy)
+	coreGroup.Put("/environment/:environmentId/freeze-windows", environmentController.UpdateFreezeWindows)
+	coreGroup.Put("/environment/:environmentId/approval-policy", environmentController.UpdateApprovalPolicy)
+	coreGroup.Get("/environment/:environmentId/approvals", approvalController.GetPending)
+	coreGroup.Get("/environment/:environmentId/experiments", experimentController.GetExperimentsByEnvironmentId)
+	coreGroup.Post("/environment/:environmentId/symbolicate", sourceMapController.Symbolicate)
+	coreGroup.Put("/environment/:environmentId", environmentController.RenameEnvironment)
+	coreGroup.Post("/environment/:id/deletion-token", deletionController.RequestDeletion(utils.RESOURCE_ENVIRONMENT))
+	coreGroup.Delete("/environment/:id", deletionController.Delete(utils.RESOURCE_ENVIRONMENT))
+	coreGroup.Post("/environment/:id/restore", deletionController.Restore(utils.RESOURCE_ENVIRONMENT))
 	coreGroup.Get("/version/:versionId", versionController.GetByVersionId)
 	coreGroup.Get("/version", versionController.GetAll)
 	coreGroup.Get("/version/bundle/:versionId", bundleController.GetAllByVersionId)
 	coreGroup.Put("/version/bundle/:bundleId/mandatory", bundleController.ToggleMandatory)
 	coreGroup.Put("/version/bundle/:bundleId/active", bundleController.ToggleActive)
+	coreGroup.Put("/version/bundle/:bundleId/schedule", bundleController.ScheduleBundle)
+	coreGroup.Delete("/version/bundle/:bundleId/schedule", bundleController.CancelSchedule)
+	coreGroup.Put("/version/bundle/:bundleId/targeting", bundleController.UpdateTargeting)
+	coreGroup.Put("/version/bundle/:bundleId/sourcemap", sourceMapController.UploadSourceMap)
+	coreGroup.Put("/version/bundle/:bundleId/description", bundleController.UpdateDescription)
+	coreGroup.Post("/version/bundle/:bundleId/promote", promotionController.Promote)
+	coreGroup.Post("/version/bundle/:bundleId/approve", approvalController.Approve)
+	coreGroup.Post("/version/bundle/:bundleId/reject", approvalController.Reject)
+	coreGroup.Post("/version/bundle/:bundleId/comments", approvalController.Comment)
+	coreGroup.Post("/version/:id/deletion-token", deletionController.RequestDeletion(utils.RESOURCE_VERSION))
+	coreGroup.Delete("/version/:id", deletionController.Delete(utils.RESOURCE_VERSION))
+	coreGroup.Post("/version/:id/restore", deletionController.Restore(utils.RESOURCE_VERSION))
 	coreGroup.Get("/app", appController.GetApps)
 	coreGroup.Post("/app", appController.CreateApp)
 	coreGroup.Get("/app/:id", appController.GetAppById)
+	coreGroup.Put("/app/:id", appController.RenameApp)
+	coreGroup.Post("/app/:id/deletion-token", deletionController.RequestDeletion(utils.RESOURCE_APP))
+	coreGroup.Delete("/app/:id", deletionController.Delete(utils.RESOURCE_APP))
+	coreGroup.Post("/app/:id/restore", deletionController.Restore(utils.RESOURCE_APP))
+	coreGroup.Get("/deleted", deletionController.GetDeleted)
+	coreGroup.Post("/experiments", experimentController.StartExperiment)
+	coreGroup.Get("/experiments/:experimentId", experimentController.GetExperiment)
+	coreGroup.Post("/experiments/:experimentId/conclude", experimentController.Conclude)
+	coreGroup.Post("/experiments/:experimentId/stop", experimentController.Stop)
+	coreGroup.Post("/app/:appId/webhooks", webhookController.CreateWebhook)
+	coreGroup.Get("/app/:appId/webhooks", webhookController.GetWebhooks)
+	coreGroup.Put("/webhooks/:webhookId", webhookController.UpdateWebhook)
+	coreGroup.Delete("/webhooks/:webhookId", webhookController.DeleteWebhook)
+	coreGroup.Get("/webhooks/:webhookId/deliveries", webhookController.GetDeliveries)
 	coreGroup.Post("/auth-key/create", authKeyController.CreateAuthKey)
 	coreGroup.Get("/auth-keys", authKeyController.GetAllAuthKeys)
+	coreGroup.Delete("/auth-key/:id", authKeyController.DeleteAuthKey)
 	coreGroup.Post("/rollback", bundleController.Rollback)
+	coreGroup.Get("/metrics", metricController.GetSeries)
+	coreGroup.Get("/audit-events", auditController.GetEvents)
 	coreGroup.Post("/user/create", userController.CreateUser)
 
 	// auth key protected endpoints
-	bundleGroup := app.Group("/bundle", func(c *fiber.Ctx) error {
+	releaseLimiter := middleware.RateLimitMiddleware(rateLimitStore, "release", releaseLimit, middleware.ByAuthKey)
+	bundleGroup := app.Group("/bundle", releaseLimiter, func(c *fiber.Ctx) error {
 		return middleware.AuthKeyMiddleware(c, authKeyService)
 	})
 	bundleGroup.Post("/create", bundleController.CreateNewBundle)
 	bundleGroup.Post("/upload", bundleController.UploadBundle)
 
-	// Start server
+	// Start server, SIGTERM and SIGINT stop accepting connections and let in-flight
+	// requests finish for up to SHUTDOWN_TIMEOUT
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(":" + config.ServerPort)
+	}()
 	log.Println("Server started on port " + config.ServerPort)
-	log.Fatal(app.Listen(":" + config.ServerPort))
+
+	select {
+	case err := <-listenErr:
+		if err != nil {
+			log.Fatal(err)
+		}
+	case <-signalCtx.Done():
+	}
+	log.Println("Shutting down, draining in-flight requests")
+	if err := app.ShutdownWithTimeout(timeouts.Shutdown); err != nil {
+		log.Println("Error draining requests: " + err.Error())
+	}
+	stopWorkers()
+	workers.Wait()
+
+	disconnectCtx, cancelDisconnect := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelDisconnect()
+	if err := db.Client().Disconnect(disconnectCtx); err != nil {
+		log.Println("Error disconnecting from MongoDB: " + err.Error())
+	}
+	logger.L.Sync()
+	log.Println("Server stopped")
+}
+
+// runMigrations retries until every migration is applied, so a database that is still starting
+// does not crash the server. It returns false when the server stops first. Until then /readyz
+// reports not ready and every other route answers 503
+func runMigrations(ctx context.Context, migrationService service.MigrationService) bool {
+	for {
+		err := migrationService.Run(ctx)
+		if err == nil {
+			return true
+		}
+		log.Println("Migrations failed, retrying in 10s: " + err.Error())
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(10 * time.Second):
+		}
+	}
 }
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
-	"go.uber.org/zap"
 )
 
 var (
@@ -19,26 +25,265 @@ var (
 	CloudflareR2AccessKeyID     = GetEnv("CLOUDFLARE_R2_ACCESS_KEY_ID", "")
 	CloudflareR2SecretAccessKey = GetEnv("CLOUDFLARE_R2_SECRET_ACCESS_KEY", "")
 	ServeStatic                 = GetEnv("SERVE_STATIC", "false")
-	StaticDir                   = GetEnv("STATIC_DIR", "./web/build")
+	StaticDir                   = GetEnv("STATIC_DIR", "./web/dist")
+	DeploymentKeyGracePeriod    = GetEnv("DEPLOYMENT_KEY_GRACE_PERIOD", "168h")
+	TokenIssuer                 = GetEnv("TOKEN_ISSUER", "spread")
+	AccessTokenTTL              = GetEnv("ACCESS_TOKEN_TTL", "15m")
+	RefreshTokenTTL             = GetEnv("REFRESH_TOKEN_TTL", "720h")
+	OIDCIssuerURL               = GetEnv("OIDC_ISSUER_URL", "")
+	OIDCClientID                = GetEnv("OIDC_CLIENT_ID", "")
+	OIDCClientSecret            = GetEnv("OIDC_CLIENT_SECRET", "")
+	OIDCRedirectURL             = GetEnv("OIDC_REDIRECT_URL", "")
+	OIDCScopes                  = GetEnv("OIDC_SCOPES", "openid profile email groups")
+	OIDCGroupsClaim             = GetEnv("OIDC_GROUPS_CLAIM", "groups")
+	OIDCRoleMapping             = GetEnv("OIDC_ROLE_MAPPING", "")
+	OIDCDashboardRedirectURL    = GetEnv("OIDC_DASHBOARD_REDIRECT_URL", "/web/login/sso")
+	NotificationWebhookURL      = GetEnv("NOTIFICATION_WEBHOOK_URL", "")
+	WebhookAllowPrivateTargets  = GetEnv("WEBHOOK_ALLOW_PRIVATE_TARGETS", "false")
+	MetricsBearerToken          = GetEnv("METRICS_BEARER_TOKEN", "")
+	ServerReadTimeout           = GetEnv("SERVER_READ_TIMEOUT", "60s")
+	ServerWriteTimeout          = GetEnv("SERVER_WRITE_TIMEOUT", "60s")
+	ServerIdleTimeout           = GetEnv("SERVER_IDLE_TIMEOUT", "120s")
+	ShutdownTimeout             = GetEnv("SHUTDOWN_TIMEOUT", "30s")
+	CORSAllowOrigins            = GetEnv("CORS_ALLOW_ORIGINS", "")
+	CORSAllowMethods            = GetEnv("CORS_ALLOW_METHODS", "GET, POST, PUT, DELETE")
+	LogLevel                    = GetEnv("LOG_LEVEL", "info")
+	ProxyHeader                 = GetEnv("PROXY_HEADER", "")
+	RateLimitStore              = GetEnv("RATE_LIMIT_STORE", "memory")
+	RateLimitLogin              = GetEnv("RATE_LIMIT_LOGIN", "10/1m")
+	RateLimitCodePush           = GetEnv("RATE_LIMIT_CODEPUSH", "20000/1m")
+	RateLimitRelease            = GetEnv("RATE_LIMIT_RELEASE", "60/1m")
+	LoginLockoutThreshold       = GetEnv("LOGIN_LOCKOUT_THRESHOLD", "5")
+	LoginLockoutDuration        = GetEnv("LOGIN_LOCKOUT_DURATION", "15m")
+	DeletionRetention           = GetEnv("DELETION_RETENTION", "720h")
+	GCOrphanGracePeriod         = GetEnv("GC_ORPHAN_GRACE_PERIOD", "24h")
+	GCKeepBundles               = GetEnv("GC_KEEP_BUNDLES", "0")
+	GCInterval                  = GetEnv("GC_INTERVAL", "")
+	HSTSMaxAge                  = GetEnv("HSTS_MAX_AGE", "31536000")
+	DashboardCSP                = GetEnv("DASHBOARD_CSP", "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com; img-src 'self' data: https:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'; frame-ancestors 'none'")
 )
 
+var loadDotEnv sync.Once
+
+// GetEnv returns the variable from the environment or the .env file, which is read once
 func GetEnv(key, defaultValue string) string {
+	loadDotEnv.Do(func() {
+		// variables already in the environment win over the file
+		_ = godotenv.Load(".env")
+	})
+	value := os.Getenv(key)
+	if len(value) == 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// ValidateAuthConfig returns an error when the server cannot safely issue and verify tokens
+func ValidateAuthConfig() error {
+	if TokenSecret == "" {
+		return errors.New("TOKEN_SECRET must be set")
+	}
+	if _, err := time.ParseDuration(AccessTokenTTL); err != nil {
+		return fmt.Errorf("invalid ACCESS_TOKEN_TTL: %w", err)
+	}
+	if _, err := time.ParseDuration(RefreshTokenTTL); err != nil {
+		return fmt.Errorf("invalid REFRESH_TOKEN_TTL: %w", err)
+	}
+	return nil
+}
+
+// ServerTimeouts bound how long a connection may stay open, and how long in-flight requests get to
+// finish when the server is asked to stop
+type ServerTimeouts struct {
+	Read     time.Duration
+	Write    time.Duration
+	Idle     time.Duration
+	Shutdown time.Duration
+}
+
+// ParseServerTimeouts reads the SERVER_*_TIMEOUT and SHUTDOWN_TIMEOUT durations, 0 disables a timeout
+func ParseServerTimeouts() (*ServerTimeouts, error) {
+	timeouts := &ServerTimeouts{}
+	for _, timeout := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"SERVER_READ_TIMEOUT", ServerReadTimeout, &timeouts.Read},
+		{"SERVER_WRITE_TIMEOUT", ServerWriteTimeout, &timeouts.Write},
+		{"SERVER_IDLE_TIMEOUT", ServerIdleTimeout, &timeouts.Idle},
+		{"SHUTDOWN_TIMEOUT", ShutdownTimeout, &timeouts.Shutdown},
+	} {
+		duration, err := time.ParseDuration(timeout.value)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid %s %q, expected a duration such as 30s", timeout.name, timeout.value)
+		}
+		*timeout.into = duration
+	}
+	return timeouts, nil
+}
+
+// OIDCEnabled reports whether single sign-on through an OpenID Connect provider is configured
+func OIDCEnabled() bool {
+	return OIDCIssuerURL != ""
+}
+
+// ValidateOIDCConfig returns an error when single sign-on is enabled but cannot work
+func ValidateOIDCConfig() error {
+	if !OIDCEnabled() {
+		return nil
+	}
+	if OIDCClientID == "" {
+		return errors.New("OIDC_CLIENT_ID must be set when OIDC_ISSUER_URL is set")
+	}
+	if OIDCRedirectURL == "" {
+		return errors.New("OIDC_REDIRECT_URL must be set when OIDC_ISSUER_URL is set")
+	}
+	roleMapping, err := ParseOIDCRoleMapping(OIDCRoleMapping)
+	if err != nil {
+		return err
+	}
+	if len(roleMapping) == 0 {
+		return errors.New("OIDC_ROLE_MAPPING must map at least one group to a role")
+	}
+	return nil
+}
 
-	if _, exists := os.LookupEnv(key); !exists {
-		err := godotenv.Load(".env")
-		if err != nil {
-			zap.Error(err)
+// ParseOIDCRoleMapping parses "group=role;group=role" into a map of group to roles,
+// a group may be listed more than once to grant several roles. Entries are split on
+// the last "=" so LDAP style group names such as "cn=admins,ou=groups" can be used
+func ParseOIDCRoleMapping(value string) (map[string][]string, error) {
+	roleMapping := map[string][]string{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
 		}
-		value := os.Getenv(key)
-		if len(value) == 0 {
-			return defaultValue
+		separator := strings.LastIndex(entry, "=")
+		if separator <= 0 || separator == len(entry)-1 {
+			return nil, fmt.Errorf("invalid OIDC_ROLE_MAPPING entry %q, expected group=role", entry)
 		}
-		return value
+		group := strings.TrimSpace(entry[:separator])
+		role := strings.TrimSpace(entry[separator+1:])
+		roleMapping[group] = append(roleMapping[group], role)
 	}
+	return roleMapping, nil
+}
 
-	value := os.Getenv(key)
-	if len(value) == 0 {
-		return defaultValue
+// ParseCORSAllowOrigins splits CORS_ALLOW_ORIGINS into origins. The dashboard and management API
+// never allow every origin, the public CodePush endpoints do regardless of this setting
+func ParseCORSAllowOrigins(value string) ([]string, error) {
+	origins := []string{}
+	for _, origin := range strings.Split(value, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "" {
+			continue
+		}
+		if origin == "*" {
+			return nil, errors.New("CORS_ALLOW_ORIGINS cannot be *, list the origins the dashboard is served from")
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+			(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.User != nil {
+			return nil, fmt.Errorf("invalid CORS_ALLOW_ORIGINS entry %q, expected scheme://host[:port]", origin)
+		}
+		origins = append(origins, strings.TrimSuffix(origin, "/"))
 	}
-	return value
+	return origins, nil
+}
+
+// ParseHSTSMaxAge returns the max-age in seconds of the Strict-Transport-Security header, 0 disables it
+func ParseHSTSMaxAge() (int, error) {
+	maxAge, err := strconv.Atoi(HSTSMaxAge)
+	if err != nil || maxAge < 0 {
+		return 0, fmt.Errorf("invalid HSTS_MAX_AGE %q, expected seconds", HSTSMaxAge)
+	}
+	return maxAge, nil
+}
+
+// RateLimit allows Requests requests per Window
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// ParseRateLimit parses "requests/window" such as "10/1m", nil when the value is empty or "0" which turns the limit off
+func ParseRateLimit(name string, value string) (*RateLimit, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return nil, nil
+	}
+	requests, window, found := strings.Cut(value, "/")
+	limit := &RateLimit{}
+	var err error
+	if found {
+		limit.Requests, err = strconv.Atoi(requests)
+	}
+	if found && err == nil {
+		limit.Window, err = time.ParseDuration(window)
+	}
+	if !found || err != nil || limit.Requests <= 0 || limit.Window <= 0 {
+		return nil, fmt.Errorf("invalid %s %q, expected requests/window such as 10/1m", name, value)
+	}
+	return limit, nil
+}
+
+// LoginLockout locks a username out for Duration after Threshold failed sign ins within Duration
+type LoginLockout struct {
+	Threshold int
+	Duration  time.Duration
+}
+
+// ParseLoginLockout reads LOGIN_LOCKOUT_THRESHOLD and LOGIN_LOCKOUT_DURATION, a threshold of 0 turns the lockout off
+func ParseLoginLockout() (*LoginLockout, error) {
+	threshold, err := strconv.Atoi(LoginLockoutThreshold)
+	if err != nil || threshold < 0 {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_THRESHOLD %q, expected a number of failed sign ins", LoginLockoutThreshold)
+	}
+	duration, err := time.ParseDuration(LoginLockoutDuration)
+	if err != nil || (threshold > 0 && duration <= 0) {
+		return nil, fmt.Errorf("invalid LOGIN_LOCKOUT_DURATION %q, expected a duration such as 15m", LoginLockoutDuration)
+	}
+	return &LoginLockout{Threshold: threshold, Duration: duration}, nil
+}
+
+// ParseDeletionRetention returns how long deleted apps, environments and versions can be restored
+// before they are purged, 0 purges them on the next run
+func ParseDeletionRetention() (time.Duration, error) {
+	retention, err := time.ParseDuration(DeletionRetention)
+	if err != nil || retention < 0 {
+		return 0, fmt.Errorf("invalid DELETION_RETENTION %q, expected a duration such as 720h", DeletionRetention)
+	}
+	return retention, nil
+}
+
+// GCPolicy decides which stored bundles `spread gc` and the background collector delete
+type GCPolicy struct {
+	// uploads no bundle points at are deleted once they are older than this, a release uploads
+	// its zip before it creates the bundle
+	OrphanGracePeriod time.Duration
+	// bundles kept per version besides the current one and its rollback target, 0 keeps all
+	KeepBundles int
+	// how often the server collects in the background, 0 when it does not
+	Interval time.Duration
+}
+
+// ParseGCPolicy reads GC_ORPHAN_GRACE_PERIOD, GC_KEEP_BUNDLES and GC_INTERVAL
+func ParseGCPolicy() (*GCPolicy, error) {
+	gracePeriod, err := time.ParseDuration(GCOrphanGracePeriod)
+	if err != nil || gracePeriod < 0 {
+		return nil, fmt.Errorf("invalid GC_ORPHAN_GRACE_PERIOD %q, expected a duration such as 24h", GCOrphanGracePeriod)
+	}
+	keep, err := strconv.Atoi(GCKeepBundles)
+	if err != nil || keep < 0 {
+		return nil, fmt.Errorf("invalid GC_KEEP_BUNDLES %q, expected a number of bundles", GCKeepBundles)
+	}
+	policy := &GCPolicy{OrphanGracePeriod: gracePeriod, KeepBundles: keep}
+	if GCInterval != "" {
+		policy.Interval, err = time.ParseDuration(GCInterval)
+		if err != nil || policy.Interval < 0 {
+			return nil, fmt.Errorf("invalid GC_INTERVAL %q, expected a duration such as 24h", GCInterval)
+		}
+	}
+	return policy, nil
 }
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration of the server as a file given to `spread serve --config`. Every
+// setting has an environment variable, which wins over the file, and settings missing from both
+// keep their defaults. Settings are tagged redact:"all" when they are secrets and redact:"password"
+// when they are URLs that may carry credentials
+type Config struct {
+	Env           string              `yaml:"env" env:"ENV"`
+	AppName       string              `yaml:"appName" env:"APP_NAME"`
+	Server        ServerConfig        `yaml:"server"`
+	Mongo         MongoConfig         `yaml:"mongo"`
+	Storage       StorageConfig       `yaml:"storage"`
+	Auth          AuthConfig          `yaml:"auth"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Security      SecurityConfig      `yaml:"security"`
+	RateLimit     RateLimitConfig     `yaml:"rateLimit"`
+	GC            GCConfig            `yaml:"gc"`
+	Logging       LoggingConfig       `yaml:"logging"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+	Webhooks      WebhooksConfig      `yaml:"webhooks"`
+}
+
+type ServerConfig struct {
+	Port            string `yaml:"port" env:"PORT"`
+	ReadTimeout     string `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT"`
+	WriteTimeout    string `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     string `yaml:"idleTimeout" env:"SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout string `yaml:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT"`
+	ServeStatic     string `yaml:"serveStatic" env:"SERVE_STATIC"`
+	StaticDir       string `yaml:"staticDir" env:"STATIC_DIR"`
+}
+
+type MongoConfig struct {
+	Url      string `yaml:"url" env:"MONGODB_URL" redact:"password"`
+	Database string `yaml:"database" env:"MONGODB_DATABASE"`
+}
+
+type StorageConfig struct {
+	AccountId       string `yaml:"accountId" env:"CLOUDFLARE_R2_ACCOUNT_ID"`
+	Bucket          string `yaml:"bucket" env:"CLOUDFLARE_R2_BUCKET"`
+	AccessKeyId     string `yaml:"accessKeyId" env:"CLOUDFLARE_R2_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secretAccessKey" env:"CLOUDFLARE_R2_SECRET_ACCESS_KEY" redact:"all"`
+}
+
+type AuthConfig struct {
+	TokenSecret              string     `yaml:"tokenSecret" env:"TOKEN_SECRET" redact:"all"`
+	TokenIssuer              string     `yaml:"tokenIssuer" env:"TOKEN_ISSUER"`
+	AccessTokenTTL           string     `yaml:"accessTokenTTL" env:"ACCESS_TOKEN_TTL"`
+	RefreshTokenTTL          string     `yaml:"refreshTokenTTL" env:"REFRESH_TOKEN_TTL"`
+	DeploymentKeyGracePeriod string     `yaml:"deploymentKeyGracePeriod" env:"DEPLOYMENT_KEY_GRACE_PERIOD"`
+	DeletionRetention        string     `yaml:"deletionRetention" env:"DELETION_RETENTION"`
+	OIDC                     OIDCConfig `yaml:"oidc"`
+}
+
+type OIDCConfig struct {
+	IssuerURL            string `yaml:"issuerURL" env:"OIDC_ISSUER_URL"`
+	ClientID             string `yaml:"clientID" env:"OIDC_CLIENT_ID"`
+	ClientSecret         string `yaml:"clientSecret" env:"OIDC_CLIENT_SECRET" redact:"all"`
+	RedirectURL          string `yaml:"redirectURL" env:"OIDC_REDIRECT_URL"`
+	Scopes               string `yaml:"scopes" env:"OIDC_SCOPES"`
+	GroupsClaim          string `yaml:"groupsClaim" env:"OIDC_GROUPS_CLAIM"`
+	RoleMapping          string `yaml:"roleMapping" env:"OIDC_ROLE_MAPPING"`
+	DashboardRedirectURL string `yaml:"dashboardRedirectURL" env:"OIDC_DASHBOARD_REDIRECT_URL"`
+}
+
+type CORSConfig struct {
+	AllowOrigins string `yaml:"allowOrigins" env:"CORS_ALLOW_ORIGINS"`
+	AllowMethods string `yaml:"allowMethods" env:"CORS_ALLOW_METHODS"`
+}
+
+type SecurityConfig struct {
+	HSTSMaxAge   string `yaml:"hstsMaxAge" env:"HSTS_MAX_AGE"`
+	DashboardCSP string `yaml:"dashboardCSP" env:"DASHBOARD_CSP"`
+}
+
+type RateLimitConfig struct {
+	Store                 string `yaml:"store" env:"RATE_LIMIT_STORE"`
+	ProxyHeader           string `yaml:"proxyHeader" env:"PROXY_HEADER"`
+	Login                 string `yaml:"login" env:"RATE_LIMIT_LOGIN"`
+	CodePush              string `yaml:"codePush" env:"RATE_LIMIT_CODEPUSH"`
+	Release               string `yaml:"release" env:"RATE_LIMIT_RELEASE"`
+	LoginLockoutThreshold string `yaml:"loginLockoutThreshold" env:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutDuration  string `yaml:"loginLockoutDuration" env:"LOGIN_LOCKOUT_DURATION"`
+}
+
+type GCConfig struct {
+	OrphanGracePeriod string `yaml:"orphanGracePeriod" env:"GC_ORPHAN_GRACE_PERIOD"`
+	KeepBundles       string `yaml:"keepBundles" env:"GC_KEEP_BUNDLES"`
+	Interval          string `yaml:"interval" env:"GC_INTERVAL"`
+}
+
+type LoggingConfig struct {
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+type MetricsConfig struct {
+	BearerToken string `yaml:"bearerToken" env:"METRICS_BEARER_TOKEN" redact:"all"`
+}
+
+type NotificationsConfig struct {
+	WebhookURL string `yaml:"webhookURL" env:"NOTIFICATION_WEBHOOK_URL" redact:"all"`
+}
+
+type WebhooksConfig struct {
+	AllowPrivateTargets string `yaml:"allowPrivateTargets" env:"WEBHOOK_ALLOW_PRIVATE_TARGETS"`
+}
+
+// the package variables the rest of the server reads, keyed by environment variable
+var settings = map[string]*string{
+	"ENV":                             &ENV,
+	"APP_NAME":                        &AppName,
+	"PORT":                            &ServerPort,
+	"SERVER_READ_TIMEOUT":             &ServerReadTimeout,
+	"SERVER_WRITE_TIMEOUT":            &ServerWriteTimeout,
+	"SERVER_IDLE_TIMEOUT":             &ServerIdleTimeout,
+	"SHUTDOWN_TIMEOUT":                &ShutdownTimeout,
+	"SERVE_STATIC":                    &ServeStatic,
+	"STATIC_DIR":                      &StaticDir,
+	"MONGODB_URL":                     &MongoUrl,
+	"MONGODB_DATABASE":                &MongoDatabase,
+	"CLOUDFLARE_R2_ACCOUNT_ID":        &CloudflareR2AccountID,
+	"CLOUDFLARE_R2_BUCKET":            &CloudflareR2Bucket,
+	"CLOUDFLARE_R2_ACCESS_KEY_ID":     &CloudflareR2AccessKeyID,
+	"CLOUDFLARE_R2_SECRET_ACCESS_KEY": &CloudflareR2SecretAccessKey,
+	"TOKEN_SECRET":                    &TokenSecret,
+	"TOKEN_ISSUER":                    &TokenIssuer,
+	"ACCESS_TOKEN_TTL":                &AccessTokenTTL,
+	"REFRESH_TOKEN_TTL":               &RefreshTokenTTL,
+	"DEPLOYMENT_KEY_GRACE_PERIOD":     &DeploymentKeyGracePeriod,
+	"DELETION_RETENTION":              &DeletionRetention,
+	"OIDC_ISSUER_URL":                 &OIDCIssuerURL,
+	"OIDC_CLIENT_ID":                  &OIDCClientID,
+	"OIDC_CLIENT_SECRET":              &OIDCClientSecret,
+	"OIDC_REDIRECT_URL":               &OIDCRedirectURL,
+	"OIDC_SCOPES":                     &OIDCScopes,
+	"OIDC_GROUPS_CLAIM":               &OIDCGroupsClaim,
+	"OIDC_ROLE_MAPPING":               &OIDCRoleMapping,
+	"OIDC_DASHBOARD_REDIRECT_URL":     &OIDCDashboardRedirectURL,
+	"CORS_ALLOW_ORIGINS":              &CORSAllowOrigins,
+	"CORS_ALLOW_METHODS":              &CORSAllowMethods,
+	"HSTS_MAX_AGE":                    &HSTSMaxAge,
+	"DASHBOARD_CSP":                   &DashboardCSP,
+	"LOG_LEVEL":                       &LogLevel,
+	"RATE_LIMIT_STORE":                &RateLimitStore,
+	"PROXY_HEADER":                    &ProxyHeader,
+	"RATE_LIMIT_LOGIN":                &RateLimitLogin,
+	"RATE_LIMIT_CODEPUSH":             &RateLimitCodePush,
+	"RATE_LIMIT_RELEASE":              &RateLimitRelease,
+	"LOGIN_LOCKOUT_THRESHOLD":         &LoginLockoutThreshold,
+	"LOGIN_LOCKOUT_DURATION":          &LoginLockoutDuration,
+	"GC_ORPHAN_GRACE_PERIOD":          &GCOrphanGracePeriod,
+	"GC_KEEP_BUNDLES":                 &GCKeepBundles,
+	"GC_INTERVAL":                     &GCInterval,
+	"METRICS_BEARER_TOKEN":            &MetricsBearerToken,
+	"NOTIFICATION_WEBHOOK_URL":        &NotificationWebhookURL,
+	"WEBHOOK_ALLOW_PRIVATE_TARGETS":   &WebhookAllowPrivateTargets,
+}
+
+const redactedValue = "<redacted>"
+
+// field is one setting of a Config
+type field struct {
+	env    string
+	redact string
+	value  *string
+}
+
+// Load reads the file, when a path is given, over the defaults, applies the environment variables
+// on top and makes the result the configuration the server runs with. Unknown keys in the file are
+// an error so a typo does not silently leave a setting at its default
+func Load(path string) (*Config, error) {
+	config := &Config{}
+	for _, field := range config.fields() {
+		*field.value = *settings[field.env]
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	for _, field := range config.fields() {
+		if value := GetEnv(field.env, ""); value != "" {
+			*field.value = value
+		}
+		*settings[field.env] = *field.value
+	}
+	return config, nil
+}
+
+// Validate checks the configuration the server runs with and returns every problem at once
+func Validate() error {
+	var errs []error
+	if MongoUrl == "" {
+		errs = append(errs, errors.New("MONGODB_URL (mongo.url) must be set"))
+	}
+	if MongoDatabase == "" {
+		errs = append(errs, errors.New("MONGODB_DATABASE (mongo.database) must be set"))
+	}
+	// storage is optional, but a partly configured bucket only fails on the first upload
+	storageSet := 0
+	for _, value := range []string{CloudflareR2AccountID, CloudflareR2Bucket, CloudflareR2AccessKeyID, CloudflareR2SecretAccessKey} {
+		if value != "" {
+			storageSet++
+		}
+	}
+	if storageSet > 0 && storageSet < 4 {
+		errs = append(errs, errors.New("storage needs all of CLOUDFLARE_R2_ACCOUNT_ID, CLOUDFLARE_R2_BUCKET, CLOUDFLARE_R2_ACCESS_KEY_ID and CLOUDFLARE_R2_SECRET_ACCESS_KEY"))
+	}
+	if err := ValidateAuthConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := time.ParseDuration(DeploymentKeyGracePeriod); err != nil {
+		errs = append(errs, fmt.Errorf("invalid DEPLOYMENT_KEY_GRACE_PERIOD: %w", err))
+	}
+	if _, err := ParseDeletionRetention(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := ValidateOIDCConfig(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseServerTimeouts(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseCORSAllowOrigins(CORSAllowOrigins); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseHSTSMaxAge(); err != nil {
+		errs = append(errs, err)
+	}
+	if RateLimitStore != "memory" && RateLimitStore != "mongo" {
+		errs = append(errs, fmt.Errorf("invalid RATE_LIMIT_STORE %q, expected memory or mongo", RateLimitStore))
+	}
+	for _, limit := range [][2]string{{"RATE_LIMIT_LOGIN", RateLimitLogin}, {"RATE_LIMIT_CODEPUSH", RateLimitCodePush}, {"RATE_LIMIT_RELEASE", RateLimitRelease}} {
+		if _, err := ParseRateLimit(limit[0], limit[1]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if _, err := ParseLoginLockout(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := ParseGCPolicy(); err != nil {
+		errs = append(errs, err)
+	}
+	if WebhookAllowPrivateTargets != "true" && WebhookAllowPrivateTargets != "false" {
+		errs = append(errs, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE_TARGETS %q, expected true or false", WebhookAllowPrivateTargets))
+	}
+	if _, err := zapcore.ParseLevel(LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid LOG_LEVEL %q, expected debug, info, warn or error", LogLevel))
+	}
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy safe to print, secrets are replaced and URLs lose their passwords
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	for _, field := range redacted.fields() {
+		if *field.value == "" {
+			continue
+		}
+		switch field.redact {
+		case "all":
+			*field.value = redactedValue
+		case "password":
+			*field.value = redactPassword(*field.value)
+		}
+	}
+	return &redacted
+}
+
+// YAML renders the configuration in the format of the file
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+// fields lists every setting of the configuration with a pointer to its value
+func (c *Config) fields() []field {
+	return collectFields(reflect.ValueOf(c).Elem())
+}
+
+func collectFields(value reflect.Value) []field {
+	fields := []field{}
+	for i := 0; i < value.NumField(); i++ {
+		structField := value.Type().Field(i)
+		if structField.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(value.Field(i))...)
+			continue
+		}
+		env := structField.Tag.Get("env")
+		if _, ok := settings[env]; !ok {
+			panic("config: no setting for " + env)
+		}
+		fields = append(fields, field{
+			env:    env,
+			redact: structField.Tag.Get("redact"),
+			value:  value.Field(i).Addr().Interface().(*string),
+		})
+	}
+	return fields
+}
+
+func redactPassword(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return redactedValue
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), "xxxxx")
+	}
+	return parsed.String()
+}
@@ -15,13 +15,24 @@ func init() {
 	L = createLogger()
 }
 
+// Reload rebuilds the logger after the configuration was loaded from a file
+func Reload() {
+	L.Sync()
+	L = createLogger()
+}
+
 func createLogger() *zap.Logger {
+	logLevel, err := zapcore.ParseLevel(config.LogLevel)
+	if err != nil {
+		logLevel = zap.InfoLevel
+	}
+
 	// for local development
 	if config.ENV == "local" || config.ENV == "development" {
 		config := zap.NewDevelopmentConfig()
 		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
 		config.Encoding = "console"
-		config.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+		config.Level = zap.NewAtomicLevelAt(logLevel)
 		logger, _ := config.Build()
 		defer logger.Sync()
 		return logger
@@ -36,7 +47,7 @@ func createLogger() *zap.Logger {
 		MaxAge:     7, // days
 	})
 
-	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	level := zap.NewAtomicLevelAt(logLevel)
 
 	productionCfg := zap.NewProductionEncoderConfig()
 	productionCfg.TimeKey = "timestamp"
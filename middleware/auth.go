@@ -26,10 +26,15 @@ func AuthMiddleware(c *fiber.Ctx, userService service.UserService) error {
 		return utils.UnauthorizedResponse(c, "Invalid Authorization format")
 	}
 
-	// Parse JWT
+	// Parse JWT, only accept our own unexpired HS256 tokens
 	token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.TokenSecret), nil
-	})
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(config.TokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil || !token.Valid {
 		logger.L.Error("In authMiddleware: Invalid token", zap.Error(err))
 		return utils.UnauthorizedResponse(c, "Invalid token")
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MetricsMiddleware counts and times requests by their route pattern, so ids in the path do not
+// create a series per request
+func MetricsMiddleware(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+	// the error handler writes the response after the middleware returns, use the status it will send
+	status := c.Response().StatusCode()
+	if err != nil {
+		status = fiber.StatusInternalServerError
+		if fiberError, ok := err.(*fiber.Error); ok {
+			status = fiberError.Code
+		}
+	}
+	route := c.Route().Path
+	if c.Route().Method == "USE" {
+		// only middleware matched, the path is not one of ours
+		route = "unmatched"
+	}
+	pkg.HTTPRequestsTotal.WithLabelValues(c.Method(), route, strconv.Itoa(status)).Inc()
+	pkg.ObserveSince(pkg.HTTPRequestDuration.WithLabelValues(c.Method(), route), start)
+	return err
+}
+
+// MetricsAuthMiddleware requires METRICS_BEARER_TOKEN on /metrics when it is set,
+// configure it as the bearer token of the Prometheus scrape job
+func MetricsAuthMiddleware(c *fiber.Ctx) error {
+	if config.MetricsBearerToken == "" {
+		return c.Next()
+	}
+	expected := "Bearer " + config.MetricsBearerToken
+	if subtle.ConstantTimeCompare([]byte(c.Get("Authorization")), []byte(expected)) != 1 {
+		return utils.UnauthorizedResponse(c, "Unauthorized")
+	}
+	return c.Next()
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// MigrationGateMiddleware answers 503 until migrated is set. Auth keys are only found by hash and
+// device reports rely on the unique device index, so no request may be served before the
+// migrations are applied. /healthz and /readyz still answer so the orchestrator keeps the
+// server alive and out of rotation
+func MigrationGateMiddleware(migrated *atomic.Bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if migrated.Load() || c.Path() == "/healthz" || c.Path() == "/readyz" {
+			return c.Next()
+		}
+		return utils.ServiceUnavailableResponse(c, "Server is applying migrations", 10*time.Second)
+	}
+}
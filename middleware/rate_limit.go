@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// RateLimitMiddleware allows limit.Requests requests per limit.Window for each key keyOf
+// returns, requests without a key are counted by client IP. Requests over the limit get 429 with
+// Retry-After. When the store fails the request goes through, an outage of the store must not take
+// the API down. A nil limit turns the middleware off
+func RateLimitMiddleware(store pkg.RateLimitStore, name string, limit *config.RateLimit, keyOf func(c *fiber.Ctx) string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if limit == nil {
+			return c.Next()
+		}
+		subject := keyOf(c)
+		if subject == "" {
+			subject = ByClientIP(c)
+		}
+		count, resetAt, err := store.Hit(c.Context(), "ratelimit:"+name+":"+subject, limit.Window)
+		if err != nil {
+			logger.L.Error("In RateLimitMiddleware: Error counting request", zap.String("limit", name), zap.Error(err))
+			return c.Next()
+		}
+		if count > limit.Requests {
+			pkg.RateLimitedTotal.WithLabelValues(name).Inc()
+			return utils.TooManyRequestsResponse(c, "Too many requests", time.Until(resetAt))
+		}
+		return c.Next()
+	}
+}
+
+// ByClientIP counts requests by client IP, set PROXY_HEADER when the server runs behind a proxy
+func ByClientIP(c *fiber.Ctx) string {
+	return "ip:" + c.IP()
+}
+
+// ByDeploymentKey counts CodePush requests by the deployment key in the query or the JSON body
+func ByDeploymentKey(c *fiber.Ctx) string {
+	deploymentKey := c.Query("deployment_key")
+	if deploymentKey == "" && c.Method() == fiber.MethodPost {
+		body := struct {
+			DeploymentKey string `json:"deployment_key"`
+		}{}
+		// a body that does not parse is rejected by the handler, count it by IP
+		_ = c.BodyParser(&body)
+		deploymentKey = body.DeploymentKey
+	}
+	if deploymentKey == "" {
+		return ""
+	}
+	return "deployment:" + utils.HashKey(deploymentKey)
+}
+
+// ByAuthKey counts release requests by auth key, hashed so the store never holds the key
+func ByAuthKey(c *fiber.Ctx) string {
+	authKey := c.Get("x-auth-key")
+	if authKey == "" {
+		return ""
+	}
+	return "authkey:" + utils.HashKey(authKey)
+}
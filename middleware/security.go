@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/helmet"
+)
+
+// the endpoints the CodePush SDK calls, they carry no credentials and are open to any origin
+const publicCodePushPrefix = "/v0.1/public/codepush/"
+
+// the dashboard build served when SERVE_STATIC is true
+const dashboardPrefix = "/web"
+
+// NewCORSMiddleware allows any origin on the public CodePush endpoints and only the origins in
+// CORS_ALLOW_ORIGINS everywhere else. Without CORS_ALLOW_ORIGINS the dashboard and management API
+// answer same-origin requests only. Call after config.Validate, the origins are assumed valid
+func NewCORSMiddleware() fiber.Handler {
+	public := cors.New(cors.Config{
+		AllowOrigins: "*",
+		AllowMethods: "GET, POST",
+	})
+	origins, _ := config.ParseCORSAllowOrigins(config.CORSAllowOrigins)
+	var private fiber.Handler
+	if len(origins) > 0 {
+		private = cors.New(cors.Config{
+			AllowOrigins: strings.Join(origins, ","),
+			AllowMethods: config.CORSAllowMethods,
+		})
+	}
+	return func(c *fiber.Ctx) error {
+		if strings.HasPrefix(c.Path(), publicCodePushPrefix) {
+			return public(c)
+		}
+		if private == nil {
+			return c.Next()
+		}
+		return private(c)
+	}
+}
+
+// NewSecurityHeadersMiddleware sets HSTS, frame and content type options on every response. The
+// dashboard gets DASHBOARD_CSP, API responses a policy that allows nothing since they are never rendered
+func NewSecurityHeadersMiddleware() fiber.Handler {
+	hstsMaxAge, _ := config.ParseHSTSMaxAge()
+	headers := helmet.Config{
+		XFrameOptions:      "DENY",
+		HSTSMaxAge:         hstsMaxAge,
+		ReferrerPolicy:     "no-referrer",
+		ContentTypeNosniff: "nosniff",
+		// the dashboard loads its fonts from Google, which does not send Cross-Origin-Resource-Policy
+		CrossOriginEmbedderPolicy: "unsafe-none",
+	}
+	apiHeaders := headers
+	apiHeaders.ContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+	dashboardHeaders := headers
+	dashboardHeaders.ContentSecurityPolicy = config.DashboardCSP
+
+	api := helmet.New(apiHeaders)
+	dashboard := helmet.New(dashboardHeaders)
+	return func(c *fiber.Ctx) error {
+		if c.Path() == dashboardPrefix || strings.HasPrefix(c.Path(), dashboardPrefix+"/") {
+			return dashboard(c)
+		}
+		return api(c)
+	}
+}
@@ -3,6 +3,8 @@ package pkg
 import (
 	"bytes"
 	"context"
+	"io"
+	"time"
 
 	"github.com/SwishHQ/spread/config"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -57,10 +59,63 @@ func (s *S3Service) UploadFileToR2(ctx context.Context, key string, file []byte)
 	}
 
 	// Upload the file to Cloudflare R2 Storage
+	start := time.Now()
 	_, err := s.s3Client.PutObject(ctx, input)
 	if err != nil {
+		ObserveSince(StorageUploadDuration.WithLabelValues("error"), start)
 		return err
 	}
+	ObserveSince(StorageUploadDuration.WithLabelValues("success"), start)
+	StorageUploadBytes.Add(float64(len(file)))
 
 	return nil
 }
+
+// Ping checks the bucket exists and the credentials can reach it
+func (s *S3Service) Ping(ctx context.Context) error {
+	_, err := s.s3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+// DeleteFile removes an uploaded bundle, deleting a key that does not exist succeeds
+func (s *S3Service) DeleteFile(ctx context.Context, key string) error {
+	_, err := s.s3Client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	return err
+}
+
+// GetFile downloads a file from the bucket
+func (s *S3Service) GetFile(ctx context.Context, key string) ([]byte, error) {
+	output, err := s.s3Client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(s.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	return io.ReadAll(output.Body)
+}
+
+// StorageObject is a file in the bucket
+type StorageObject struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// ListFiles returns every file in the bucket
+func (s *S3Service) ListFiles(ctx context.Context) ([]StorageObject, error) {
+	objects := make([]StorageObject, 0)
+	paginator := s3.NewListObjectsV2Paginator(s.s3Client, &s3.ListObjectsV2Input{Bucket: aws.String(s.bucket)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			objects = append(objects, StorageObject{
+				Key:          aws.ToString(object.Key),
+				Size:         aws.ToInt64(object.Size),
+				LastModified: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return objects, nil
+}
@@ -2,28 +2,29 @@ package pkg
 
 import (
 	"context"
-	"fmt"
-	"log"
 
 	"github.com/SwishHQ/spread/config"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-// ConnectDB initializes the database connection
+// MongoConnection creates the client for the configured database. The driver connects lazily,
+// an unreachable server is not an error here, PingMongo and /readyz report it
 func MongoConnection() (*mongo.Database, error) {
-	// Set client options
-	clientOptions := options.Client().ApplyURI(config.MongoUrl)
-	// Connect to MongoDB
+	clientOptions := options.Client().ApplyURI(config.MongoUrl).SetMonitor(NewMongoCommandMonitor())
 	client, err := mongo.Connect(context.Background(), clientOptions)
 	if err != nil {
-		log.Fatal("Error", err)
+		return nil, err
 	}
-	// Check the connection
-	err = client.Ping(context.TODO(), nil)
-	if err != nil {
-		log.Fatal(err)
-	}
-	fmt.Println("Connected to MongoDB!")
 	return client.Database(config.MongoDatabase), nil
 }
+
+// MongoChecker reports whether the primary of the database answers
+type MongoChecker struct {
+	Database *mongo.Database
+}
+
+func (c *MongoChecker) Ping(ctx context.Context) error {
+	return c.Database.Client().Ping(ctx, readpref.Primary())
+}
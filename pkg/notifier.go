@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"go.uber.org/zap"
+)
+
+// Notifier tells the people running spread about something that happened without them
+type Notifier interface {
+	Notify(ctx context.Context, message string) error
+}
+
+// NewNotifier posts to NOTIFICATION_WEBHOOK_URL when it is set and only logs otherwise
+func NewNotifier() Notifier {
+	if config.NotificationWebhookURL == "" {
+		return &logNotifier{}
+	}
+	return &webhookNotifier{
+		url:    config.NotificationWebhookURL,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// webhookNotifier sends {"text": message}, the payload Slack and most chat incoming webhooks accept
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, message string) error {
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("notification webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+type logNotifier struct{}
+
+func (n *logNotifier) Notify(ctx context.Context, message string) error {
+	logger.L.Warn("Notification", zap.String("message", message))
+	return nil
+}
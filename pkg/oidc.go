@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCIdentity is the user the identity provider vouched for in a verified ID token
+type OIDCIdentity struct {
+	Subject  string
+	Username string
+	Email    string
+	Groups   []string
+}
+
+type OIDCProvider interface {
+	AuthCodeURL(state string, nonce string, codeVerifier string) string
+	Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*OIDCIdentity, error)
+}
+
+type oidcProvider struct {
+	oauth2Config oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	groupsClaim  string
+}
+
+// NewOIDCProvider discovers the provider endpoints from OIDC_ISSUER_URL
+func NewOIDCProvider(ctx context.Context) (OIDCProvider, error) {
+	return newOIDCProvider(ctx, config.OIDCIssuerURL, oauth2.Config{
+		ClientID:     config.OIDCClientID,
+		ClientSecret: config.OIDCClientSecret,
+		RedirectURL:  config.OIDCRedirectURL,
+		Scopes:       strings.Fields(config.OIDCScopes),
+	}, config.OIDCGroupsClaim)
+}
+
+func newOIDCProvider(ctx context.Context, issuerURL string, oauth2Config oauth2.Config, groupsClaim string) (OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, issuerURL)
+	if err != nil {
+		return nil, err
+	}
+	oauth2Config.Endpoint = provider.Endpoint()
+	return &oidcProvider{
+		oauth2Config: oauth2Config,
+		verifier:     provider.Verifier(&oidc.Config{ClientID: oauth2Config.ClientID}),
+		groupsClaim:  groupsClaim,
+	}, nil
+}
+
+// AuthCodeURL builds the authorization request, the code challenge is derived from codeVerifier (PKCE S256)
+func (p *oidcProvider) AuthCodeURL(state string, nonce string, codeVerifier string) string {
+	return p.oauth2Config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier))
+}
+
+// Exchange redeems the authorization code and verifies the returned ID token
+func (p *oidcProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*OIDCIdentity, error) {
+	token, err := p.oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	identity := &OIDCIdentity{
+		Subject: idToken.Subject,
+		Groups:  stringsClaim(claims[p.groupsClaim]),
+	}
+	identity.Email, _ = claims["email"].(string)
+	// prefer the name people know themselves by, fall back to the stable subject
+	identity.Username, _ = claims["preferred_username"].(string)
+	if identity.Username == "" {
+		identity.Username = identity.Email
+	}
+	if identity.Username == "" {
+		identity.Username = identity.Subject
+	}
+	return identity, nil
+}
+
+// providers send groups either as a list or, with a single group, as a plain string
+func stringsClaim(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// fakeIdP is a stand-in identity provider serving discovery, keys and the token endpoint,
+// the ID token it issues carries idTokenClaims signed with signingKey
+type fakeIdP struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	signingKey    *rsa.PrivateKey
+	idTokenClaims map[string]interface{}
+	codeVerifier  string
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp := &fakeIdP{key: key, signingKey: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test-key",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		idp.codeVerifier = r.FormValue("code_verifier")
+		if r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		writeJSON(w, map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.signIDToken(t),
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	idp.idTokenClaims = map[string]interface{}{
+		"iss":                idp.server.URL,
+		"aud":                "spread",
+		"sub":                "idp-subject",
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"nonce":              "nonce",
+		"preferred_username": "jane",
+		"email":              "jane@example.com",
+		"groups":             []string{"spread-devs", "spread-admins"},
+	}
+	return idp
+}
+
+func (idp *fakeIdP) signIDToken(t *testing.T) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "test-key"})
+	claims, _ := json.Marshal(idp.idTokenClaims)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.signingKey, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (idp *fakeIdP) provider(t *testing.T) OIDCProvider {
+	provider, err := newOIDCProvider(context.Background(), idp.server.URL, oauth2.Config{
+		ClientID:    "spread",
+		RedirectURL: "https://spread.example.com/oidc/callback",
+		Scopes:      []string{"openid", "profile", "email", "groups"},
+	}, "groups")
+	require.NoError(t, err)
+	return provider
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestOIDCProvider_AuthCodeURL_UsesPKCE(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider(t)
+
+	// Execute
+	authURL, err := url.Parse(provider.AuthCodeURL("state", "nonce", "verifier"))
+
+	// Assert
+	require.NoError(t, err)
+	query := authURL.Query()
+	challenge := sha256.Sum256([]byte("verifier"))
+	assert.Equal(t, idp.server.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "state", query.Get("state"))
+	assert.Equal(t, "nonce", query.Get("nonce"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(challenge[:]), query.Get("code_challenge"))
+}
+
+func TestOIDCProvider_Exchange_Success(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider(t)
+
+	// Execute
+	identity, err := provider.Exchange(context.Background(), "good-code", "verifier", "nonce")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "verifier", idp.codeVerifier)
+	assert.Equal(t, "idp-subject", identity.Subject)
+	assert.Equal(t, "jane", identity.Username)
+	assert.Equal(t, "jane@example.com", identity.Email)
+	assert.Equal(t, []string{"spread-devs", "spread-admins"}, identity.Groups)
+}
+
+func TestOIDCProvider_Exchange_SingleGroupAndNoUsername(t *testing.T) {
+	idp := newFakeIdP(t)
+	delete(idp.idTokenClaims, "preferred_username")
+	idp.idTokenClaims["groups"] = "spread-devs"
+	provider := idp.provider(t)
+
+	// Execute
+	identity, err := provider.Exchange(context.Background(), "good-code", "verifier", "nonce")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", identity.Username)
+	assert.Equal(t, []string{"spread-devs"}, identity.Groups)
+}
+
+func TestOIDCProvider_Exchange_NonceMismatch(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider(t)
+
+	// Execute
+	identity, err := provider.Exchange(context.Background(), "good-code", "verifier", "nonce-of-another-login")
+
+	// Assert
+	assert.EqualError(t, err, "id_token nonce mismatch")
+	assert.Nil(t, identity)
+}
+
+func TestOIDCProvider_Exchange_RejectsInvalidIDToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := map[string]func(idp *fakeIdP){
+		"foreign signature": func(idp *fakeIdP) { idp.signingKey = otherKey },
+		"other audience":    func(idp *fakeIdP) { idp.idTokenClaims["aud"] = "another-client" },
+		"other issuer":      func(idp *fakeIdP) { idp.idTokenClaims["iss"] = "https://evil.example.com" },
+		"expired":           func(idp *fakeIdP) { idp.idTokenClaims["exp"] = time.Now().Add(-time.Hour).Unix() },
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			idp := newFakeIdP(t)
+			provider := idp.provider(t)
+			tamper(idp)
+
+			// Execute
+			identity, err := provider.Exchange(context.Background(), "good-code", "verifier", "nonce")
+
+			// Assert
+			assert.Error(t, err)
+			assert.Nil(t, identity)
+		})
+	}
+}
+
+func TestOIDCProvider_Exchange_InvalidCode(t *testing.T) {
+	idp := newFakeIdP(t)
+	provider := idp.provider(t)
+
+	// Execute
+	identity, err := provider.Exchange(context.Background(), "stolen-code", "verifier", "nonce")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, identity)
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/event"
+	"go.uber.org/zap"
+)
+
+// collectors exposed on /metrics, registered with the default registry next to the Go and process collectors
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spread_http_requests_total",
+		Help: "HTTP requests handled, by route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "spread_http_request_duration_seconds",
+		Help:    "Time spent handling HTTP requests, by route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	MongoCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "spread_mongo_command_duration_seconds",
+		Help:    "Time spent on MongoDB commands, by command, collection and outcome.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "collection", "outcome"})
+	StorageUploadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "spread_storage_upload_duration_seconds",
+		Help:    "Time spent uploading bundles to object storage, by outcome.",
+		Buckets: []float64{.1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"outcome"})
+	StorageUploadBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "spread_storage_upload_bytes_total",
+		Help: "Bytes of bundles uploaded to object storage.",
+	})
+	UpdateChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spread_update_checks_total",
+		Help: "Update checks answered, by result: bundle, binary (update from the store) or none.",
+	}, []string{"result"})
+	ReleaseEventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spread_release_events_total",
+		Help: "Downloads, installs, failures and rollbacks reported by devices.",
+	}, []string{"event"})
+	RateLimitedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spread_rate_limited_total",
+		Help: "Requests refused with 429, by limit: login, codepush, release or login_lockout.",
+	}, []string{"limit"})
+	CacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "spread_cache_requests_total",
+		Help: "Cache lookups, by cache and result: hit or miss. The hit rate is hits over all lookups.",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		MongoCommandDuration,
+		StorageUploadDuration,
+		StorageUploadBytes,
+		UpdateChecksTotal,
+		ReleaseEventsTotal,
+		RateLimitedTotal,
+		CacheRequestsTotal,
+	)
+}
+
+// ObserveSince records the seconds elapsed since start on the observer
+func ObserveSince(observer prometheus.Observer, start time.Time) {
+	observer.Observe(time.Since(start).Seconds())
+}
+
+// NewMongoCommandMonitor times every command the driver sends, which covers all repository operations
+func NewMongoCommandMonitor() *event.CommandMonitor {
+	// the succeeded and failed events do not carry the collection, keep it from the started event
+	var collections sync.Map
+	finish := func(requestId int64, commandName string, duration time.Duration, outcome string) {
+		collection := ""
+		if value, ok := collections.LoadAndDelete(requestId); ok {
+			collection = value.(string)
+		}
+		MongoCommandDuration.WithLabelValues(commandName, collection, outcome).Observe(duration.Seconds())
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, started *event.CommandStartedEvent) {
+			// the first element of a CRUD command is its name with the collection as value
+			collection := ""
+			if element, err := started.Command.IndexErr(0); err == nil {
+				collection, _ = element.Value().StringValueOK()
+			}
+			collections.Store(started.RequestID, collection)
+		},
+		Succeeded: func(ctx context.Context, succeeded *event.CommandSucceededEvent) {
+			finish(succeeded.RequestID, succeeded.CommandName, succeeded.Duration, "success")
+		},
+		Failed: func(ctx context.Context, failed *event.CommandFailedEvent) {
+			finish(failed.RequestID, failed.CommandName, failed.Duration, "error")
+		},
+	}
+}
+
+// activeDevicesCollector reads the active device count of every bundle when Prometheus scrapes,
+// so all instances of spread report the same numbers
+type activeDevicesCollector struct {
+	getBundles func(ctx context.Context) ([]*model.Bundle, error)
+	desc       *prometheus.Desc
+}
+
+// NewActiveDevicesCollector exposes spread_bundle_active_devices for the bundles getBundles returns
+func NewActiveDevicesCollector(getBundles func(ctx context.Context) ([]*model.Bundle, error)) prometheus.Collector {
+	return &activeDevicesCollector{
+		getBundles: getBundles,
+		desc: prometheus.NewDesc(
+			"spread_bundle_active_devices",
+			"Devices currently running the bundle.",
+			[]string{"app_id", "environment_id", "label"}, nil,
+		),
+	}
+}
+
+func (c *activeDevicesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *activeDevicesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	bundles, err := c.getBundles(ctx)
+	if err != nil {
+		logger.L.Error("In Collect: Error getting active devices", zap.Error(err))
+		return
+	}
+	for _, bundle := range bundles {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(bundle.Active), bundle.AppId.Hex(), bundle.EnvironmentId.Hex(), bundle.Label)
+	}
+}
@@ -0,0 +1,49 @@
+package pkg
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for address, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.20.0.1":      false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fe80::1":         false,
+		"fd00:ec2::254":   false,
+		"::ffff:10.0.0.1": false,
+	} {
+		assert.Equal(t, public, IsPublicIP(net.ParseIP(address)), address)
+	}
+}
+
+func TestNewPublicHTTPClient_RefusesPrivateAddresses(t *testing.T) {
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	client := NewPublicHTTPClient(time.Second)
+
+	// Execute
+	request, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, nil)
+	_, err := client.Do(request)
+
+	// Assert
+	assert.ErrorContains(t, err, "127.0.0.1 is not a public address")
+	assert.Equal(t, 0, requests)
+}
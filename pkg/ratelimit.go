@@ -0,0 +1,159 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitStore counts hits per key in fixed windows
+type RateLimitStore interface {
+	// Hit adds one to the count of the key and returns the count and when its window ends,
+	// the first hit after a window ended starts a new one
+	Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+	// Get returns the count of the key in its current window, 0 when there is none
+	Get(ctx context.Context, key string) (int, time.Time, error)
+	Reset(ctx context.Context, key string) error
+}
+
+// NewRateLimitStore keeps counts in Mongo when RATE_LIMIT_STORE is mongo, so every replica sees
+// the same counts, and in memory otherwise, which is enough for a single replica
+func NewRateLimitStore(db *mongo.Database) RateLimitStore {
+	if config.RateLimitStore == "mongo" {
+		return NewMongoRateLimitStore(db)
+	}
+	return NewMemoryRateLimitStore()
+}
+
+type rateLimitWindow struct {
+	count     int
+	expiresAt time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateLimitWindow
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{windows: map[string]*rateLimitWindow{}, lastSweep: time.Now()}
+}
+
+func (s *memoryRateLimitStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.sweep(now)
+	current, ok := s.windows[key]
+	if !ok || !current.expiresAt.After(now) {
+		current = &rateLimitWindow{expiresAt: now.Add(window)}
+		s.windows[key] = current
+	}
+	current.count++
+	return current.count, current.expiresAt, nil
+}
+
+func (s *memoryRateLimitStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.windows[key]
+	if !ok || !current.expiresAt.After(time.Now()) {
+		return 0, time.Time{}, nil
+	}
+	return current.count, current.expiresAt, nil
+}
+
+func (s *memoryRateLimitStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.windows, key)
+	return nil
+}
+
+// sweep drops ended windows once a minute so keys that stop sending do not stay in memory
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	for key, window := range s.windows {
+		if !window.expiresAt.After(now) {
+			delete(s.windows, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+type MongoRateLimitStore struct {
+	Connection *mongo.Database
+}
+
+func NewMongoRateLimitStore(db *mongo.Database) *MongoRateLimitStore {
+	return &MongoRateLimitStore{Connection: db}
+}
+
+// EnsureIndexes lets Mongo delete windows once they ended
+func (s *MongoRateLimitStore) EnsureIndexes(ctx context.Context) error {
+	collection := s.Connection.Collection("rate_limits")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (s *MongoRateLimitStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	collection := s.Connection.Collection("rate_limits")
+	now := time.Now()
+	// one pipeline update so concurrent hits from several replicas never lose a count,
+	// the TTL monitor runs once a minute so an ended window may still be there
+	open := bson.M{"$gt": bson.A{"$expiresAt", now}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"count":     bson.M{"$cond": bson.A{open, bson.M{"$add": bson.A{"$count", 1}}, 1}},
+		"expiresAt": bson.M{"$cond": bson.A{open, "$expiresAt", now.Add(window)}},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var result struct {
+		Count     int       `bson:"count"`
+		ExpiresAt time.Time `bson:"expiresAt"`
+	}
+	var err error
+	// two replicas creating the same key at once, one of them gets a duplicate key error
+	for attempt := 0; attempt < 2; attempt++ {
+		err = collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&result)
+		if !mongo.IsDuplicateKeyError(err) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return result.Count, result.ExpiresAt, nil
+}
+
+func (s *MongoRateLimitStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	collection := s.Connection.Collection("rate_limits")
+	var result struct {
+		Count     int       `bson:"count"`
+		ExpiresAt time.Time `bson:"expiresAt"`
+	}
+	err := collection.FindOne(ctx, bson.M{"_id": key, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&result)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, time.Time{}, nil
+		}
+		return 0, time.Time{}, err
+	}
+	return result.Count, result.ExpiresAt, nil
+}
+
+func (s *MongoRateLimitStore) Reset(ctx context.Context, key string) error {
+	collection := s.Connection.Collection("rate_limits")
+	_, err := collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const base64Chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// SourceMap is a parsed version 3 source map, the format Metro and the Hermes compose script write
+type SourceMap struct {
+	sources []string
+	names   []string
+	// lines holds the segments of every generated line, sorted by generated column
+	lines [][]mappingSegment
+}
+
+type mappingSegment struct {
+	generatedColumn int
+	source          int
+	originalLine    int
+	originalColumn  int
+	name            int
+}
+
+// OriginalPosition is where a generated position comes from, Line is 1-based and Column 0-based
+type OriginalPosition struct {
+	Source string
+	Line   int
+	Column int
+	Name   string
+}
+
+type rawSourceMap struct {
+	Version  int               `json:"version"`
+	Sources  []string          `json:"sources"`
+	Names    []string          `json:"names"`
+	Mappings string            `json:"mappings"`
+	Sections []json.RawMessage `json:"sections"`
+}
+
+func ParseSourceMap(content []byte) (*SourceMap, error) {
+	var raw rawSourceMap
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("not a source map: %w", err)
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("source map version %d is not supported, only version 3 is", raw.Version)
+	}
+	if len(raw.Sections) > 0 {
+		return nil, errors.New("indexed source maps are not supported")
+	}
+	sourceMap := &SourceMap{sources: raw.Sources, names: raw.Names}
+	// source, original line and column and name are relative to the previous segment across lines
+	var source, originalLine, originalColumn, name int
+	for lineIndex, line := range strings.Split(raw.Mappings, ";") {
+		var segments []mappingSegment
+		generatedColumn := 0
+		for _, encoded := range strings.Split(line, ",") {
+			if encoded == "" {
+				continue
+			}
+			values, err := decodeVLQ(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineIndex+1, err)
+			}
+			generatedColumn += values[0]
+			segment := mappingSegment{generatedColumn: generatedColumn, source: -1, name: -1}
+			if len(values) >= 4 {
+				source += values[1]
+				originalLine += values[2]
+				originalColumn += values[3]
+				if source < 0 || source >= len(raw.Sources) {
+					return nil, fmt.Errorf("line %d: source %d out of range", lineIndex+1, source)
+				}
+				segment.source, segment.originalLine, segment.originalColumn = source, originalLine, originalColumn
+			}
+			if len(values) >= 5 {
+				name += values[4]
+				segment.name = name
+			}
+			segments = append(segments, segment)
+		}
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].generatedColumn < segments[j].generatedColumn })
+		sourceMap.lines = append(sourceMap.lines, segments)
+	}
+	return sourceMap, nil
+}
+
+// Lookup returns the original position of a 1-based line and 0-based column of the generated code,
+// the one of the closest segment at or before the column
+func (m *SourceMap) Lookup(line int, column int) (OriginalPosition, bool) {
+	if line < 1 || line > len(m.lines) {
+		return OriginalPosition{}, false
+	}
+	segments := m.lines[line-1]
+	i := sort.Search(len(segments), func(i int) bool { return segments[i].generatedColumn > column }) - 1
+	if i < 0 || segments[i].source < 0 {
+		return OriginalPosition{}, false
+	}
+	segment := segments[i]
+	position := OriginalPosition{Source: m.sources[segment.source], Line: segment.originalLine + 1, Column: segment.originalColumn}
+	if segment.name >= 0 && segment.name < len(m.names) {
+		position.Name = m.names[segment.name]
+	}
+	return position, true
+}
+
+// decodeVLQ decodes the base64 VLQ values of one mapping segment
+func decodeVLQ(encoded string) ([]int, error) {
+	var values []int
+	value, shift := 0, 0
+	for i := 0; i < len(encoded); i++ {
+		digit := strings.IndexByte(base64Chars, encoded[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid mapping character %q", encoded[i])
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 == 1 {
+			values = append(values, -(value >> 1))
+		} else {
+			values = append(values, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, errors.New("truncated mapping segment")
+	}
+	if len(values) != 1 && len(values) != 4 && len(values) != 5 {
+		return nil, fmt.Errorf("mapping segment with %d fields", len(values))
+	}
+	return values, nil
+}
@@ -15,6 +15,7 @@ type AppController interface {
 	CreateApp(c *fiber.Ctx) error
 	GetApps(c *fiber.Ctx) error
 	GetAppById(c *fiber.Ctx) error
+	RenameApp(c *fiber.Ctx) error
 }
 
 type appControllerImpl struct {
@@ -61,3 +62,17 @@ func (controller *appControllerImpl) GetAppById(c *fiber.Ctx) error {
 	}
 	return utils.SuccessResponse(c, app)
 }
+
+func (controller *appControllerImpl) RenameApp(c *fiber.Ctx) error {
+	var renameRequest types.RenameAppRequest
+	validationErrors := utils.BindAndValidate(c, &renameRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	app, err := controller.appService.RenameApp(c.Context(), c.Params("id"), renameRequest.AppName)
+	if err != nil {
+		logger.L.Error("In RenameApp: Error renaming app", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, app)
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type ApprovalController interface {
+	Approve(c *fiber.Ctx) error
+	Reject(c *fiber.Ctx) error
+	Comment(c *fiber.Ctx) error
+	GetPending(c *fiber.Ctx) error
+}
+
+type approvalControllerImpl struct {
+	approvalService service.ApprovalService
+}
+
+func NewApprovalController(approvalService service.ApprovalService) ApprovalController {
+	return &approvalControllerImpl{approvalService: approvalService}
+}
+
+func (approvalController *approvalControllerImpl) Approve(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	// the body is optional, it carries a comment and the reason to override a freeze window
+	var approvalRequest types.ApprovalDecisionRequest
+	if len(c.Body()) > 0 {
+		validationErrors := utils.BindAndValidate(c, &approvalRequest)
+		if len(validationErrors) > 0 {
+			return utils.ValidationErrorResponse(c, validationErrors)
+		}
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, approvalRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	bundle, err := approvalController.approvalService.Approve(c.Context(), bundleId, user, approvalRequest.Comment, override)
+	if err != nil {
+		logger.L.Error("In Approve: Error approving bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return decisionErrorResponse(c, err)
+	}
+	logger.L.Info("In Approve: Bundle approved", zap.String("bundleId", bundleId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (approvalController *approvalControllerImpl) Reject(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var rejectRequest types.RejectBundleRequest
+	validationErrors := utils.BindAndValidate(c, &rejectRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	bundle, err := approvalController.approvalService.Reject(c.Context(), bundleId, user, rejectRequest.Comment)
+	if err != nil {
+		logger.L.Error("In Reject: Error rejecting bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return decisionErrorResponse(c, err)
+	}
+	logger.L.Info("In Reject: Bundle rejected", zap.String("bundleId", bundleId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (approvalController *approvalControllerImpl) Comment(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var commentRequest types.ApprovalCommentRequest
+	validationErrors := utils.BindAndValidate(c, &commentRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	bundle, err := approvalController.approvalService.Comment(c.Context(), bundleId, user, commentRequest.Text)
+	if err != nil {
+		logger.L.Error("In Comment: Error commenting on bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, bundle.Approval.Comments)
+}
+
+func (approvalController *approvalControllerImpl) GetPending(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	bundles, err := approvalController.approvalService.GetPending(c.Context(), environmentId)
+	if err != nil {
+		logger.L.Error("In GetPending: Error getting bundles waiting for approval", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, bundles)
+}
+
+// decisionErrorResponse answers 403 when the user may not decide on the release
+func decisionErrorResponse(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrNotApprover) || errors.Is(err, service.ErrSelfApproval) {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	return utils.ErrorResponse(c, err.Error())
+}
@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type AuditController interface {
+	GetEvents(c *fiber.Ctx) error
+}
+
+type auditController struct {
+	auditService service.AuditService
+}
+
+func NewAuditController(auditService service.AuditService) AuditController {
+	return &auditController{auditService: auditService}
+}
+
+func (c *auditController) GetEvents(ctx *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(ctx.Query("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(ctx, "invalid environment id")
+	}
+	events, err := c.auditService.GetEventsByEnvironmentId(ctx.Context(), environmentId)
+	if err != nil {
+		logger.L.Error("In GetEvents: Error getting audit events", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, events)
+}
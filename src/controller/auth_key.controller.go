@@ -6,11 +6,13 @@ import (
 	"github.com/SwishHQ/spread/types"
 	"github.com/SwishHQ/spread/utils"
 	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type AuthKeyController interface {
 	CreateAuthKey(c *fiber.Ctx) error
 	GetAllAuthKeys(c *fiber.Ctx) error
+	DeleteAuthKey(c *fiber.Ctx) error
 }
 
 type authKeyController struct {
@@ -43,3 +45,14 @@ func (c *authKeyController) GetAllAuthKeys(ctx *fiber.Ctx) error {
 	}
 	return utils.SuccessResponse(ctx, authKeys)
 }
+
+func (c *authKeyController) DeleteAuthKey(ctx *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(ctx.Params("id"))
+	if err != nil {
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	if err := c.authKeyService.DeleteAuthKey(ctx.Context(), id); err != nil {
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, nil)
+}
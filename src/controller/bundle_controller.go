@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/SwishHQ/spread/logger"
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/service"
@@ -18,14 +20,21 @@ type BundleController interface {
 	ToggleMandatory(c *fiber.Ctx) error
 	Rollback(c *fiber.Ctx) error
 	ToggleActive(c *fiber.Ctx) error
+	ScheduleBundle(c *fiber.Ctx) error
+	CancelSchedule(c *fiber.Ctx) error
+	UpdateTargeting(c *fiber.Ctx) error
+	UpdateDescription(c *fiber.Ctx) error
 }
 
 type bundleControllerImpl struct {
-	bundleService service.BundleService
+	bundleService          service.BundleService
+	releaseScheduleService service.ReleaseScheduleService
+	targetingService       service.TargetingService
+	userService            service.UserService
 }
 
-func NewBundleController(bundleService service.BundleService) BundleController {
-	return &bundleControllerImpl{bundleService: bundleService}
+func NewBundleController(bundleService service.BundleService, releaseScheduleService service.ReleaseScheduleService, targetingService service.TargetingService, userService service.UserService) BundleController {
+	return &bundleControllerImpl{bundleService: bundleService, releaseScheduleService: releaseScheduleService, targetingService: targetingService, userService: userService}
 }
 
 func (bundleController *bundleControllerImpl) UploadBundle(c *fiber.Ctx) error {
@@ -62,6 +71,17 @@ func (bundleController *bundleControllerImpl) CreateNewBundle(c *fiber.Ctx) erro
 	}
 	authKey := c.Locals("authKey").(*model.AuthKey)
 	createdBy := authKey.CreatedBy
+	if createNewBundleRequest.OverrideFreezeReason != "" {
+		// the key acts for the user who created it, so does the permission to override a freeze
+		keyUser, err := bundleController.userService.GetUserByUsername(c.Context(), createdBy)
+		if err != nil {
+			logger.L.Error("In CreateNewBundle: Error getting key user", zap.Error(err))
+			return utils.ErrorResponse(c, err.Error())
+		}
+		if keyUser == nil || !utils.HasRole(keyUser.Roles, utils.ROLE_ADMIN) {
+			return utils.ForbiddenResponse(c, "only admins can override a release freeze")
+		}
+	}
 	logger.L.Info("In CreateNewBundle: Creating new bundle", zap.Any("keyUser", createdBy), zap.Any("createNewBundleRequest", createNewBundleRequest))
 	bundle, err := bundleController.bundleService.CreateNewBundle(&createNewBundleRequest, createdBy)
 	if err != nil {
@@ -128,9 +148,111 @@ func (bundleController *bundleControllerImpl) ToggleActive(c *fiber.Ctx) error {
 	if err != nil {
 		return utils.ErrorResponse(c, err.Error())
 	}
-	err = bundleController.bundleService.ToggleActive(bundleIdPrimitive)
+	// the body is optional, it is only needed to override a freeze window
+	var toggleActiveRequest types.ToggleActiveRequest
+	if len(c.Body()) > 0 {
+		validationErrors := utils.BindAndValidate(c, &toggleActiveRequest)
+		if len(validationErrors) > 0 {
+			return utils.ValidationErrorResponse(c, validationErrors)
+		}
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, toggleActiveRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	bundle, err := bundleController.bundleService.ToggleActive(bundleIdPrimitive, user.Username, override)
 	if err != nil {
 		return utils.ErrorResponse(c, err.Error())
 	}
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (bundleController *bundleControllerImpl) ScheduleBundle(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var scheduleRequest types.ScheduleBundleRequest
+	validationErrors := utils.BindAndValidate(c, &scheduleRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, scheduleRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	bundle, err := bundleController.releaseScheduleService.ScheduleBundle(c.Context(), bundleId, scheduleRequest.ScheduledAt, override, user.Username)
+	if err != nil {
+		logger.L.Error("In ScheduleBundle: Error scheduling bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (bundleController *bundleControllerImpl) CancelSchedule(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	user := c.Locals("user").(*model.User)
+	if err := bundleController.releaseScheduleService.CancelSchedule(c.Context(), bundleId, user.Username); err != nil {
+		logger.L.Error("In CancelSchedule: Error cancelling schedule", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
 	return utils.SuccessResponse(c, nil)
 }
+
+func (bundleController *bundleControllerImpl) UpdateTargeting(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var targetingRequest types.UpdateTargetingRequest
+	validationErrors := utils.BindAndValidate(c, &targetingRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, targetingRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	bundle, err := bundleController.targetingService.UpdateTargeting(c.Context(), bundleId, &targetingRequest.TargetingRequest, user.Username, override)
+	if err != nil {
+		logger.L.Error("In UpdateTargeting: Error updating targeting", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In UpdateTargeting: Updated targeting", zap.String("bundleId", bundleId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (bundleController *bundleControllerImpl) UpdateDescription(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var descriptionRequest types.UpdateDescriptionRequest
+	validationErrors := utils.BindAndValidate(c, &descriptionRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	bundle, err := bundleController.bundleService.UpdateDescription(c.Context(), bundleId, descriptionRequest.Description)
+	if err != nil {
+		logger.L.Error("In UpdateDescription: Error updating description", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, bundle)
+}
+
+// freezeOverride returns nil without a reason, and an error when the user is not an admin
+func freezeOverride(user *model.User, reason string) (*types.FreezeOverride, error) {
+	if reason == "" {
+		return nil, nil
+	}
+	if !utils.HasRole(user.Roles, utils.ROLE_ADMIN) {
+		return nil, errors.New("only admins can override a release freeze")
+	}
+	return &types.FreezeOverride{By: user.Username, Reason: reason}, nil
+}
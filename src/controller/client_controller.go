@@ -1,6 +1,8 @@
 package controller
 
 import (
+	"strings"
+
 	"github.com/SwishHQ/spread/logger"
 	"github.com/SwishHQ/spread/src/service"
 	"github.com/SwishHQ/spread/types"
@@ -31,8 +33,20 @@ func (c *clientController) CheckUpdate(ctx *fiber.Ctx) error {
 	label := ctx.Query("label")
 	clientUniqueId := ctx.Query("client_unique_id")
 
+	// optional, sent by SDKs that support release targeting, tags are comma separated
+	device := &types.DeviceAttributes{
+		ClientUniqueId: clientUniqueId,
+		OsVersion:      ctx.Query("os_version"),
+		Locale:         ctx.Query("locale"),
+	}
+	for _, tag := range strings.Split(ctx.Query("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			device.Tags = append(device.Tags, tag)
+		}
+	}
+
 	logger.L.Info("In CheckUpdate", zap.String("environmentKey", environmentKey), zap.String("appVersion", appVersion), zap.String("bundleHash", bundleHash), zap.String("label", label), zap.String("clientUniqueId", clientUniqueId))
-	updateInfo, err := c.clientService.CheckUpdate(environmentKey, appVersion, bundleHash)
+	updateInfo, err := c.clientService.CheckUpdate(environmentKey, appVersion, bundleHash, label, device)
 	if err != nil {
 		logger.L.Error("In CheckUpdate: Error checking update", zap.Error(err))
 		// when update_info is nil, it means there is no update available
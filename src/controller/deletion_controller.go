@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// DeletionController serves the same three endpoints for apps, environments and versions,
+// the resource is fixed when the route is registered and the id is the :id param
+type DeletionController interface {
+	RequestDeletion(resource string) fiber.Handler
+	Delete(resource string) fiber.Handler
+	Restore(resource string) fiber.Handler
+	GetDeleted(c *fiber.Ctx) error
+}
+
+type deletionController struct {
+	deletionService service.DeletionService
+}
+
+func NewDeletionController(deletionService service.DeletionService) DeletionController {
+	return &deletionController{deletionService: deletionService}
+}
+
+func (controller *deletionController) RequestDeletion(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.ErrorResponse(c, err.Error())
+		}
+		confirmation, err := controller.deletionService.RequestDeletion(c.Context(), resource, id)
+		if err != nil {
+			logger.L.Error("In RequestDeletion: Error preparing deletion", zap.String("resource", resource), zap.Error(err))
+			return utils.ErrorResponse(c, err.Error())
+		}
+		return utils.SuccessResponse(c, confirmation)
+	}
+}
+
+// Delete expects the token from RequestDeletion in the confirmationToken query param
+func (controller *deletionController) Delete(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.ErrorResponse(c, err.Error())
+		}
+		user := c.Locals("user").(*model.User)
+		deleted, err := controller.deletionService.Delete(c.Context(), resource, id, c.Query("confirmationToken"), user.Username)
+		if err != nil {
+			logger.L.Error("In Delete: Error deleting", zap.String("resource", resource), zap.String("id", id.Hex()), zap.Error(err))
+			return utils.ErrorResponse(c, err.Error())
+		}
+		logger.L.Info("In Delete: Deleted", zap.String("resource", resource), zap.String("id", id.Hex()), zap.String("user", user.Username))
+		return utils.SuccessResponse(c, deleted)
+	}
+}
+
+func (controller *deletionController) Restore(resource string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, err := primitive.ObjectIDFromHex(c.Params("id"))
+		if err != nil {
+			return utils.ErrorResponse(c, err.Error())
+		}
+		user := c.Locals("user").(*model.User)
+		if err := controller.deletionService.Restore(c.Context(), resource, id, user.Username); err != nil {
+			logger.L.Error("In Restore: Error restoring", zap.String("resource", resource), zap.String("id", id.Hex()), zap.Error(err))
+			return utils.ErrorResponse(c, err.Error())
+		}
+		return utils.SuccessResponse(c, fiber.Map{"resource": resource, "id": id.Hex()})
+	}
+}
+
+func (controller *deletionController) GetDeleted(c *fiber.Ctx) error {
+	deleted, err := controller.deletionService.GetDeleted(c.Context())
+	if err != nil {
+		logger.L.Error("In GetDeleted: Error listing deleted resources", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, deleted)
+}
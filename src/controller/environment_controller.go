@@ -1,7 +1,11 @@
 package controller
 
 import (
+	"time"
+
+	"github.com/SwishHQ/spread/config"
 	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/service"
 	"github.com/SwishHQ/spread/types"
 	"github.com/SwishHQ/spread/utils"
@@ -13,6 +17,11 @@ import (
 type EnvironmentController interface {
 	CreateEnvironment(c *fiber.Ctx) error
 	GetAllEnvironmentsByAppId(c *fiber.Ctx) error
+	RotateEnvironmentKey(c *fiber.Ctx) error
+	UpdateRollbackPolicy(c *fiber.Ctx) error
+	RenameEnvironment(c *fiber.Ctx) error
+	UpdateFreezeWindows(c *fiber.Ctx) error
+	UpdateApprovalPolicy(c *fiber.Ctx) error
 }
 
 type environmentControllerImpl struct {
@@ -54,3 +63,121 @@ func (environmentController *environmentControllerImpl) GetAllEnvironmentsByAppI
 	}
 	return utils.SuccessResponse(c, environments)
 }
+
+func (environmentController *environmentControllerImpl) RotateEnvironmentKey(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var rotateRequest types.RotateEnvironmentKeyRequest
+	validationErrors := utils.BindAndValidate(c, &rotateRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	gracePeriod, err := time.ParseDuration(config.DeploymentKeyGracePeriod)
+	if err != nil {
+		logger.L.Error("In RotateEnvironmentKey: Invalid DEPLOYMENT_KEY_GRACE_PERIOD", zap.Error(err))
+		return utils.ErrorResponse(c, "Invalid deployment key grace period configuration")
+	}
+	if rotateRequest.GracePeriodHours != nil {
+		gracePeriod = time.Duration(*rotateRequest.GracePeriodHours) * time.Hour
+	}
+	environment, err := environmentController.environmentService.RotateEnvironmentKey(c.Context(), environmentId, gracePeriod)
+	if err != nil {
+		logger.L.Error("In RotateEnvironmentKey: Error rotating key", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In RotateEnvironmentKey: Deployment key rotated", zap.String("environmentId", environment.Id.Hex()), zap.Timep("previousKeyExpiresAt", environment.PreviousKeyExpiresAt))
+	return utils.SuccessResponse(c, fiber.Map{
+		"key":                  environment.Key,
+		"name":                 environment.Name,
+		"previousKeyExpiresAt": environment.PreviousKeyExpiresAt,
+	})
+}
+
+func (environmentController *environmentControllerImpl) UpdateRollbackPolicy(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var policyRequest types.UpdateRollbackPolicyRequest
+	validationErrors := utils.BindAndValidate(c, &policyRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	environment, err := environmentController.environmentService.UpdateRollbackPolicy(c.Context(), environmentId, &policyRequest)
+	if err != nil {
+		logger.L.Error("In UpdateRollbackPolicy: Error updating rollback policy", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, environment.RollbackPolicy)
+}
+
+func (environmentController *environmentControllerImpl) RenameEnvironment(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var renameRequest types.RenameEnvironmentRequest
+	validationErrors := utils.BindAndValidate(c, &renameRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	environment, err := environmentController.environmentService.RenameEnvironment(c.Context(), environmentId, renameRequest.EnvironmentName)
+	if err != nil {
+		logger.L.Error("In RenameEnvironment: Error renaming environment", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.Map{
+		"id":   environment.Id,
+		"name": environment.Name,
+	})
+}
+
+// UpdateFreezeWindows is for admins only, anyone else could lift a freeze by removing its window
+func (environmentController *environmentControllerImpl) UpdateFreezeWindows(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	user := c.Locals("user").(*model.User)
+	if !utils.HasRole(user.Roles, utils.ROLE_ADMIN) {
+		return utils.ForbiddenResponse(c, "only admins can change freeze windows")
+	}
+	var freezeWindowsRequest types.UpdateFreezeWindowsRequest
+	validationErrors := utils.BindAndValidate(c, &freezeWindowsRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	environment, err := environmentController.environmentService.UpdateFreezeWindows(c.Context(), environmentId, &freezeWindowsRequest)
+	if err != nil {
+		logger.L.Error("In UpdateFreezeWindows: Error updating freeze windows", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In UpdateFreezeWindows: Updated freeze windows", zap.String("environmentId", environmentId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, environment.FreezeWindows)
+}
+
+// UpdateApprovalPolicy is for admins only, the policy decides who can release to the environment
+func (environmentController *environmentControllerImpl) UpdateApprovalPolicy(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	user := c.Locals("user").(*model.User)
+	if !utils.HasRole(user.Roles, utils.ROLE_ADMIN) {
+		return utils.ForbiddenResponse(c, "only admins can change the approval policy")
+	}
+	var approvalPolicyRequest types.UpdateApprovalPolicyRequest
+	validationErrors := utils.BindAndValidate(c, &approvalPolicyRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	environment, err := environmentController.environmentService.UpdateApprovalPolicy(c.Context(), environmentId, &approvalPolicyRequest)
+	if err != nil {
+		logger.L.Error("In UpdateApprovalPolicy: Error updating approval policy", zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In UpdateApprovalPolicy: Updated approval policy", zap.String("environmentId", environmentId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, environment.ApprovalPolicy)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type ExperimentController interface {
+	StartExperiment(c *fiber.Ctx) error
+	GetExperiment(c *fiber.Ctx) error
+	GetExperimentsByEnvironmentId(c *fiber.Ctx) error
+	Conclude(c *fiber.Ctx) error
+	Stop(c *fiber.Ctx) error
+}
+
+type experimentControllerImpl struct {
+	experimentService service.ExperimentService
+}
+
+func NewExperimentController(experimentService service.ExperimentService) ExperimentController {
+	return &experimentControllerImpl{experimentService: experimentService}
+}
+
+func (experimentController *experimentControllerImpl) StartExperiment(c *fiber.Ctx) error {
+	var startRequest types.StartExperimentRequest
+	validationErrors := utils.BindAndValidate(c, &startRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, startRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	experiment, err := experimentController.experimentService.StartExperiment(c.Context(), &startRequest, user.Username, override)
+	if err != nil {
+		logger.L.Error("In StartExperiment: Error starting experiment", zap.String("versionId", startRequest.VersionId), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In StartExperiment: Experiment started", zap.String("experimentId", experiment.Id.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, experiment)
+}
+
+func (experimentController *experimentControllerImpl) GetExperiment(c *fiber.Ctx) error {
+	experimentId, err := primitive.ObjectIDFromHex(c.Params("experimentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	experiment, results, err := experimentController.experimentService.GetExperiment(c.Context(), experimentId)
+	if err != nil {
+		logger.L.Error("In GetExperiment: Error getting experiment", zap.String("experimentId", experimentId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, fiber.Map{"experiment": experiment, "variants": results})
+}
+
+func (experimentController *experimentControllerImpl) GetExperimentsByEnvironmentId(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	experiments, err := experimentController.experimentService.GetExperimentsByEnvironmentId(c.Context(), environmentId)
+	if err != nil {
+		logger.L.Error("In GetExperimentsByEnvironmentId: Error getting experiments", zap.String("environmentId", environmentId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, experiments)
+}
+
+func (experimentController *experimentControllerImpl) Conclude(c *fiber.Ctx) error {
+	experimentId, err := primitive.ObjectIDFromHex(c.Params("experimentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var concludeRequest types.ConcludeExperimentRequest
+	validationErrors := utils.BindAndValidate(c, &concludeRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	winnerBundleId, err := primitive.ObjectIDFromHex(concludeRequest.WinnerBundleId)
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, concludeRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	experiment, err := experimentController.experimentService.Conclude(c.Context(), experimentId, winnerBundleId, user.Username, override)
+	if err != nil {
+		logger.L.Error("In Conclude: Error concluding experiment", zap.String("experimentId", experimentId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In Conclude: Experiment concluded", zap.String("experimentId", experimentId.Hex()), zap.String("winnerBundleId", winnerBundleId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, experiment)
+}
+
+func (experimentController *experimentControllerImpl) Stop(c *fiber.Ctx) error {
+	experimentId, err := primitive.ObjectIDFromHex(c.Params("experimentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	user := c.Locals("user").(*model.User)
+	experiment, err := experimentController.experimentService.Stop(c.Context(), experimentId, user.Username)
+	if err != nil {
+		logger.L.Error("In Stop: Error stopping experiment", zap.String("experimentId", experimentId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In Stop: Experiment stopped", zap.String("experimentId", experimentId.Hex()), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, experiment)
+}
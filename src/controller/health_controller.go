@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type HealthController interface {
+	Liveness(c *fiber.Ctx) error
+	Readiness(c *fiber.Ctx) error
+}
+
+type healthController struct {
+	healthService service.HealthService
+}
+
+func NewHealthController(healthService service.HealthService) HealthController {
+	return &healthController{healthService: healthService}
+}
+
+// Liveness only says the process serves requests, a dependency being down must not get it restarted
+func (c *healthController) Liveness(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+}
+
+// Readiness answers 503 until every dependency is reachable and the migrations are applied
+func (c *healthController) Readiness(ctx *fiber.Ctx) error {
+	report, ready := c.healthService.Readiness(ctx.Context())
+	if !ready {
+		logger.L.Warn("In Readiness: Not ready", zap.Any("checks", report.Checks))
+		return ctx.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return ctx.Status(fiber.StatusOK).JSON(report)
+}
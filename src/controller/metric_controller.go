@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+type MetricController interface {
+	GetSeries(c *fiber.Ctx) error
+}
+
+type metricController struct {
+	metricService service.MetricService
+}
+
+func NewMetricController(metricService service.MetricService) MetricController {
+	return &metricController{metricService: metricService}
+}
+
+func (c *metricController) GetSeries(ctx *fiber.Ctx) error {
+	request := types.MetricSeriesRequest{}
+	if err := ctx.QueryParser(&request); err != nil {
+		logger.L.Error("In GetSeries: Error parsing query", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	if validationErrors := utils.ValidateStruct(&request); len(validationErrors) > 0 {
+		logger.L.Error("In GetSeries: Validation errors", zap.Any("validationErrors", validationErrors))
+		return utils.ErrorResponse(ctx, "environmentId is required and granularity must be hour or day")
+	}
+	series, err := c.metricService.GetSeries(ctx.Context(), &request)
+	if err != nil {
+		logger.L.Error("In GetSeries: Error getting metric series", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, series)
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+// the cookie tying a sign in to the browser that started it
+const oidcStateCookie = "spread_oidc_state"
+
+type OIDCController interface {
+	GetConfig(c *fiber.Ctx) error
+	Login(c *fiber.Ctx) error
+	Callback(c *fiber.Ctx) error
+}
+
+type oidcController struct {
+	oidcService service.OIDCService
+}
+
+func NewOIDCController(oidcService service.OIDCService) OIDCController {
+	return &oidcController{oidcService: oidcService}
+}
+
+// GetConfig tells the dashboard whether to offer single sign-on
+func (c *oidcController) GetConfig(ctx *fiber.Ctx) error {
+	return utils.SuccessResponse(ctx, fiber.Map{
+		"enabled": config.OIDCEnabled(),
+	})
+}
+
+func (c *oidcController) Login(ctx *fiber.Ctx) error {
+	authURL, state, err := c.oidcService.BeginLogin(ctx.Context())
+	if err != nil {
+		logger.L.Error("In Login: Error starting single sign-on", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	// Lax so the cookie comes along on the top level redirect back from the identity provider
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/oidc",
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+// Callback finishes the sign in and hands the tokens to the dashboard in the URL fragment,
+// fragments are never sent to servers so the tokens stay out of access logs
+func (c *oidcController) Callback(ctx *fiber.Ctx) error {
+	browserState := ctx.Cookies(oidcStateCookie)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/oidc",
+		Expires:  time.Unix(0, 0),
+		HTTPOnly: true,
+		Secure:   true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	if idpError := ctx.Query("error"); idpError != "" {
+		logger.L.Error("In Callback: Identity provider returned an error", zap.String("error", idpError), zap.String("description", ctx.Query("error_description")))
+		return c.redirectToDashboard(ctx, url.Values{"error": {"Single sign-on was cancelled or denied"}})
+	}
+	tokens, err := c.oidcService.CompleteLogin(ctx.Context(), ctx.Query("state"), browserState, ctx.Query("code"))
+	if err != nil {
+		logger.L.Error("In Callback: Error completing single sign-on", zap.Error(err))
+		return c.redirectToDashboard(ctx, url.Values{"error": {err.Error()}})
+	}
+	return c.redirectToDashboard(ctx, url.Values{
+		"access_token":  {tokens.AccessToken},
+		"refresh_token": {tokens.RefreshToken},
+		"expires_in":    {strconv.FormatInt(tokens.ExpiresIn, 10)},
+	})
+}
+
+func (c *oidcController) redirectToDashboard(ctx *fiber.Ctx, fragment url.Values) error {
+	return ctx.Redirect(config.OIDCDashboardRedirectURL+"#"+fragment.Encode(), fiber.StatusFound)
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type PromotionController interface {
+	Promote(c *fiber.Ctx) error
+}
+
+type promotionControllerImpl struct {
+	promotionService service.PromotionService
+}
+
+func NewPromotionController(promotionService service.PromotionService) PromotionController {
+	return &promotionControllerImpl{promotionService: promotionService}
+}
+
+func (promotionController *promotionControllerImpl) Promote(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var promoteRequest types.PromoteBundleRequest
+	validationErrors := utils.BindAndValidate(c, &promoteRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	user := c.Locals("user").(*model.User)
+	override, err := freezeOverride(user, promoteRequest.OverrideFreezeReason)
+	if err != nil {
+		return utils.ForbiddenResponse(c, err.Error())
+	}
+	bundle, err := promotionController.promotionService.Promote(c.Context(), bundleId, &promoteRequest, user.Username, override)
+	if err != nil {
+		logger.L.Error("In Promote: Error promoting bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In Promote: Promoted bundle", zap.String("bundleId", bundleId.Hex()), zap.String("label", bundle.Label), zap.String("user", user.Username))
+	return utils.SuccessResponse(c, bundle)
+}
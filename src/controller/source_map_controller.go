@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"io"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type SourceMapController interface {
+	UploadSourceMap(c *fiber.Ctx) error
+	Symbolicate(c *fiber.Ctx) error
+}
+
+type sourceMapControllerImpl struct {
+	sourceMapService service.SourceMapService
+}
+
+func NewSourceMapController(sourceMapService service.SourceMapService) SourceMapController {
+	return &sourceMapControllerImpl{sourceMapService: sourceMapService}
+}
+
+func (sourceMapController *sourceMapControllerImpl) UploadSourceMap(c *fiber.Ctx) error {
+	bundleId, err := primitive.ObjectIDFromHex(c.Params("bundleId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	uploadedFile, err := c.FormFile("file")
+	if err != nil {
+		logger.L.Error("In UploadSourceMap: no file found", zap.Error(err))
+		return utils.ErrorResponse(c, "No file found")
+	}
+	file, err := uploadedFile.Open()
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	bundle, err := sourceMapController.sourceMapService.UploadSourceMap(c.Context(), bundleId, content)
+	if err != nil {
+		logger.L.Error("In UploadSourceMap: Error uploading source map", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	logger.L.Info("In UploadSourceMap: Source map uploaded", zap.String("bundleId", bundleId.Hex()), zap.String("sourceMapFile", bundle.SourceMapFile))
+	return utils.SuccessResponse(c, bundle)
+}
+
+func (sourceMapController *sourceMapControllerImpl) Symbolicate(c *fiber.Ctx) error {
+	environmentId, err := primitive.ObjectIDFromHex(c.Params("environmentId"))
+	if err != nil {
+		return utils.ErrorResponse(c, err.Error())
+	}
+	var symbolicateRequest types.SymbolicateRequest
+	validationErrors := utils.BindAndValidate(c, &symbolicateRequest)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(c, validationErrors)
+	}
+	response, err := sourceMapController.sourceMapService.Symbolicate(c.Context(), environmentId, &symbolicateRequest)
+	if err != nil {
+		logger.L.Error("In Symbolicate: Error symbolicating stack trace", zap.String("environmentId", environmentId.Hex()), zap.String("label", symbolicateRequest.Label), zap.Error(err))
+		return utils.ErrorResponse(c, err.Error())
+	}
+	return utils.SuccessResponse(c, response)
+}
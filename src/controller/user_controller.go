@@ -1,7 +1,11 @@
 package controller
 
 import (
+	"errors"
+
 	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/service"
 	"github.com/SwishHQ/spread/types"
 	"github.com/SwishHQ/spread/utils"
@@ -12,20 +16,30 @@ import (
 type UserController interface {
 	CreateUser(c *fiber.Ctx) error
 	LoginUser(c *fiber.Ctx) error
+	RefreshToken(c *fiber.Ctx) error
+	Logout(c *fiber.Ctx) error
 	GetUser(c *fiber.Ctx) error
 	SetupStatus(c *fiber.Ctx) error
 	InitUser(c *fiber.Ctx) error
 }
 
 type userController struct {
-	userService service.UserService
+	userService         service.UserService
+	loginAttemptService service.LoginAttemptService
 }
 
-func NewUserController(userService service.UserService) UserController {
-	return &userController{userService: userService}
+func NewUserController(userService service.UserService, loginAttemptService service.LoginAttemptService) UserController {
+	return &userController{userService: userService, loginAttemptService: loginAttemptService}
 }
 
+// CreateUser is for admins only. Roles decide who approves releases and overrides freezes, a user
+// who could create accounts could give a second account admin and approve their own release
 func (c *userController) CreateUser(ctx *fiber.Ctx) error {
+	creator := ctx.Locals("user").(*model.User)
+	if !utils.HasRole(creator.Roles, utils.ROLE_ADMIN) {
+		logger.L.Warn("In CreateUser: Non admin tried to create a user", zap.String("user", creator.Username))
+		return utils.ForbiddenResponse(ctx, "only admins can create users")
+	}
 	user := types.CreateUserRequest{}
 	validationErrors := utils.BindAndValidate(ctx, &user)
 	if len(validationErrors) > 0 {
@@ -60,14 +74,63 @@ func (c *userController) LoginUser(ctx *fiber.Ctx) error {
 		logger.L.Error("In LoginUser: Validation errors", zap.Any("validationErrors", validationErrors))
 		return utils.ValidationErrorResponse(ctx, validationErrors)
 	}
-	accessToken, err := c.userService.Login(&user)
+	lockedFor, err := c.loginAttemptService.LockedFor(ctx.Context(), user.Username)
+	if err != nil {
+		// the lockout is best effort, an unavailable store must not stop every sign in
+		logger.L.Error("In LoginUser: Error checking login lockout", zap.Error(err))
+	}
+	if lockedFor > 0 {
+		pkg.RateLimitedTotal.WithLabelValues("login_lockout").Inc()
+		return utils.TooManyRequestsResponse(ctx, "Too many failed sign in attempts, try again later", lockedFor)
+	}
+	tokens, err := c.userService.Login(&user)
 	if err != nil {
 		logger.L.Error("In LoginUser: Error logging in", zap.Error(err))
+		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrInvalidPassword) {
+			lockedFor, lockoutErr := c.loginAttemptService.RecordFailure(ctx.Context(), user.Username)
+			if lockoutErr != nil {
+				logger.L.Error("In LoginUser: Error recording failed sign in", zap.Error(lockoutErr))
+			}
+			if lockedFor > 0 {
+				logger.L.Warn("In LoginUser: Username locked out after repeated failed sign ins", zap.String("username", user.Username))
+			}
+		}
 		return utils.ErrorResponse(ctx, err.Error())
 	}
-	return utils.SuccessResponse(ctx, fiber.Map{
-		"access_token": *accessToken,
-	})
+	if err := c.loginAttemptService.RecordSuccess(ctx.Context(), user.Username); err != nil {
+		logger.L.Error("In LoginUser: Error clearing failed sign ins", zap.Error(err))
+	}
+	return utils.SuccessResponse(ctx, tokens)
+}
+
+func (c *userController) RefreshToken(ctx *fiber.Ctx) error {
+	request := types.RefreshTokenRequest{}
+	validationErrors := utils.BindAndValidate(ctx, &request)
+	if len(validationErrors) > 0 {
+		logger.L.Error("In RefreshToken: Validation errors", zap.Any("validationErrors", validationErrors))
+		return utils.ValidationErrorResponse(ctx, validationErrors)
+	}
+	tokens, err := c.userService.RefreshToken(request.RefreshToken)
+	if err != nil {
+		logger.L.Error("In RefreshToken: Error refreshing token", zap.Error(err))
+		return utils.UnauthorizedResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, tokens)
+}
+
+func (c *userController) Logout(ctx *fiber.Ctx) error {
+	request := types.RefreshTokenRequest{}
+	validationErrors := utils.BindAndValidate(ctx, &request)
+	if len(validationErrors) > 0 {
+		logger.L.Error("In Logout: Validation errors", zap.Any("validationErrors", validationErrors))
+		return utils.ValidationErrorResponse(ctx, validationErrors)
+	}
+	err := c.userService.Logout(request.RefreshToken)
+	if err != nil {
+		logger.L.Error("In Logout: Error revoking refresh token", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, nil)
 }
 
 func (c *userController) SetupStatus(ctx *fiber.Ctx) error {
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/service"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+type WebhookController interface {
+	CreateWebhook(c *fiber.Ctx) error
+	GetWebhooks(c *fiber.Ctx) error
+	UpdateWebhook(c *fiber.Ctx) error
+	DeleteWebhook(c *fiber.Ctx) error
+	GetDeliveries(c *fiber.Ctx) error
+}
+
+type webhookController struct {
+	webhookService service.WebhookService
+}
+
+func NewWebhookController(webhookService service.WebhookService) WebhookController {
+	return &webhookController{webhookService: webhookService}
+}
+
+// CreateWebhook is the only response that includes the secret
+func (c *webhookController) CreateWebhook(ctx *fiber.Ctx) error {
+	request := types.CreateWebhookRequest{}
+	validationErrors := utils.BindAndValidate(ctx, &request)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(ctx, validationErrors)
+	}
+	user := ctx.Locals("user").(*model.User)
+	webhook, err := c.webhookService.CreateWebhook(ctx.Context(), ctx.Params("appId"), &request, user.Username)
+	if err != nil {
+		logger.L.Error("In CreateWebhook: Error creating webhook", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, fiber.Map{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+func (c *webhookController) GetWebhooks(ctx *fiber.Ctx) error {
+	webhooks, err := c.webhookService.GetWebhooksByAppId(ctx.Context(), ctx.Params("appId"))
+	if err != nil {
+		logger.L.Error("In GetWebhooks: Error getting webhooks", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, webhooks)
+}
+
+func (c *webhookController) UpdateWebhook(ctx *fiber.Ctx) error {
+	webhookId, err := primitive.ObjectIDFromHex(ctx.Params("webhookId"))
+	if err != nil {
+		return utils.ErrorResponse(ctx, "invalid webhook id")
+	}
+	request := types.UpdateWebhookRequest{}
+	validationErrors := utils.BindAndValidate(ctx, &request)
+	if len(validationErrors) > 0 {
+		return utils.ValidationErrorResponse(ctx, validationErrors)
+	}
+	webhook, err := c.webhookService.UpdateWebhook(ctx.Context(), webhookId, &request)
+	if err != nil {
+		logger.L.Error("In UpdateWebhook: Error updating webhook", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, webhook)
+}
+
+func (c *webhookController) DeleteWebhook(ctx *fiber.Ctx) error {
+	webhookId, err := primitive.ObjectIDFromHex(ctx.Params("webhookId"))
+	if err != nil {
+		return utils.ErrorResponse(ctx, "invalid webhook id")
+	}
+	if err := c.webhookService.DeleteWebhook(ctx.Context(), webhookId); err != nil {
+		logger.L.Error("In DeleteWebhook: Error deleting webhook", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, nil)
+}
+
+func (c *webhookController) GetDeliveries(ctx *fiber.Ctx) error {
+	webhookId, err := primitive.ObjectIDFromHex(ctx.Params("webhookId"))
+	if err != nil {
+		return utils.ErrorResponse(ctx, "invalid webhook id")
+	}
+	deliveries, err := c.webhookService.GetDeliveries(ctx.Context(), webhookId)
+	if err != nil {
+		logger.L.Error("In GetDeliveries: Error getting webhook deliveries", zap.Error(err))
+		return utils.ErrorResponse(ctx, err.Error())
+	}
+	return utils.SuccessResponse(ctx, deliveries)
+}
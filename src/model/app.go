@@ -12,4 +12,6 @@ type App struct {
 	OS        string             `json:"os" bson:"os"`
 	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
 	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	// set when the app was deleted, it is purged once DELETION_RETENTION has passed
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
@@ -0,0 +1,19 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditEvent records a change made to a release, by a user or by spread itself
+type AuditEvent struct {
+	Id            primitive.ObjectID     `json:"id" bson:"_id,omitempty"`
+	Action        string                 `json:"action" bson:"action"`
+	Actor         string                 `json:"actor" bson:"actor"`
+	AppId         primitive.ObjectID     `json:"appId,omitempty" bson:"appId,omitempty"`
+	EnvironmentId primitive.ObjectID     `json:"environmentId,omitempty" bson:"environmentId,omitempty"`
+	BundleId      primitive.ObjectID     `json:"bundleId,omitempty" bson:"bundleId,omitempty"`
+	Details       map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt" bson:"createdAt"`
+}
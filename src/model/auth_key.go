@@ -7,11 +7,15 @@ import (
 )
 
 type AuthKey struct {
-	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name"`
-	Key       string             `json:"key" bson:"key"`
-	IsValid   bool               `json:"isValid" bson:"isValid,default:true"`
-	CreatedBy string             `json:"createdBy" bson:"createdBy"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	Id   primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name string             `json:"name" bson:"name"`
+	// Key only holds the plaintext key on records created before keys were hashed,
+	// it is removed by the hash_auth_keys migration and never serialized
+	Key       string    `json:"-" bson:"key,omitempty"`
+	KeyHash   string    `json:"-" bson:"keyHash"`
+	Prefix    string    `json:"prefix" bson:"prefix"`
+	IsValid   bool      `json:"isValid" bson:"isValid,default:true"`
+	CreatedBy string    `json:"createdBy" bson:"createdBy"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updatedAt"`
 }
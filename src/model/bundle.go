@@ -23,6 +23,47 @@ type Bundle struct {
 	Label         string             `json:"label" bson:"label"`
 	IsValid       bool               `json:"isValid" bson:"isValid" default:"true"`
 	CreatedBy     string             `json:"createdBy" bson:"createdBy"`
-	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+	// storage key of the source map crash reports of the bundle are symbolicated with
+	SourceMapFile string `json:"sourceMapFile,omitempty" bson:"sourceMapFile,omitempty"`
+	// set while the bundle waits to be released, the scheduler makes it current at this time
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty" bson:"scheduledAt,omitempty"`
+	// why an admin released the bundle during a freeze window, a scheduled release with one is
+	// not held back by freeze windows
+	FreezeOverrideReason string `json:"freezeOverrideReason,omitempty" bson:"freezeOverrideReason,omitempty"`
+	// set for releases to an environment with an approval policy, the bundle is not made current
+	// while it is pending or once rejected
+	Approval *Approval `json:"approval,omitempty" bson:"approval,omitempty"`
+	// limits which devices get the bundle, the others get the newest earlier bundle they match
+	Targeting *Targeting `json:"targeting,omitempty" bson:"targeting,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt" bson:"updatedAt"`
+}
+
+type Approval struct {
+	Status      string            `json:"status" bson:"status"`
+	RequestedBy string            `json:"requestedBy" bson:"requestedBy"`
+	RequestedAt time.Time         `json:"requestedAt" bson:"requestedAt"`
+	DecidedBy   string            `json:"decidedBy,omitempty" bson:"decidedBy,omitempty"`
+	DecidedAt   *time.Time        `json:"decidedAt,omitempty" bson:"decidedAt,omitempty"`
+	Comments    []ApprovalComment `json:"comments,omitempty" bson:"comments,omitempty"`
+}
+
+type ApprovalComment struct {
+	By        string    `json:"by" bson:"by"`
+	Text      string    `json:"text" bson:"text"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Targeting lets a device have the bundle when its client unique id is in the allowlist, or when
+// there are rules and it matches all of them
+type Targeting struct {
+	Allowlist []string        `json:"allowlist,omitempty" bson:"allowlist,omitempty"`
+	Rules     []TargetingRule `json:"rules,omitempty" bson:"rules,omitempty"`
+}
+
+// TargetingRule compares an attribute the SDK sends on update checks with the values
+type TargetingRule struct {
+	Attribute string   `json:"attribute" bson:"attribute"`
+	Operator  string   `json:"operator" bson:"operator"`
+	Values    []string `json:"values" bson:"values"`
 }
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BundleMetric holds what devices reported for a bundle during one hour or day
+type BundleMetric struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BundleId      primitive.ObjectID `json:"bundleId" bson:"bundleId"`
+	EnvironmentId primitive.ObjectID `json:"environmentId" bson:"environmentId"`
+	Label         string             `json:"label" bson:"label"`
+	Granularity   string             `json:"granularity" bson:"granularity"`
+	BucketStart   time.Time          `json:"bucketStart" bson:"bucketStart"`
+	Downloads     int                `json:"downloads" bson:"downloads"`
+	Installs      int                `json:"installs" bson:"installs"`
+	Failures      int                `json:"failures" bson:"failures"`
+	Rollbacks     int                `json:"rollbacks" bson:"rollbacks"`
+	// the active count of the bundle at its last change in the bucket, nil when it did not change
+	ActiveDevices *int      `json:"activeDevices,omitempty" bson:"activeDevices,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
+}
@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device is the last known state of one installation of an app in an environment,
+// bundle counters only move when this state changes so SDK retries are not counted twice
+type Device struct {
+	Id               primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	EnvironmentId    primitive.ObjectID `json:"environmentId" bson:"environmentId"`
+	ClientUniqueId   string             `json:"clientUniqueId" bson:"clientUniqueId"`
+	AppVersion       string             `json:"appVersion" bson:"appVersion"`
+	CurrentLabel     string             `json:"currentLabel" bson:"currentLabel,omitempty"`
+	CurrentBundleId  primitive.ObjectID `json:"currentBundleId" bson:"currentBundleId,omitempty"`
+	PreviousLabel    string             `json:"previousLabel" bson:"previousLabel,omitempty"`
+	PreviousBundleId primitive.ObjectID `json:"previousBundleId" bson:"previousBundleId,omitempty"`
+	DownloadedLabel  string             `json:"downloadedLabel" bson:"downloadedLabel,omitempty"`
+	LastLabel        string             `json:"lastLabel" bson:"lastLabel,omitempty"`
+	Status           string             `json:"status" bson:"status,omitempty"`
+	ReportedAt       time.Time          `json:"reportedAt" bson:"reportedAt,omitempty"`
+	CreatedAt        time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt        time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
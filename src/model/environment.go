@@ -7,10 +7,46 @@ import (
 )
 
 type Environment struct {
-	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	AppId     primitive.ObjectID `json:"appId" bson:"appId"`
-	Name      string             `json:"name" bson:"name"`
-	Key       string             `json:"key" bson:"key"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	Id    primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AppId primitive.ObjectID `json:"appId" bson:"appId"`
+	Name  string             `json:"name" bson:"name"`
+	Key   string             `json:"key" bson:"key"`
+	// after a key rotation the previous key keeps working until PreviousKeyExpiresAt
+	PreviousKey          string     `json:"previousKey,omitempty" bson:"previousKey,omitempty"`
+	PreviousKeyExpiresAt *time.Time `json:"previousKeyExpiresAt,omitempty" bson:"previousKeyExpiresAt,omitempty"`
+	// disables a bundle on its own when too many devices fail to install it
+	RollbackPolicy *RollbackPolicy `json:"rollbackPolicy,omitempty" bson:"rollbackPolicy,omitempty"`
+	// weekly periods in which nothing is released to the environment without an admin override
+	FreezeWindows []FreezeWindow `json:"freezeWindows,omitempty" bson:"freezeWindows,omitempty"`
+	// holds new releases until a second user approves them
+	ApprovalPolicy *ApprovalPolicy `json:"approvalPolicy,omitempty" bson:"approvalPolicy,omitempty"`
+	UpdatedAt      time.Time       `json:"updatedAt" bson:"updatedAt"`
+	CreatedAt      time.Time       `json:"createdAt" bson:"createdAt"`
+	// set when the environment or its app was deleted, restoring the app restores it
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// RollbackPolicy rolls a bundle back when, within the last WindowMinutes, at least MinInstalls
+// devices tried to install it and more than MaxFailureRate percent of them failed
+type RollbackPolicy struct {
+	Enabled        bool    `json:"enabled" bson:"enabled"`
+	MaxFailureRate float64 `json:"maxFailureRate" bson:"maxFailureRate"`
+	MinInstalls    int64   `json:"minInstalls" bson:"minInstalls"`
+	WindowMinutes  int     `json:"windowMinutes" bson:"windowMinutes"`
+}
+
+// FreezeWindow repeats every week from Start to End, both a day and a time such as "Fri 16:00",
+// in Timezone. A window that ends before it starts runs over the weekend into the next week
+type FreezeWindow struct {
+	Name     string `json:"name" bson:"name"`
+	Start    string `json:"start" bson:"start"`
+	End      string `json:"end" bson:"end"`
+	Timezone string `json:"timezone" bson:"timezone"`
+}
+
+// ApprovalPolicy makes a release wait until a user with ApproverRole, other than the one who
+// released it, approves it
+type ApprovalPolicy struct {
+	Required     bool   `json:"required" bson:"required"`
+	ApproverRole string `json:"approverRole" bson:"approverRole"`
 }
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Experiment serves several bundles of a version at once, every device is put in the cohort of one
+// variant by its client unique id
+type Experiment struct {
+	Id            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	AppId         primitive.ObjectID  `json:"appId" bson:"appId"`
+	EnvironmentId primitive.ObjectID  `json:"environmentId" bson:"environmentId"`
+	VersionId     primitive.ObjectID  `json:"versionId" bson:"versionId"`
+	Name          string              `json:"name" bson:"name"`
+	Variants      []ExperimentVariant `json:"variants" bson:"variants"`
+	Status        string              `json:"status" bson:"status"`
+	// the bundle made current for every device when the experiment was concluded
+	WinnerBundleId primitive.ObjectID `json:"winnerBundleId,omitempty" bson:"winnerBundleId,omitempty"`
+	CreatedBy      string             `json:"createdBy" bson:"createdBy"`
+	EndedBy        string             `json:"endedBy,omitempty" bson:"endedBy,omitempty"`
+	EndedAt        *time.Time         `json:"endedAt,omitempty" bson:"endedAt,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ExperimentVariant is a bundle and the percentage of devices that get it
+type ExperimentVariant struct {
+	BundleId primitive.ObjectID `json:"bundleId" bson:"bundleId"`
+	Label    string             `json:"label" bson:"label"`
+	Weight   int                `json:"weight" bson:"weight"`
+}
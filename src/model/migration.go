@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Migration struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	AppliedAt time.Time          `json:"appliedAt" bson:"appliedAt"`
+}
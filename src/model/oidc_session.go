@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OIDCSession holds the state of a single sign-on attempt between the redirect to the
+// identity provider and its callback
+type OIDCSession struct {
+	Id           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	State        string             `json:"-" bson:"state"`
+	Nonce        string             `json:"-" bson:"nonce"`
+	CodeVerifier string             `json:"-" bson:"codeVerifier"`
+	ExpiresAt    time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+}
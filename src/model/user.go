@@ -7,11 +7,12 @@ import (
 )
 
 type User struct {
-	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Username  string             `json:"username" bson:"username"`
-	Password  string             `json:"password" bson:"password"`
-	Roles     []string           `json:"roles" bson:"roles"`
-	IsValid   bool               `json:"isValid" bson:"isValid" default:"true"`
-	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
-	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+	Id          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username    string             `json:"username" bson:"username"`
+	Password    string             `json:"password" bson:"password"`
+	Roles       []string           `json:"roles" bson:"roles"`
+	IsValid     bool               `json:"isValid" bson:"isValid" default:"true"`
+	OIDCSubject string             `json:"-" bson:"oidcSubject,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
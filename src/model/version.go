@@ -12,6 +12,10 @@ type Version struct {
 	AppVersion      string             `json:"appVersion" bson:"appVersion"`
 	VersionNumber   int64              `json:"versionNumber" bson:"versionNumber"`
 	CurrentBundleId primitive.ObjectID `json:"currentBundleId" bson:"currentBundleId"`
-	UpdatedAt       time.Time          `json:"updatedAt" bson:"updatedAt"`
-	CreatedAt       time.Time          `json:"createdAt" bson:"createdAt"`
+	// set while an experiment runs, its variants are served instead of the current bundle
+	ExperimentId primitive.ObjectID `json:"experimentId,omitempty" bson:"experimentId,omitempty"`
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	// set when the version, its environment or its app was deleted
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
 }
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Webhook subscribes a URL to release events of an app, an empty Events list receives every event
+type Webhook struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	AppId     primitive.ObjectID `json:"appId" bson:"appId"`
+	Url       string             `json:"url" bson:"url"`
+	Secret    string             `json:"-" bson:"secret"`
+	Events    []string           `json:"events" bson:"events"`
+	Enabled   bool               `json:"enabled" bson:"enabled"`
+	CreatedBy string             `json:"createdBy" bson:"createdBy"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// WebhookDelivery is one event sent to one webhook, the payload is stored so every attempt sends the same bytes
+type WebhookDelivery struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	WebhookId      primitive.ObjectID `json:"webhookId" bson:"webhookId"`
+	AppId          primitive.ObjectID `json:"appId" bson:"appId"`
+	Event          string             `json:"event" bson:"event"`
+	Payload        string             `json:"payload" bson:"payload"`
+	Status         string             `json:"status" bson:"status"`
+	Attempts       int                `json:"attempts" bson:"attempts"`
+	ResponseStatus int                `json:"responseStatus,omitempty" bson:"responseStatus,omitempty"`
+	Error          string             `json:"error,omitempty" bson:"error,omitempty"`
+	NextAttemptAt  time.Time          `json:"nextAttemptAt" bson:"nextAttemptAt"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
@@ -2,11 +2,13 @@ package repository
 
 import (
 	"context"
+	"time"
 
 	"github.com/SwishHQ/spread/src/model"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type AppRepository interface {
@@ -14,6 +16,12 @@ type AppRepository interface {
 	GetByName(ctx context.Context, name string) (*model.App, error)
 	GetAll(ctx context.Context) ([]*model.App, error)
 	GetById(ctx context.Context, id primitive.ObjectID) (*model.App, error)
+	UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.App, error)
+	MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error)
+	GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.App, error)
+	GetAllDeleted(ctx context.Context, before time.Time) ([]*model.App, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
 }
 
 type appRepositoryImpl struct {
@@ -36,7 +44,7 @@ func (appRepository *appRepositoryImpl) Insert(ctx context.Context, app *model.A
 func (appRepository *appRepositoryImpl) GetByName(ctx context.Context, name string) (*model.App, error) {
 	collection := appRepository.db.Collection("apps")
 	var app model.App
-	err := collection.FindOne(ctx, bson.M{"name": name}).Decode(&app)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"name": name})).Decode(&app)
 	if err != nil {
 		return nil, err
 	}
@@ -46,7 +54,7 @@ func (appRepository *appRepositoryImpl) GetByName(ctx context.Context, name stri
 func (appRepository *appRepositoryImpl) GetAll(ctx context.Context) ([]*model.App, error) {
 	collection := appRepository.db.Collection("apps")
 	apps := make([]*model.App, 0)
-	cursor, err := collection.Find(ctx, bson.M{})
+	cursor, err := collection.Find(ctx, notDeleted(bson.M{}))
 	if err != nil {
 		return nil, err
 	}
@@ -59,7 +67,7 @@ func (appRepository *appRepositoryImpl) GetAll(ctx context.Context) ([]*model.Ap
 func (appRepository *appRepositoryImpl) GetById(ctx context.Context, id primitive.ObjectID) (*model.App, error) {
 	collection := appRepository.db.Collection("apps")
 	var app model.App
-	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&app)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&app)
 	if err == mongo.ErrNoDocuments {
 		return nil, nil
 	}
@@ -68,3 +76,41 @@ func (appRepository *appRepositoryImpl) GetById(ctx context.Context, id primitiv
 	}
 	return &app, nil
 }
+
+func (appRepository *appRepositoryImpl) UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.App, error) {
+	collection := appRepository.db.Collection("apps")
+	update := bson.M{"$set": bson.M{"name": name, "updatedAt": time.Now()}}
+	var app model.App
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&app)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// MarkDeleted soft deletes the app, false when it was already deleted
+func (appRepository *appRepositoryImpl) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	marked, err := markDeleted(ctx, appRepository.db.Collection("apps"), bson.M{"_id": id}, at)
+	return marked > 0, err
+}
+
+func (appRepository *appRepositoryImpl) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	restored, err := restoreDeleted(ctx, appRepository.db.Collection("apps"), bson.M{"_id": id}, deletedAt)
+	return restored > 0, err
+}
+
+func (appRepository *appRepositoryImpl) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.App, error) {
+	return findOneDeleted[model.App](ctx, appRepository.db.Collection("apps"), bson.M{"_id": id})
+}
+
+func (appRepository *appRepositoryImpl) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.App, error) {
+	return findDeleted[model.App](ctx, appRepository.db.Collection("apps"), bson.M{}, before)
+}
+
+func (appRepository *appRepositoryImpl) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := appRepository.db.Collection("apps").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
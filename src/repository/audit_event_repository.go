@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type AuditEventRepository interface {
+	Insert(ctx context.Context, event *model.AuditEvent) (*model.AuditEvent, error)
+	GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID, limit int64) ([]*model.AuditEvent, error)
+}
+
+type auditEventRepository struct {
+	Connection *mongo.Database
+}
+
+func NewAuditEventRepository(db *mongo.Database) AuditEventRepository {
+	return &auditEventRepository{Connection: db}
+}
+
+func (r *auditEventRepository) Insert(ctx context.Context, event *model.AuditEvent) (*model.AuditEvent, error) {
+	event.CreatedAt = time.Now()
+	collection := r.Connection.Collection("audit_events")
+	insertedEvent, err := collection.InsertOne(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	event.Id = insertedEvent.InsertedID.(primitive.ObjectID)
+	return event, nil
+}
+
+// GetAllByEnvironmentId returns the newest events first
+func (r *auditEventRepository) GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID, limit int64) ([]*model.AuditEvent, error) {
+	collection := r.Connection.Collection("audit_events")
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{"environmentId": environmentId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []*model.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -12,8 +12,11 @@ import (
 
 type AuthKeyRepository interface {
 	Insert(authKey *model.AuthKey) (*model.AuthKey, error)
-	GetById(key string) (*model.AuthKey, error)
+	GetByKeyHash(keyHash string) (*model.AuthKey, error)
 	GetAll(ctx context.Context) ([]*model.AuthKey, error)
+	GetAllWithPlaintextKey(ctx context.Context) ([]*model.AuthKey, error)
+	UpdateKeyHash(ctx context.Context, id primitive.ObjectID, keyHash string, prefix string) error
+	Delete(ctx context.Context, id primitive.ObjectID) (bool, error)
 }
 
 type authKeyRepository struct {
@@ -36,10 +39,10 @@ func (r *authKeyRepository) Insert(authKey *model.AuthKey) (*model.AuthKey, erro
 	return authKey, nil
 }
 
-func (r *authKeyRepository) GetById(key string) (*model.AuthKey, error) {
+func (r *authKeyRepository) GetByKeyHash(keyHash string) (*model.AuthKey, error) {
 	collection := r.Connection.Collection("auth_keys")
 	var authKey model.AuthKey
-	err := collection.FindOne(context.Background(), bson.M{"key": key}).Decode(&authKey)
+	err := collection.FindOne(context.Background(), bson.M{"keyHash": keyHash}).Decode(&authKey)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -63,3 +66,41 @@ func (r *authKeyRepository) GetAll(ctx context.Context) ([]*model.AuthKey, error
 	}
 	return authKeys, nil
 }
+
+// GetAllWithPlaintextKey returns the auth keys which were stored before keys were hashed
+func (r *authKeyRepository) GetAllWithPlaintextKey(ctx context.Context) ([]*model.AuthKey, error) {
+	collection := r.Connection.Collection("auth_keys")
+	cursor, err := collection.Find(ctx, bson.M{"key": bson.M{"$exists": true, "$ne": ""}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var authKeys []*model.AuthKey
+	if err = cursor.All(ctx, &authKeys); err != nil {
+		return nil, err
+	}
+	return authKeys, nil
+}
+
+// UpdateKeyHash stores the hash and prefix of a key and drops the plaintext key
+func (r *authKeyRepository) UpdateKeyHash(ctx context.Context, id primitive.ObjectID, keyHash string, prefix string) error {
+	collection := r.Connection.Collection("auth_keys")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"keyHash": keyHash, "prefix": prefix, "updatedAt": time.Now()},
+		"$unset": bson.M{"key": ""},
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *authKeyRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := r.Connection.Collection("auth_keys")
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
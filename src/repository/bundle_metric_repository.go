@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BundleMetricRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	Increment(ctx context.Context, bundle *model.Bundle, metric string, at time.Time) error
+	SetActiveDevices(ctx context.Context, bundle *model.Bundle, activeDevices int, at time.Time) error
+	GetSeries(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, from time.Time, to time.Time) ([]*model.BundleMetric, error)
+	GetLastActiveDevices(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, before time.Time) ([]*model.BundleMetric, error)
+	DeleteByBundleIds(ctx context.Context, bundleIds []primitive.ObjectID) error
+	DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error
+}
+
+type bundleMetricRepository struct {
+	Connection *mongo.Database
+}
+
+func NewBundleMetricRepository(db *mongo.Database) BundleMetricRepository {
+	return &bundleMetricRepository{Connection: db}
+}
+
+func (r *bundleMetricRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("bundle_metrics")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "bundleId", Value: 1}, {Key: "granularity", Value: 1}, {Key: "bucketStart", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "environmentId", Value: 1}, {Key: "granularity", Value: 1}, {Key: "bucketStart", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *bundleMetricRepository) Increment(ctx context.Context, bundle *model.Bundle, metric string, at time.Time) error {
+	return r.upsertBuckets(ctx, bundle, at, bson.M{"$inc": bson.M{metric: 1}})
+}
+
+// SetActiveDevices stores the active count of the bundle, the bucket keeps the value of its last change
+func (r *bundleMetricRepository) SetActiveDevices(ctx context.Context, bundle *model.Bundle, activeDevices int, at time.Time) error {
+	return r.upsertBuckets(ctx, bundle, at, bson.M{"$set": bson.M{"activeDevices": activeDevices}})
+}
+
+// GetSeries returns the buckets of every bundle of the environment, or of the bundle with the label,
+// between from and to, oldest first
+func (r *bundleMetricRepository) GetSeries(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, from time.Time, to time.Time) ([]*model.BundleMetric, error) {
+	collection := r.Connection.Collection("bundle_metrics")
+	filter := bson.M{
+		"environmentId": environmentId,
+		"granularity":   granularity,
+		"bucketStart":   bson.M{"$gte": from, "$lte": to},
+	}
+	if label != "" {
+		filter["label"] = label
+	}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"bucketStart": 1}))
+	if err != nil {
+		return nil, err
+	}
+	metrics := []*model.BundleMetric{}
+	if err := cursor.All(ctx, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+// GetLastActiveDevices returns, for each bundle, the last bucket before the given time that
+// recorded its active count
+func (r *bundleMetricRepository) GetLastActiveDevices(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, before time.Time) ([]*model.BundleMetric, error) {
+	collection := r.Connection.Collection("bundle_metrics")
+	match := bson.M{
+		"environmentId": environmentId,
+		"granularity":   granularity,
+		"bucketStart":   bson.M{"$lt": before},
+		"activeDevices": bson.M{"$exists": true},
+	}
+	if label != "" {
+		match["label"] = label
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$sort", Value: bson.M{"bucketStart": -1}}},
+		{{Key: "$group", Value: bson.M{"_id": "$bundleId", "metric": bson.M{"$first": "$$ROOT"}}}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$metric"}}},
+	}
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	metrics := []*model.BundleMetric{}
+	if err := cursor.All(ctx, &metrics); err != nil {
+		return nil, err
+	}
+	return metrics, nil
+}
+
+func (r *bundleMetricRepository) upsertBuckets(ctx context.Context, bundle *model.Bundle, at time.Time, update bson.M) error {
+	collection := r.Connection.Collection("bundle_metrics")
+	// every report is counted into both bucket sizes
+	writes := []mongo.WriteModel{}
+	for _, granularity := range []string{utils.METRIC_GRANULARITY_HOUR, utils.METRIC_GRANULARITY_DAY} {
+		bucketUpdate := bson.M{
+			"$setOnInsert": bson.M{"environmentId": bundle.EnvironmentId, "label": bundle.Label},
+			"$currentDate": bson.M{"updatedAt": true},
+		}
+		for operator, fields := range update {
+			bucketUpdate[operator] = fields
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"bundleId": bundle.Id, "granularity": granularity, "bucketStart": utils.MetricBucketStart(granularity, at)}).
+			SetUpdate(bucketUpdate).
+			SetUpsert(true))
+	}
+	_, err := collection.BulkWrite(ctx, writes)
+	return err
+}
+
+func (r *bundleMetricRepository) DeleteByBundleIds(ctx context.Context, bundleIds []primitive.ObjectID) error {
+	collection := r.Connection.Collection("bundle_metrics")
+	_, err := collection.DeleteMany(ctx, bson.M{"bundleId": bson.M{"$in": bundleIds}})
+	return err
+}
+
+func (r *bundleMetricRepository) DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error {
+	collection := r.Connection.Collection("bundle_metrics")
+	_, err := collection.DeleteMany(ctx, bson.M{"environmentId": environmentId})
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -27,6 +28,25 @@ type BundleRepository interface {
 	AddFailed(ctx context.Context, id primitive.ObjectID) error
 	AddInstalled(ctx context.Context, id primitive.ObjectID) error
 	DecrementActive(ctx context.Context, id primitive.ObjectID) error
+	Disable(ctx context.Context, id primitive.ObjectID) (bool, error)
+	GetLastValidBefore(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, sequenceId int64) (*model.Bundle, error)
+	GetMandatoryBetween(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, afterSequenceId int64, upToSequenceId int64) ([]*model.Bundle, error)
+	GetAllWithActiveDevices(ctx context.Context) ([]*model.Bundle, error)
+	DeleteByVersionId(ctx context.Context, versionId primitive.ObjectID) error
+	DeleteByIds(ctx context.Context, ids []primitive.ObjectID) error
+	GetAllDownloadFiles(ctx context.Context) ([]string, error)
+	EnsureIndexes(ctx context.Context) error
+	GetDueScheduled(ctx context.Context, before time.Time) ([]*model.Bundle, error)
+	ClaimScheduled(ctx context.Context, id primitive.ObjectID) (bool, error)
+	UpdateSchedule(ctx context.Context, id primitive.ObjectID, scheduledAt time.Time, freezeOverrideReason string) (bool, error)
+	CancelSchedule(ctx context.Context, id primitive.ObjectID) (bool, error)
+	SetApproval(ctx context.Context, id primitive.ObjectID, approval *model.Approval) error
+	DecideApproval(ctx context.Context, id primitive.ObjectID, status string, decidedBy string, comment *model.ApprovalComment) (bool, error)
+	AddApprovalComment(ctx context.Context, id primitive.ObjectID, comment *model.ApprovalComment) (bool, error)
+	GetAllPendingApproval(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error)
+	UpdateTargeting(ctx context.Context, id primitive.ObjectID, targeting *model.Targeting) (bool, error)
+	UpdateSourceMapFile(ctx context.Context, id primitive.ObjectID, sourceMapFile string) (bool, error)
+	UpdateDescription(ctx context.Context, id primitive.ObjectID, description string) (bool, error)
 }
 
 type bundleRepository struct {
@@ -156,7 +176,8 @@ func (bundleRepository *bundleRepository) AddInstalled(ctx context.Context, id p
 
 func (bundleRepository *bundleRepository) DecrementActive(ctx context.Context, id primitive.ObjectID) error {
 	collection := bundleRepository.Connection.Collection("bundles")
-	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$inc": bson.M{"active": -1}})
+	// never let the count drop below zero
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id, "active": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"active": -1}})
 	if err != nil {
 		return err
 	}
@@ -194,3 +215,240 @@ func (bundleRepository *bundleRepository) UpdateIsValid(ctx context.Context, id
 	}
 	return &model.Bundle{Id: id, IsValid: isValid}, nil
 }
+
+// Disable marks a valid bundle invalid, false when it was already invalid so concurrent callers act once
+func (bundleRepository *bundleRepository) Disable(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "isValid": true}, bson.M{"$set": bson.M{"isValid": false, "updatedAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// GetLastValidBefore returns the newest valid bundle of the version released before sequenceId
+func (bundleRepository *bundleRepository) GetLastValidBefore(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, sequenceId int64) (*model.Bundle, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	var bundle model.Bundle
+	filter := bson.M{"environmentId": environmentId, "versionId": versionId, "isValid": true, "sequenceId": bson.M{"$lt": sequenceId}}
+	err := collection.FindOne(ctx, filter, options.FindOne().SetSort(bson.M{"sequenceId": -1})).Decode(&bundle)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &bundle, nil
+}
+
+// GetMandatoryBetween returns the valid mandatory bundles of the version released after
+// afterSequenceId and up to upToSequenceId, only the fields needed to tell who they target
+func (bundleRepository *bundleRepository) GetMandatoryBetween(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, afterSequenceId int64, upToSequenceId int64) ([]*model.Bundle, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	filter := bson.M{
+		"environmentId": environmentId,
+		"versionId":     versionId,
+		"isValid":       true,
+		"isMandatory":   true,
+		"sequenceId":    bson.M{"$gt": afterSequenceId, "$lte": upToSequenceId},
+	}
+	opts := options.Find().SetProjection(bson.M{"label": 1, "sequenceId": 1, "targeting": 1})
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	bundles := []*model.Bundle{}
+	if err := cursor.All(ctx, &bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// GetAllWithActiveDevices returns the bundles some device is running, only the fields the metrics need
+func (bundleRepository *bundleRepository) GetAllWithActiveDevices(ctx context.Context) ([]*model.Bundle, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	opts := options.Find().SetProjection(bson.M{"appId": 1, "environmentId": 1, "label": 1, "active": 1})
+	cursor, err := collection.Find(ctx, bson.M{"active": bson.M{"$gt": 0}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	bundles := []*model.Bundle{}
+	if err := cursor.All(ctx, &bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+func (bundleRepository *bundleRepository) DeleteByVersionId(ctx context.Context, versionId primitive.ObjectID) error {
+	collection := bundleRepository.Connection.Collection("bundles")
+	_, err := collection.DeleteMany(ctx, bson.M{"versionId": versionId})
+	return err
+}
+
+func (bundleRepository *bundleRepository) DeleteByIds(ctx context.Context, ids []primitive.ObjectID) error {
+	collection := bundleRepository.Connection.Collection("bundles")
+	_, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+// GetAllDownloadFiles returns the storage key of every bundle and source map, deleted versions included
+func (bundleRepository *bundleRepository) GetAllDownloadFiles(ctx context.Context) ([]string, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	downloadFiles := []string{}
+	for _, field := range []string{"downloadFile", "sourceMapFile"} {
+		values, err := collection.Distinct(ctx, field, bson.M{field: bson.M{"$exists": true}})
+		if err != nil {
+			return nil, err
+		}
+		for _, value := range values {
+			if downloadFile, ok := value.(string); ok && downloadFile != "" {
+				downloadFiles = append(downloadFiles, downloadFile)
+			}
+		}
+	}
+	return downloadFiles, nil
+}
+
+// EnsureIndexes creates the index the release scheduler polls, only scheduled bundles are in it
+func (bundleRepository *bundleRepository) EnsureIndexes(ctx context.Context) error {
+	collection := bundleRepository.Connection.Collection("bundles")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "scheduledAt", Value: 1}},
+		Options: options.Index().SetSparse(true),
+	})
+	return err
+}
+
+// GetDueScheduled returns the bundles scheduled at or before the given time, oldest schedule first
+func (bundleRepository *bundleRepository) GetDueScheduled(ctx context.Context, before time.Time) ([]*model.Bundle, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	cursor, err := collection.Find(ctx, bson.M{"scheduledAt": bson.M{"$lte": before}}, options.Find().SetSort(bson.M{"scheduledAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	bundles := []*model.Bundle{}
+	if err := cursor.All(ctx, &bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// ClaimScheduled enables a scheduled bundle and clears its schedule, false when it is no longer
+// scheduled or not approved, so a release is activated once even with several servers
+func (bundleRepository *bundleRepository) ClaimScheduled(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	update := bson.M{"$set": bson.M{"isValid": true, "updatedAt": time.Now()}, "$unset": bson.M{"scheduledAt": ""}}
+	filter := bson.M{"_id": id, "scheduledAt": bson.M{"$exists": true}, "approval.status": bson.M{"$nin": bson.A{utils.APPROVAL_PENDING, utils.APPROVAL_REJECTED}}}
+	result, err := collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UpdateSchedule schedules a disabled bundle or moves its schedule, false when the bundle is enabled
+func (bundleRepository *bundleRepository) UpdateSchedule(ctx context.Context, id primitive.ObjectID, scheduledAt time.Time, freezeOverrideReason string) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	update := bson.M{"$set": bson.M{"scheduledAt": scheduledAt, "freezeOverrideReason": freezeOverrideReason, "updatedAt": time.Now()}}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "isValid": false}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// CancelSchedule clears the schedule of a bundle, false when it was not scheduled
+func (bundleRepository *bundleRepository) CancelSchedule(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	update := bson.M{"$set": bson.M{"updatedAt": time.Now()}, "$unset": bson.M{"scheduledAt": ""}}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "scheduledAt": bson.M{"$exists": true}}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SetApproval replaces the approval of a bundle, it is how a release asks for one
+func (bundleRepository *bundleRepository) SetApproval(ctx context.Context, id primitive.ObjectID, approval *model.Approval) error {
+	collection := bundleRepository.Connection.Collection("bundles")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"approval": approval, "updatedAt": time.Now()}})
+	return err
+}
+
+// DecideApproval approves or rejects a pending bundle, false when it was no longer pending so two
+// approvers cannot both decide. A rejected bundle loses its schedule
+func (bundleRepository *bundleRepository) DecideApproval(ctx context.Context, id primitive.ObjectID, status string, decidedBy string, comment *model.ApprovalComment) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"approval.status": status, "approval.decidedBy": decidedBy, "approval.decidedAt": now, "updatedAt": now}}
+	if comment != nil {
+		update["$push"] = bson.M{"approval.comments": comment}
+	}
+	if status == utils.APPROVAL_REJECTED {
+		update["$unset"] = bson.M{"scheduledAt": ""}
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "approval.status": utils.APPROVAL_PENDING}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// AddApprovalComment adds a comment to the approval of a bundle, false when it has none
+func (bundleRepository *bundleRepository) AddApprovalComment(ctx context.Context, id primitive.ObjectID, comment *model.ApprovalComment) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	update := bson.M{"$push": bson.M{"approval.comments": comment}, "$set": bson.M{"updatedAt": time.Now()}}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "approval": bson.M{"$exists": true}}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// GetAllPendingApproval returns the bundles of an environment waiting for approval, oldest first
+func (bundleRepository *bundleRepository) GetAllPendingApproval(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	filter := bson.M{"environmentId": environmentId, "approval.status": utils.APPROVAL_PENDING}
+	cursor, err := collection.Find(ctx, filter, options.Find().SetSort(bson.M{"approval.requestedAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	bundles := []*model.Bundle{}
+	if err := cursor.All(ctx, &bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// UpdateTargeting replaces the targeting of a bundle, nil removes it. False when the bundle does not exist
+func (bundleRepository *bundleRepository) UpdateTargeting(ctx context.Context, id primitive.ObjectID, targeting *model.Targeting) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	update := bson.M{"$set": bson.M{"targeting": targeting, "updatedAt": time.Now()}}
+	if targeting == nil {
+		update = bson.M{"$unset": bson.M{"targeting": ""}, "$set": bson.M{"updatedAt": time.Now()}}
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// UpdateSourceMapFile points the bundle to a newly uploaded source map
+func (bundleRepository *bundleRepository) UpdateSourceMapFile(ctx context.Context, id primitive.ObjectID, sourceMapFile string) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"sourceMapFile": sourceMapFile, "updatedAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+func (bundleRepository *bundleRepository) UpdateDescription(ctx context.Context, id primitive.ObjectID, description string) (bool, error) {
+	collection := bundleRepository.Connection.Collection("bundles")
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"description": description, "updatedAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
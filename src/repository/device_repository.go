@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DeviceRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	GetByClientUniqueId(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error)
+	MarkDownloaded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error)
+	MarkDeployed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, bundleId primitive.ObjectID, status string) (*model.Device, bool, error)
+	MarkFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, status string) (bool, error)
+	ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error)
+	CountByLastStatus(ctx context.Context, environmentId primitive.ObjectID, label string, status string, since time.Time) (int64, error)
+	DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error
+}
+
+type deviceRepository struct {
+	Connection *mongo.Database
+}
+
+func NewDeviceRepository(db *mongo.Database) DeviceRepository {
+	return &deviceRepository{Connection: db}
+}
+
+// EnsureIndexes creates the unique index the state transitions rely on, without it
+// concurrent first reports of a device would create duplicate records, and the one the
+// rollback policy and experiments count installs and failures of a release with
+func (r *deviceRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("devices")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "environmentId", Value: 1}, {Key: "clientUniqueId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys: bson.D{{Key: "environmentId", Value: 1}, {Key: "lastLabel", Value: 1}, {Key: "status", Value: 1}, {Key: "reportedAt", Value: 1}},
+		},
+	})
+	return err
+}
+
+func (r *deviceRepository) GetByClientUniqueId(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error) {
+	collection := r.Connection.Collection("devices")
+	var device model.Device
+	err := collection.FindOne(ctx, bson.M{"environmentId": environmentId, "clientUniqueId": clientUniqueId}).Decode(&device)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &device, nil
+}
+
+// MarkDownloaded records that the device downloaded the label, false when it already had
+func (r *deviceRepository) MarkDownloaded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	filter := bson.M{"environmentId": environmentId, "clientUniqueId": clientUniqueId, "downloadedLabel": bson.M{"$ne": label}}
+	update := bson.M{
+		"$set":         bson.M{"downloadedLabel": label, "updatedAt": time.Now()},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	_, changed, err := r.transition(ctx, filter, update)
+	return changed, err
+}
+
+// MarkDeployed makes the label the one the device runs and returns the state before the change,
+// false when the device already ran the label
+func (r *deviceRepository) MarkDeployed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, bundleId primitive.ObjectID, status string) (*model.Device, bool, error) {
+	filter := bson.M{"environmentId": environmentId, "clientUniqueId": clientUniqueId, "currentLabel": bson.M{"$ne": label}}
+	now := time.Now()
+	// a pipeline update so the current bundle moves to previous in the same write,
+	// values sent by the SDK are wrapped in $literal so they are never read as field paths
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"previousLabel":    "$currentLabel",
+		"previousBundleId": "$currentBundleId",
+		"currentLabel":     bson.M{"$literal": label},
+		"currentBundleId":  bundleId,
+		"lastLabel":        bson.M{"$literal": label},
+		"status":           bson.M{"$literal": status},
+		"appVersion":       bson.M{"$literal": appVersion},
+		"reportedAt":       now,
+		"createdAt":        bson.M{"$ifNull": bson.A{"$createdAt", now}},
+		"updatedAt":        now,
+	}}}}
+	return r.transition(ctx, filter, update)
+}
+
+// MarkFailed records a failed install of the label, false when the failure was already recorded
+func (r *deviceRepository) MarkFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, status string) (bool, error) {
+	filter := bson.M{
+		"environmentId":  environmentId,
+		"clientUniqueId": clientUniqueId,
+		"$nor":           bson.A{bson.M{"lastLabel": label, "status": status}},
+	}
+	update := bson.M{
+		"$set":         bson.M{"lastLabel": label, "status": status, "appVersion": appVersion, "reportedAt": time.Now(), "updatedAt": time.Now()},
+		"$setOnInsert": bson.M{"createdAt": time.Now()},
+	}
+	_, changed, err := r.transition(ctx, filter, update)
+	return changed, err
+}
+
+// ReleaseBundle clears the current label of a device that moved to another environment,
+// false when the device was not running the label there
+func (r *deviceRepository) ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	collection := r.Connection.Collection("devices")
+	result, err := collection.UpdateOne(ctx,
+		bson.M{"environmentId": environmentId, "clientUniqueId": clientUniqueId, "currentLabel": label},
+		bson.M{
+			"$set":   bson.M{"previousLabel": label, "updatedAt": time.Now()},
+			"$unset": bson.M{"currentLabel": "", "currentBundleId": ""},
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// CountByLastStatus counts devices whose last deploy report, made since the given time, was status for the label
+func (r *deviceRepository) CountByLastStatus(ctx context.Context, environmentId primitive.ObjectID, label string, status string, since time.Time) (int64, error) {
+	collection := r.Connection.Collection("devices")
+	return collection.CountDocuments(ctx, bson.M{
+		"environmentId": environmentId,
+		"lastLabel":     label,
+		"status":        status,
+		"reportedAt":    bson.M{"$gte": since},
+	})
+}
+
+// transition upserts the device when the filter matches, i.e. the device is not already in the
+// target state. When the device exists but does not match, the upsert hits the unique index,
+// which is how a repeated report shows up. A duplicate key error can also come from a concurrent
+// first report of the same device, so the write is tried once more before treating it as a repeat
+func (r *deviceRepository) transition(ctx context.Context, filter bson.M, update interface{}) (*model.Device, bool, error) {
+	collection := r.Connection.Collection("devices")
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before)
+	for attempt := 0; attempt < 2; attempt++ {
+		var device model.Device
+		err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&device)
+		if err == nil {
+			return &device, true, nil
+		}
+		if err == mongo.ErrNoDocuments {
+			// the device was inserted, there is no previous state
+			return nil, true, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, false, err
+		}
+	}
+	return nil, false, nil
+}
+
+func (r *deviceRepository) DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error {
+	collection := r.Connection.Collection("devices")
+	_, err := collection.DeleteMany(ctx, bson.M{"environmentId": environmentId})
+	return err
+}
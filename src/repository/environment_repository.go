@@ -17,6 +17,19 @@ type EnvironmentRepository interface {
 	GetByAppIdAndName(ctx context.Context, appId primitive.ObjectID, name string) (*model.Environment, error)
 	GetAllByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Environment, error)
 	GetByIdAndAppId(ctx context.Context, id primitive.ObjectID, appId primitive.ObjectID) (*model.Environment, error)
+	GetById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error)
+	UpdateKey(ctx context.Context, id primitive.ObjectID, key string, previousKey string, previousKeyExpiresAt time.Time) (*model.Environment, error)
+	UpdateRollbackPolicy(ctx context.Context, id primitive.ObjectID, policy *model.RollbackPolicy) (*model.Environment, error)
+	UpdateFreezeWindows(ctx context.Context, id primitive.ObjectID, windows []model.FreezeWindow) (*model.Environment, error)
+	UpdateApprovalPolicy(ctx context.Context, id primitive.ObjectID, policy *model.ApprovalPolicy) (*model.Environment, error)
+	UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.Environment, error)
+	MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	MarkDeletedByAppId(ctx context.Context, appId primitive.ObjectID, at time.Time) ([]primitive.ObjectID, error)
+	Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error)
+	RestoreByAppId(ctx context.Context, appId primitive.ObjectID, deletedAt time.Time) ([]primitive.ObjectID, error)
+	GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error)
+	GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Environment, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
 }
 
 type environmentRepositoryImpl struct {
@@ -41,7 +54,11 @@ func (environmentRepository *environmentRepositoryImpl) Insert(ctx context.Conte
 func (environmentRepository *environmentRepositoryImpl) GetByKey(ctx context.Context, key string) (*model.Environment, error) {
 	var environment model.Environment
 	collection := environmentRepository.Connection.Collection("environments")
-	filter := bson.M{"key": key}
+	// a rotated out key still matches until its grace period ends
+	filter := notDeleted(bson.M{"$or": bson.A{
+		bson.M{"key": key},
+		bson.M{"previousKey": key, "previousKeyExpiresAt": bson.M{"$gt": time.Now()}},
+	}})
 	err := collection.FindOne(ctx, filter).Decode(&environment)
 	if err != nil {
 		return nil, err
@@ -52,7 +69,7 @@ func (environmentRepository *environmentRepositoryImpl) GetByKey(ctx context.Con
 func (environmentRepository *environmentRepositoryImpl) GetByAppIdAndName(ctx context.Context, appId primitive.ObjectID, name string) (*model.Environment, error) {
 	var environment model.Environment
 	collection := environmentRepository.Connection.Collection("environments")
-	filter := bson.M{"appId": appId, "name": name}
+	filter := notDeleted(bson.M{"appId": appId, "name": name})
 	err := collection.FindOne(ctx, filter).Decode(&environment)
 	if err != nil {
 		return nil, err
@@ -62,7 +79,7 @@ func (environmentRepository *environmentRepositoryImpl) GetByAppIdAndName(ctx co
 
 func (environmentRepository *environmentRepositoryImpl) GetAllByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Environment, error) {
 	collection := environmentRepository.Connection.Collection("environments")
-	filter := bson.M{"appId": appId}
+	filter := notDeleted(bson.M{"appId": appId})
 	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -82,7 +99,7 @@ func (environmentRepository *environmentRepositoryImpl) GetAllByAppId(ctx contex
 
 func (environmentRepository *environmentRepositoryImpl) GetByIdAndAppId(ctx context.Context, id primitive.ObjectID, appId primitive.ObjectID) (*model.Environment, error) {
 	collection := environmentRepository.Connection.Collection("environments")
-	filter := bson.M{"_id": id, "appId": appId}
+	filter := notDeleted(bson.M{"_id": id, "appId": appId})
 	var environment model.Environment
 	err := collection.FindOne(ctx, filter).Decode(&environment)
 	if err != nil {
@@ -90,3 +107,150 @@ func (environmentRepository *environmentRepositoryImpl) GetByIdAndAppId(ctx cont
 	}
 	return &environment, nil
 }
+
+func (environmentRepository *environmentRepositoryImpl) GetById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	var environment model.Environment
+	err := collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&environment)
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+// UpdateKey replaces previousKey with key, only while previousKey is still the key of the environment
+// so a concurrent rotation does not drop the key it replaced
+func (environmentRepository *environmentRepositoryImpl) UpdateKey(ctx context.Context, id primitive.ObjectID, key string, previousKey string, previousKeyExpiresAt time.Time) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	update := bson.M{"$set": bson.M{
+		"key":                  key,
+		"previousKey":          previousKey,
+		"previousKeyExpiresAt": previousKeyExpiresAt,
+		"updatedAt":            time.Now(),
+	}}
+	var environment model.Environment
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id, "key": previousKey}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&environment)
+	if err != nil {
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (environmentRepository *environmentRepositoryImpl) UpdateRollbackPolicy(ctx context.Context, id primitive.ObjectID, policy *model.RollbackPolicy) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	update := bson.M{"$set": bson.M{"rollbackPolicy": policy, "updatedAt": time.Now()}}
+	var environment model.Environment
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&environment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (environmentRepository *environmentRepositoryImpl) UpdateFreezeWindows(ctx context.Context, id primitive.ObjectID, windows []model.FreezeWindow) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	update := bson.M{"$set": bson.M{"freezeWindows": windows, "updatedAt": time.Now()}}
+	var environment model.Environment
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&environment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (environmentRepository *environmentRepositoryImpl) UpdateApprovalPolicy(ctx context.Context, id primitive.ObjectID, policy *model.ApprovalPolicy) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	update := bson.M{"$set": bson.M{"approvalPolicy": policy, "updatedAt": time.Now()}}
+	var environment model.Environment
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&environment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &environment, nil
+}
+
+func (environmentRepository *environmentRepositoryImpl) UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.Environment, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	update := bson.M{"$set": bson.M{"name": name, "updatedAt": time.Now()}}
+	var environment model.Environment
+	err := collection.FindOneAndUpdate(ctx, notDeleted(bson.M{"_id": id}), update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&environment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &environment, nil
+}
+
+// MarkDeleted soft deletes the environment, false when it was already deleted
+func (environmentRepository *environmentRepositoryImpl) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	marked, err := markDeleted(ctx, environmentRepository.Connection.Collection("environments"), bson.M{"_id": id}, at)
+	return marked > 0, err
+}
+
+// MarkDeletedByAppId soft deletes the environments of a deleted app and returns their ids
+func (environmentRepository *environmentRepositoryImpl) MarkDeletedByAppId(ctx context.Context, appId primitive.ObjectID, at time.Time) ([]primitive.ObjectID, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	if _, err := markDeleted(ctx, collection, bson.M{"appId": appId}, at); err != nil {
+		return nil, err
+	}
+	return environmentRepository.idsDeletedAt(ctx, appId, at)
+}
+
+func (environmentRepository *environmentRepositoryImpl) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	restored, err := restoreDeleted(ctx, environmentRepository.Connection.Collection("environments"), bson.M{"_id": id}, deletedAt)
+	return restored > 0, err
+}
+
+// RestoreByAppId restores the environments deleted together with their app and returns their ids
+func (environmentRepository *environmentRepositoryImpl) RestoreByAppId(ctx context.Context, appId primitive.ObjectID, deletedAt time.Time) ([]primitive.ObjectID, error) {
+	ids, err := environmentRepository.idsDeletedAt(ctx, appId, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	_, err = restoreDeleted(ctx, environmentRepository.Connection.Collection("environments"), bson.M{"appId": appId}, deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (environmentRepository *environmentRepositoryImpl) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error) {
+	return findOneDeleted[model.Environment](ctx, environmentRepository.Connection.Collection("environments"), bson.M{"_id": id})
+}
+
+func (environmentRepository *environmentRepositoryImpl) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Environment, error) {
+	return findDeleted[model.Environment](ctx, environmentRepository.Connection.Collection("environments"), bson.M{}, before)
+}
+
+func (environmentRepository *environmentRepositoryImpl) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := environmentRepository.Connection.Collection("environments").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (environmentRepository *environmentRepositoryImpl) idsDeletedAt(ctx context.Context, appId primitive.ObjectID, deletedAt time.Time) ([]primitive.ObjectID, error) {
+	collection := environmentRepository.Connection.Collection("environments")
+	cursor, err := collection.Find(ctx, bson.M{"appId": appId, "deletedAt": deletedAt}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var environments []model.Environment
+	if err := cursor.All(ctx, &environments); err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(environments))
+	for _, environment := range environments {
+		ids = append(ids, environment.Id)
+	}
+	return ids, nil
+}
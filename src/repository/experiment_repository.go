@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ExperimentRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	Insert(ctx context.Context, experiment *model.Experiment) (*model.Experiment, error)
+	GetById(ctx context.Context, id primitive.ObjectID) (*model.Experiment, error)
+	GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error)
+	End(ctx context.Context, id primitive.ObjectID, status string, winnerBundleId primitive.ObjectID, endedBy string) (bool, error)
+}
+
+type experimentRepository struct {
+	Connection *mongo.Database
+}
+
+func NewExperimentRepository(db *mongo.Database) ExperimentRepository {
+	return &experimentRepository{Connection: db}
+}
+
+func (r *experimentRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("experiments")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "environmentId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	return err
+}
+
+func (r *experimentRepository) Insert(ctx context.Context, experiment *model.Experiment) (*model.Experiment, error) {
+	experiment.CreatedAt = time.Now()
+	experiment.UpdatedAt = time.Now()
+	collection := r.Connection.Collection("experiments")
+	insertedExperiment, err := collection.InsertOne(ctx, experiment)
+	if err != nil {
+		return nil, err
+	}
+	experiment.Id = insertedExperiment.InsertedID.(primitive.ObjectID)
+	return experiment, nil
+}
+
+func (r *experimentRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Experiment, error) {
+	collection := r.Connection.Collection("experiments")
+	var experiment model.Experiment
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&experiment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+// GetAllByEnvironmentId returns the experiments of an environment, newest first
+func (r *experimentRepository) GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error) {
+	collection := r.Connection.Collection("experiments")
+	cursor, err := collection.Find(ctx, bson.M{"environmentId": environmentId}, options.Find().SetSort(bson.M{"createdAt": -1}))
+	if err != nil {
+		return nil, err
+	}
+	experiments := []*model.Experiment{}
+	if err := cursor.All(ctx, &experiments); err != nil {
+		return nil, err
+	}
+	return experiments, nil
+}
+
+// End concludes or stops a running experiment, false when it already ended so it ends once
+func (r *experimentRepository) End(ctx context.Context, id primitive.ObjectID, status string, winnerBundleId primitive.ObjectID, endedBy string) (bool, error) {
+	collection := r.Connection.Collection("experiments")
+	now := time.Now()
+	set := bson.M{"status": status, "endedBy": endedBy, "endedAt": now, "updatedAt": now}
+	if !winnerBundleId.IsZero() {
+		set["winnerBundleId"] = winnerBundleId
+	}
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "status": utils.EXPERIMENT_RUNNING}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MigrationRepository interface {
+	IsApplied(ctx context.Context, name string) (bool, error)
+	MarkApplied(ctx context.Context, name string) error
+}
+
+type migrationRepository struct {
+	Connection *mongo.Database
+}
+
+func NewMigrationRepository(db *mongo.Database) MigrationRepository {
+	return &migrationRepository{Connection: db}
+}
+
+func (r *migrationRepository) IsApplied(ctx context.Context, name string) (bool, error) {
+	collection := r.Connection.Collection("migrations")
+	count, err := collection.CountDocuments(ctx, bson.M{"name": name})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *migrationRepository) MarkApplied(ctx context.Context, name string) error {
+	collection := r.Connection.Collection("migrations")
+	_, err := collection.InsertOne(ctx, &model.Migration{
+		Name:      name,
+		AppliedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
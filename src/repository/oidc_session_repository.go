@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type OIDCSessionRepository interface {
+	Insert(ctx context.Context, session *model.OIDCSession) (*model.OIDCSession, error)
+	ConsumeByState(ctx context.Context, state string) (*model.OIDCSession, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type oidcSessionRepository struct {
+	Connection *mongo.Database
+}
+
+func NewOIDCSessionRepository(db *mongo.Database) OIDCSessionRepository {
+	return &oidcSessionRepository{Connection: db}
+}
+
+// EnsureIndexes lets Mongo delete sessions of logins that were never completed once they expired
+func (r *oidcSessionRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("oidc_sessions")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+func (r *oidcSessionRepository) Insert(ctx context.Context, session *model.OIDCSession) (*model.OIDCSession, error) {
+	session.CreatedAt = time.Now()
+	collection := r.Connection.Collection("oidc_sessions")
+	insertedSession, err := collection.InsertOne(ctx, session)
+	if err != nil {
+		return nil, err
+	}
+	session.Id = insertedSession.InsertedID.(primitive.ObjectID)
+	return session, nil
+}
+
+// ConsumeByState returns and deletes the session so a callback can only be redeemed once
+func (r *oidcSessionRepository) ConsumeByState(ctx context.Context, state string) (*model.OIDCSession, error) {
+	collection := r.Connection.Collection("oidc_sessions")
+	var session model.OIDCSession
+	err := collection.FindOneAndDelete(ctx, bson.M{"state": state}).Decode(&session)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
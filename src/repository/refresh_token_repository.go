@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type RefreshTokenRepository interface {
+	Insert(ctx context.Context, refreshToken *model.RefreshToken) (*model.RefreshToken, error)
+	GetByTokenHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+	// Revoke returns false when the token was already revoked
+	Revoke(ctx context.Context, id primitive.ObjectID) (bool, error)
+	RevokeAllByUserId(ctx context.Context, userId primitive.ObjectID) error
+}
+
+type refreshTokenRepository struct {
+	Connection *mongo.Database
+}
+
+func NewRefreshTokenRepository(db *mongo.Database) RefreshTokenRepository {
+	return &refreshTokenRepository{Connection: db}
+}
+
+func (r *refreshTokenRepository) Insert(ctx context.Context, refreshToken *model.RefreshToken) (*model.RefreshToken, error) {
+	refreshToken.CreatedAt = time.Now()
+	refreshToken.UpdatedAt = time.Now()
+	collection := r.Connection.Collection("refresh_tokens")
+	insertedRefreshToken, err := collection.InsertOne(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken.Id = insertedRefreshToken.InsertedID.(primitive.ObjectID)
+	return refreshToken, nil
+}
+
+func (r *refreshTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	collection := r.Connection.Collection("refresh_tokens")
+	var refreshToken model.RefreshToken
+	err := collection.FindOne(ctx, bson.M{"tokenHash": tokenHash}).Decode(&refreshToken)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &refreshToken, nil
+}
+
+func (r *refreshTokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := r.Connection.Collection("refresh_tokens")
+	now := time.Now()
+	result, err := collection.UpdateOne(ctx, bson.M{"_id": id, "revokedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revokedAt": now, "updatedAt": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func (r *refreshTokenRepository) RevokeAllByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	collection := r.Connection.Collection("refresh_tokens")
+	now := time.Now()
+	_, err := collection.UpdateMany(ctx, bson.M{"userId": userId, "revokedAt": bson.M{"$exists": false}}, bson.M{"$set": bson.M{"revokedAt": now, "updatedAt": now}})
+	if err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// notDeleted adds the condition that hides soft deleted apps, environments and versions to a filter,
+// every read outside of deletion and restore goes through it
+func notDeleted(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// markDeleted soft deletes the documents matching the filter that are not deleted yet. Children are
+// marked with the time of their parent so restoring the parent restores exactly them
+func markDeleted(ctx context.Context, collection *mongo.Collection, filter bson.M, at time.Time) (int64, error) {
+	result, err := collection.UpdateMany(ctx, notDeleted(filter), bson.M{"$set": bson.M{"deletedAt": at, "updatedAt": time.Now()}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// restoreDeleted undeletes the documents matching the filter that were deleted at the given time
+func restoreDeleted(ctx context.Context, collection *mongo.Collection, filter bson.M, deletedAt time.Time) (int64, error) {
+	filter["deletedAt"] = deletedAt
+	result, err := collection.UpdateMany(ctx, filter, bson.M{
+		"$unset": bson.M{"deletedAt": ""},
+		"$set":   bson.M{"updatedAt": time.Now()},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// findDeleted returns the documents matching the filter that were deleted before the given time
+func findDeleted[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, before time.Time) ([]*T, error) {
+	filter["deletedAt"] = bson.M{"$lt": before}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]*T, 0)
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// findOneDeleted returns the soft deleted document matching the filter, nil when there is none
+func findOneDeleted[T any](ctx context.Context, collection *mongo.Collection, filter bson.M) (*T, error) {
+	filter["deletedAt"] = bson.M{"$exists": true}
+	var document T
+	err := collection.FindOne(ctx, filter).Decode(&document)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &document, nil
+}
@@ -14,6 +14,8 @@ type UserRepository interface {
 	Insert(ctx context.Context, user *model.User) (*model.User, error)
 	GetByUsername(ctx context.Context, username string) (*model.User, error)
 	GetById(ctx context.Context, id primitive.ObjectID) (*model.User, error)
+	GetByOIDCSubject(ctx context.Context, subject string) (*model.User, error)
+	UpdateRoles(ctx context.Context, id primitive.ObjectID, roles []string) error
 	Count(ctx context.Context) (int64, error)
 }
 
@@ -64,6 +66,25 @@ func (r *userRepository) GetById(ctx context.Context, id primitive.ObjectID) (*m
 	return &user, nil
 }
 
+func (r *userRepository) GetByOIDCSubject(ctx context.Context, subject string) (*model.User, error) {
+	collection := r.db.Collection("users")
+	var user model.User
+	err := collection.FindOne(ctx, bson.M{"oidcSubject": subject}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) UpdateRoles(ctx context.Context, id primitive.ObjectID, roles []string) error {
+	collection := r.db.Collection("users")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"roles": roles, "updatedAt": time.Now()}})
+	return err
+}
+
 func (r *userRepository) Count(ctx context.Context) (int64, error) {
 	collection := r.db.Collection("users")
 	count, err := collection.CountDocuments(ctx, bson.M{})
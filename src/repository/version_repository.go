@@ -18,8 +18,18 @@ type VersionRepository interface {
 	GetByEnvironmentAndVersion(ctx context.Context, environment string, version string) (*model.Version, error)
 	GetLatestVersionByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) (*model.Version, error)
 	GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Version, error)
+	GetAll(ctx context.Context) ([]*model.Version, error)
 	GetById(ctx context.Context, id primitive.ObjectID) (*model.Version, error)
 	GetByIdAndEnvironmentId(ctx context.Context, id primitive.ObjectID, environmentId primitive.ObjectID) (*model.Version, error)
+	MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error)
+	MarkDeletedByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, at time.Time) error
+	Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error)
+	RestoreByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, deletedAt time.Time) error
+	GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Version, error)
+	GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Version, error)
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	StartExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) (bool, error)
+	EndExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) error
 }
 
 type versionRepository struct {
@@ -45,7 +55,7 @@ func (v *versionRepository) Create(ctx context.Context, version *model.Version)
 func (v *versionRepository) GetByEnvironmentIdAndAppVersion(ctx context.Context, environmentId primitive.ObjectID, appVersion string) (*model.Version, error) {
 	var version model.Version
 	collection := v.Connection.Collection("versions")
-	filter := bson.M{"environmentId": environmentId, "appVersion": appVersion}
+	filter := notDeleted(bson.M{"environmentId": environmentId, "appVersion": appVersion})
 	err := collection.FindOne(ctx, filter).Decode(&version)
 	if err != nil {
 		return nil, err
@@ -65,7 +75,7 @@ func (v *versionRepository) UpdateCurrentBundleId(ctx context.Context, id primit
 func (v *versionRepository) GetByEnvironmentAndVersion(ctx context.Context, environment string, version string) (*model.Version, error) {
 	collection := v.Connection.Collection("versions")
 	var versionDocument model.Version
-	err := collection.FindOne(ctx, bson.M{"environment": environment, "version": version}).Decode(&versionDocument)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"environment": environment, "version": version})).Decode(&versionDocument)
 	if err != nil {
 		return nil, err
 	}
@@ -76,7 +86,7 @@ func (v *versionRepository) GetLatestVersionByEnvironmentId(ctx context.Context,
 	collection := v.Connection.Collection("versions")
 	var versionDocument model.Version
 	opts := options.FindOne().SetSort(bson.D{{Key: "versionNumber", Value: -1}})
-	err := collection.FindOne(ctx, bson.M{"environmentId": environmentId}, opts).Decode(&versionDocument)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"environmentId": environmentId}), opts).Decode(&versionDocument)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -88,7 +98,7 @@ func (v *versionRepository) GetLatestVersionByEnvironmentId(ctx context.Context,
 
 func (v *versionRepository) GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Version, error) {
 	collection := v.Connection.Collection("versions")
-	filter := bson.M{"environmentId": environmentId}
+	filter := notDeleted(bson.M{"environmentId": environmentId})
 	cursor, err := collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
@@ -106,10 +116,23 @@ func (v *versionRepository) GetAllByEnvironmentId(ctx context.Context, environme
 	return versions, nil
 }
 
+func (v *versionRepository) GetAll(ctx context.Context) ([]*model.Version, error) {
+	collection := v.Connection.Collection("versions")
+	cursor, err := collection.Find(ctx, notDeleted(bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*model.Version, 0)
+	if err := cursor.All(ctx, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
 func (v *versionRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Version, error) {
 	collection := v.Connection.Collection("versions")
 	var version model.Version
-	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&version)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"_id": id})).Decode(&version)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, nil
@@ -122,9 +145,66 @@ func (v *versionRepository) GetById(ctx context.Context, id primitive.ObjectID)
 func (v *versionRepository) GetByIdAndEnvironmentId(ctx context.Context, versionId primitive.ObjectID, environmentId primitive.ObjectID) (*model.Version, error) {
 	collection := v.Connection.Collection("versions")
 	var version model.Version
-	err := collection.FindOne(ctx, bson.M{"_id": versionId, "environmentId": environmentId}).Decode(&version)
+	err := collection.FindOne(ctx, notDeleted(bson.M{"_id": versionId, "environmentId": environmentId})).Decode(&version)
 	if err != nil {
 		return nil, err
 	}
 	return &version, nil
 }
+
+// MarkDeleted soft deletes the version, false when it was already deleted
+func (v *versionRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	marked, err := markDeleted(ctx, v.Connection.Collection("versions"), bson.M{"_id": id}, at)
+	return marked > 0, err
+}
+
+// MarkDeletedByEnvironmentIds soft deletes the versions of deleted environments
+func (v *versionRepository) MarkDeletedByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, at time.Time) error {
+	_, err := markDeleted(ctx, v.Connection.Collection("versions"), bson.M{"environmentId": bson.M{"$in": environmentIds}}, at)
+	return err
+}
+
+func (v *versionRepository) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	restored, err := restoreDeleted(ctx, v.Connection.Collection("versions"), bson.M{"_id": id}, deletedAt)
+	return restored > 0, err
+}
+
+// RestoreByEnvironmentIds restores the versions deleted together with their environments
+func (v *versionRepository) RestoreByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, deletedAt time.Time) error {
+	_, err := restoreDeleted(ctx, v.Connection.Collection("versions"), bson.M{"environmentId": bson.M{"$in": environmentIds}}, deletedAt)
+	return err
+}
+
+func (v *versionRepository) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Version, error) {
+	return findOneDeleted[model.Version](ctx, v.Connection.Collection("versions"), bson.M{"_id": id})
+}
+
+func (v *versionRepository) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Version, error) {
+	return findDeleted[model.Version](ctx, v.Connection.Collection("versions"), bson.M{}, before)
+}
+
+func (v *versionRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := v.Connection.Collection("versions").DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+// StartExperiment attaches a running experiment to the version, false when another one already runs
+func (v *versionRepository) StartExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) (bool, error) {
+	collection := v.Connection.Collection("versions")
+	filter := notDeleted(bson.M{"_id": id, "experimentId": bson.M{"$exists": false}})
+	result, err := collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"experimentId": experimentId, "updatedAt": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// EndExperiment detaches the experiment, the current bundle is served to every device again
+func (v *versionRepository) EndExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) error {
+	collection := v.Connection.Collection("versions")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id, "experimentId": experimentId}, bson.M{
+		"$unset": bson.M{"experimentId": ""},
+		"$set":   bson.M{"updatedAt": time.Now()},
+	})
+	return err
+}
@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookDeliveryRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	Insert(ctx context.Context, delivery *model.WebhookDelivery) (*model.WebhookDelivery, error)
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id primitive.ObjectID, status string, responseStatus int, errorMessage string, nextAttemptAt time.Time) error
+	GetAllByWebhookId(ctx context.Context, webhookId primitive.ObjectID, limit int64) ([]*model.WebhookDelivery, error)
+	DeleteByWebhookId(ctx context.Context, webhookId primitive.ObjectID) error
+}
+
+type webhookDeliveryRepository struct {
+	Connection *mongo.Database
+}
+
+func NewWebhookDeliveryRepository(db *mongo.Database) WebhookDeliveryRepository {
+	return &webhookDeliveryRepository{Connection: db}
+}
+
+func (r *webhookDeliveryRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("webhook_deliveries")
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "nextAttemptAt", Value: 1}}},
+		{Keys: bson.D{{Key: "webhookId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	return err
+}
+
+func (r *webhookDeliveryRepository) Insert(ctx context.Context, delivery *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	delivery.CreatedAt = time.Now()
+	delivery.UpdatedAt = time.Now()
+	collection := r.Connection.Collection("webhook_deliveries")
+	insertedDelivery, err := collection.InsertOne(ctx, delivery)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Id = insertedDelivery.InsertedID.(primitive.ObjectID)
+	return delivery, nil
+}
+
+// ClaimDue takes the oldest pending delivery that is due and counts the attempt. Its next attempt is
+// pushed out by the lease, so other servers skip it and a server that dies mid-attempt only delays it
+func (r *webhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	collection := r.Connection.Collection("webhook_deliveries")
+	var delivery model.WebhookDelivery
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"status": utils.WEBHOOK_DELIVERY_PENDING, "nextAttemptAt": bson.M{"$lte": now}},
+		bson.M{
+			"$set": bson.M{"nextAttemptAt": now.Add(lease), "updatedAt": now},
+			"$inc": bson.M{"attempts": 1},
+		},
+		options.FindOneAndUpdate().SetSort(bson.M{"nextAttemptAt": 1}).SetReturnDocument(options.After),
+	).Decode(&delivery)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// RecordAttempt stores the outcome of the last attempt, nextAttemptAt only matters while the delivery is pending
+func (r *webhookDeliveryRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, status string, responseStatus int, errorMessage string, nextAttemptAt time.Time) error {
+	collection := r.Connection.Collection("webhook_deliveries")
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"status":         status,
+		"responseStatus": responseStatus,
+		"error":          errorMessage,
+		"nextAttemptAt":  nextAttemptAt,
+		"updatedAt":      time.Now(),
+	}})
+	return err
+}
+
+// GetAllByWebhookId returns the newest deliveries first
+func (r *webhookDeliveryRepository) GetAllByWebhookId(ctx context.Context, webhookId primitive.ObjectID, limit int64) ([]*model.WebhookDelivery, error) {
+	collection := r.Connection.Collection("webhook_deliveries")
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit)
+	cursor, err := collection.Find(ctx, bson.M{"webhookId": webhookId}, opts)
+	if err != nil {
+		return nil, err
+	}
+	deliveries := []*model.WebhookDelivery{}
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookDeliveryRepository) DeleteByWebhookId(ctx context.Context, webhookId primitive.ObjectID) error {
+	collection := r.Connection.Collection("webhook_deliveries")
+	_, err := collection.DeleteMany(ctx, bson.M{"webhookId": webhookId})
+	return err
+}
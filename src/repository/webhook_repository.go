@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type WebhookRepository interface {
+	EnsureIndexes(ctx context.Context) error
+	Insert(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error)
+	GetById(ctx context.Context, id primitive.ObjectID) (*model.Webhook, error)
+	GetAllByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Webhook, error)
+	GetSubscribed(ctx context.Context, appId primitive.ObjectID, event string) ([]*model.Webhook, error)
+	Update(ctx context.Context, id primitive.ObjectID, url string, events []string, enabled bool) (*model.Webhook, error)
+	Delete(ctx context.Context, id primitive.ObjectID) (bool, error)
+	AddEvent(ctx context.Context, subscribedTo string, event string) (int64, error)
+}
+
+type webhookRepository struct {
+	Connection *mongo.Database
+}
+
+func NewWebhookRepository(db *mongo.Database) WebhookRepository {
+	return &webhookRepository{Connection: db}
+}
+
+func (r *webhookRepository) EnsureIndexes(ctx context.Context) error {
+	collection := r.Connection.Collection("webhooks")
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "appId", Value: 1}},
+	})
+	return err
+}
+
+func (r *webhookRepository) Insert(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error) {
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+	collection := r.Connection.Collection("webhooks")
+	insertedWebhook, err := collection.InsertOne(ctx, webhook)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Id = insertedWebhook.InsertedID.(primitive.ObjectID)
+	return webhook, nil
+}
+
+func (r *webhookRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Webhook, error) {
+	collection := r.Connection.Collection("webhooks")
+	var webhook model.Webhook
+	err := collection.FindOne(ctx, bson.M{"_id": id}).Decode(&webhook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) GetAllByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Webhook, error) {
+	collection := r.Connection.Collection("webhooks")
+	cursor, err := collection.Find(ctx, bson.M{"appId": appId}, options.Find().SetSort(bson.M{"createdAt": 1}))
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []*model.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// GetSubscribed returns the enabled webhooks of the app listening to the event
+func (r *webhookRepository) GetSubscribed(ctx context.Context, appId primitive.ObjectID, event string) ([]*model.Webhook, error) {
+	collection := r.Connection.Collection("webhooks")
+	cursor, err := collection.Find(ctx, bson.M{
+		"appId":   appId,
+		"enabled": true,
+		"$or": bson.A{
+			bson.M{"events": event},
+			bson.M{"events": bson.M{"$size": 0}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	webhooks := []*model.Webhook{}
+	if err := cursor.All(ctx, &webhooks); err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+// Update returns nil when the webhook does not exist
+func (r *webhookRepository) Update(ctx context.Context, id primitive.ObjectID, url string, events []string, enabled bool) (*model.Webhook, error) {
+	collection := r.Connection.Collection("webhooks")
+	var webhook model.Webhook
+	err := collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"url": url, "events": events, "enabled": enabled, "updatedAt": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&webhook)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// AddEvent subscribes the webhooks listening to subscribedTo to event as well, returns how many changed
+func (r *webhookRepository) AddEvent(ctx context.Context, subscribedTo string, event string) (int64, error) {
+	collection := r.Connection.Collection("webhooks")
+	result, err := collection.UpdateMany(ctx,
+		bson.M{"events": subscribedTo},
+		bson.M{"$addToSet": bson.M{"events": event}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *webhookRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	collection := r.Connection.Collection("webhooks")
+	result, err := collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
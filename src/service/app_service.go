@@ -16,6 +16,7 @@ type AppService interface {
 	GetAppByName(ctx context.Context, appName string) (*model.App, error)
 	GetApps(ctx context.Context) ([]*model.App, error)
 	GetAppById(ctx context.Context, id string) (*model.App, error)
+	RenameApp(ctx context.Context, id string, appName string) (*model.App, error)
 }
 
 type appServiceImpl struct {
@@ -80,3 +81,26 @@ func (appService *appServiceImpl) GetAppById(ctx context.Context, id string) (*m
 	}
 	return existingApp, nil
 }
+
+// RenameApp changes the name of an app, deployment keys and history stay with it
+func (appService *appServiceImpl) RenameApp(ctx context.Context, id string, appName string) (*model.App, error) {
+	app, err := appService.GetAppById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	existingApp, err := appService.appRepository.GetByName(ctx, appName)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if existingApp != nil && existingApp.Id != app.Id {
+		return nil, errors.New("app with name " + appName + " already exists")
+	}
+	renamedApp, err := appService.appRepository.UpdateName(ctx, app.Id, appName)
+	if err != nil {
+		return nil, err
+	}
+	if renamedApp == nil {
+		return nil, errors.New("app not found")
+	}
+	return renamedApp, nil
+}
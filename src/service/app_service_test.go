@@ -50,6 +50,45 @@ func (m *MockAppRepository) GetAll(ctx context.Context) ([]*model.App, error) {
 	return args.Get(0).([]*model.App), args.Error(1)
 }
 
+func (m *MockAppRepository) UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.App, error) {
+	args := m.Called(ctx, id, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.App), args.Error(1)
+}
+
+func (m *MockAppRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	args := m.Called(ctx, id, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAppRepository) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, deletedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAppRepository) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.App, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.App), args.Error(1)
+}
+
+func (m *MockAppRepository) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.App, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.App), args.Error(1)
+}
+
+func (m *MockAppRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
 func TestNewAppService(t *testing.T) {
 	mockRepo := &MockAppRepository{}
 	service := NewAppService(mockRepo)
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrNotApprover      = errors.New("you do not have the role needed to approve releases to this environment")
+	ErrSelfApproval     = errors.New("a release has to be approved by someone other than the user who released it")
+	ErrNotPending       = errors.New("bundle is not waiting for approval")
+	ErrBundleRejected   = errors.New("bundle was rejected, release a new bundle instead")
+	ErrAwaitingApproval = errors.New("bundle is waiting for approval")
+)
+
+// ApprovalService holds releases to environments with an approval policy until a second user
+// approves them
+type ApprovalService interface {
+	RequestApproval(ctx context.Context, environment *model.Environment, bundle *model.Bundle, requestedBy string) (*model.Bundle, error)
+	Approve(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string, override *types.FreezeOverride) (*model.Bundle, error)
+	Reject(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string) (*model.Bundle, error)
+	Comment(ctx context.Context, bundleId primitive.ObjectID, user *model.User, text string) (*model.Bundle, error)
+	GetPending(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error)
+}
+
+type approvalService struct {
+	bundleRepository       repository.BundleRepository
+	versionRepository      repository.VersionRepository
+	environmentRepository  repository.EnvironmentRepository
+	releaseScheduleService ReleaseScheduleService
+	webhookService         WebhookService
+	auditService           AuditService
+	notifier               pkg.Notifier
+}
+
+func NewApprovalService(bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, environmentRepository repository.EnvironmentRepository, releaseScheduleService ReleaseScheduleService, webhookService WebhookService, auditService AuditService, notifier pkg.Notifier) ApprovalService {
+	return &approvalService{
+		bundleRepository:       bundleRepository,
+		versionRepository:      versionRepository,
+		environmentRepository:  environmentRepository,
+		releaseScheduleService: releaseScheduleService,
+		webhookService:         webhookService,
+		auditService:           auditService,
+		notifier:               notifier,
+	}
+}
+
+// approvalRequired reports whether releases to the environment wait for approval
+func approvalRequired(environment *model.Environment) bool {
+	return environment.ApprovalPolicy != nil && environment.ApprovalPolicy.Required
+}
+
+// RequestApproval puts a bundle in the pending state and tells the approvers about it
+func (s *approvalService) RequestApproval(ctx context.Context, environment *model.Environment, bundle *model.Bundle, requestedBy string) (*model.Bundle, error) {
+	approval := &model.Approval{Status: utils.APPROVAL_PENDING, RequestedBy: requestedBy, RequestedAt: time.Now()}
+	if err := s.bundleRepository.SetApproval(ctx, bundle.Id, approval); err != nil {
+		return nil, err
+	}
+	bundle.Approval = approval
+	details := map[string]interface{}{"label": bundle.Label, "requestedBy": requestedBy}
+	s.record(ctx, "RequestApproval", bundle, utils.AUDIT_ACTION_APPROVAL_REQUESTED, requestedBy, details)
+	s.publish(ctx, "RequestApproval", utils.WEBHOOK_EVENT_RELEASE_APPROVAL_REQUESTED, bundle, details)
+	s.notify(ctx, "RequestApproval", fmt.Sprintf("spread: %s released %s to %s, it is waiting for approval", requestedBy, bundle.Label, environment.Name))
+	return bundle, nil
+}
+
+// Approve releases a pending bundle. A scheduled bundle is left to the scheduler, any other is made
+// current right away, so it has to be outside the freeze windows of the environment unless overridden
+func (s *approvalService) Approve(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string, override *types.FreezeOverride) (*model.Bundle, error) {
+	bundle, environment, err := s.getPending(ctx, bundleId, approver)
+	if err != nil {
+		return nil, err
+	}
+	if approver.Username == bundle.Approval.RequestedBy {
+		return nil, ErrSelfApproval
+	}
+	var freezeWindow *model.FreezeWindow
+	if bundle.ScheduledAt == nil {
+		freezeWindow, err = s.releaseScheduleService.CheckFreeze(ctx, environment.Id, time.Now(), override)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := s.decide(ctx, bundle, utils.APPROVAL_APPROVED, approver.Username, comment); err != nil {
+		return nil, err
+	}
+	details := map[string]interface{}{"label": bundle.Label, "requestedBy": bundle.Approval.RequestedBy, "comment": comment}
+	s.record(ctx, "Approve", bundle, utils.AUDIT_ACTION_APPROVED, approver.Username, details)
+	s.publish(ctx, "Approve", utils.WEBHOOK_EVENT_RELEASE_APPROVED, bundle, details)
+	message := fmt.Sprintf("spread: %s approved %s to %s", approver.Username, bundle.Label, environment.Name)
+	if bundle.ScheduledAt != nil {
+		s.notify(ctx, "Approve", message+", it is released at "+bundle.ScheduledAt.Format(time.RFC3339))
+		return bundle, nil
+	}
+
+	if _, err := s.bundleRepository.UpdateIsValid(ctx, bundle.Id, true); err != nil {
+		return nil, err
+	}
+	bundle.IsValid = true
+	if err := makeCurrent(ctx, s.bundleRepository, s.versionRepository, bundle); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, "Approve", utils.WEBHOOK_EVENT_RELEASE_ENABLED, bundle, nil)
+	s.releaseScheduleService.RecordOverride(ctx, bundle, freezeWindow, override, "approve")
+	s.notify(ctx, "Approve", message+", it is released now")
+	return bundle, nil
+}
+
+// Reject stops a pending bundle from ever being released, its schedule is cancelled
+func (s *approvalService) Reject(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string) (*model.Bundle, error) {
+	if comment == "" {
+		return nil, errors.New("a comment is required to reject a release")
+	}
+	bundle, environment, err := s.getPending(ctx, bundleId, approver)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.decide(ctx, bundle, utils.APPROVAL_REJECTED, approver.Username, comment); err != nil {
+		return nil, err
+	}
+	bundle.ScheduledAt = nil
+	details := map[string]interface{}{"label": bundle.Label, "requestedBy": bundle.Approval.RequestedBy, "comment": comment}
+	s.record(ctx, "Reject", bundle, utils.AUDIT_ACTION_REJECTED, approver.Username, details)
+	s.publish(ctx, "Reject", utils.WEBHOOK_EVENT_RELEASE_REJECTED, bundle, details)
+	s.notify(ctx, "Reject", fmt.Sprintf("spread: %s rejected %s to %s: %s", approver.Username, bundle.Label, environment.Name, comment))
+	return bundle, nil
+}
+
+// Comment adds to the discussion of a release, anyone can comment whether it is decided or not
+func (s *approvalService) Comment(ctx context.Context, bundleId primitive.ObjectID, user *model.User, text string) (*model.Bundle, error) {
+	bundle, err := s.getBundle(ctx, bundleId)
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Approval == nil {
+		return nil, errors.New("bundle has no approval to comment on")
+	}
+	comment := &model.ApprovalComment{By: user.Username, Text: text, CreatedAt: time.Now()}
+	added, err := s.bundleRepository.AddApprovalComment(ctx, bundle.Id, comment)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, errors.New("bundle has no approval to comment on")
+	}
+	bundle.Approval.Comments = append(bundle.Approval.Comments, *comment)
+	s.record(ctx, "Comment", bundle, utils.AUDIT_ACTION_APPROVAL_COMMENTED, user.Username, map[string]interface{}{"label": bundle.Label, "comment": text})
+	s.notify(ctx, "Comment", fmt.Sprintf("spread: %s commented on %s: %s", user.Username, bundle.Label, text))
+	return bundle, nil
+}
+
+func (s *approvalService) GetPending(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error) {
+	return s.bundleRepository.GetAllPendingApproval(ctx, environmentId)
+}
+
+// getPending returns a pending bundle with its environment once the user may decide on it
+func (s *approvalService) getPending(ctx context.Context, bundleId primitive.ObjectID, user *model.User) (*model.Bundle, *model.Environment, error) {
+	bundle, err := s.getBundle(ctx, bundleId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if bundle.Approval == nil || bundle.Approval.Status != utils.APPROVAL_PENDING {
+		return nil, nil, ErrNotPending
+	}
+	environment, err := s.environmentRepository.GetById(ctx, bundle.EnvironmentId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, errors.New("environment not found")
+		}
+		return nil, nil, err
+	}
+	// a pending release of an environment whose policy was removed is still decided by admins
+	approverRole := utils.ROLE_ADMIN
+	if environment.ApprovalPolicy != nil && environment.ApprovalPolicy.ApproverRole != "" {
+		approverRole = environment.ApprovalPolicy.ApproverRole
+	}
+	if !utils.HasRole(user.Roles, approverRole) {
+		return nil, nil, ErrNotApprover
+	}
+	return bundle, environment, nil
+}
+
+func (s *approvalService) getBundle(ctx context.Context, bundleId primitive.ObjectID) (*model.Bundle, error) {
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// decide records the decision, it fails when someone else decided first
+func (s *approvalService) decide(ctx context.Context, bundle *model.Bundle, status string, decidedBy string, comment string) error {
+	now := time.Now()
+	var approvalComment *model.ApprovalComment
+	if comment != "" {
+		approvalComment = &model.ApprovalComment{By: decidedBy, Text: comment, CreatedAt: now}
+	}
+	decided, err := s.bundleRepository.DecideApproval(ctx, bundle.Id, status, decidedBy, approvalComment)
+	if err != nil {
+		return err
+	}
+	if !decided {
+		return ErrNotPending
+	}
+	bundle.Approval.Status = status
+	bundle.Approval.DecidedBy = decidedBy
+	bundle.Approval.DecidedAt = &now
+	if approvalComment != nil {
+		bundle.Approval.Comments = append(bundle.Approval.Comments, *approvalComment)
+	}
+	return nil
+}
+
+// audit events, webhooks and notifications follow a change that already happened, their
+// failures are logged and not returned
+func (s *approvalService) record(ctx context.Context, fn string, bundle *model.Bundle, action string, actor string, details map[string]interface{}) {
+	err := s.auditService.Record(ctx, &model.AuditEvent{
+		Action:        action,
+		Actor:         actor,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details:       details,
+	})
+	if err != nil {
+		logger.L.Error("In "+fn+": Error recording audit event", zap.String("action", action), zap.Error(err))
+	}
+}
+
+func (s *approvalService) publish(ctx context.Context, fn string, event string, bundle *model.Bundle, data map[string]interface{}) {
+	if err := s.webhookService.Publish(ctx, event, bundle, data); err != nil {
+		logger.L.Error("In "+fn+": Error publishing webhook event", zap.String("event", event), zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+}
+
+func (s *approvalService) notify(ctx context.Context, fn string, message string) {
+	if err := s.notifier.Notify(ctx, message); err != nil {
+		logger.L.Error("In "+fn+": Error sending notification", zap.Error(err))
+	}
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockApprovalService is a mock implementation of ApprovalService
+type MockApprovalService struct {
+	mock.Mock
+}
+
+func (m *MockApprovalService) RequestApproval(ctx context.Context, environment *model.Environment, bundle *model.Bundle, requestedBy string) (*model.Bundle, error) {
+	args := m.Called(ctx, environment, bundle, requestedBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockApprovalService) Approve(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string, override *types.FreezeOverride) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, approver, comment, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockApprovalService) Reject(ctx context.Context, bundleId primitive.ObjectID, approver *model.User, comment string) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, approver, comment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockApprovalService) Comment(ctx context.Context, bundleId primitive.ObjectID, user *model.User, text string) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, user, text)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockApprovalService) GetPending(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error) {
+	args := m.Called(ctx, environmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+type approvalTestMocks struct {
+	bundleRepository      *MockBundleRepository
+	versionRepository     *MockVersionRepository
+	environmentRepository *MockEnvironmentRepository
+	webhookService        *MockWebhookService
+	auditService          *MockAuditService
+	notifier              *MockNotifier
+}
+
+func newApprovalTestService() (ApprovalService, *approvalTestMocks) {
+	mocks := &approvalTestMocks{
+		bundleRepository:      &MockBundleRepository{},
+		versionRepository:     &MockVersionRepository{},
+		environmentRepository: &MockEnvironmentRepository{},
+		webhookService:        newPublishingWebhookService(),
+		auditService:          &MockAuditService{},
+		notifier:              &MockNotifier{},
+	}
+	mocks.auditService.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	mocks.notifier.On("Notify", mock.Anything, mock.Anything).Return(nil).Maybe()
+	service := NewApprovalService(mocks.bundleRepository, mocks.versionRepository, mocks.environmentRepository, newOpenReleaseScheduleService(), mocks.webhookService, mocks.auditService, mocks.notifier)
+	return service, mocks
+}
+
+func newPendingBundle(environment *model.Environment, requestedBy string) *model.Bundle {
+	return &model.Bundle{
+		Id:            primitive.NewObjectID(),
+		EnvironmentId: environment.Id,
+		VersionId:     primitive.NewObjectID(),
+		Label:         "v1x2",
+		SequenceId:    2,
+		Approval:      &model.Approval{Status: "pending", RequestedBy: requestedBy, RequestedAt: time.Now()},
+	}
+}
+
+func newApprovalEnvironment() *model.Environment {
+	return &model.Environment{
+		Id:             primitive.NewObjectID(),
+		Name:           "production",
+		ApprovalPolicy: &model.ApprovalPolicy{Required: true, ApproverRole: "release-manager"},
+	}
+}
+
+func TestApprovalService_RequestApproval_Success(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, Label: "v1x2"}
+	mocks.bundleRepository.On("SetApproval", ctx, bundle.Id, mock.MatchedBy(func(approval *model.Approval) bool {
+		return approval.Status == "pending" && approval.RequestedBy == "alice"
+	})).Return(nil)
+
+	// Execute
+	pendingBundle, err := service.RequestApproval(ctx, environment, bundle, "alice")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "pending", pendingBundle.Approval.Status)
+	mocks.webhookService.AssertCalled(t, "Publish", ctx, "release.approval_requested", bundle, mock.Anything)
+	mocks.notifier.AssertNumberOfCalls(t, "Notify", 1)
+}
+
+func TestApprovalService_Approve_MakesBundleCurrent(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	version := &model.Version{Id: bundle.VersionId}
+	approver := &model.User{Username: "bob", Roles: []string{"release-manager"}}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("DecideApproval", ctx, bundle.Id, "approved", "bob", mock.Anything).Return(true, nil)
+	mocks.bundleRepository.On("UpdateIsValid", ctx, bundle.Id, true).Return(bundle, nil)
+	mocks.versionRepository.On("GetById", ctx, bundle.VersionId).Return(version, nil)
+	mocks.versionRepository.On("UpdateCurrentBundleId", ctx, version.Id, bundle.Id).Return(version, nil)
+
+	// Execute
+	approvedBundle, err := service.Approve(ctx, bundle.Id, approver, "looks good", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, approvedBundle.IsValid)
+	assert.Equal(t, "approved", approvedBundle.Approval.Status)
+	assert.Equal(t, "bob", approvedBundle.Approval.DecidedBy)
+	assert.Len(t, approvedBundle.Approval.Comments, 1)
+	mocks.versionRepository.AssertExpectations(t)
+	mocks.webhookService.AssertCalled(t, "Publish", ctx, "release.approved", bundle, mock.Anything)
+	mocks.webhookService.AssertCalled(t, "Publish", ctx, "release.enabled", bundle, map[string]interface{}(nil))
+}
+
+func TestApprovalService_Approve_ScheduledBundleLeftToScheduler(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	scheduledAt := time.Now().Add(time.Hour)
+	bundle.ScheduledAt = &scheduledAt
+	approver := &model.User{Username: "bob", Roles: []string{"release-manager"}}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("DecideApproval", ctx, bundle.Id, "approved", "bob", (*model.ApprovalComment)(nil)).Return(true, nil)
+
+	// Execute
+	approvedBundle, err := service.Approve(ctx, bundle.Id, approver, "", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, approvedBundle.IsValid)
+	mocks.bundleRepository.AssertNotCalled(t, "UpdateIsValid", mock.Anything, mock.Anything, mock.Anything)
+	mocks.versionRepository.AssertNotCalled(t, "UpdateCurrentBundleId", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApprovalService_Approve_SelfApprovalRefused(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+
+	// Execute
+	_, err := service.Approve(ctx, bundle.Id, &model.User{Username: "alice", Roles: []string{"release-manager"}}, "", nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrSelfApproval)
+	mocks.bundleRepository.AssertNotCalled(t, "DecideApproval", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApprovalService_Approve_WrongRoleRefused(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+
+	// Execute
+	_, err := service.Approve(ctx, bundle.Id, &model.User{Username: "bob", Roles: []string{"admin"}}, "", nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotApprover)
+}
+
+func TestApprovalService_Approve_AlreadyDecided(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	// someone else decided between reading the bundle and deciding on it
+	mocks.bundleRepository.On("DecideApproval", ctx, bundle.Id, "approved", "bob", (*model.ApprovalComment)(nil)).Return(false, nil)
+
+	// Execute
+	_, err := service.Approve(ctx, bundle.Id, &model.User{Username: "bob", Roles: []string{"release-manager"}}, "", nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotPending)
+	mocks.bundleRepository.AssertNotCalled(t, "UpdateIsValid", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApprovalService_Reject_Success(t *testing.T) {
+	service, mocks := newApprovalTestService()
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := newPendingBundle(environment, "alice")
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("DecideApproval", ctx, bundle.Id, "rejected", "bob", mock.Anything).Return(true, nil)
+
+	// Execute
+	rejectedBundle, err := service.Reject(ctx, bundle.Id, &model.User{Username: "bob", Roles: []string{"release-manager"}}, "crashes on launch")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "rejected", rejectedBundle.Approval.Status)
+	mocks.webhookService.AssertCalled(t, "Publish", ctx, "release.rejected", bundle, mock.Anything)
+	mocks.versionRepository.AssertNotCalled(t, "UpdateCurrentBundleId", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBundleService_ToggleActive_RequestsApproval(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockApprovalService := &MockApprovalService{}
+	mockBundleRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), mockApprovalService, mockBundleRepo)
+
+	ctx := context.Background()
+	environment := newApprovalEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), AppId: primitive.NewObjectID(), EnvironmentId: environment.Id, Label: "v1x2"}
+	mockBundleRepo.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockEnvironmentService.On("GetEnvironmentByAppIdAndEnvironmentId", ctx, bundle.AppId, environment.Id.Hex()).Return(environment, nil)
+	mockApprovalService.On("RequestApproval", ctx, environment, bundle, "alice").Return(bundle, nil)
+
+	// Execute
+	_, err := service.ToggleActive(bundle.Id, "alice", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	mockApprovalService.AssertExpectations(t)
+	mockBundleRepo.AssertNotCalled(t, "UpdateIsValid", mock.Anything, mock.Anything, mock.Anything)
+}
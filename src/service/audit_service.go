@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how many events the audit log returns at most
+var auditEventLimit int64 = 200
+
+type AuditService interface {
+	Record(ctx context.Context, event *model.AuditEvent) error
+	GetEventsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.AuditEvent, error)
+}
+
+type auditService struct {
+	auditEventRepository repository.AuditEventRepository
+}
+
+func NewAuditService(auditEventRepository repository.AuditEventRepository) AuditService {
+	return &auditService{auditEventRepository: auditEventRepository}
+}
+
+func (s *auditService) Record(ctx context.Context, event *model.AuditEvent) error {
+	_, err := s.auditEventRepository.Insert(ctx, event)
+	return err
+}
+
+func (s *auditService) GetEventsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.AuditEvent, error) {
+	return s.auditEventRepository.GetAllByEnvironmentId(ctx, environmentId, auditEventLimit)
+}
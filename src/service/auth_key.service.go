@@ -2,16 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
 
+	"github.com/SwishHQ/spread/logger"
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/repository"
 	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
 )
 
 type AuthKeyService interface {
 	CreateAuthKey(name string, username string) (string, error)
 	GetByAuthKey(key string) (*model.AuthKey, error)
 	GetAllAuthKeys(ctx context.Context) ([]*model.AuthKey, error)
+	HashPlaintextKeys(ctx context.Context) error
+	DeleteAuthKey(ctx context.Context, id primitive.ObjectID) error
 }
 
 type authKeyService struct {
@@ -22,22 +28,26 @@ func NewAuthKeyService(authKeyRepository repository.AuthKeyRepository) AuthKeySe
 	return &authKeyService{authKeyRepository: authKeyRepository}
 }
 
+// only the hash and a short prefix of the key are stored, the plaintext key is
+// returned here once and cannot be recovered afterwards
 func (s *authKeyService) CreateAuthKey(name string, username string) (string, error) {
+	key := utils.GenerateAuthKey()
 	authKey := &model.AuthKey{
 		Name:      name,
-		Key:       utils.GenerateAuthKey(),
+		KeyHash:   utils.HashKey(key),
+		Prefix:    utils.AuthKeyPrefix(key),
 		IsValid:   true,
 		CreatedBy: username,
 	}
-	authKey, err := s.authKeyRepository.Insert(authKey)
+	_, err := s.authKeyRepository.Insert(authKey)
 	if err != nil {
 		return "", err
 	}
-	return authKey.Key, nil
+	return key, nil
 }
 
 func (s *authKeyService) GetByAuthKey(key string) (*model.AuthKey, error) {
-	authKey, err := s.authKeyRepository.GetById(key)
+	authKey, err := s.authKeyRepository.GetByKeyHash(utils.HashKey(key))
 	if err != nil {
 		return nil, err
 	}
@@ -47,3 +57,32 @@ func (s *authKeyService) GetByAuthKey(key string) (*model.AuthKey, error) {
 func (s *authKeyService) GetAllAuthKeys(ctx context.Context) ([]*model.AuthKey, error) {
 	return s.authKeyRepository.GetAll(ctx)
 }
+
+// HashPlaintextKeys replaces the plaintext key of auth keys created before keys
+// were hashed with its hash and prefix, existing keys keep working
+func (s *authKeyService) HashPlaintextKeys(ctx context.Context) error {
+	authKeys, err := s.authKeyRepository.GetAllWithPlaintextKey(ctx)
+	if err != nil {
+		return err
+	}
+	for _, authKey := range authKeys {
+		err = s.authKeyRepository.UpdateKeyHash(ctx, authKey.Id, utils.HashKey(authKey.Key), utils.AuthKeyPrefix(authKey.Key))
+		if err != nil {
+			return err
+		}
+	}
+	logger.L.Info("In HashPlaintextKeys: Hashed plaintext auth keys", zap.Int("count", len(authKeys)))
+	return nil
+}
+
+// DeleteAuthKey deletes an auth key, releases made with it stop working right away
+func (s *authKeyService) DeleteAuthKey(ctx context.Context, id primitive.ObjectID) error {
+	deleted, err := s.authKeyRepository.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("auth key not found")
+	}
+	return nil
+}
@@ -3,10 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -25,8 +27,8 @@ func (m *MockAuthKeyRepository) Insert(authKey *model.AuthKey) (*model.AuthKey,
 	return args.Get(0).(*model.AuthKey), args.Error(1)
 }
 
-func (m *MockAuthKeyRepository) GetById(key string) (*model.AuthKey, error) {
-	args := m.Called(key)
+func (m *MockAuthKeyRepository) GetByKeyHash(keyHash string) (*model.AuthKey, error) {
+	args := m.Called(keyHash)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
@@ -41,6 +43,19 @@ func (m *MockAuthKeyRepository) GetAll(ctx context.Context) ([]*model.AuthKey, e
 	return args.Get(0).([]*model.AuthKey), args.Error(1)
 }
 
+func (m *MockAuthKeyRepository) GetAllWithPlaintextKey(ctx context.Context) ([]*model.AuthKey, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.AuthKey), args.Error(1)
+}
+
+func (m *MockAuthKeyRepository) UpdateKeyHash(ctx context.Context, id primitive.ObjectID, keyHash string, prefix string) error {
+	args := m.Called(ctx, id, keyHash, prefix)
+	return args.Error(0)
+}
+
 func (m *MockAuthKeyRepository) GetByUser(ctx context.Context, username string) ([]*model.AuthKey, error) {
 	args := m.Called(ctx, username)
 	if args.Get(0) == nil {
@@ -49,6 +64,11 @@ func (m *MockAuthKeyRepository) GetByUser(ctx context.Context, username string)
 	return args.Get(0).([]*model.AuthKey), args.Error(1)
 }
 
+func (m *MockAuthKeyRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestNewAuthKeyService(t *testing.T) {
 	mockRepo := &MockAuthKeyRepository{}
 	service := NewAuthKeyService(mockRepo)
@@ -68,7 +88,6 @@ func TestAuthKeyService_CreateAuthKey_Success(t *testing.T) {
 	expectedAuthKey := &model.AuthKey{
 		Id:        primitive.NewObjectID(),
 		Name:      name,
-		Key:       "generated-key-123",
 		IsValid:   true,
 		CreatedBy: username,
 		CreatedAt: time.Now(),
@@ -82,7 +101,6 @@ func TestAuthKeyService_CreateAuthKey_Success(t *testing.T) {
 	// Assert
 	assert.NoError(t, err)
 	assert.NotEmpty(t, result)
-	assert.Equal(t, expectedAuthKey.Key, result)
 
 	// Verify the auth key was created with correct fields
 	mockRepo.AssertExpectations(t)
@@ -91,7 +109,11 @@ func TestAuthKeyService_CreateAuthKey_Success(t *testing.T) {
 	assert.Equal(t, name, authKeyArg.Name)
 	assert.Equal(t, username, authKeyArg.CreatedBy)
 	assert.True(t, authKeyArg.IsValid)
-	assert.NotEmpty(t, authKeyArg.Key) // Should be generated
+	// Only the hash and prefix of the generated key should be stored
+	assert.Empty(t, authKeyArg.Key)
+	assert.Equal(t, utils.HashKey(result), authKeyArg.KeyHash)
+	assert.Equal(t, utils.AuthKeyPrefix(result), authKeyArg.Prefix)
+	assert.True(t, strings.HasPrefix(result, utils.AUTH_KEY_PREFIX))
 }
 
 func TestAuthKeyService_CreateAuthKey_InsertError(t *testing.T) {
@@ -125,13 +147,14 @@ func TestAuthKeyService_GetByAuthKey_Success(t *testing.T) {
 	expectedAuthKey := &model.AuthKey{
 		Id:        primitive.NewObjectID(),
 		Name:      "test-auth-key",
-		Key:       key,
+		KeyHash:   utils.HashKey(key),
+		Prefix:    utils.AuthKeyPrefix(key),
 		IsValid:   true,
 		CreatedBy: "testuser",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
-	mockRepo.On("GetById", key).Return(expectedAuthKey, nil)
+	mockRepo.On("GetByKeyHash", utils.HashKey(key)).Return(expectedAuthKey, nil)
 
 	// Execute
 	result, err := service.GetByAuthKey(key)
@@ -139,7 +162,7 @@ func TestAuthKeyService_GetByAuthKey_Success(t *testing.T) {
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.Equal(t, expectedAuthKey.Key, result.Key)
+	assert.Equal(t, expectedAuthKey.KeyHash, result.KeyHash)
 	assert.Equal(t, expectedAuthKey.Name, result.Name)
 	assert.Equal(t, expectedAuthKey.CreatedBy, result.CreatedBy)
 
@@ -152,8 +175,8 @@ func TestAuthKeyService_GetByAuthKey_NotFound(t *testing.T) {
 
 	key := "nonexistent-key"
 
-	// Mock GetById to return nil (not found)
-	mockRepo.On("GetById", key).Return(nil, nil)
+	// Mock GetByKeyHash to return nil (not found)
+	mockRepo.On("GetByKeyHash", utils.HashKey(key)).Return(nil, nil)
 
 	// Execute
 	result, err := service.GetByAuthKey(key)
@@ -171,8 +194,8 @@ func TestAuthKeyService_GetByAuthKey_RepositoryError(t *testing.T) {
 
 	key := "test-key-123"
 
-	// Mock GetById to return an error
-	mockRepo.On("GetById", key).Return(nil, errors.New("database error"))
+	// Mock GetByKeyHash to return an error
+	mockRepo.On("GetByKeyHash", utils.HashKey(key)).Return(nil, errors.New("database error"))
 
 	// Execute
 	result, err := service.GetByAuthKey(key)
@@ -196,7 +219,7 @@ func TestAuthKeyService_GetAllAuthKeys_Success(t *testing.T) {
 		{
 			Id:        primitive.NewObjectID(),
 			Name:      "auth-key-1",
-			Key:       "key-1",
+			Prefix:    "KEY1AAAA",
 			IsValid:   true,
 			CreatedBy: "user1",
 			CreatedAt: time.Now(),
@@ -205,7 +228,7 @@ func TestAuthKeyService_GetAllAuthKeys_Success(t *testing.T) {
 		{
 			Id:        primitive.NewObjectID(),
 			Name:      "auth-key-2",
-			Key:       "key-2",
+			Prefix:    "KEY2AAAA",
 			IsValid:   false,
 			CreatedBy: "user2",
 			CreatedAt: time.Now(),
@@ -278,7 +301,6 @@ func TestAuthKeyService_CreateAuthKey_GeneratesUniqueKeys(t *testing.T) {
 	authKey1 := &model.AuthKey{
 		Id:        primitive.NewObjectID(),
 		Name:      name,
-		Key:       "unique-key-1",
 		IsValid:   true,
 		CreatedBy: username,
 		CreatedAt: time.Now(),
@@ -287,7 +309,6 @@ func TestAuthKeyService_CreateAuthKey_GeneratesUniqueKeys(t *testing.T) {
 	authKey2 := &model.AuthKey{
 		Id:        primitive.NewObjectID(),
 		Name:      name,
-		Key:       "unique-key-2",
 		IsValid:   true,
 		CreatedBy: username,
 		CreatedAt: time.Now(),
@@ -310,3 +331,71 @@ func TestAuthKeyService_CreateAuthKey_GeneratesUniqueKeys(t *testing.T) {
 
 	mockRepo.AssertExpectations(t)
 }
+
+func TestAuthKeyService_HashPlaintextKeys_Success(t *testing.T) {
+	mockRepo := &MockAuthKeyRepository{}
+	service := NewAuthKeyService(mockRepo)
+
+	ctx := context.Background()
+
+	legacyAuthKey := &model.AuthKey{
+		Id:        primitive.NewObjectID(),
+		Name:      "legacy-auth-key",
+		Key:       "LEGACYKEY0123456789ABCDEFGHIJKLM",
+		IsValid:   true,
+		CreatedBy: "testuser",
+	}
+	mockRepo.On("GetAllWithPlaintextKey", ctx).Return([]*model.AuthKey{legacyAuthKey}, nil)
+	mockRepo.On("UpdateKeyHash", ctx, legacyAuthKey.Id, utils.HashKey(legacyAuthKey.Key), "LEGACYKEY012").Return(nil)
+
+	// Execute
+	err := service.HashPlaintextKeys(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthKeyService_HashPlaintextKeys_UpdateError(t *testing.T) {
+	mockRepo := &MockAuthKeyRepository{}
+	service := NewAuthKeyService(mockRepo)
+
+	ctx := context.Background()
+
+	legacyAuthKey := &model.AuthKey{
+		Id:  primitive.NewObjectID(),
+		Key: "LEGACYKEY0123456789ABCDEFGHIJKLM",
+	}
+	mockRepo.On("GetAllWithPlaintextKey", ctx).Return([]*model.AuthKey{legacyAuthKey}, nil)
+	mockRepo.On("UpdateKeyHash", ctx, legacyAuthKey.Id, mock.Anything, mock.Anything).Return(errors.New("database error"))
+
+	// Execute
+	err := service.HashPlaintextKeys(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, "database error", err.Error())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuthKeyService_DeleteAuthKey(t *testing.T) {
+	mockRepo := &MockAuthKeyRepository{}
+	service := NewAuthKeyService(mockRepo)
+
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	unknownId := primitive.NewObjectID()
+	mockRepo.On("Delete", ctx, id).Return(true, nil)
+	mockRepo.On("Delete", ctx, unknownId).Return(false, nil)
+
+	// Execute
+	err := service.DeleteAuthKey(ctx, id)
+	notFoundErr := service.DeleteAuthKey(ctx, unknownId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.EqualError(t, notFoundErr, "auth key not found")
+	mockRepo.AssertExpectations(t)
+}
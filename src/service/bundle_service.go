@@ -7,6 +7,7 @@ import (
 	"mime/multipart"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/SwishHQ/spread/config"
 	"github.com/SwishHQ/spread/logger"
@@ -29,23 +30,30 @@ type BundleService interface {
 	GetBundleByHashAndVersionId(hash string, versionId primitive.ObjectID) (*model.Bundle, error)
 	GetBundlesByVersionId(versionId primitive.ObjectID) ([]*model.Bundle, error)
 	ToggleMandatory(bundleId primitive.ObjectID) error
-	ToggleActive(bundleId primitive.ObjectID) error
+	ToggleActive(bundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Bundle, error)
 	AddActive(ctx context.Context, id primitive.ObjectID) error
 	AddFailed(ctx context.Context, id primitive.ObjectID) error
 	AddInstalled(ctx context.Context, id primitive.ObjectID) error
 	DecrementActive(ctx context.Context, id primitive.ObjectID) error
+	DisableAndRollback(ctx context.Context, bundle *model.Bundle) (*model.Bundle, bool, error)
+	GetBundlesWithActiveDevices(ctx context.Context) ([]*model.Bundle, error)
+	IsMandatoryFrom(ctx context.Context, bundle *model.Bundle, label string, device *types.DeviceAttributes) (bool, error)
+	UpdateDescription(ctx context.Context, bundleId primitive.ObjectID, description string) (*model.Bundle, error)
 }
 
 type bundleService struct {
-	appService         AppService
-	versionService     VersionService
-	environmentService EnvironmentService
+	appService             AppService
+	versionService         VersionService
+	environmentService     EnvironmentService
+	webhookService         WebhookService
+	releaseScheduleService ReleaseScheduleService
+	approvalService        ApprovalService
 
 	bundleRepository repository.BundleRepository
 }
 
-func NewBundleService(appService AppService, versionService VersionService, environmentService EnvironmentService, bundleRepository repository.BundleRepository) BundleService {
-	return &bundleService{appService: appService, versionService: versionService, environmentService: environmentService, bundleRepository: bundleRepository}
+func NewBundleService(appService AppService, versionService VersionService, environmentService EnvironmentService, webhookService WebhookService, releaseScheduleService ReleaseScheduleService, approvalService ApprovalService, bundleRepository repository.BundleRepository) BundleService {
+	return &bundleService{appService: appService, versionService: versionService, environmentService: environmentService, webhookService: webhookService, releaseScheduleService: releaseScheduleService, approvalService: approvalService, bundleRepository: bundleRepository}
 }
 
 func (bundleService *bundleService) UploadBundle(fileName string, file *multipart.FileHeader) error {
@@ -75,6 +83,8 @@ func (bundleService *bundleService) UploadBundle(fileName string, file *multipar
 
 // we check if a version (0.0.1) exists, if it does then we create a new bundle and set the version id to the bundle
 // if it doesn't exist then we create a new version and set the bundle id to the version
+// a scheduled bundle is not made current, the release scheduler does that once it is due, and a bundle
+// of an environment that requires approval waits for it before it is made current
 func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBundleRequest, createdBy string) (*model.Bundle, error) {
 	logger.L.Info("In CreateNewBundle: Creating new bundle", zap.Any("payload", createdBy))
 	// Retrieve the app by name
@@ -93,6 +103,34 @@ func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBund
 	if environment == nil {
 		return nil, errors.New("environment not found")
 	}
+	targeting, err := newTargeting(payload.Targeting)
+	if err != nil {
+		return nil, err
+	}
+	var override *types.FreezeOverride
+	if payload.OverrideFreezeReason != "" {
+		override = &types.FreezeOverride{By: createdBy, Reason: payload.OverrideFreezeReason}
+	}
+	releaseAt := time.Now()
+	if payload.ScheduledAt != nil {
+		if !payload.ScheduledAt.After(releaseAt) {
+			return nil, errors.New("scheduled time must be in the future")
+		}
+		releaseAt = *payload.ScheduledAt
+	}
+	held := payload.ScheduledAt != nil || approvalRequired(environment)
+	// an unscheduled release waiting for approval goes out when approved, the freeze is checked then
+	var freezeWindow *model.FreezeWindow
+	if payload.ScheduledAt != nil || !approvalRequired(environment) {
+		freezeWindow, err = bundleService.releaseScheduleService.CheckFreeze(context.Background(), environment.Id, releaseAt, override)
+		if err != nil {
+			return nil, err
+		}
+	}
+	freezeOverrideReason := ""
+	if payload.ScheduledAt != nil && freezeWindow != nil {
+		freezeOverrideReason = override.Reason
+	}
 	// Retrieve the version by environment ID and app version
 	version, err := bundleService.versionService.GetVersionByEnvironmentIdAndAppVersion(context.Background(), environment.Id, payload.AppVersion)
 	if err != nil {
@@ -106,16 +144,21 @@ func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBund
 			AppId:         app.Id,
 			EnvironmentId: environment.Id,
 			DownloadFile:  payload.DownloadFile,
+			SourceMapFile: payload.SourceMapFile,
 			Size:          payload.Size,
 			Hash:          payload.Hash,
 			Description:   payload.Description,
-			IsMandatory:   false,
+			IsMandatory:   payload.IsMandatory,
 			Failed:        0,
 			Installed:     0,
 			IsValid:       false,
 			Label:         "v" + strconv.Itoa(int(versionNumber)) + "x" + strconv.Itoa(1),
 			CreatedBy:     createdBy,
 			SequenceId:    1,
+
+			ScheduledAt:          payload.ScheduledAt,
+			FreezeOverrideReason: freezeOverrideReason,
+			Targeting:            targeting,
 		}
 
 		bundle, err = bundleService.bundleRepository.CreateBundle(context.Background(), bundle)
@@ -128,6 +171,9 @@ func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBund
 			VersionNumber:   versionNumber,
 			CurrentBundleId: bundle.Id,
 		}
+		if held {
+			version.CurrentBundleId = primitive.NilObjectID
+		}
 		_, err = bundleService.versionService.CreateVersion(context.Background(), version)
 		if err != nil {
 			return nil, err
@@ -138,7 +184,7 @@ func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBund
 		if err != nil {
 			return nil, err
 		}
-		return bundle, nil
+		return bundleService.released(environment, bundle, freezeWindow, override)
 	}
 	// If version exists, check if a bundle with the same hash already exists
 	existingBundle, err := bundleService.GetBundleByHashAndVersionId(payload.Hash, version.Id)
@@ -158,26 +204,48 @@ func (bundleService *bundleService) CreateNewBundle(payload *types.CreateNewBund
 		AppId:         app.Id,
 		EnvironmentId: environment.Id,
 		DownloadFile:  payload.DownloadFile,
+		SourceMapFile: payload.SourceMapFile,
 		Size:          payload.Size,
 		Hash:          payload.Hash,
 		Description:   payload.Description,
 		SequenceId:    sequenceId,
 		VersionId:     version.Id,
 		CreatedBy:     createdBy,
-		IsMandatory:   false,
+		IsMandatory:   payload.IsMandatory,
 		Failed:        0,
 		Installed:     0,
 		Label:         "v" + strconv.Itoa(int(version.VersionNumber)) + "x" + strconv.Itoa(int(sequenceId)),
 		IsValid:       false,
+
+		ScheduledAt:          payload.ScheduledAt,
+		FreezeOverrideReason: freezeOverrideReason,
+		Targeting:            targeting,
 	}
 	bundle, err = bundleService.bundleRepository.CreateBundle(context.Background(), bundle)
 	if err != nil {
 		return nil, err
 	}
-	version.CurrentBundleId = bundle.Id
-	_, err = bundleService.versionService.UpdateVersionCurrentBundleIdByVersionId(context.Background(), version.Id, bundle.Id)
-	if err != nil {
-		return nil, err
+	if !held {
+		version.CurrentBundleId = bundle.Id
+		_, err = bundleService.versionService.UpdateVersionCurrentBundleIdByVersionId(context.Background(), version.Id, bundle.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bundleService.released(environment, bundle, freezeWindow, override)
+}
+
+// released announces a created bundle, records its schedule and the freeze window it overrides and
+// asks for approval when the environment requires it
+func (bundleService *bundleService) released(environment *model.Environment, bundle *model.Bundle, freezeWindow *model.FreezeWindow, override *types.FreezeOverride) (*model.Bundle, error) {
+	bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_CREATED, bundle, nil)
+	if bundle.ScheduledAt != nil {
+		bundleService.releaseScheduleService.RecordScheduled(context.Background(), bundle, freezeWindow, override, bundle.CreatedBy)
+	} else {
+		bundleService.releaseScheduleService.RecordOverride(context.Background(), bundle, freezeWindow, override, "create")
+	}
+	if approvalRequired(environment) {
+		return bundleService.approvalService.RequestApproval(context.Background(), environment, bundle, bundle.CreatedBy)
 	}
 	return bundle, nil
 }
@@ -232,6 +300,10 @@ func (bundleService *bundleService) Rollback(rollbackRequest *types.RollbackRequ
 		return nil, err
 	}
 	// if reollback bundle which is the previous bundle to rollback to is not present
+	// a release that was never approved cannot be rolled back to
+	if rollbackBundle != nil && rollbackBundle.Approval != nil && rollbackBundle.Approval.Status != utils.APPROVAL_APPROVED {
+		return nil, errors.New("previous bundle " + rollbackBundle.Label + " was not approved and cannot be rolled back to")
+	}
 	if rollbackBundle == nil {
 		version.CurrentBundleId = primitive.NilObjectID
 		_, err = bundleService.versionService.UpdateVersionCurrentBundleIdByVersionId(context.Background(), version.Id, primitive.NilObjectID)
@@ -239,6 +311,7 @@ func (bundleService *bundleService) Rollback(rollbackRequest *types.RollbackRequ
 			logger.L.Error("In Rollback: Error updating version current bundle id with nil", zap.Error(err))
 			return nil, err
 		}
+		bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_ROLLED_BACK, bundle, map[string]interface{}{"rolledBackTo": nil})
 		return nil, nil
 	}
 	version.CurrentBundleId = rollbackBundle.Id
@@ -247,6 +320,7 @@ func (bundleService *bundleService) Rollback(rollbackRequest *types.RollbackRequ
 		logger.L.Error("In Rollback: Error updating version current bundle id", zap.Error(err))
 		return nil, err
 	}
+	bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_ROLLED_BACK, bundle, map[string]interface{}{"rolledBackTo": rollbackBundle.Label})
 	return rollbackBundle, nil
 }
 
@@ -287,20 +361,72 @@ func (bundleService *bundleService) ToggleMandatory(bundleId primitive.ObjectID)
 	if err != nil {
 		return err
 	}
+	bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_MANDATORY_CHANGED, bundle, nil)
 	return nil
 }
 
-func (bundleService *bundleService) ToggleActive(bundleId primitive.ObjectID) error {
+// ToggleActive enables or disables a bundle, enabling it during a freeze window needs an override.
+// Enabling a bundle of an environment that requires approval asks for approval instead, the bundle is
+// enabled once it is approved
+func (bundleService *bundleService) ToggleActive(bundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Bundle, error) {
 	bundle, err := bundleService.bundleRepository.GetById(context.Background(), bundleId)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	var freezeWindow *model.FreezeWindow
+	if !bundle.IsValid {
+		if bundle.ScheduledAt != nil {
+			return nil, errors.New("bundle is scheduled, cancel the schedule to release it now")
+		}
+		if bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_REJECTED {
+			return nil, ErrBundleRejected
+		}
+		if bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_PENDING {
+			return nil, ErrAwaitingApproval
+		}
+		environment, err := bundleService.environmentService.GetEnvironmentByAppIdAndEnvironmentId(context.Background(), bundle.AppId, bundle.EnvironmentId.Hex())
+		if err != nil {
+			return nil, err
+		}
+		if environment == nil {
+			return nil, errors.New("environment not found")
+		}
+		if bundle.Approval == nil && approvalRequired(environment) {
+			return bundleService.approvalService.RequestApproval(context.Background(), environment, bundle, actor)
+		}
+		freezeWindow, err = bundleService.releaseScheduleService.CheckFreeze(context.Background(), bundle.EnvironmentId, time.Now(), override)
+		if err != nil {
+			return nil, err
+		}
 	}
 	bundle.IsValid = !bundle.IsValid
 	_, err = bundleService.bundleRepository.UpdateIsValid(context.Background(), bundleId, bundle.IsValid)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	// a new bundle is created disabled, enabling it is what releases it to devices
+	if bundle.IsValid {
+		bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_ENABLED, bundle, nil)
+		bundleService.releaseScheduleService.RecordOverride(context.Background(), bundle, freezeWindow, override, "promote")
+	} else {
+		bundleService.publish(context.Background(), utils.WEBHOOK_EVENT_RELEASE_DISABLED, bundle, nil)
+	}
+	return bundle, nil
+}
+
+func (bundleService *bundleService) UpdateDescription(ctx context.Context, bundleId primitive.ObjectID, description string) (*model.Bundle, error) {
+	bundle, err := bundleService.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	if _, err := bundleService.bundleRepository.UpdateDescription(ctx, bundleId, description); err != nil {
+		return nil, err
+	}
+	bundle.Description = description
+	return bundle, nil
 }
 
 func (bundleService *bundleService) GetBundleByHashAndVersionId(hash string, versionId primitive.ObjectID) (*model.Bundle, error) {
@@ -340,3 +466,82 @@ func (bundleService *bundleService) AddInstalled(ctx context.Context, id primiti
 func (bundleService *bundleService) DecrementActive(ctx context.Context, id primitive.ObjectID) error {
 	return bundleService.bundleRepository.DecrementActive(ctx, id)
 }
+
+func (bundleService *bundleService) GetBundlesWithActiveDevices(ctx context.Context) ([]*model.Bundle, error) {
+	return bundleService.bundleRepository.GetAllWithActiveDevices(ctx)
+}
+
+// IsMandatoryFrom reports whether a device running label has to install bundle, which is the case
+// when bundle or any enabled release of the version it skips is mandatory. An empty or unknown
+// label, or one of another version, means the device runs the binary and skips every release.
+// Skipped releases whose targeting leaves the device out were never offered to it and don't count
+func (bundleService *bundleService) IsMandatoryFrom(ctx context.Context, bundle *model.Bundle, label string, device *types.DeviceAttributes) (bool, error) {
+	if bundle.IsMandatory {
+		return true, nil
+	}
+	var afterSequenceId int64
+	if label != "" {
+		installed, err := bundleService.bundleRepository.GetByLabelAndEnvironmentId(ctx, label, bundle.EnvironmentId)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return false, err
+		}
+		if installed != nil && installed.VersionId == bundle.VersionId {
+			afterSequenceId = installed.SequenceId
+		}
+	}
+	// a device ahead of bundle after a rollback skips nothing
+	if afterSequenceId >= bundle.SequenceId {
+		return false, nil
+	}
+	skipped, err := bundleService.bundleRepository.GetMandatoryBetween(ctx, bundle.EnvironmentId, bundle.VersionId, afterSequenceId, bundle.SequenceId)
+	if err != nil {
+		return false, err
+	}
+	for _, mandatory := range skipped {
+		if mandatory.Targeting == nil || matchesTargeting(mandatory.Targeting, device) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DisableAndRollback disables the bundle and, when it is the current bundle of its version, moves the
+// version back to the newest valid bundle released before it. The returned bundle is the one now
+// served, nil means devices stay on the binary. false when the bundle was already disabled
+func (bundleService *bundleService) DisableAndRollback(ctx context.Context, bundle *model.Bundle) (*model.Bundle, bool, error) {
+	disabled, err := bundleService.bundleRepository.Disable(ctx, bundle.Id)
+	if err != nil {
+		return nil, false, err
+	}
+	if !disabled {
+		return nil, false, nil
+	}
+	version, err := bundleService.versionService.GetByVersionId(ctx, bundle.VersionId)
+	if err != nil {
+		return nil, true, err
+	}
+	// a newer release already replaced the bundle, disabling it is enough
+	if version.CurrentBundleId != bundle.Id {
+		return nil, true, nil
+	}
+	lastGoodBundle, err := bundleService.bundleRepository.GetLastValidBefore(ctx, bundle.EnvironmentId, bundle.VersionId, bundle.SequenceId)
+	if err != nil {
+		return nil, true, err
+	}
+	currentBundleId := primitive.NilObjectID
+	if lastGoodBundle != nil {
+		currentBundleId = lastGoodBundle.Id
+	}
+	_, err = bundleService.versionService.UpdateVersionCurrentBundleIdByVersionId(ctx, version.Id, currentBundleId)
+	if err != nil {
+		return nil, true, err
+	}
+	return lastGoodBundle, true, nil
+}
+
+// webhooks are best effort, a release must not fail because its event could not be queued
+func (bundleService *bundleService) publish(ctx context.Context, event string, bundle *model.Bundle, data map[string]interface{}) {
+	if err := bundleService.webhookService.Publish(ctx, event, bundle, data); err != nil {
+		logger.L.Error("In publish: Error publishing webhook event", zap.String("event", event), zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+}
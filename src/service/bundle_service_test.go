@@ -128,6 +128,46 @@ func (m *MockEnvironmentService) GetEnvironmentByAppIdAndEnvironmentId(ctx conte
 	return args.Get(0).(*model.Environment), args.Error(1)
 }
 
+func (m *MockEnvironmentService) RotateEnvironmentKey(ctx context.Context, environmentId primitive.ObjectID, gracePeriod time.Duration) (*model.Environment, error) {
+	args := m.Called(ctx, environmentId, gracePeriod)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentService) UpdateRollbackPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateRollbackPolicyRequest) (*model.Environment, error) {
+	args := m.Called(ctx, environmentId, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentService) RenameEnvironment(ctx context.Context, environmentId primitive.ObjectID, environmentName string) (*model.Environment, error) {
+	args := m.Called(ctx, environmentId, environmentName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentService) UpdateFreezeWindows(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateFreezeWindowsRequest) (*model.Environment, error) {
+	args := m.Called(ctx, environmentId, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentService) UpdateApprovalPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateApprovalPolicyRequest) (*model.Environment, error) {
+	args := m.Called(ctx, environmentId, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
 // MockBundleRepository is a mock implementation of BundleRepository
 type MockBundleRepository struct {
 	mock.Mock
@@ -238,13 +278,126 @@ func (m *MockBundleRepository) DecrementActive(ctx context.Context, id primitive
 	return args.Error(0)
 }
 
+func (m *MockBundleRepository) GetAllWithActiveDevices(ctx context.Context) ([]*model.Bundle, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleRepository) Disable(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) GetLastValidBefore(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, sequenceId int64) (*model.Bundle, error) {
+	args := m.Called(ctx, environmentId, versionId, sequenceId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleRepository) DeleteByVersionId(ctx context.Context, versionId primitive.ObjectID) error {
+	args := m.Called(ctx, versionId)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) DeleteByIds(ctx context.Context, ids []primitive.ObjectID) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) GetAllDownloadFiles(ctx context.Context) ([]string, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockBundleRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) GetDueScheduled(ctx context.Context, before time.Time) ([]*model.Bundle, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleRepository) ClaimScheduled(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) UpdateSchedule(ctx context.Context, id primitive.ObjectID, scheduledAt time.Time, freezeOverrideReason string) (bool, error) {
+	args := m.Called(ctx, id, scheduledAt, freezeOverrideReason)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) CancelSchedule(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) SetApproval(ctx context.Context, id primitive.ObjectID, approval *model.Approval) error {
+	args := m.Called(ctx, id, approval)
+	return args.Error(0)
+}
+
+func (m *MockBundleRepository) DecideApproval(ctx context.Context, id primitive.ObjectID, status string, decidedBy string, comment *model.ApprovalComment) (bool, error) {
+	args := m.Called(ctx, id, status, decidedBy, comment)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) AddApprovalComment(ctx context.Context, id primitive.ObjectID, comment *model.ApprovalComment) (bool, error) {
+	args := m.Called(ctx, id, comment)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) GetAllPendingApproval(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Bundle, error) {
+	args := m.Called(ctx, environmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleRepository) UpdateTargeting(ctx context.Context, id primitive.ObjectID, targeting *model.Targeting) (bool, error) {
+	args := m.Called(ctx, id, targeting)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) GetMandatoryBetween(ctx context.Context, environmentId primitive.ObjectID, versionId primitive.ObjectID, afterSequenceId int64, upToSequenceId int64) ([]*model.Bundle, error) {
+	args := m.Called(ctx, environmentId, versionId, afterSequenceId, upToSequenceId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleRepository) UpdateSourceMapFile(ctx context.Context, id primitive.ObjectID, sourceMapFile string) (bool, error) {
+	args := m.Called(ctx, id, sourceMapFile)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleRepository) UpdateDescription(ctx context.Context, id primitive.ObjectID, description string) (bool, error) {
+	args := m.Called(ctx, id, description)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestNewBundleService(t *testing.T) {
 	mockAppService := &MockAppService{}
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
 
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	assert.NotNil(t, service)
 	assert.IsType(t, &bundleService{}, service)
@@ -255,7 +408,7 @@ func TestBundleService_GetBundleById_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleID := primitive.NewObjectID()
@@ -289,7 +442,7 @@ func TestBundleService_GetBundleById_Error(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleID := primitive.NewObjectID()
@@ -310,7 +463,7 @@ func TestBundleService_GetBundleByLabelAndEnvironmentId_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	label := "v1x1"
@@ -342,7 +495,7 @@ func TestBundleService_GetBundleByLabelAndEnvironmentId_NotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	label := "v1x1"
@@ -363,7 +516,7 @@ func TestBundleService_GetBundleByLabelAndEnvironmentId_Error(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	label := "v1x1"
@@ -385,7 +538,7 @@ func TestBundleService_GetBundlesByVersionId_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	versionId := primitive.NewObjectID()
@@ -429,7 +582,7 @@ func TestBundleService_GetBundlesByVersionId_Error(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	versionId := primitive.NewObjectID()
@@ -450,7 +603,7 @@ func TestBundleService_ToggleMandatory_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -480,7 +633,7 @@ func TestBundleService_ToggleMandatory_GetByIdError(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -500,7 +653,7 @@ func TestBundleService_ToggleActive_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -516,11 +669,13 @@ func TestBundleService_ToggleActive_Success(t *testing.T) {
 	}
 
 	mockBundleRepo.On("GetById", ctx, bundleId).Return(existingBundle, nil)
+	mockEnvironmentService.On("GetEnvironmentByAppIdAndEnvironmentId", ctx, existingBundle.AppId, existingBundle.EnvironmentId.Hex()).Return(&model.Environment{}, nil)
 	mockBundleRepo.On("UpdateIsValid", ctx, bundleId, true).Return(updatedBundle, nil)
 
-	err := service.ToggleActive(bundleId)
+	bundle, err := service.ToggleActive(bundleId, "alice", nil)
 
 	assert.NoError(t, err)
+	assert.True(t, bundle.IsValid)
 
 	mockBundleRepo.AssertExpectations(t)
 }
@@ -530,14 +685,14 @@ func TestBundleService_ToggleActive_GetByIdError(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
 
 	mockBundleRepo.On("GetById", ctx, bundleId).Return(nil, errors.New("database error"))
 
-	err := service.ToggleActive(bundleId)
+	_, err := service.ToggleActive(bundleId, "alice", nil)
 
 	assert.Error(t, err)
 	assert.Equal(t, "database error", err.Error())
@@ -550,7 +705,7 @@ func TestBundleService_AddActive_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -569,7 +724,7 @@ func TestBundleService_AddActive_Error(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -589,7 +744,7 @@ func TestBundleService_AddFailed_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -608,7 +763,7 @@ func TestBundleService_AddInstalled_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -627,7 +782,7 @@ func TestBundleService_DecrementActive_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockBundleRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
 
 	ctx := context.Background()
 	bundleId := primitive.NewObjectID()
@@ -646,7 +801,7 @@ func TestBundleService_GetBundleByHashAndVersionId_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	hash := "test-hash"
@@ -678,7 +833,7 @@ func TestBundleService_GetBundleByHashAndVersionId_NotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	hash := "test-hash"
@@ -702,7 +857,7 @@ func TestBundleService_GetBundleByHashAndVersionId_Error(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	hash := "test-hash"
@@ -727,7 +882,7 @@ func TestBundleService_CreateNewBundle_NewVersion_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	createdBy := "test-user"
@@ -821,7 +976,7 @@ func TestBundleService_CreateNewBundle_ExistingVersion_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	createdBy := "test-user"
@@ -920,7 +1075,7 @@ func TestBundleService_CreateNewBundle_AppNotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	createdBy := "test-user"
@@ -953,7 +1108,7 @@ func TestBundleService_CreateNewBundle_EnvironmentNotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	createdBy := "test-user"
@@ -996,7 +1151,7 @@ func TestBundleService_CreateNewBundle_DuplicateHash(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	createdBy := "test-user"
@@ -1067,7 +1222,7 @@ func TestBundleService_Rollback_Success(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1147,7 +1302,7 @@ func TestBundleService_Rollback_AppNotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1175,7 +1330,7 @@ func TestBundleService_Rollback_EnvironmentNotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1215,7 +1370,7 @@ func TestBundleService_Rollback_InvalidVersionId(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1261,7 +1416,7 @@ func TestBundleService_Rollback_VersionNotFound(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1313,7 +1468,7 @@ func TestBundleService_Rollback_NoCurrentBundle(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1372,7 +1527,7 @@ func TestBundleService_Rollback_BaseBundleCannotRollback(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1440,7 +1595,7 @@ func TestBundleService_Rollback_NoPreviousBundle(t *testing.T) {
 	mockVersionService := &MockVersionService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockRepo := &MockBundleRepository{}
-	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, mockRepo)
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
 
 	ctx := context.Background()
 	rollbackRequest := &types.RollbackRequest{
@@ -1507,3 +1662,274 @@ func TestBundleService_Rollback_NoPreviousBundle(t *testing.T) {
 	mockVersionService.AssertExpectations(t)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestBundleService_DisableAndRollback_MovesVersionBack(t *testing.T) {
+	mockVersionService := &MockVersionService{}
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, mockVersionService, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), SequenceId: 3, Label: "v1x3", IsValid: true}
+	lastGoodBundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1", SequenceId: 1, IsValid: true}
+	version := &model.Version{Id: bundle.VersionId, CurrentBundleId: bundle.Id}
+
+	mockRepo.On("Disable", ctx, bundle.Id).Return(true, nil)
+	mockVersionService.On("GetByVersionId", ctx, bundle.VersionId).Return(version, nil)
+	mockRepo.On("GetLastValidBefore", ctx, bundle.EnvironmentId, bundle.VersionId, int64(3)).Return(lastGoodBundle, nil)
+	mockVersionService.On("UpdateVersionCurrentBundleIdByVersionId", ctx, version.Id, lastGoodBundle.Id).Return(version, nil)
+
+	// Execute
+	currentBundle, disabled, err := service.DisableAndRollback(ctx, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+	assert.Equal(t, lastGoodBundle, currentBundle)
+
+	mockRepo.AssertExpectations(t)
+	mockVersionService.AssertExpectations(t)
+}
+
+func TestBundleService_DisableAndRollback_NoGoodBundleServesBinary(t *testing.T) {
+	mockVersionService := &MockVersionService{}
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, mockVersionService, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), SequenceId: 1, IsValid: true}
+	version := &model.Version{Id: bundle.VersionId, CurrentBundleId: bundle.Id}
+
+	mockRepo.On("Disable", ctx, bundle.Id).Return(true, nil)
+	mockVersionService.On("GetByVersionId", ctx, bundle.VersionId).Return(version, nil)
+	mockRepo.On("GetLastValidBefore", ctx, bundle.EnvironmentId, bundle.VersionId, int64(1)).Return(nil, nil)
+	mockVersionService.On("UpdateVersionCurrentBundleIdByVersionId", ctx, version.Id, primitive.NilObjectID).Return(version, nil)
+
+	// Execute
+	currentBundle, disabled, err := service.DisableAndRollback(ctx, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, disabled)
+	assert.Nil(t, currentBundle)
+
+	mockVersionService.AssertExpectations(t)
+}
+
+func TestBundleService_DisableAndRollback_AlreadyDisabled(t *testing.T) {
+	mockVersionService := &MockVersionService{}
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, mockVersionService, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), VersionId: primitive.NewObjectID()}
+	mockRepo.On("Disable", ctx, bundle.Id).Return(false, nil)
+
+	// Execute
+	currentBundle, disabled, err := service.DisableAndRollback(ctx, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, disabled)
+	assert.Nil(t, currentBundle)
+	mockVersionService.AssertNotCalled(t, "GetByVersionId", mock.Anything, mock.Anything)
+}
+
+func TestBundleService_ToggleActive_PublishesPromoted(t *testing.T) {
+	mockWebhookService := &MockWebhookService{}
+	mockBundleRepo := &MockBundleRepository{}
+	mockEnvironmentService := &MockEnvironmentService{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, mockEnvironmentService, mockWebhookService, newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", IsValid: false}
+	mockBundleRepo.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockEnvironmentService.On("GetEnvironmentByAppIdAndEnvironmentId", ctx, bundle.AppId, bundle.EnvironmentId.Hex()).Return(&model.Environment{}, nil)
+	mockBundleRepo.On("UpdateIsValid", ctx, bundle.Id, true).Return(bundle, nil)
+	mockWebhookService.On("Publish", ctx, "release.enabled", bundle, map[string]interface{}(nil)).Return(nil)
+
+	// Execute
+	_, err := service.ToggleActive(bundle.Id, "alice", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	mockWebhookService.AssertExpectations(t)
+}
+
+func TestBundleService_ToggleMandatory_PublishErrorIgnored(t *testing.T) {
+	mockWebhookService := &MockWebhookService{}
+	mockBundleRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, mockWebhookService, newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+	mockBundleRepo.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockBundleRepo.On("UpdateIsMandatoryById", ctx, bundle.Id, true).Return(bundle, nil)
+	mockWebhookService.On("Publish", ctx, "release.mandatory_changed", bundle, map[string]interface{}(nil)).Return(errors.New("mongo unavailable"))
+
+	// Execute
+	err := service.ToggleMandatory(bundle.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	mockWebhookService.AssertExpectations(t)
+}
+
+func TestBundleService_CreateNewBundle_Frozen(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockReleaseScheduleService := &MockReleaseScheduleService{}
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(mockAppService, &MockVersionService{}, mockEnvironmentService, newPublishingWebhookService(), mockReleaseScheduleService, &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	payload := &types.CreateNewBundleRequest{AppName: "test-app", Environment: "Production", AppVersion: "1.0.0", Hash: "test-hash"}
+	app := &model.App{Id: primitive.NewObjectID(), Name: payload.AppName}
+	environment := &model.Environment{Id: primitive.NewObjectID(), AppId: app.Id, Name: payload.Environment}
+	mockAppService.On("GetAppByName", ctx, payload.AppName).Return(app, nil)
+	mockEnvironmentService.On("GetEnvironmentByAppIdAndName", ctx, app.Id, payload.Environment).Return(environment, nil)
+	mockReleaseScheduleService.On("CheckFreeze", ctx, environment.Id, mock.Anything, (*types.FreezeOverride)(nil)).Return(nil, ErrReleaseFrozen)
+
+	// Execute
+	result, err := service.CreateNewBundle(payload, "test-user")
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReleaseFrozen)
+	assert.Nil(t, result)
+	mockRepo.AssertNotCalled(t, "CreateBundle", mock.Anything, mock.Anything)
+}
+
+func TestBundleService_CreateNewBundle_ScheduledKeepsCurrentBundle(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockVersionService := &MockVersionService{}
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockReleaseScheduleService := newOpenReleaseScheduleService()
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(mockAppService, mockVersionService, mockEnvironmentService, newPublishingWebhookService(), mockReleaseScheduleService, &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	scheduledAt := time.Now().Add(2 * time.Hour)
+	payload := &types.CreateNewBundleRequest{AppName: "test-app", Environment: "Production", AppVersion: "1.0.0", Hash: "test-hash", ScheduledAt: &scheduledAt}
+	app := &model.App{Id: primitive.NewObjectID(), Name: payload.AppName}
+	environment := &model.Environment{Id: primitive.NewObjectID(), AppId: app.Id, Name: payload.Environment}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, VersionNumber: 1, CurrentBundleId: primitive.NewObjectID()}
+	mockAppService.On("GetAppByName", ctx, payload.AppName).Return(app, nil)
+	mockEnvironmentService.On("GetEnvironmentByAppIdAndName", ctx, app.Id, payload.Environment).Return(environment, nil)
+	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, payload.AppVersion).Return(version, nil)
+	mockRepo.On("GetByHashAndVersionId", ctx, payload.Hash, version.Id).Return(nil, mongo.ErrNoDocuments)
+	mockRepo.On("GetNextSeqByEnvironmentIdAndVersionId", ctx, environment.Id, version.Id).Return(int64(2), nil)
+	mockRepo.On("CreateBundle", ctx, mock.MatchedBy(func(bundle *model.Bundle) bool {
+		return bundle.ScheduledAt != nil && bundle.ScheduledAt.Equal(scheduledAt) && !bundle.IsValid
+	})).Return(&model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", ScheduledAt: &scheduledAt, CreatedBy: "test-user"}, nil)
+
+	// Execute
+	result, err := service.CreateNewBundle(payload, "test-user")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "v1x2", result.Label)
+	mockRepo.AssertExpectations(t)
+	mockReleaseScheduleService.AssertCalled(t, "CheckFreeze", ctx, environment.Id, scheduledAt, (*types.FreezeOverride)(nil))
+	mockReleaseScheduleService.AssertCalled(t, "RecordScheduled", ctx, result, (*model.FreezeWindow)(nil), (*types.FreezeOverride)(nil), "test-user")
+	mockVersionService.AssertNotCalled(t, "UpdateVersionCurrentBundleIdByVersionId", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBundleService_IsMandatoryFrom_SkippedMandatoryRelease(t *testing.T) {
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	environmentId, versionId := primitive.NewObjectID(), primitive.NewObjectID()
+	installed := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 2, Label: "v1x2"}
+	latest := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 4, Label: "v1x4"}
+	mockRepo.On("GetByLabelAndEnvironmentId", ctx, "v1x2", environmentId).Return(installed, nil)
+	mockRepo.On("GetMandatoryBetween", ctx, environmentId, versionId, int64(2), int64(4)).Return([]*model.Bundle{{Label: "v1x3", SequenceId: 3}}, nil)
+
+	// Execute
+	isMandatory, err := service.IsMandatoryFrom(ctx, latest, "v1x2", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, isMandatory)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestBundleService_IsMandatoryFrom_BinaryOrUnknownLabel(t *testing.T) {
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	latest := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), SequenceId: 4}
+	otherVersion := &model.Bundle{EnvironmentId: latest.EnvironmentId, VersionId: primitive.NewObjectID(), SequenceId: 9, Label: "v2x9"}
+	mockRepo.On("GetByLabelAndEnvironmentId", ctx, "v2x9", latest.EnvironmentId).Return(otherVersion, nil)
+	mockRepo.On("GetByLabelAndEnvironmentId", ctx, "v1x99", latest.EnvironmentId).Return(nil, mongo.ErrNoDocuments)
+	mockRepo.On("GetMandatoryBetween", ctx, latest.EnvironmentId, latest.VersionId, int64(0), int64(4)).Return([]*model.Bundle{}, nil)
+
+	// Execute and Assert
+	for _, label := range []string{"", "v2x9", "v1x99"} {
+		isMandatory, err := service.IsMandatoryFrom(ctx, latest, label, nil)
+		assert.NoError(t, err)
+		assert.False(t, isMandatory)
+	}
+	mockRepo.AssertNumberOfCalls(t, "GetMandatoryBetween", 3)
+}
+
+func TestBundleService_IsMandatoryFrom_DeviceAheadAfterRollback(t *testing.T) {
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	environmentId, versionId := primitive.NewObjectID(), primitive.NewObjectID()
+	installed := &model.Bundle{EnvironmentId: environmentId, VersionId: versionId, SequenceId: 5, Label: "v1x5"}
+	rolledBackTo := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 3}
+	mockRepo.On("GetByLabelAndEnvironmentId", ctx, "v1x5", environmentId).Return(installed, nil)
+
+	// Execute
+	isMandatory, err := service.IsMandatoryFrom(ctx, rolledBackTo, "v1x5", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, isMandatory)
+	mockRepo.AssertNotCalled(t, "GetMandatoryBetween", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestBundleService_IsMandatoryFrom_SkipsMandatoryReleasesNotTargetingDevice(t *testing.T) {
+	mockRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockRepo)
+
+	ctx := context.Background()
+	environmentId, versionId := primitive.NewObjectID(), primitive.NewObjectID()
+	installed := &model.Bundle{EnvironmentId: environmentId, VersionId: versionId, SequenceId: 2, Label: "v1x2"}
+	latest := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 4, Label: "v1x4"}
+	// v1x3 was mandatory for the QA devices only
+	qaOnly := &model.Bundle{Label: "v1x3", SequenceId: 3, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	mockRepo.On("GetByLabelAndEnvironmentId", ctx, "v1x2", environmentId).Return(installed, nil)
+	mockRepo.On("GetMandatoryBetween", ctx, environmentId, versionId, int64(2), int64(4)).Return([]*model.Bundle{qaOnly}, nil)
+
+	// Execute
+	forCustomer, err := service.IsMandatoryFrom(ctx, latest, "v1x2", &types.DeviceAttributes{ClientUniqueId: "customer-device"})
+	assert.NoError(t, err)
+	forQA, err := service.IsMandatoryFrom(ctx, latest, "v1x2", &types.DeviceAttributes{ClientUniqueId: "qa-device"})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.False(t, forCustomer)
+	assert.True(t, forQA)
+}
+
+func TestBundleService_UpdateDescription(t *testing.T) {
+	mockBundleRepo := &MockBundleRepository{}
+	service := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockBundleRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", Description: "fix login"}
+	mockBundleRepo.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockBundleRepo.On("UpdateDescription", ctx, bundle.Id, "fix login on Android 14").Return(true, nil)
+
+	// Execute
+	updated, err := service.UpdateDescription(ctx, bundle.Id, "fix login on Android 14")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "fix login on Android 14", updated.Description)
+	mockBundleRepo.AssertExpectations(t)
+}
@@ -6,13 +6,16 @@ import (
 
 	"github.com/SwishHQ/spread/config"
 	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/types"
 	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
 type ClientService interface {
-	CheckUpdate(environmentKey string, appVersion string, bundleHash string) (*types.UpdateInfo, error)
+	CheckUpdate(environmentKey string, appVersion string, bundleHash string, label string, device *types.DeviceAttributes) (*types.UpdateInfo, error)
 	ReportStatusDeploy(reportStatusRequest *types.ReportStatusDeployRequest) error
 	ReportStatusDownload(reportStatusRequest *types.ReportStatusDownloadRequest) error
 }
@@ -22,21 +25,47 @@ type clientService struct {
 	environmentService EnvironmentService
 	bundleService      BundleService
 	versionService     VersionService
+	deviceService      DeviceService
+	metricService      MetricService
+	rollbackService    RollbackPolicyService
+	targetingService   TargetingService
+	experimentService  ExperimentService
 }
 
-func NewClientService(appService AppService, environmentService EnvironmentService, bundleService BundleService, versionService VersionService) ClientService {
+func NewClientService(appService AppService, environmentService EnvironmentService, bundleService BundleService, versionService VersionService, deviceService DeviceService, metricService MetricService, rollbackService RollbackPolicyService, targetingService TargetingService, experimentService ExperimentService) ClientService {
 	return &clientService{
 		appService:         appService,
 		environmentService: environmentService,
 		bundleService:      bundleService,
 		versionService:     versionService,
+		deviceService:      deviceService,
+		metricService:      metricService,
+		rollbackService:    rollbackService,
+		targetingService:   targetingService,
+		experimentService:  experimentService,
 	}
 }
 
 // check for new update for a given environment and app version
 // if there is a new update, return the update info
 // if there is no update, return nil
-func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bundleHash string) (*types.UpdateInfo, error) {
+func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bundleHash string, label string, device *types.DeviceAttributes) (*types.UpdateInfo, error) {
+	updateInfo, err := s.checkUpdate(environmentKey, appVersion, bundleHash, label, device)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case updateInfo == nil:
+		pkg.UpdateChecksTotal.WithLabelValues("none").Inc()
+	case updateInfo.UpdateAppVersion:
+		pkg.UpdateChecksTotal.WithLabelValues("binary").Inc()
+	default:
+		pkg.UpdateChecksTotal.WithLabelValues("bundle").Inc()
+	}
+	return updateInfo, nil
+}
+
+func (s *clientService) checkUpdate(environmentKey string, appVersion string, bundleHash string, label string, device *types.DeviceAttributes) (*types.UpdateInfo, error) {
 	var updateInfo *types.UpdateInfo
 	environment, err := s.environmentService.GetEnvironmentByKey(context.Background(), environmentKey)
 	if err != nil {
@@ -57,6 +86,10 @@ func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bu
 		logger.L.Error("In CheckUpdate: Version not found", zap.String("environmentId", environment.Id.Hex()), zap.String("appVersion", appVersion))
 		return updateInfo, nil
 	}
+	// the only bundles of the version are scheduled, or it was rolled back past its first bundle
+	if version.CurrentBundleId.IsZero() {
+		return updateInfo, nil
+	}
 
 	bundle, err := s.bundleService.GetBundleById(version.CurrentBundleId)
 	if err != nil {
@@ -67,6 +100,26 @@ func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bu
 		logger.L.Error("In CheckUpdate: Bundle not found", zap.String("bundleId", version.CurrentBundleId.Hex()))
 		return updateInfo, nil
 	}
+	// while an experiment runs the device gets the variant of its cohort instead of the current bundle
+	if !version.ExperimentId.IsZero() && device != nil {
+		variant, err := s.experimentService.AssignBundle(context.Background(), version, device.ClientUniqueId)
+		if err != nil {
+			logger.L.Error("In CheckUpdate: Error assigning experiment variant", zap.String("experimentId", version.ExperimentId.Hex()), zap.Error(err))
+			return updateInfo, err
+		}
+		if variant != nil {
+			bundle = variant
+		}
+	}
+	// a device the current bundle does not target gets an earlier one, or stays on what it runs
+	if bundle.Targeting != nil {
+		bundleId := bundle.Id
+		bundle, err = s.targetingService.ResolveBundle(context.Background(), bundle, device)
+		if err != nil {
+			logger.L.Error("In CheckUpdate: Error resolving targeted bundle", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+			return updateInfo, err
+		}
+	}
 
 	latestVersion, err := s.versionService.GetLatestVersionByEnvironmentId(context.Background(), environment.Id)
 	if err != nil {
@@ -74,13 +127,19 @@ func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bu
 		return updateInfo, err
 	}
 
-	if bundle.Hash != bundleHash && appVersion == version.AppVersion {
+	if bundle != nil && bundle.Hash != bundleHash && appVersion == version.AppVersion {
+		// a device skipping a mandatory release has to install this one as well
+		isMandatory, err := s.bundleService.IsMandatoryFrom(context.Background(), bundle, label, device)
+		if err != nil {
+			logger.L.Error("In CheckUpdate: Error checking skipped mandatory releases", zap.String("bundleId", bundle.Id.Hex()), zap.String("label", label), zap.Error(err))
+			return updateInfo, err
+		}
 		updateInfo = &types.UpdateInfo{
 			DownloadUrl:            utils.GetBaseBucketUrl(config.ENV) + "/" + bundle.DownloadFile,
 			Description:            bundle.Description,
 			IsAvailable:            bundle.IsValid,
 			IsDisabled:             false,
-			IsMandatory:            bundle.IsMandatory,
+			IsMandatory:            isMandatory,
 			TargetBinaryRange:      appVersion,
 			PackageHash:            bundle.Hash,
 			Label:                  bundle.Label,
@@ -104,9 +163,15 @@ func (s *clientService) CheckUpdate(environmentKey string, appVersion string, bu
 }
 
 // we retrive bundle using the labelId, during checkupdate we send label as bundleId which
-// the SDK sends back to report status. Use the label to fetch bundle and react to the status
+// the SDK sends back to report status. Use the label to fetch bundle and react to the status.
+// Counters only move when the recorded state of the device changes, so SDK retries are not counted twice
 func (s *clientService) ReportStatusDeploy(reportStatusRequest *types.ReportStatusDeployRequest) error {
-	environment, err := s.environmentService.GetEnvironmentByKey(context.Background(), reportStatusRequest.DeploymentKey)
+	ctx := context.Background()
+	if reportStatusRequest.ClientUniqueId == "" {
+		logger.L.Error("In ReportStatusDeploy: Missing client unique id", zap.String("deploymentKey", reportStatusRequest.DeploymentKey))
+		return errors.New("client unique id is required")
+	}
+	environment, err := s.environmentService.GetEnvironmentByKey(ctx, reportStatusRequest.DeploymentKey)
 	if err != nil {
 		logger.L.Error("In ReportStatusDeploy: Error getting environment by key", zap.String("deploymentKey", reportStatusRequest.DeploymentKey), zap.Error(err))
 		return err
@@ -125,55 +190,145 @@ func (s *clientService) ReportStatusDeploy(reportStatusRequest *types.ReportStat
 		return errors.New("bundle not found")
 	}
 
-	if reportStatusRequest.Status == "DeploymentSucceeded" {
-		err = s.bundleService.AddActive(context.Background(), bundle.Id)
+	if reportStatusRequest.Status == utils.DEPLOYMENT_FAILED {
+		changed, err := s.deviceService.RecordDeployFailed(ctx, environment.Id, reportStatusRequest.ClientUniqueId, reportStatusRequest.AppVersion, bundle.Label)
 		if err != nil {
-			logger.L.Error("In ReportStatusDeploy: Error adding active", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+			logger.L.Error("In ReportStatusDeploy: Error recording failed deploy", zap.String("clientUniqueId", reportStatusRequest.ClientUniqueId), zap.Error(err))
 			return err
 		}
-	}
-
-	if reportStatusRequest.Status == "DeploymentFailed" {
-		err = s.bundleService.AddFailed(context.Background(), bundle.Id)
+		if !changed {
+			return nil
+		}
+		err = s.bundleService.AddFailed(ctx, bundle.Id)
 		if err != nil {
 			logger.L.Error("In ReportStatusDeploy: Error adding failed", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
 			return err
 		}
+		s.recordEvent(ctx, bundle, utils.METRIC_FAILURES)
+		// the failure is recorded whatever the policy decides, a rollback must not fail the report
+		if _, err := s.rollbackService.Evaluate(ctx, environment, bundle); err != nil {
+			logger.L.Error("In ReportStatusDeploy: Error evaluating rollback policy", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+		}
+		return nil
 	}
 
-	// if the previous label or app version is not nil, we need to decrement the active count of the previous bundle
-	if reportStatusRequest.Status == "DeploymentSucceeded" && reportStatusRequest.PreviousLabelOrAppVersion != nil && reportStatusRequest.PreviousDeploymentKey != nil {
-		logger.L.Info("In ReportStatusDeploy: Decrementing active count of previous bundle", zap.String("previousDeploymentKey", *reportStatusRequest.PreviousDeploymentKey), zap.String("previousLabelOrAppVersion", *reportStatusRequest.PreviousLabelOrAppVersion))
-		previousEnvironment, err := s.environmentService.GetEnvironmentByKey(context.Background(), *reportStatusRequest.PreviousDeploymentKey)
-		if err != nil {
-			logger.L.Error("In ReportStatusDeploy: Error getting environment by key", zap.String("deploymentKey", *reportStatusRequest.PreviousDeploymentKey), zap.Error(err))
-			return nil
-		}
-		if previousEnvironment == nil {
-			logger.L.Error("In ReportStatusDeploy: Previous environment not found", zap.String("previousDeploymentKey", *reportStatusRequest.PreviousDeploymentKey))
-			return nil
-		}
-		previousBundle, err := s.bundleService.GetBundleByLabelAndEnvironmentId(*reportStatusRequest.PreviousLabelOrAppVersion, previousEnvironment.Id)
+	if reportStatusRequest.Status != utils.DEPLOYMENT_SUCCEEDED {
+		return nil
+	}
+	previousState, changed, err := s.deviceService.RecordDeploySucceeded(ctx, environment.Id, reportStatusRequest.ClientUniqueId, reportStatusRequest.AppVersion, bundle)
+	if err != nil {
+		logger.L.Error("In ReportStatusDeploy: Error recording deploy", zap.String("clientUniqueId", reportStatusRequest.ClientUniqueId), zap.Error(err))
+		return err
+	}
+	if !changed {
+		// the device already runs this bundle, a retried report
+		return nil
+	}
+	err = s.bundleService.AddActive(ctx, bundle.Id)
+	if err != nil {
+		logger.L.Error("In ReportStatusDeploy: Error adding active", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+		return err
+	}
+	s.recordEvent(ctx, bundle, utils.METRIC_INSTALLS)
+	s.recordActiveDevices(ctx, bundle.Id)
+
+	// we know which bundle the device ran before, it is no longer active there
+	if previousState != nil && !previousState.CurrentBundleId.IsZero() {
+		previousBundle, err := s.bundleService.GetBundleById(previousState.CurrentBundleId)
 		if err != nil {
-			logger.L.Error("In ReportStatusDeploy: Error getting bundle by label", zap.String("bundleId", *reportStatusRequest.PreviousLabelOrAppVersion), zap.Error(err))
+			logger.L.Error("In ReportStatusDeploy: Error getting previous bundle", zap.String("bundleId", previousState.CurrentBundleId.Hex()), zap.Error(err))
 			return err
 		}
 		if previousBundle == nil {
-			logger.L.Error("In ReportStatusDeploy: Previous bundle not found", zap.String("bundleLabel", *reportStatusRequest.PreviousLabelOrAppVersion))
-			return errors.New("previous bundle not found")
+			return nil
 		}
-		// if previous bundle exist, then we decrement the active count of the previous bundle
-		err = s.bundleService.DecrementActive(context.Background(), previousBundle.Id)
+		return s.leaveBundle(ctx, previousBundle, bundle)
+	}
+	return s.releasePreviousDeployment(ctx, reportStatusRequest, bundle)
+}
+
+// releasePreviousDeployment decrements the active count of the bundle the SDK says it ran before,
+// used when we have no record of what the device ran in this environment: the device came from
+// another environment or was counted before devices were recorded
+func (s *clientService) releasePreviousDeployment(ctx context.Context, reportStatusRequest *types.ReportStatusDeployRequest, bundle *model.Bundle) error {
+	if reportStatusRequest.PreviousLabelOrAppVersion == nil || reportStatusRequest.PreviousDeploymentKey == nil {
+		return nil
+	}
+	logger.L.Info("In ReportStatusDeploy: Decrementing active count of previous bundle", zap.String("previousDeploymentKey", *reportStatusRequest.PreviousDeploymentKey), zap.String("previousLabelOrAppVersion", *reportStatusRequest.PreviousLabelOrAppVersion))
+	previousEnvironment, err := s.environmentService.GetEnvironmentByKey(ctx, *reportStatusRequest.PreviousDeploymentKey)
+	if err != nil {
+		logger.L.Error("In ReportStatusDeploy: Error getting environment by key", zap.String("deploymentKey", *reportStatusRequest.PreviousDeploymentKey), zap.Error(err))
+		return nil
+	}
+	if previousEnvironment == nil {
+		logger.L.Error("In ReportStatusDeploy: Previous environment not found", zap.String("previousDeploymentKey", *reportStatusRequest.PreviousDeploymentKey))
+		return nil
+	}
+	previousBundle, err := s.bundleService.GetBundleByLabelAndEnvironmentId(*reportStatusRequest.PreviousLabelOrAppVersion, previousEnvironment.Id)
+	if err != nil {
+		logger.L.Error("In ReportStatusDeploy: Error getting bundle by label", zap.String("bundleId", *reportStatusRequest.PreviousLabelOrAppVersion), zap.Error(err))
+		return err
+	}
+	if previousBundle == nil {
+		// the device ran the binary version, no bundle to decrement
+		logger.L.Info("In ReportStatusDeploy: Previous bundle not found", zap.String("bundleLabel", *reportStatusRequest.PreviousLabelOrAppVersion))
+		return nil
+	}
+	if previousEnvironment.Id != bundle.EnvironmentId {
+		previousDevice, err := s.deviceService.GetDevice(ctx, previousEnvironment.Id, reportStatusRequest.ClientUniqueId)
 		if err != nil {
-			logger.L.Error("In ReportStatusDeploy: Error decrementing active", zap.String("bundleId", previousBundle.Id.Hex()), zap.Error(err))
+			logger.L.Error("In ReportStatusDeploy: Error getting previous device", zap.String("clientUniqueId", reportStatusRequest.ClientUniqueId), zap.Error(err))
 			return err
 		}
+		// when the device is recorded there, only decrement what it was counted as running
+		if previousDevice != nil {
+			released, err := s.deviceService.ReleaseBundle(ctx, previousEnvironment.Id, reportStatusRequest.ClientUniqueId, previousBundle.Label)
+			if err != nil {
+				logger.L.Error("In ReportStatusDeploy: Error releasing previous bundle", zap.String("clientUniqueId", reportStatusRequest.ClientUniqueId), zap.Error(err))
+				return err
+			}
+			if !released {
+				return nil
+			}
+		}
 	}
+	// if previous bundle exist, then we decrement the active count of the previous bundle
+	return s.leaveBundle(ctx, previousBundle, bundle)
+}
 
+// leaveBundle decrements the active count of the bundle a device moved away from, moving to
+// an older bundle of the same version is a rollback
+func (s *clientService) leaveBundle(ctx context.Context, previousBundle *model.Bundle, bundle *model.Bundle) error {
+	err := s.bundleService.DecrementActive(ctx, previousBundle.Id)
+	if err != nil {
+		logger.L.Error("In ReportStatusDeploy: Error decrementing active", zap.String("bundleId", previousBundle.Id.Hex()), zap.Error(err))
+		return err
+	}
+	s.recordActiveDevices(ctx, previousBundle.Id)
+	if previousBundle.VersionId == bundle.VersionId && previousBundle.SequenceId > bundle.SequenceId {
+		s.recordEvent(ctx, previousBundle, utils.METRIC_ROLLBACKS)
+	}
 	return nil
 }
 
+// metrics are best effort, a failed write must not fail the report of the SDK
+func (s *clientService) recordEvent(ctx context.Context, bundle *model.Bundle, metric string) {
+	if err := s.metricService.RecordEvent(ctx, bundle, metric); err != nil {
+		logger.L.Error("In recordEvent: Error recording metric", zap.String("metric", metric), zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+}
+
+func (s *clientService) recordActiveDevices(ctx context.Context, bundleId primitive.ObjectID) {
+	if err := s.metricService.RecordActiveDevices(ctx, bundleId); err != nil {
+		logger.L.Error("In recordActiveDevices: Error recording active devices", zap.String("bundleId", bundleId.Hex()), zap.Error(err))
+	}
+}
+
 func (s *clientService) ReportStatusDownload(reportStatusRequest *types.ReportStatusDownloadRequest) error {
+	if reportStatusRequest.ClientUniqueId == "" {
+		logger.L.Error("In ReportStatusDownload: Missing client unique id", zap.String("deploymentKey", reportStatusRequest.DeploymentKey))
+		return errors.New("client unique id is required")
+	}
 	environment, err := s.environmentService.GetEnvironmentByKey(context.Background(), reportStatusRequest.DeploymentKey)
 	if err != nil {
 		logger.L.Error("In ReportStatusDownload: Error getting environment by key", zap.String("deploymentKey", reportStatusRequest.DeploymentKey), zap.Error(err))
@@ -192,10 +347,20 @@ func (s *clientService) ReportStatusDownload(reportStatusRequest *types.ReportSt
 		logger.L.Error("In ReportStatusDownload: Bundle not found", zap.String("bundleLabel", reportStatusRequest.Label))
 		return errors.New("bundle not found")
 	}
+	changed, err := s.deviceService.RecordDownload(context.Background(), environment.Id, reportStatusRequest.ClientUniqueId, bundle.Label)
+	if err != nil {
+		logger.L.Error("In ReportStatusDownload: Error recording download", zap.String("clientUniqueId", reportStatusRequest.ClientUniqueId), zap.Error(err))
+		return err
+	}
+	if !changed {
+		// the device already reported downloading this bundle
+		return nil
+	}
 	err = s.bundleService.AddInstalled(context.Background(), bundle.Id)
 	if err != nil {
 		logger.L.Error("In ReportStatusDownload: Error adding install", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
 		return err
 	}
+	s.recordEvent(context.Background(), bundle, utils.METRIC_DOWNLOADS)
 	return nil
 }
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"mime/multipart"
 	"testing"
+	"time"
 
+	"github.com/SwishHQ/spread/pkg"
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -76,9 +79,12 @@ func (m *MockBundleService) ToggleMandatory(bundleId primitive.ObjectID) error {
 	return args.Error(0)
 }
 
-func (m *MockBundleService) ToggleActive(bundleId primitive.ObjectID) error {
-	args := m.Called(bundleId)
-	return args.Error(0)
+func (m *MockBundleService) ToggleActive(bundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Bundle, error) {
+	args := m.Called(bundleId, actor, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
 }
 
 func (m *MockBundleService) AddActive(ctx context.Context, id primitive.ObjectID) error {
@@ -101,13 +107,128 @@ func (m *MockBundleService) DecrementActive(ctx context.Context, id primitive.Ob
 	return args.Error(0)
 }
 
+func (m *MockBundleService) GetBundlesWithActiveDevices(ctx context.Context) ([]*model.Bundle, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Bundle), args.Error(1)
+}
+
+func (m *MockBundleService) DisableAndRollback(ctx context.Context, bundle *model.Bundle) (*model.Bundle, bool, error) {
+	args := m.Called(ctx, bundle)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*model.Bundle), args.Bool(1), args.Error(2)
+}
+
+func (m *MockBundleService) IsMandatoryFrom(ctx context.Context, bundle *model.Bundle, label string, device *types.DeviceAttributes) (bool, error) {
+	args := m.Called(ctx, bundle, label, device)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBundleService) UpdateDescription(ctx context.Context, bundleId primitive.ObjectID, description string) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, description)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+// MockDeviceService is a mock implementation of DeviceService
+type MockDeviceService struct {
+	mock.Mock
+}
+
+func (m *MockDeviceService) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockDeviceService) GetDevice(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceService) RecordDownload(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, label)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceService) RecordDeploySucceeded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, bundle *model.Bundle) (*model.Device, bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, appVersion, bundle)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*model.Device), args.Bool(1), args.Error(2)
+}
+
+func (m *MockDeviceService) RecordDeployFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, appVersion, label)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceService) ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, label)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceService) CountDeployOutcomes(ctx context.Context, environmentId primitive.ObjectID, label string, since time.Time) (int64, int64, error) {
+	args := m.Called(ctx, environmentId, label, since)
+	return args.Get(0).(int64), args.Get(1).(int64), args.Error(2)
+}
+
+// MockMetricService is a mock implementation of MetricService
+type MockMetricService struct {
+	mock.Mock
+}
+
+func (m *MockMetricService) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockMetricService) RecordEvent(ctx context.Context, bundle *model.Bundle, metric string) error {
+	args := m.Called(ctx, bundle, metric)
+	return args.Error(0)
+}
+
+func (m *MockMetricService) RecordActiveDevices(ctx context.Context, bundleId primitive.ObjectID) error {
+	args := m.Called(ctx, bundleId)
+	return args.Error(0)
+}
+
+func (m *MockMetricService) GetSeries(ctx context.Context, request *types.MetricSeriesRequest) (*types.MetricSeriesResponse, error) {
+	args := m.Called(ctx, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*types.MetricSeriesResponse), args.Error(1)
+}
+
+// MockRollbackPolicyService is a mock implementation of RollbackPolicyService
+type MockRollbackPolicyService struct {
+	mock.Mock
+}
+
+func (m *MockRollbackPolicyService) Evaluate(ctx context.Context, environment *model.Environment, bundle *model.Bundle) (bool, error) {
+	args := m.Called(ctx, environment, bundle)
+	return args.Bool(0), args.Error(1)
+}
+
 func TestNewClientService(t *testing.T) {
 	mockAppService := &MockAppService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
 
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	assert.NotNil(t, service)
 	assert.IsType(t, &clientService{}, service)
@@ -118,7 +239,9 @@ func TestClientService_CheckUpdate_Success(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "test-env-key"
@@ -159,8 +282,9 @@ func TestClientService_CheckUpdate_Success(t *testing.T) {
 	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, appVersion).Return(version, nil)
 	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(bundle, nil)
 	mockVersionService.On("GetLatestVersionByEnvironmentId", ctx, environment.Id).Return(latestVersion, nil)
+	mockBundleService.On("IsMandatoryFrom", ctx, bundle, "", (*types.DeviceAttributes)(nil)).Return(false, nil)
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
@@ -176,12 +300,103 @@ func TestClientService_CheckUpdate_Success(t *testing.T) {
 	mockBundleService.AssertExpectations(t)
 }
 
+func TestClientService_CheckUpdate_TargetedBundleFallsBack(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockVersionService := &MockVersionService{}
+	mockTargetingService := &MockTargetingService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, mockVersionService, &MockDeviceService{}, &MockMetricService{}, &MockRollbackPolicyService{}, mockTargetingService, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "prod-key"}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, AppVersion: "1.0.0", CurrentBundleId: primitive.NewObjectID()}
+	dogfood := &model.Bundle{Id: version.CurrentBundleId, Hash: "dogfood-hash", Label: "v1x2", IsValid: true, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	stable := &model.Bundle{Id: primitive.NewObjectID(), Hash: "stable-hash", Label: "v1x1", IsValid: true}
+	device := &types.DeviceAttributes{ClientUniqueId: "customer-device"}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "prod-key").Return(environment, nil)
+	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, "1.0.0").Return(version, nil)
+	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(dogfood, nil)
+	mockVersionService.On("GetLatestVersionByEnvironmentId", ctx, environment.Id).Return(version, nil)
+	mockTargetingService.On("ResolveBundle", ctx, dogfood, device).Return(stable, nil)
+	mockBundleService.On("IsMandatoryFrom", ctx, stable, "", device).Return(false, nil)
+
+	// Execute
+	result, err := service.CheckUpdate("prod-key", "1.0.0", "old-hash", "", device)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "v1x1", result.Label)
+	assert.Equal(t, "stable-hash", result.PackageHash)
+	mockTargetingService.AssertExpectations(t)
+}
+
+func TestClientService_CheckUpdate_ServesExperimentVariant(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockVersionService := &MockVersionService{}
+	mockExperimentService := &MockExperimentService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, mockVersionService, &MockDeviceService{}, &MockMetricService{}, &MockRollbackPolicyService{}, &MockTargetingService{}, mockExperimentService)
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "prod-key"}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, AppVersion: "1.0.0", CurrentBundleId: primitive.NewObjectID(), ExperimentId: primitive.NewObjectID()}
+	current := &model.Bundle{Id: version.CurrentBundleId, Hash: "current-hash", Label: "v1x1", IsValid: true}
+	variant := &model.Bundle{Id: primitive.NewObjectID(), Hash: "variant-hash", Label: "v1x2", IsValid: true}
+	device := &types.DeviceAttributes{ClientUniqueId: "device-in-cohort-b"}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "prod-key").Return(environment, nil)
+	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, "1.0.0").Return(version, nil)
+	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(current, nil)
+	mockVersionService.On("GetLatestVersionByEnvironmentId", ctx, environment.Id).Return(version, nil)
+	mockExperimentService.On("AssignBundle", ctx, version, "device-in-cohort-b").Return(variant, nil)
+	mockBundleService.On("IsMandatoryFrom", ctx, variant, "", device).Return(false, nil)
+
+	// Execute
+	result, err := service.CheckUpdate("prod-key", "1.0.0", "current-hash", "", device)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "v1x2", result.Label)
+	assert.Equal(t, "variant-hash", result.PackageHash)
+	mockExperimentService.AssertExpectations(t)
+}
+
+func TestClientService_CheckUpdate_MandatoryAcrossSkippedReleases(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockVersionService := &MockVersionService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, mockVersionService, &MockDeviceService{}, &MockMetricService{}, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "prod-key"}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, AppVersion: "1.0.0", CurrentBundleId: primitive.NewObjectID()}
+	latest := &model.Bundle{Id: version.CurrentBundleId, Hash: "v4-hash", Label: "v1x4", IsValid: true, IsMandatory: false}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "prod-key").Return(environment, nil)
+	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, "1.0.0").Return(version, nil)
+	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(latest, nil)
+	mockVersionService.On("GetLatestVersionByEnvironmentId", ctx, environment.Id).Return(version, nil)
+	mockBundleService.On("IsMandatoryFrom", ctx, latest, "v1x2", (*types.DeviceAttributes)(nil)).Return(true, nil)
+
+	// Execute
+	result, err := service.CheckUpdate("prod-key", "1.0.0", "v2-hash", "v1x2", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "v1x4", result.Label)
+	assert.True(t, result.IsMandatory)
+	mockBundleService.AssertExpectations(t)
+}
+
 func TestClientService_CheckUpdate_EnvironmentNotFound(t *testing.T) {
 	mockAppService := &MockAppService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "nonexistent-key"
@@ -190,7 +405,7 @@ func TestClientService_CheckUpdate_EnvironmentNotFound(t *testing.T) {
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, environmentKey).Return(nil, nil)
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.NoError(t, err)
 	assert.Nil(t, result)
@@ -198,12 +413,29 @@ func TestClientService_CheckUpdate_EnvironmentNotFound(t *testing.T) {
 	mockEnvironmentService.AssertExpectations(t)
 }
 
+func TestClientService_CheckUpdate_CountsResult(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, &MockBundleService{}, &MockVersionService{}, &MockDeviceService{}, &MockMetricService{}, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	mockEnvironmentService.On("GetEnvironmentByKey", context.Background(), "nonexistent-key").Return(nil, nil)
+	before := testutil.ToFloat64(pkg.UpdateChecksTotal.WithLabelValues("none"))
+
+	// Execute
+	_, err := service.CheckUpdate("nonexistent-key", "1.0.0", "old-hash", "", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(pkg.UpdateChecksTotal.WithLabelValues("none")))
+}
+
 func TestClientService_CheckUpdate_EnvironmentError(t *testing.T) {
 	mockAppService := &MockAppService{}
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "test-env-key"
@@ -212,7 +444,7 @@ func TestClientService_CheckUpdate_EnvironmentError(t *testing.T) {
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, environmentKey).Return(nil, errors.New("database error"))
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.Error(t, err)
 	assert.Nil(t, result)
@@ -226,7 +458,9 @@ func TestClientService_CheckUpdate_VersionNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "test-env-key"
@@ -241,7 +475,7 @@ func TestClientService_CheckUpdate_VersionNotFound(t *testing.T) {
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, environmentKey).Return(environment, nil)
 	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, appVersion).Return(nil, nil)
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.NoError(t, err)
 	assert.Nil(t, result)
@@ -255,7 +489,9 @@ func TestClientService_CheckUpdate_BundleNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "test-env-key"
@@ -278,7 +514,7 @@ func TestClientService_CheckUpdate_BundleNotFound(t *testing.T) {
 	mockVersionService.On("GetVersionByEnvironmentIdAndAppVersion", ctx, environment.Id, appVersion).Return(version, nil)
 	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(nil, nil)
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.NoError(t, err)
 	assert.Nil(t, result)
@@ -293,7 +529,9 @@ func TestClientService_CheckUpdate_NoUpdateNeeded(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	environmentKey := "test-env-key"
@@ -335,7 +573,7 @@ func TestClientService_CheckUpdate_NoUpdateNeeded(t *testing.T) {
 	mockBundleService.On("GetBundleById", version.CurrentBundleId).Return(bundle, nil)
 	mockVersionService.On("GetLatestVersionByEnvironmentId", ctx, environment.Id).Return(latestVersion, nil)
 
-	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash)
+	result, err := service.CheckUpdate(environmentKey, appVersion, bundleHash, "", nil)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, result) // Should return an update to prompt app version update since there's a newer version
@@ -352,16 +590,19 @@ func TestClientService_ReportStatusDeploy_Success(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "test-env-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDeployRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
-		Status:        "DeploymentSucceeded",
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
+		Status:         "DeploymentSucceeded",
 	}
 
 	environment := &model.Environment{
@@ -376,7 +617,10 @@ func TestClientService_ReportStatusDeploy_Success(t *testing.T) {
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, deploymentKey).Return(environment, nil)
 	mockBundleService.On("GetBundleByLabelAndEnvironmentId", label, environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeploySucceeded", ctx, environment.Id, "device-1", "", bundle).Return(nil, true, nil)
 	mockBundleService.On("AddActive", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "installs").Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, bundle.Id).Return(nil)
 
 	err := service.ReportStatusDeploy(request)
 
@@ -384,6 +628,7 @@ func TestClientService_ReportStatusDeploy_Success(t *testing.T) {
 
 	mockEnvironmentService.AssertExpectations(t)
 	mockBundleService.AssertExpectations(t)
+	mockDeviceService.AssertExpectations(t)
 }
 
 func TestClientService_ReportStatusDeploy_EnvironmentNotFound(t *testing.T) {
@@ -391,16 +636,19 @@ func TestClientService_ReportStatusDeploy_EnvironmentNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "nonexistent-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDeployRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
-		Status:        "DeploymentSucceeded",
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
+		Status:         "DeploymentSucceeded",
 	}
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, deploymentKey).Return(nil, nil)
@@ -418,16 +666,19 @@ func TestClientService_ReportStatusDeploy_BundleNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "test-env-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDeployRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
-		Status:        "DeploymentSucceeded",
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
+		Status:         "DeploymentSucceeded",
 	}
 
 	environment := &model.Environment{
@@ -452,16 +703,20 @@ func TestClientService_ReportStatusDeploy_DeploymentFailed(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	mockRollbackPolicyService := &MockRollbackPolicyService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, mockRollbackPolicyService, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "test-env-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDeployRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
-		Status:        "DeploymentFailed",
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
+		Status:         "DeploymentFailed",
 	}
 
 	environment := &model.Environment{
@@ -476,7 +731,10 @@ func TestClientService_ReportStatusDeploy_DeploymentFailed(t *testing.T) {
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, deploymentKey).Return(environment, nil)
 	mockBundleService.On("GetBundleByLabelAndEnvironmentId", label, environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeployFailed", ctx, environment.Id, "device-1", "", label).Return(true, nil)
 	mockBundleService.On("AddFailed", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "failures").Return(nil)
+	mockRollbackPolicyService.On("Evaluate", ctx, environment, bundle).Return(false, nil)
 
 	err := service.ReportStatusDeploy(request)
 
@@ -484,6 +742,235 @@ func TestClientService_ReportStatusDeploy_DeploymentFailed(t *testing.T) {
 
 	mockEnvironmentService.AssertExpectations(t)
 	mockBundleService.AssertExpectations(t)
+	mockDeviceService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_RetriedReportNotCounted(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "test-env-key"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+	previousLabel := "v1x1"
+	request := &types.ReportStatusDeployRequest{
+		DeploymentKey:             "test-env-key",
+		ClientUniqueId:            "device-1",
+		AppVersion:                "1.0.0",
+		Label:                     "v1x2",
+		Status:                    "DeploymentSucceeded",
+		PreviousLabelOrAppVersion: &previousLabel,
+		PreviousDeploymentKey:     &environment.Key,
+	}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "test-env-key").Return(environment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x2", environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeploySucceeded", ctx, environment.Id, "device-1", "1.0.0", bundle).Return(nil, false, nil)
+
+	// Execute
+	err := service.ReportStatusDeploy(request)
+
+	// Assert
+	assert.NoError(t, err)
+	mockBundleService.AssertNotCalled(t, "AddActive", mock.Anything, mock.Anything)
+	mockBundleService.AssertNotCalled(t, "DecrementActive", mock.Anything, mock.Anything)
+	mockDeviceService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_DecrementsRecordedPreviousBundle(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "test-env-key"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+	previousBundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1"}
+	previousState := &model.Device{CurrentLabel: "v1x1", CurrentBundleId: previousBundle.Id}
+	// the SDK claim is ignored when the device state is known
+	claimedLabel := "v1x0"
+	request := &types.ReportStatusDeployRequest{
+		DeploymentKey:             "test-env-key",
+		ClientUniqueId:            "device-1",
+		AppVersion:                "1.0.0",
+		Label:                     "v1x2",
+		Status:                    "DeploymentSucceeded",
+		PreviousLabelOrAppVersion: &claimedLabel,
+		PreviousDeploymentKey:     &environment.Key,
+	}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "test-env-key").Return(environment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x2", environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeploySucceeded", ctx, environment.Id, "device-1", "1.0.0", bundle).Return(previousState, true, nil)
+	mockBundleService.On("AddActive", ctx, bundle.Id).Return(nil)
+	mockBundleService.On("GetBundleById", previousBundle.Id).Return(previousBundle, nil)
+	mockBundleService.On("DecrementActive", ctx, previousBundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "installs").Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, previousBundle.Id).Return(nil)
+
+	// Execute
+	err := service.ReportStatusDeploy(request)
+
+	// Assert
+	assert.NoError(t, err)
+	mockEnvironmentService.AssertNumberOfCalls(t, "GetEnvironmentByKey", 1)
+	mockBundleService.AssertExpectations(t)
+	mockDeviceService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_DeviceMovedFromOtherEnvironment(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "production-key"}
+	previousEnvironment := &model.Environment{Id: primitive.NewObjectID(), Key: "staging-key"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+	previousBundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x5"}
+	request := &types.ReportStatusDeployRequest{
+		DeploymentKey:             "production-key",
+		ClientUniqueId:            "device-1",
+		AppVersion:                "1.0.0",
+		Label:                     "v1x2",
+		Status:                    "DeploymentSucceeded",
+		PreviousLabelOrAppVersion: &previousBundle.Label,
+		PreviousDeploymentKey:     &previousEnvironment.Key,
+	}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "production-key").Return(environment, nil)
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "staging-key").Return(previousEnvironment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x2", environment.Id).Return(bundle, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x5", previousEnvironment.Id).Return(previousBundle, nil)
+	mockDeviceService.On("RecordDeploySucceeded", ctx, environment.Id, "device-1", "1.0.0", bundle).Return(nil, true, nil)
+	mockDeviceService.On("GetDevice", ctx, previousEnvironment.Id, "device-1").Return(&model.Device{CurrentLabel: "v1x5"}, nil)
+	mockDeviceService.On("ReleaseBundle", ctx, previousEnvironment.Id, "device-1", "v1x5").Return(true, nil)
+	mockBundleService.On("AddActive", ctx, bundle.Id).Return(nil)
+	mockBundleService.On("DecrementActive", ctx, previousBundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "installs").Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, previousBundle.Id).Return(nil)
+
+	// Execute
+	err := service.ReportStatusDeploy(request)
+
+	// Assert
+	assert.NoError(t, err)
+	mockEnvironmentService.AssertExpectations(t)
+	mockBundleService.AssertExpectations(t)
+	mockDeviceService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_OlderBundleCountsRollback(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "test-env-key"}
+	versionId := primitive.NewObjectID()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1", VersionId: versionId, SequenceId: 1}
+	previousBundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", VersionId: versionId, SequenceId: 2}
+	request := &types.ReportStatusDeployRequest{
+		DeploymentKey:  "test-env-key",
+		ClientUniqueId: "device-1",
+		AppVersion:     "1.0.0",
+		Label:          "v1x1",
+		Status:         "DeploymentSucceeded",
+	}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "test-env-key").Return(environment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x1", environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeploySucceeded", ctx, environment.Id, "device-1", "1.0.0", bundle).Return(&model.Device{CurrentLabel: "v1x2", CurrentBundleId: previousBundle.Id}, true, nil)
+	mockBundleService.On("AddActive", ctx, bundle.Id).Return(nil)
+	mockBundleService.On("GetBundleById", previousBundle.Id).Return(previousBundle, nil)
+	mockBundleService.On("DecrementActive", ctx, previousBundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "installs").Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordActiveDevices", ctx, previousBundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, previousBundle, "rollbacks").Return(nil)
+
+	// Execute
+	err := service.ReportStatusDeploy(request)
+
+	// Assert
+	assert.NoError(t, err)
+	mockBundleService.AssertExpectations(t)
+	mockMetricService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_MetricErrorIgnored(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	mockRollbackPolicyService := &MockRollbackPolicyService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, mockRollbackPolicyService, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "test-env-key"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1"}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "test-env-key").Return(environment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x1", environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDeployFailed", ctx, environment.Id, "device-1", "", "v1x1").Return(true, nil)
+	mockBundleService.On("AddFailed", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "failures").Return(errors.New("write failed"))
+	mockRollbackPolicyService.On("Evaluate", ctx, environment, bundle).Return(false, errors.New("count failed"))
+
+	// Execute
+	err := service.ReportStatusDeploy(&types.ReportStatusDeployRequest{DeploymentKey: "test-env-key", ClientUniqueId: "device-1", Label: "v1x1", Status: "DeploymentFailed"})
+
+	// Assert
+	assert.NoError(t, err)
+	mockMetricService.AssertExpectations(t)
+}
+
+func TestClientService_ReportStatusDeploy_MissingClientUniqueId(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, &MockBundleService{}, &MockVersionService{}, &MockDeviceService{}, &MockMetricService{}, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	// Execute
+	err := service.ReportStatusDeploy(&types.ReportStatusDeployRequest{DeploymentKey: "test-env-key", Label: "v1x1", Status: "DeploymentSucceeded"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, "client unique id is required", err.Error())
+	mockEnvironmentService.AssertNotCalled(t, "GetEnvironmentByKey", mock.Anything, mock.Anything)
+}
+
+func TestClientService_ReportStatusDownload_RetriedReportNotCounted(t *testing.T) {
+	mockEnvironmentService := &MockEnvironmentService{}
+	mockBundleService := &MockBundleService{}
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(&MockAppService{}, mockEnvironmentService, mockBundleService, &MockVersionService{}, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
+
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Key: "test-env-key"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1"}
+
+	mockEnvironmentService.On("GetEnvironmentByKey", ctx, "test-env-key").Return(environment, nil)
+	mockBundleService.On("GetBundleByLabelAndEnvironmentId", "v1x1", environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDownload", ctx, environment.Id, "device-1", "v1x1").Return(false, nil)
+
+	// Execute
+	err := service.ReportStatusDownload(&types.ReportStatusDownloadRequest{DeploymentKey: "test-env-key", ClientUniqueId: "device-1", Label: "v1x1"})
+
+	// Assert
+	assert.NoError(t, err)
+	mockBundleService.AssertNotCalled(t, "AddInstalled", mock.Anything, mock.Anything)
+	mockDeviceService.AssertExpectations(t)
 }
 
 func TestClientService_ReportStatusDownload_Success(t *testing.T) {
@@ -491,15 +978,18 @@ func TestClientService_ReportStatusDownload_Success(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "test-env-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDownloadRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
 	}
 
 	environment := &model.Environment{
@@ -514,7 +1004,9 @@ func TestClientService_ReportStatusDownload_Success(t *testing.T) {
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, deploymentKey).Return(environment, nil)
 	mockBundleService.On("GetBundleByLabelAndEnvironmentId", label, environment.Id).Return(bundle, nil)
+	mockDeviceService.On("RecordDownload", ctx, environment.Id, "device-1", label).Return(true, nil)
 	mockBundleService.On("AddInstalled", ctx, bundle.Id).Return(nil)
+	mockMetricService.On("RecordEvent", ctx, bundle, "downloads").Return(nil)
 
 	err := service.ReportStatusDownload(request)
 
@@ -522,6 +1014,7 @@ func TestClientService_ReportStatusDownload_Success(t *testing.T) {
 
 	mockEnvironmentService.AssertExpectations(t)
 	mockBundleService.AssertExpectations(t)
+	mockDeviceService.AssertExpectations(t)
 }
 
 func TestClientService_ReportStatusDownload_EnvironmentNotFound(t *testing.T) {
@@ -529,15 +1022,18 @@ func TestClientService_ReportStatusDownload_EnvironmentNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "nonexistent-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDownloadRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
 	}
 
 	mockEnvironmentService.On("GetEnvironmentByKey", ctx, deploymentKey).Return(nil, nil)
@@ -555,15 +1051,18 @@ func TestClientService_ReportStatusDownload_BundleNotFound(t *testing.T) {
 	mockEnvironmentService := &MockEnvironmentService{}
 	mockBundleService := &MockBundleService{}
 	mockVersionService := &MockVersionService{}
-	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService)
+	mockDeviceService := &MockDeviceService{}
+	mockMetricService := &MockMetricService{}
+	service := NewClientService(mockAppService, mockEnvironmentService, mockBundleService, mockVersionService, mockDeviceService, mockMetricService, &MockRollbackPolicyService{}, &MockTargetingService{}, &MockExperimentService{})
 
 	ctx := context.Background()
 	deploymentKey := "test-env-key"
 	label := "v1x1"
 
 	request := &types.ReportStatusDownloadRequest{
-		DeploymentKey: deploymentKey,
-		Label:         label,
+		DeploymentKey:  deploymentKey,
+		ClientUniqueId: "device-1",
+		Label:          label,
 	}
 
 	environment := &model.Environment{
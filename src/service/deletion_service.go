@@ -0,0 +1,568 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var (
+	// how long a confirmation token from RequestDeletion can be used to delete
+	deletionConfirmationTTL = 10 * time.Minute
+	// how often the purger looks for deletions past the retention window
+	deletionPurgeInterval = time.Hour
+)
+
+var ErrInvalidConfirmationToken = errors.New("invalid or expired confirmation token, request a new one")
+
+// BundleStorage removes uploaded bundles when their version is purged
+type BundleStorage interface {
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// DeletionService deletes apps, environments and versions in two steps. A delete hides the resource
+// and everything under it right away, the purger removes it with its bundles, devices, metrics and
+// stored files once the retention window passed. Until then it can be restored
+type DeletionService interface {
+	RequestDeletion(ctx context.Context, resource string, id primitive.ObjectID) (*types.DeletionConfirmation, error)
+	Delete(ctx context.Context, resource string, id primitive.ObjectID, confirmationToken string, actor string) (*types.DeletedResource, error)
+	Restore(ctx context.Context, resource string, id primitive.ObjectID, actor string) error
+	GetDeleted(ctx context.Context) ([]*types.DeletedResource, error)
+	PurgeExpired(ctx context.Context) (int, error)
+	RunPurger(ctx context.Context)
+}
+
+type deletionService struct {
+	appRepository          repository.AppRepository
+	environmentRepository  repository.EnvironmentRepository
+	versionRepository      repository.VersionRepository
+	bundleRepository       repository.BundleRepository
+	deviceRepository       repository.DeviceRepository
+	bundleMetricRepository repository.BundleMetricRepository
+	webhookService         WebhookService
+	auditService           AuditService
+	storage                BundleStorage
+	retention              time.Duration
+}
+
+// NewDeletionService keeps deleted resources restorable for retention. storage may be nil when no
+// bucket is configured, purged bundles then leave their files behind
+func NewDeletionService(
+	appRepository repository.AppRepository,
+	environmentRepository repository.EnvironmentRepository,
+	versionRepository repository.VersionRepository,
+	bundleRepository repository.BundleRepository,
+	deviceRepository repository.DeviceRepository,
+	bundleMetricRepository repository.BundleMetricRepository,
+	webhookService WebhookService,
+	auditService AuditService,
+	storage BundleStorage,
+	retention time.Duration,
+) DeletionService {
+	return &deletionService{
+		appRepository:          appRepository,
+		environmentRepository:  environmentRepository,
+		versionRepository:      versionRepository,
+		bundleRepository:       bundleRepository,
+		deviceRepository:       deviceRepository,
+		bundleMetricRepository: bundleMetricRepository,
+		webhookService:         webhookService,
+		auditService:           auditService,
+		storage:                storage,
+		retention:              retention,
+	}
+}
+
+// RequestDeletion returns what deleting the resource takes with it and the token that confirms it
+func (s *deletionService) RequestDeletion(ctx context.Context, resource string, id primitive.ObjectID) (*types.DeletionConfirmation, error) {
+	var name string
+	var environments []*model.Environment
+	var versions []*model.Version
+	switch resource {
+	case utils.RESOURCE_APP:
+		app, err := s.getApp(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		name = app.Name
+		environments, err = s.environmentRepository.GetAllByAppId(ctx, app.Id)
+		if err != nil {
+			return nil, err
+		}
+	case utils.RESOURCE_ENVIRONMENT:
+		environment, err := s.getEnvironment(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		name = environment.Name
+		environments = []*model.Environment{environment}
+	case utils.RESOURCE_VERSION:
+		version, err := s.getVersion(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		name = version.AppVersion
+		versions = []*model.Version{version}
+	default:
+		return nil, errors.New("unknown resource " + resource)
+	}
+
+	impact := types.DeletionImpact{}
+	if resource != utils.RESOURCE_VERSION {
+		impact.Environments = len(environments)
+	}
+	for _, environment := range environments {
+		environmentVersions, err := s.versionRepository.GetAllByEnvironmentId(ctx, environment.Id)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, environmentVersions...)
+	}
+	impact.Versions = len(versions)
+	for _, version := range versions {
+		bundles, err := s.bundleRepository.GetAllByVersionId(ctx, version.Id)
+		if err != nil {
+			return nil, err
+		}
+		impact.Bundles += len(bundles)
+	}
+
+	expiresAt := time.Now().Add(deletionConfirmationTTL)
+	return &types.DeletionConfirmation{
+		Resource:          resource,
+		Id:                id.Hex(),
+		Name:              name,
+		Impact:            impact,
+		ConfirmationToken: utils.SignConfirmationToken(config.TokenSecret, confirmationSubject(resource, id), expiresAt),
+		ExpiresAt:         expiresAt.Truncate(time.Second),
+	}, nil
+}
+
+// Delete soft deletes the resource and everything under it with the same deletion time, so a restore
+// brings back exactly what this delete took and nothing deleted on its own before
+func (s *deletionService) Delete(ctx context.Context, resource string, id primitive.ObjectID, confirmationToken string, actor string) (*types.DeletedResource, error) {
+	if !utils.VerifyConfirmationToken(config.TokenSecret, confirmationSubject(resource, id), confirmationToken, time.Now()) {
+		return nil, ErrInvalidConfirmationToken
+	}
+	// Mongo keeps milliseconds, the restore matches on this exact time
+	at := time.Now().UTC().Truncate(time.Millisecond)
+	deleted := &types.DeletedResource{Resource: resource, Id: id.Hex(), DeletedAt: at, PurgeAt: at.Add(s.retention)}
+	event := &model.AuditEvent{Action: resource + "." + utils.AUDIT_ACTION_DELETED, Actor: actor}
+
+	switch resource {
+	case utils.RESOURCE_APP:
+		app, err := s.getApp(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.markDeleted(s.appRepository.MarkDeleted(ctx, app.Id, at)); err != nil {
+			return nil, err
+		}
+		environmentIds, err := s.environmentRepository.MarkDeletedByAppId(ctx, app.Id, at)
+		if err != nil {
+			return nil, err
+		}
+		if len(environmentIds) > 0 {
+			if err := s.versionRepository.MarkDeletedByEnvironmentIds(ctx, environmentIds, at); err != nil {
+				return nil, err
+			}
+		}
+		deleted.Name = app.Name
+		event.AppId = app.Id
+	case utils.RESOURCE_ENVIRONMENT:
+		environment, err := s.getEnvironment(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.markDeleted(s.environmentRepository.MarkDeleted(ctx, environment.Id, at)); err != nil {
+			return nil, err
+		}
+		if err := s.versionRepository.MarkDeletedByEnvironmentIds(ctx, []primitive.ObjectID{environment.Id}, at); err != nil {
+			return nil, err
+		}
+		deleted.Name = environment.Name
+		deleted.AppId = environment.AppId.Hex()
+		event.AppId = environment.AppId
+		event.EnvironmentId = environment.Id
+	case utils.RESOURCE_VERSION:
+		version, err := s.getVersion(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		environment, err := s.getEnvironment(ctx, version.EnvironmentId)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.markDeleted(s.versionRepository.MarkDeleted(ctx, version.Id, at)); err != nil {
+			return nil, err
+		}
+		deleted.Name = version.AppVersion
+		deleted.AppId = environment.AppId.Hex()
+		deleted.EnvironmentId = environment.Id.Hex()
+		event.AppId = environment.AppId
+		event.EnvironmentId = environment.Id
+	default:
+		return nil, errors.New("unknown resource " + resource)
+	}
+
+	event.Details = map[string]interface{}{"id": deleted.Id, "name": deleted.Name, "purgeAt": deleted.PurgeAt}
+	s.record(ctx, "Delete", event)
+	return deleted, nil
+}
+
+// Restore undoes a delete that was not purged yet. Names freed by the delete may have been reused
+// in the meantime, the new resource has to be renamed before the old one can come back
+func (s *deletionService) Restore(ctx context.Context, resource string, id primitive.ObjectID, actor string) error {
+	event := &model.AuditEvent{Action: resource + "." + utils.AUDIT_ACTION_RESTORED, Actor: actor}
+
+	switch resource {
+	case utils.RESOURCE_APP:
+		app, err := s.appRepository.GetDeletedById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if app == nil {
+			return errors.New("deleted app not found")
+		}
+		existingApp, err := s.appRepository.GetByName(ctx, app.Name)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if existingApp != nil {
+			return errors.New("app with name " + app.Name + " already exists, rename it before restoring")
+		}
+		if _, err := s.appRepository.Restore(ctx, app.Id, *app.DeletedAt); err != nil {
+			return err
+		}
+		environmentIds, err := s.environmentRepository.RestoreByAppId(ctx, app.Id, *app.DeletedAt)
+		if err != nil {
+			return err
+		}
+		if len(environmentIds) > 0 {
+			if err := s.versionRepository.RestoreByEnvironmentIds(ctx, environmentIds, *app.DeletedAt); err != nil {
+				return err
+			}
+		}
+		event.AppId = app.Id
+		event.Details = map[string]interface{}{"id": app.Id.Hex(), "name": app.Name}
+	case utils.RESOURCE_ENVIRONMENT:
+		environment, err := s.environmentRepository.GetDeletedById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if environment == nil {
+			return errors.New("deleted environment not found")
+		}
+		app, err := s.appRepository.GetById(ctx, environment.AppId)
+		if err != nil {
+			return err
+		}
+		if app == nil {
+			return errors.New("the app of this environment is deleted, restore the app instead")
+		}
+		existingEnvironment, err := s.environmentRepository.GetByAppIdAndName(ctx, app.Id, environment.Name)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if existingEnvironment != nil {
+			return errors.New("environment with name " + environment.Name + " already exists for app " + app.Name + ", rename it before restoring")
+		}
+		if _, err := s.environmentRepository.Restore(ctx, environment.Id, *environment.DeletedAt); err != nil {
+			return err
+		}
+		if err := s.versionRepository.RestoreByEnvironmentIds(ctx, []primitive.ObjectID{environment.Id}, *environment.DeletedAt); err != nil {
+			return err
+		}
+		event.AppId = app.Id
+		event.EnvironmentId = environment.Id
+		event.Details = map[string]interface{}{"id": environment.Id.Hex(), "name": environment.Name}
+	case utils.RESOURCE_VERSION:
+		version, err := s.versionRepository.GetDeletedById(ctx, id)
+		if err != nil {
+			return err
+		}
+		if version == nil {
+			return errors.New("deleted version not found")
+		}
+		environment, err := s.environmentRepository.GetById(ctx, version.EnvironmentId)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return errors.New("the environment of this version is deleted, restore the environment instead")
+			}
+			return err
+		}
+		existingVersion, err := s.versionRepository.GetByEnvironmentIdAndAppVersion(ctx, environment.Id, version.AppVersion)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if existingVersion != nil {
+			return errors.New("version " + version.AppVersion + " was released again after it was deleted")
+		}
+		if _, err := s.versionRepository.Restore(ctx, version.Id, *version.DeletedAt); err != nil {
+			return err
+		}
+		event.AppId = environment.AppId
+		event.EnvironmentId = environment.Id
+		event.Details = map[string]interface{}{"id": version.Id.Hex(), "name": version.AppVersion}
+	default:
+		return errors.New("unknown resource " + resource)
+	}
+
+	s.record(ctx, "Restore", event)
+	return nil
+}
+
+// GetDeleted lists what can still be restored. Environments and versions deleted together with
+// their app or environment are left out, restoring the parent brings them back
+func (s *deletionService) GetDeleted(ctx context.Context) ([]*types.DeletedResource, error) {
+	now := time.Now()
+	apps, err := s.appRepository.GetAllDeleted(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	environments, err := s.environmentRepository.GetAllDeleted(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := s.versionRepository.GetAllDeleted(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedApps := map[primitive.ObjectID]time.Time{}
+	deletedEnvironments := map[primitive.ObjectID]*model.Environment{}
+	resources := make([]*types.DeletedResource, 0)
+	for _, app := range apps {
+		deletedApps[app.Id] = *app.DeletedAt
+		resources = append(resources, &types.DeletedResource{
+			Resource:  utils.RESOURCE_APP,
+			Id:        app.Id.Hex(),
+			Name:      app.Name,
+			DeletedAt: *app.DeletedAt,
+			PurgeAt:   app.DeletedAt.Add(s.retention),
+		})
+	}
+	for _, environment := range environments {
+		deletedEnvironments[environment.Id] = environment
+		if deletedAt, ok := deletedApps[environment.AppId]; ok && deletedAt.Equal(*environment.DeletedAt) {
+			continue
+		}
+		resources = append(resources, &types.DeletedResource{
+			Resource:  utils.RESOURCE_ENVIRONMENT,
+			Id:        environment.Id.Hex(),
+			Name:      environment.Name,
+			AppId:     environment.AppId.Hex(),
+			DeletedAt: *environment.DeletedAt,
+			PurgeAt:   environment.DeletedAt.Add(s.retention),
+		})
+	}
+	for _, version := range versions {
+		resource := &types.DeletedResource{
+			Resource:      utils.RESOURCE_VERSION,
+			Id:            version.Id.Hex(),
+			Name:          version.AppVersion,
+			EnvironmentId: version.EnvironmentId.Hex(),
+			DeletedAt:     *version.DeletedAt,
+			PurgeAt:       version.DeletedAt.Add(s.retention),
+		}
+		if environment, ok := deletedEnvironments[version.EnvironmentId]; ok {
+			if environment.DeletedAt.Equal(*version.DeletedAt) {
+				continue
+			}
+			resource.AppId = environment.AppId.Hex()
+		} else if environment, err := s.environmentRepository.GetById(ctx, version.EnvironmentId); err == nil {
+			resource.AppId = environment.AppId.Hex()
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// PurgeExpired removes everything deleted longer than the retention window ago, versions first so
+// an environment or app is only removed once nothing points at it. Audit events are kept
+func (s *deletionService) PurgeExpired(ctx context.Context) (int, error) {
+	before := time.Now().Add(-s.retention)
+	purged := 0
+	versions, err := s.versionRepository.GetAllDeleted(ctx, before)
+	if err != nil {
+		return purged, err
+	}
+	for _, version := range versions {
+		if err := s.purgeVersion(ctx, version); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	environments, err := s.environmentRepository.GetAllDeleted(ctx, before)
+	if err != nil {
+		return purged, err
+	}
+	for _, environment := range environments {
+		if err := s.purgeEnvironment(ctx, environment); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	apps, err := s.appRepository.GetAllDeleted(ctx, before)
+	if err != nil {
+		return purged, err
+	}
+	for _, app := range apps {
+		if err := s.webhookService.DeleteWebhooksByAppId(ctx, app.Id); err != nil {
+			return purged, err
+		}
+		if err := s.appRepository.Delete(ctx, app.Id); err != nil {
+			return purged, err
+		}
+		s.record(ctx, "PurgeExpired", &model.AuditEvent{
+			Action:  utils.RESOURCE_APP + "." + utils.AUDIT_ACTION_PURGED,
+			Actor:   utils.AUDIT_ACTOR_SYSTEM,
+			AppId:   app.Id,
+			Details: map[string]interface{}{"id": app.Id.Hex(), "name": app.Name},
+		})
+		purged++
+	}
+	return purged, nil
+}
+
+// RunPurger purges expired deletions every deletionPurgeInterval until the context is cancelled
+func (s *deletionService) RunPurger(ctx context.Context) {
+	ticker := time.NewTicker(deletionPurgeInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.PurgeExpired(ctx); err != nil {
+			logger.L.Error("In RunPurger: Error purging deleted resources", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// files go first, a failed delete leaves the version in place to be retried on the next run
+func (s *deletionService) purgeVersion(ctx context.Context, version *model.Version) error {
+	bundles, err := s.bundleRepository.GetAllByVersionId(ctx, version.Id)
+	if err != nil {
+		return err
+	}
+	bundleIds := make([]primitive.ObjectID, 0, len(bundles))
+	for _, bundle := range bundles {
+		bundleIds = append(bundleIds, bundle.Id)
+		if s.storage == nil {
+			continue
+		}
+		for _, key := range []string{bundle.DownloadFile, bundle.SourceMapFile} {
+			if key == "" {
+				continue
+			}
+			if err := s.storage.DeleteFile(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	if len(bundleIds) > 0 {
+		if err := s.bundleMetricRepository.DeleteByBundleIds(ctx, bundleIds); err != nil {
+			return err
+		}
+		if err := s.bundleRepository.DeleteByVersionId(ctx, version.Id); err != nil {
+			return err
+		}
+	}
+	if err := s.versionRepository.Delete(ctx, version.Id); err != nil {
+		return err
+	}
+	s.record(ctx, "PurgeExpired", &model.AuditEvent{
+		Action:        utils.RESOURCE_VERSION + "." + utils.AUDIT_ACTION_PURGED,
+		Actor:         utils.AUDIT_ACTOR_SYSTEM,
+		EnvironmentId: version.EnvironmentId,
+		Details:       map[string]interface{}{"id": version.Id.Hex(), "name": version.AppVersion, "bundles": len(bundles)},
+	})
+	return nil
+}
+
+func (s *deletionService) purgeEnvironment(ctx context.Context, environment *model.Environment) error {
+	if err := s.deviceRepository.DeleteByEnvironmentId(ctx, environment.Id); err != nil {
+		return err
+	}
+	if err := s.bundleMetricRepository.DeleteByEnvironmentId(ctx, environment.Id); err != nil {
+		return err
+	}
+	if err := s.environmentRepository.Delete(ctx, environment.Id); err != nil {
+		return err
+	}
+	s.record(ctx, "PurgeExpired", &model.AuditEvent{
+		Action:        utils.RESOURCE_ENVIRONMENT + "." + utils.AUDIT_ACTION_PURGED,
+		Actor:         utils.AUDIT_ACTOR_SYSTEM,
+		AppId:         environment.AppId,
+		EnvironmentId: environment.Id,
+		Details:       map[string]interface{}{"id": environment.Id.Hex(), "name": environment.Name},
+	})
+	return nil
+}
+
+func (s *deletionService) getApp(ctx context.Context, id primitive.ObjectID) (*model.App, error) {
+	app, err := s.appRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if app == nil {
+		return nil, errors.New("app not found")
+	}
+	return app, nil
+}
+
+func (s *deletionService) getEnvironment(ctx context.Context, id primitive.ObjectID) (*model.Environment, error) {
+	environment, err := s.environmentRepository.GetById(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("environment not found")
+		}
+		return nil, err
+	}
+	return environment, nil
+}
+
+func (s *deletionService) getVersion(ctx context.Context, id primitive.ObjectID) (*model.Version, error) {
+	version, err := s.versionRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, errors.New("version not found")
+	}
+	return version, nil
+}
+
+// a concurrent delete of the same resource marks nothing
+func (s *deletionService) markDeleted(marked bool, err error) error {
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return errors.New("already deleted")
+	}
+	return nil
+}
+
+// the change already happened, a failed audit write is logged and not returned
+func (s *deletionService) record(ctx context.Context, fn string, event *model.AuditEvent) {
+	if err := s.auditService.Record(ctx, event); err != nil {
+		logger.L.Error("In "+fn+": Error recording audit event", zap.String("action", event.Action), zap.Error(err))
+	}
+}
+
+func confirmationSubject(resource string, id primitive.ObjectID) string {
+	return resource + ":" + id.Hex()
+}
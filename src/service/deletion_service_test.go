@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MockBundleStorage is a mock implementation of BundleStorage
+type MockBundleStorage struct {
+	mock.Mock
+}
+
+func (m *MockBundleStorage) DeleteFile(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+type deletionTestMocks struct {
+	appRepository          *MockAppRepository
+	environmentRepository  *MockEnvironmentRepository
+	versionRepository      *MockVersionRepository
+	bundleRepository       *MockBundleRepository
+	deviceRepository       *MockDeviceRepository
+	bundleMetricRepository *MockBundleMetricRepository
+	webhookService         *MockWebhookService
+	auditService           *MockAuditService
+	storage                *MockBundleStorage
+}
+
+func newDeletionTestService(retention time.Duration) (DeletionService, *deletionTestMocks) {
+	mocks := &deletionTestMocks{
+		appRepository:          &MockAppRepository{},
+		environmentRepository:  &MockEnvironmentRepository{},
+		versionRepository:      &MockVersionRepository{},
+		bundleRepository:       &MockBundleRepository{},
+		deviceRepository:       &MockDeviceRepository{},
+		bundleMetricRepository: &MockBundleMetricRepository{},
+		webhookService:         &MockWebhookService{},
+		auditService:           &MockAuditService{},
+		storage:                &MockBundleStorage{},
+	}
+	mocks.auditService.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	service := NewDeletionService(mocks.appRepository, mocks.environmentRepository, mocks.versionRepository, mocks.bundleRepository,
+		mocks.deviceRepository, mocks.bundleMetricRepository, mocks.webhookService, mocks.auditService, mocks.storage, retention)
+	return service, mocks
+}
+
+func TestDeletionService_RequestDeletion_CountsImpact(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	app := &model.App{Id: primitive.NewObjectID(), Name: "onboarding-test"}
+	staging := &model.Environment{Id: primitive.NewObjectID(), AppId: app.Id, Name: "staging"}
+	production := &model.Environment{Id: primitive.NewObjectID(), AppId: app.Id, Name: "production"}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: staging.Id, AppVersion: "1.0.0"}
+
+	mocks.appRepository.On("GetById", ctx, app.Id).Return(app, nil)
+	mocks.environmentRepository.On("GetAllByAppId", ctx, app.Id).Return([]*model.Environment{staging, production}, nil)
+	mocks.versionRepository.On("GetAllByEnvironmentId", ctx, staging.Id).Return([]*model.Version{version}, nil)
+	mocks.versionRepository.On("GetAllByEnvironmentId", ctx, production.Id).Return([]*model.Version{}, nil)
+	mocks.bundleRepository.On("GetAllByVersionId", ctx, version.Id).Return([]*model.Bundle{{Id: primitive.NewObjectID()}, {Id: primitive.NewObjectID()}}, nil)
+
+	// Execute
+	confirmation, err := service.RequestDeletion(ctx, utils.RESOURCE_APP, app.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "onboarding-test", confirmation.Name)
+	assert.Equal(t, 2, confirmation.Impact.Environments)
+	assert.Equal(t, 1, confirmation.Impact.Versions)
+	assert.Equal(t, 2, confirmation.Impact.Bundles)
+	assert.True(t, utils.VerifyConfirmationToken(config.TokenSecret, "app:"+app.Id.Hex(), confirmation.ConfirmationToken, time.Now()))
+	assert.False(t, utils.VerifyConfirmationToken(config.TokenSecret, "environment:"+app.Id.Hex(), confirmation.ConfirmationToken, time.Now()))
+}
+
+func TestDeletionService_Delete_InvalidToken(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	appId := primitive.NewObjectID()
+	otherToken := utils.SignConfirmationToken(config.TokenSecret, "app:"+primitive.NewObjectID().Hex(), time.Now().Add(time.Minute))
+	expiredToken := utils.SignConfirmationToken(config.TokenSecret, "app:"+appId.Hex(), time.Now().Add(-time.Minute))
+
+	for _, token := range []string{"", "not-a-token", otherToken, expiredToken} {
+		// Execute
+		deleted, err := service.Delete(ctx, utils.RESOURCE_APP, appId, token, "admin")
+
+		// Assert
+		assert.ErrorIs(t, err, ErrInvalidConfirmationToken)
+		assert.Nil(t, deleted)
+	}
+	mocks.appRepository.AssertNotCalled(t, "MarkDeleted", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeletionService_Delete_AppCascades(t *testing.T) {
+	service, mocks := newDeletionTestService(720 * time.Hour)
+	ctx := context.Background()
+	app := &model.App{Id: primitive.NewObjectID(), Name: "onboarding-test"}
+	environmentIds := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+	token := utils.SignConfirmationToken(config.TokenSecret, "app:"+app.Id.Hex(), time.Now().Add(time.Minute))
+
+	var deletedAt time.Time
+	mocks.appRepository.On("GetById", ctx, app.Id).Return(app, nil)
+	mocks.appRepository.On("MarkDeleted", ctx, app.Id, mock.Anything).Run(func(args mock.Arguments) {
+		deletedAt = args.Get(2).(time.Time)
+	}).Return(true, nil)
+	sameTime := mock.MatchedBy(func(at time.Time) bool { return at.Equal(deletedAt) })
+	mocks.environmentRepository.On("MarkDeletedByAppId", ctx, app.Id, sameTime).Return(environmentIds, nil)
+	mocks.versionRepository.On("MarkDeletedByEnvironmentIds", ctx, environmentIds, sameTime).Return(nil)
+
+	// Execute
+	deleted, err := service.Delete(ctx, utils.RESOURCE_APP, app.Id, token, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "onboarding-test", deleted.Name)
+	assert.Equal(t, deletedAt, deleted.DeletedAt)
+	assert.Equal(t, deletedAt.Add(720*time.Hour), deleted.PurgeAt)
+	mocks.environmentRepository.AssertExpectations(t)
+	mocks.versionRepository.AssertExpectations(t)
+	mocks.auditService.AssertCalled(t, "Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "app.deleted" && event.Actor == "admin" && event.AppId == app.Id
+	}))
+}
+
+func TestDeletionService_Restore_AppNameTaken(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	deletedAt := time.Now().Add(-time.Minute)
+	app := &model.App{Id: primitive.NewObjectID(), Name: "onboarding-test", DeletedAt: &deletedAt}
+
+	mocks.appRepository.On("GetDeletedById", ctx, app.Id).Return(app, nil)
+	mocks.appRepository.On("GetByName", ctx, "onboarding-test").Return(&model.App{Id: primitive.NewObjectID(), Name: "onboarding-test"}, nil)
+
+	// Execute
+	err := service.Restore(ctx, utils.RESOURCE_APP, app.Id, "admin")
+
+	// Assert
+	assert.Error(t, err)
+	mocks.appRepository.AssertNotCalled(t, "Restore", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDeletionService_Restore_EnvironmentWithVersions(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	deletedAt := time.Now().Add(-time.Minute)
+	app := &model.App{Id: primitive.NewObjectID(), Name: "app-ios"}
+	environment := &model.Environment{Id: primitive.NewObjectID(), AppId: app.Id, Name: "staging", DeletedAt: &deletedAt}
+
+	mocks.environmentRepository.On("GetDeletedById", ctx, environment.Id).Return(environment, nil)
+	mocks.appRepository.On("GetById", ctx, app.Id).Return(app, nil)
+	mocks.environmentRepository.On("GetByAppIdAndName", ctx, app.Id, "staging").Return(nil, mongo.ErrNoDocuments)
+	mocks.environmentRepository.On("Restore", ctx, environment.Id, deletedAt).Return(true, nil)
+	mocks.versionRepository.On("RestoreByEnvironmentIds", ctx, []primitive.ObjectID{environment.Id}, deletedAt).Return(nil)
+
+	// Execute
+	err := service.Restore(ctx, utils.RESOURCE_ENVIRONMENT, environment.Id, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	mocks.environmentRepository.AssertExpectations(t)
+	mocks.versionRepository.AssertExpectations(t)
+}
+
+func TestDeletionService_PurgeExpired_RemovesVersionBundles(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), AppVersion: "1.0.0"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, DownloadFile: "a.zip"}
+
+	mocks.versionRepository.On("GetAllDeleted", ctx, mock.Anything).Return([]*model.Version{version}, nil)
+	mocks.bundleRepository.On("GetAllByVersionId", ctx, version.Id).Return([]*model.Bundle{bundle}, nil)
+	mocks.storage.On("DeleteFile", ctx, "a.zip").Return(nil)
+	mocks.bundleMetricRepository.On("DeleteByBundleIds", ctx, []primitive.ObjectID{bundle.Id}).Return(nil)
+	mocks.bundleRepository.On("DeleteByVersionId", ctx, version.Id).Return(nil)
+	mocks.versionRepository.On("Delete", ctx, version.Id).Return(nil)
+	mocks.environmentRepository.On("GetAllDeleted", ctx, mock.Anything).Return([]*model.Environment{}, nil)
+	mocks.appRepository.On("GetAllDeleted", ctx, mock.Anything).Return([]*model.App{}, nil)
+
+	// Execute
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	mocks.storage.AssertExpectations(t)
+	mocks.bundleMetricRepository.AssertExpectations(t)
+	mocks.bundleRepository.AssertExpectations(t)
+	mocks.versionRepository.AssertExpectations(t)
+}
+
+func TestDeletionService_PurgeExpired_StorageErrorKeepsVersion(t *testing.T) {
+	service, mocks := newDeletionTestService(time.Hour)
+	ctx := context.Background()
+	version := &model.Version{Id: primitive.NewObjectID(), AppVersion: "1.0.0"}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, DownloadFile: "a.zip"}
+
+	mocks.versionRepository.On("GetAllDeleted", ctx, mock.Anything).Return([]*model.Version{version}, nil)
+	mocks.bundleRepository.On("GetAllByVersionId", ctx, version.Id).Return([]*model.Bundle{bundle}, nil)
+	mocks.storage.On("DeleteFile", ctx, "a.zip").Return(errors.New("bucket unavailable"))
+
+	// Execute
+	purged, err := service.PurgeExpired(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Zero(t, purged)
+	mocks.bundleRepository.AssertNotCalled(t, "DeleteByVersionId", mock.Anything, mock.Anything)
+	mocks.versionRepository.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type DeviceService interface {
+	EnsureIndexes(ctx context.Context) error
+	GetDevice(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error)
+	RecordDownload(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error)
+	RecordDeploySucceeded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, bundle *model.Bundle) (*model.Device, bool, error)
+	RecordDeployFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string) (bool, error)
+	ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error)
+	CountDeployOutcomes(ctx context.Context, environmentId primitive.ObjectID, label string, since time.Time) (int64, int64, error)
+}
+
+type deviceService struct {
+	deviceRepository repository.DeviceRepository
+}
+
+func NewDeviceService(deviceRepository repository.DeviceRepository) DeviceService {
+	return &deviceService{deviceRepository: deviceRepository}
+}
+
+func (s *deviceService) EnsureIndexes(ctx context.Context) error {
+	return s.deviceRepository.EnsureIndexes(ctx)
+}
+
+func (s *deviceService) GetDevice(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error) {
+	return s.deviceRepository.GetByClientUniqueId(ctx, environmentId, clientUniqueId)
+}
+
+// RecordDownload returns true only the first time the device reports downloading the label
+func (s *deviceService) RecordDownload(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	return s.deviceRepository.MarkDownloaded(ctx, environmentId, clientUniqueId, label)
+}
+
+// RecordDeploySucceeded returns the device state before the deploy and true when the device
+// switched to the bundle, a repeated report of the bundle it already runs returns false
+func (s *deviceService) RecordDeploySucceeded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, bundle *model.Bundle) (*model.Device, bool, error) {
+	return s.deviceRepository.MarkDeployed(ctx, environmentId, clientUniqueId, appVersion, bundle.Label, bundle.Id, utils.DEPLOYMENT_SUCCEEDED)
+}
+
+// RecordDeployFailed returns true unless the same failure was the last thing the device reported
+func (s *deviceService) RecordDeployFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string) (bool, error) {
+	return s.deviceRepository.MarkFailed(ctx, environmentId, clientUniqueId, appVersion, label, utils.DEPLOYMENT_FAILED)
+}
+
+// ReleaseBundle returns true when the device was running the label in the environment and no longer is
+func (s *deviceService) ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	return s.deviceRepository.ReleaseBundle(ctx, environmentId, clientUniqueId, label)
+}
+
+// CountDeployOutcomes returns how many devices installed the label and how many failed to since the given time,
+// each device counts once with its latest report
+func (s *deviceService) CountDeployOutcomes(ctx context.Context, environmentId primitive.ObjectID, label string, since time.Time) (int64, int64, error) {
+	succeeded, err := s.deviceRepository.CountByLastStatus(ctx, environmentId, label, utils.DEPLOYMENT_SUCCEEDED, since)
+	if err != nil {
+		return 0, 0, err
+	}
+	failed, err := s.deviceRepository.CountByLastStatus(ctx, environmentId, label, utils.DEPLOYMENT_FAILED, since)
+	if err != nil {
+		return 0, 0, err
+	}
+	return succeeded, failed, nil
+}
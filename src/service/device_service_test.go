@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockDeviceRepository is a mock implementation of DeviceRepository
+type MockDeviceRepository struct {
+	mock.Mock
+}
+
+func (m *MockDeviceRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockDeviceRepository) GetByClientUniqueId(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string) (*model.Device, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Device), args.Error(1)
+}
+
+func (m *MockDeviceRepository) MarkDownloaded(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, label)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceRepository) MarkDeployed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, bundleId primitive.ObjectID, status string) (*model.Device, bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, appVersion, label, bundleId, status)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*model.Device), args.Bool(1), args.Error(2)
+}
+
+func (m *MockDeviceRepository) MarkFailed(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, appVersion string, label string, status string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, appVersion, label, status)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceRepository) ReleaseBundle(ctx context.Context, environmentId primitive.ObjectID, clientUniqueId string, label string) (bool, error) {
+	args := m.Called(ctx, environmentId, clientUniqueId, label)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockDeviceRepository) CountByLastStatus(ctx context.Context, environmentId primitive.ObjectID, label string, status string, since time.Time) (int64, error) {
+	args := m.Called(ctx, environmentId, label, status, since)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockDeviceRepository) DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error {
+	args := m.Called(ctx, environmentId)
+	return args.Error(0)
+}
+
+func TestDeviceService_RecordDeploySucceeded_ReturnsPreviousState(t *testing.T) {
+	mockRepo := &MockDeviceRepository{}
+	service := NewDeviceService(mockRepo)
+
+	ctx := context.Background()
+	environmentId := primitive.NewObjectID()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+	previousState := &model.Device{CurrentLabel: "v1x1", CurrentBundleId: primitive.NewObjectID()}
+	mockRepo.On("MarkDeployed", ctx, environmentId, "device-1", "1.0.0", "v1x2", bundle.Id, "DeploymentSucceeded").Return(previousState, true, nil)
+
+	// Execute
+	device, changed, err := service.RecordDeploySucceeded(ctx, environmentId, "device-1", "1.0.0", bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, previousState, device)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestDeviceService_RecordDeployFailed_Repeated(t *testing.T) {
+	mockRepo := &MockDeviceRepository{}
+	service := NewDeviceService(mockRepo)
+
+	ctx := context.Background()
+	environmentId := primitive.NewObjectID()
+	mockRepo.On("MarkFailed", ctx, environmentId, "device-1", "1.0.0", "v1x2", "DeploymentFailed").Return(false, nil)
+
+	// Execute
+	changed, err := service.RecordDeployFailed(ctx, environmentId, "device-1", "1.0.0", "v1x2")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	mockRepo.AssertExpectations(t)
+}
@@ -3,12 +3,13 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/repository"
 	"github.com/SwishHQ/spread/types"
-	"github.com/google/uuid"
+	"github.com/SwishHQ/spread/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -19,6 +20,11 @@ type EnvironmentService interface {
 	GetEnvironmentByKey(ctx context.Context, key string) (*model.Environment, error)
 	GetAllEnvironmentsByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Environment, error)
 	GetEnvironmentByAppIdAndEnvironmentId(ctx context.Context, appId primitive.ObjectID, environmentId string) (*model.Environment, error)
+	RotateEnvironmentKey(ctx context.Context, environmentId primitive.ObjectID, gracePeriod time.Duration) (*model.Environment, error)
+	UpdateRollbackPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateRollbackPolicyRequest) (*model.Environment, error)
+	RenameEnvironment(ctx context.Context, environmentId primitive.ObjectID, environmentName string) (*model.Environment, error)
+	UpdateFreezeWindows(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateFreezeWindowsRequest) (*model.Environment, error)
+	UpdateApprovalPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateApprovalPolicyRequest) (*model.Environment, error)
 }
 
 type environmentServiceImpl struct {
@@ -57,7 +63,7 @@ func (environmentService *environmentServiceImpl) CreateEnvironment(environmentR
 	if existingEnvironment != nil {
 		return nil, errors.New("environment with name " + environmentRequest.EnvironmentName + " already exists for app " + app.Name)
 	}
-	key := uuid.New().String()
+	key := utils.GenerateDeploymentKey()
 	environment := model.Environment{
 		AppId:     app.Id,
 		Name:      environmentRequest.EnvironmentName,
@@ -102,3 +108,128 @@ func (environmentService *environmentServiceImpl) GetEnvironmentByAppIdAndEnviro
 	}
 	return environment, nil
 }
+
+// RotateEnvironmentKey issues a new deployment key for an environment, the old key
+// keeps resolving to the environment for gracePeriod so shipped binaries can move over.
+// Only one previous key is kept, so there is no new rotation until its grace period ended
+func (environmentService *environmentServiceImpl) RotateEnvironmentKey(ctx context.Context, environmentId primitive.ObjectID, gracePeriod time.Duration) (*model.Environment, error) {
+	if gracePeriod < 0 {
+		return nil, errors.New("grace period cannot be negative")
+	}
+	environment, err := environmentService.environmentRepository.GetById(ctx, environmentId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("environment not found")
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if environment.PreviousKeyExpiresAt != nil && environment.PreviousKeyExpiresAt.After(now) {
+		return nil, errors.New("the previous deployment key is valid until " + environment.PreviousKeyExpiresAt.UTC().Format(time.RFC3339) + ", the key can be rotated again once it expired")
+	}
+	rotated, err := environmentService.environmentRepository.UpdateKey(ctx, environment.Id, utils.GenerateDeploymentKey(), environment.Key, now.Add(gracePeriod))
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("the deployment key was rotated at the same time")
+		}
+		return nil, err
+	}
+	return rotated, nil
+}
+
+// UpdateRollbackPolicy replaces the automatic rollback policy of an environment
+func (environmentService *environmentServiceImpl) UpdateRollbackPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateRollbackPolicyRequest) (*model.Environment, error) {
+	environment, err := environmentService.environmentRepository.UpdateRollbackPolicy(ctx, environmentId, &model.RollbackPolicy{
+		Enabled:        request.Enabled,
+		MaxFailureRate: request.MaxFailureRate,
+		MinInstalls:    request.MinInstalls,
+		WindowMinutes:  request.WindowMinutes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if environment == nil {
+		return nil, errors.New("environment not found")
+	}
+	return environment, nil
+}
+
+// RenameEnvironment changes the name of an environment, names stay unique within the app
+func (environmentService *environmentServiceImpl) RenameEnvironment(ctx context.Context, environmentId primitive.ObjectID, environmentName string) (*model.Environment, error) {
+	environment, err := environmentService.environmentRepository.GetById(ctx, environmentId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("environment not found")
+		}
+		return nil, err
+	}
+	existingEnvironment, err := environmentService.environmentRepository.GetByAppIdAndName(ctx, environment.AppId, environmentName)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if existingEnvironment != nil && existingEnvironment.Id != environment.Id {
+		return nil, errors.New("environment with name " + environmentName + " already exists for this app")
+	}
+	renamedEnvironment, err := environmentService.environmentRepository.UpdateName(ctx, environment.Id, environmentName)
+	if err != nil {
+		return nil, err
+	}
+	if renamedEnvironment == nil {
+		return nil, errors.New("environment not found")
+	}
+	return renamedEnvironment, nil
+}
+
+// UpdateFreezeWindows replaces the freeze windows of an environment, an empty list removes them all
+func (environmentService *environmentServiceImpl) UpdateFreezeWindows(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateFreezeWindowsRequest) (*model.Environment, error) {
+	windows := make([]model.FreezeWindow, 0, len(request.Windows))
+	for i, window := range request.Windows {
+		start, err := utils.ParseWeeklyTime(window.Start)
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %d: %w", i+1, err)
+		}
+		end, err := utils.ParseWeeklyTime(window.End)
+		if err != nil {
+			return nil, fmt.Errorf("freeze window %d: %w", i+1, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("freeze window %d: start and end are the same time", i+1)
+		}
+		timezone := window.Timezone
+		if timezone == "" {
+			timezone = "UTC"
+		}
+		if _, err := time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("freeze window %d: unknown timezone %q", i+1, window.Timezone)
+		}
+		windows = append(windows, model.FreezeWindow{Name: window.Name, Start: window.Start, End: window.End, Timezone: timezone})
+	}
+	environment, err := environmentService.environmentRepository.UpdateFreezeWindows(ctx, environmentId, windows)
+	if err != nil {
+		return nil, err
+	}
+	if environment == nil {
+		return nil, errors.New("environment not found")
+	}
+	return environment, nil
+}
+
+// UpdateApprovalPolicy replaces the approval policy of an environment. Releases already waiting
+// for approval keep waiting when the policy is turned off
+func (environmentService *environmentServiceImpl) UpdateApprovalPolicy(ctx context.Context, environmentId primitive.ObjectID, request *types.UpdateApprovalPolicyRequest) (*model.Environment, error) {
+	approverRole := request.ApproverRole
+	if approverRole == "" {
+		approverRole = utils.ROLE_ADMIN
+	}
+	environment, err := environmentService.environmentRepository.UpdateApprovalPolicy(ctx, environmentId, &model.ApprovalPolicy{
+		Required:     request.Required,
+		ApproverRole: approverRole,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if environment == nil {
+		return nil, errors.New("environment not found")
+	}
+	return environment, nil
+}
@@ -3,11 +3,13 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -51,6 +53,14 @@ func (m *MockAppService) GetAppById(ctx context.Context, id string) (*model.App,
 	return args.Get(0).(*model.App), args.Error(1)
 }
 
+func (m *MockAppService) RenameApp(ctx context.Context, id string, appName string) (*model.App, error) {
+	args := m.Called(ctx, id, appName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.App), args.Error(1)
+}
+
 // MockEnvironmentRepository is a mock implementation of EnvironmentRepository
 type MockEnvironmentRepository struct {
 	mock.Mock
@@ -96,6 +106,101 @@ func (m *MockEnvironmentRepository) GetByIdAndAppId(ctx context.Context, id prim
 	return args.Get(0).(*model.Environment), args.Error(1)
 }
 
+func (m *MockEnvironmentRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) UpdateKey(ctx context.Context, id primitive.ObjectID, key string, previousKey string, previousKeyExpiresAt time.Time) (*model.Environment, error) {
+	args := m.Called(ctx, id, key, previousKey, previousKeyExpiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) UpdateRollbackPolicy(ctx context.Context, id primitive.ObjectID, policy *model.RollbackPolicy) (*model.Environment, error) {
+	args := m.Called(ctx, id, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) UpdateName(ctx context.Context, id primitive.ObjectID, name string) (*model.Environment, error) {
+	args := m.Called(ctx, id, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	args := m.Called(ctx, id, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) MarkDeletedByAppId(ctx context.Context, appId primitive.ObjectID, at time.Time) ([]primitive.ObjectID, error) {
+	args := m.Called(ctx, appId, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]primitive.ObjectID), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, deletedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) RestoreByAppId(ctx context.Context, appId primitive.ObjectID, deletedAt time.Time) ([]primitive.ObjectID, error) {
+	args := m.Called(ctx, appId, deletedAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]primitive.ObjectID), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Environment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Environment, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockEnvironmentRepository) UpdateFreezeWindows(ctx context.Context, id primitive.ObjectID, windows []model.FreezeWindow) (*model.Environment, error) {
+	args := m.Called(ctx, id, windows)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
+func (m *MockEnvironmentRepository) UpdateApprovalPolicy(ctx context.Context, id primitive.ObjectID, policy *model.ApprovalPolicy) (*model.Environment, error) {
+	args := m.Called(ctx, id, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Environment), args.Error(1)
+}
+
 func TestNewEnvironmentService(t *testing.T) {
 	mockAppService := &MockAppService{}
 	mockEnvRepo := &MockEnvironmentRepository{}
@@ -152,6 +257,10 @@ func TestEnvironmentService_CreateEnvironment_Success(t *testing.T) {
 	assert.Equal(t, expectedEnvironment.AppId, result.AppId)
 	assert.NotEmpty(t, result.Key)
 
+	// The generated deployment key should carry the deployment key prefix
+	insertedEnvironment := mockEnvRepo.Calls[1].Arguments[1].(*model.Environment)
+	assert.True(t, strings.HasPrefix(insertedEnvironment.Key, utils.DEPLOYMENT_KEY_PREFIX))
+
 	mockAppService.AssertExpectations(t)
 	mockEnvRepo.AssertExpectations(t)
 }
@@ -617,3 +726,155 @@ func TestEnvironmentService_GetEnvironmentByAppIdAndEnvironmentId_NotFound(t *te
 
 	mockEnvRepo.AssertExpectations(t)
 }
+
+func TestEnvironmentService_RotateEnvironmentKey_Success(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(mockAppService, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+	oldKey := "spd_oldkey"
+
+	existingEnvironment := &model.Environment{
+		Id:   environmentID,
+		Name: "production",
+		Key:  oldKey,
+	}
+	mockEnvRepo.On("GetById", ctx, environmentID).Return(existingEnvironment, nil)
+
+	var newKey string
+	var expiresAt time.Time
+	mockEnvRepo.On("UpdateKey", ctx, environmentID, mock.AnythingOfType("string"), oldKey, mock.AnythingOfType("time.Time")).
+		Run(func(args mock.Arguments) {
+			newKey = args.String(2)
+			expiresAt = args.Get(4).(time.Time)
+		}).
+		Return(&model.Environment{Id: environmentID, Key: "spd_newkey", PreviousKey: oldKey}, nil)
+
+	// Execute
+	before := time.Now()
+	result, err := service.RotateEnvironmentKey(ctx, environmentID, 24*time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.Equal(t, oldKey, result.PreviousKey)
+	assert.True(t, strings.HasPrefix(newKey, utils.DEPLOYMENT_KEY_PREFIX))
+	assert.NotEqual(t, oldKey, newKey)
+	assert.WithinDuration(t, before.Add(24*time.Hour), expiresAt, time.Minute)
+
+	mockEnvRepo.AssertExpectations(t)
+}
+
+func TestEnvironmentService_RotateEnvironmentKey_PreviousKeyStillValid(t *testing.T) {
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(&MockAppService{}, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+	// binaries shipping spd_firstkey still get updates for another hour
+	expiresAt := time.Now().Add(time.Hour)
+	mockEnvRepo.On("GetById", ctx, environmentID).Return(&model.Environment{Id: environmentID, Key: "spd_secondkey", PreviousKey: "spd_firstkey", PreviousKeyExpiresAt: &expiresAt}, nil)
+
+	// Execute
+	result, err := service.RotateEnvironmentKey(ctx, environmentID, 24*time.Hour)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Contains(t, err.Error(), "the previous deployment key is valid until")
+	mockEnvRepo.AssertNotCalled(t, "UpdateKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEnvironmentService_RotateEnvironmentKey_AfterPreviousKeyExpired(t *testing.T) {
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(&MockAppService{}, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+	expiredAt := time.Now().Add(-time.Minute)
+	mockEnvRepo.On("GetById", ctx, environmentID).Return(&model.Environment{Id: environmentID, Key: "spd_secondkey", PreviousKey: "spd_firstkey", PreviousKeyExpiresAt: &expiredAt}, nil)
+	mockEnvRepo.On("UpdateKey", ctx, environmentID, mock.AnythingOfType("string"), "spd_secondkey", mock.AnythingOfType("time.Time")).Return(&model.Environment{Id: environmentID, PreviousKey: "spd_secondkey"}, nil)
+
+	// Execute
+	result, err := service.RotateEnvironmentKey(ctx, environmentID, time.Hour)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "spd_secondkey", result.PreviousKey)
+	mockEnvRepo.AssertExpectations(t)
+}
+
+func TestEnvironmentService_RotateEnvironmentKey_NotFound(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(mockAppService, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+
+	mockEnvRepo.On("GetById", ctx, environmentID).Return(nil, mongo.ErrNoDocuments)
+
+	// Execute
+	result, err := service.RotateEnvironmentKey(ctx, environmentID, time.Hour)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "environment not found", err.Error())
+
+	mockEnvRepo.AssertExpectations(t)
+}
+
+func TestEnvironmentService_RotateEnvironmentKey_NegativeGracePeriod(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(mockAppService, mockEnvRepo)
+
+	// Execute
+	result, err := service.RotateEnvironmentKey(context.Background(), primitive.NewObjectID(), -time.Hour)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	mockEnvRepo.AssertNotCalled(t, "GetById", mock.Anything, mock.Anything)
+}
+
+func TestEnvironmentService_UpdateRollbackPolicy_Success(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(mockAppService, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+	policy := &model.RollbackPolicy{Enabled: true, MaxFailureRate: 5, MinInstalls: 200, WindowMinutes: 30}
+	mockEnvRepo.On("UpdateRollbackPolicy", ctx, environmentID, policy).Return(&model.Environment{Id: environmentID, RollbackPolicy: policy}, nil)
+
+	// Execute
+	result, err := service.UpdateRollbackPolicy(ctx, environmentID, &types.UpdateRollbackPolicyRequest{Enabled: true, MaxFailureRate: 5, MinInstalls: 200, WindowMinutes: 30})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, policy, result.RollbackPolicy)
+
+	mockEnvRepo.AssertExpectations(t)
+}
+
+func TestEnvironmentService_UpdateRollbackPolicy_NotFound(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockEnvRepo := &MockEnvironmentRepository{}
+	service := NewEnvironmentService(mockAppService, mockEnvRepo)
+
+	ctx := context.Background()
+	environmentID := primitive.NewObjectID()
+	mockEnvRepo.On("UpdateRollbackPolicy", ctx, environmentID, mock.Anything).Return(nil, nil)
+
+	// Execute
+	result, err := service.UpdateRollbackPolicy(ctx, environmentID, &types.UpdateRollbackPolicyRequest{MaxFailureRate: 5, MinInstalls: 1, WindowMinutes: 1})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "environment not found", err.Error())
+}
@@ -0,0 +1,344 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+var ErrExperimentEnded = errors.New("experiment already ended")
+
+// ExperimentService serves several bundles of a version to disjoint cohorts of devices until the
+// experiment is concluded with a winner or stopped
+type ExperimentService interface {
+	EnsureIndexes(ctx context.Context) error
+	StartExperiment(ctx context.Context, request *types.StartExperimentRequest, actor string, override *types.FreezeOverride) (*model.Experiment, error)
+	GetExperiment(ctx context.Context, id primitive.ObjectID) (*model.Experiment, []types.ExperimentVariantResult, error)
+	GetExperimentsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error)
+	Conclude(ctx context.Context, id primitive.ObjectID, winnerBundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Experiment, error)
+	Stop(ctx context.Context, id primitive.ObjectID, actor string) (*model.Experiment, error)
+	AssignBundle(ctx context.Context, version *model.Version, clientUniqueId string) (*model.Bundle, error)
+}
+
+type experimentService struct {
+	experimentRepository   repository.ExperimentRepository
+	versionRepository      repository.VersionRepository
+	bundleRepository       repository.BundleRepository
+	deviceService          DeviceService
+	releaseScheduleService ReleaseScheduleService
+	auditService           AuditService
+}
+
+func NewExperimentService(experimentRepository repository.ExperimentRepository, versionRepository repository.VersionRepository, bundleRepository repository.BundleRepository, deviceService DeviceService, releaseScheduleService ReleaseScheduleService, auditService AuditService) ExperimentService {
+	return &experimentService{
+		experimentRepository:   experimentRepository,
+		versionRepository:      versionRepository,
+		bundleRepository:       bundleRepository,
+		deviceService:          deviceService,
+		releaseScheduleService: releaseScheduleService,
+		auditService:           auditService,
+	}
+}
+
+func (s *experimentService) EnsureIndexes(ctx context.Context) error {
+	return s.experimentRepository.EnsureIndexes(ctx)
+}
+
+// StartExperiment serves the variants of a version from now on. Every variant has to be an enabled
+// bundle of the version, and a version runs one experiment at a time. Devices start getting other
+// bundles, so it has to be outside the freeze windows of the environment unless overridden
+func (s *experimentService) StartExperiment(ctx context.Context, request *types.StartExperimentRequest, actor string, override *types.FreezeOverride) (*model.Experiment, error) {
+	versionId, err := primitive.ObjectIDFromHex(request.VersionId)
+	if err != nil {
+		return nil, errors.New("invalid version id")
+	}
+	version, err := s.versionRepository.GetById(ctx, versionId)
+	if err != nil {
+		return nil, err
+	}
+	if version == nil {
+		return nil, errors.New("version not found")
+	}
+	if !version.ExperimentId.IsZero() {
+		return nil, errors.New("an experiment already runs for this version, conclude or stop it first")
+	}
+	freezeWindow, err := s.releaseScheduleService.CheckFreeze(ctx, version.EnvironmentId, time.Now(), override)
+	if err != nil {
+		return nil, err
+	}
+
+	experiment := &model.Experiment{
+		EnvironmentId: version.EnvironmentId,
+		VersionId:     version.Id,
+		Name:          request.Name,
+		Status:        utils.EXPERIMENT_RUNNING,
+		CreatedBy:     actor,
+	}
+	totalWeight := 0
+	seen := map[primitive.ObjectID]bool{}
+	variantBundles := []*model.Bundle{}
+	for i, variant := range request.Variants {
+		bundleId, err := primitive.ObjectIDFromHex(variant.BundleId)
+		if err != nil {
+			return nil, fmt.Errorf("variant %d: invalid bundle id", i+1)
+		}
+		if seen[bundleId] {
+			return nil, fmt.Errorf("variant %d: bundle is already a variant", i+1)
+		}
+		seen[bundleId] = true
+		bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+		if err != nil {
+			if err == mongo.ErrNoDocuments {
+				return nil, fmt.Errorf("variant %d: bundle not found", i+1)
+			}
+			return nil, err
+		}
+		if bundle.VersionId != version.Id {
+			return nil, fmt.Errorf("variant %d: bundle %s is not a bundle of version %s", i+1, bundle.Label, version.AppVersion)
+		}
+		if !bundle.IsValid {
+			return nil, fmt.Errorf("variant %d: bundle %s is not enabled", i+1, bundle.Label)
+		}
+		// an experiment must not release what still waits for its window or its approver
+		if bundle.ScheduledAt != nil {
+			return nil, fmt.Errorf("variant %d: bundle %s is scheduled", i+1, bundle.Label)
+		}
+		if bundle.Approval != nil && bundle.Approval.Status != utils.APPROVAL_APPROVED {
+			return nil, fmt.Errorf("variant %d: bundle %s is not approved", i+1, bundle.Label)
+		}
+		experiment.AppId = bundle.AppId
+		variantBundles = append(variantBundles, bundle)
+		experiment.Variants = append(experiment.Variants, model.ExperimentVariant{BundleId: bundle.Id, Label: bundle.Label, Weight: variant.Weight})
+		totalWeight += variant.Weight
+	}
+	if len(experiment.Variants) < 2 {
+		return nil, errors.New("an experiment needs at least two variants")
+	}
+	if totalWeight != 100 {
+		return nil, fmt.Errorf("variant weights add up to %d, they have to add up to 100", totalWeight)
+	}
+
+	experiment, err = s.experimentRepository.Insert(ctx, experiment)
+	if err != nil {
+		return nil, err
+	}
+	started, err := s.versionRepository.StartExperiment(ctx, version.Id, experiment.Id)
+	if err == nil && !started {
+		err = errors.New("an experiment already runs for this version, conclude or stop it first")
+	}
+	if err != nil {
+		// another experiment was started at the same time, this one never ran
+		if _, endErr := s.experimentRepository.End(ctx, experiment.Id, utils.EXPERIMENT_STOPPED, primitive.NilObjectID, actor); endErr != nil {
+			logger.L.Error("In StartExperiment: Error stopping experiment that did not start", zap.String("experimentId", experiment.Id.Hex()), zap.Error(endErr))
+		}
+		return nil, err
+	}
+	labels := make([]string, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		labels = append(labels, fmt.Sprintf("%s %d%%", variant.Label, variant.Weight))
+	}
+	details := map[string]interface{}{"name": experiment.Name, "variants": labels}
+	if freezeWindow != nil {
+		details["freezeOverrideReason"] = override.Reason
+		// the current bundle was already out, the other variants are released by the experiment
+		for _, bundle := range variantBundles {
+			if bundle.Id != version.CurrentBundleId {
+				s.releaseScheduleService.RecordOverride(ctx, bundle, freezeWindow, override, "experiment")
+			}
+		}
+	}
+	s.record(ctx, "StartExperiment", experiment, utils.AUDIT_ACTION_EXPERIMENT_STARTED, actor, details)
+	return experiment, nil
+}
+
+// GetExperiment returns the experiment and how each variant did since it started
+func (s *experimentService) GetExperiment(ctx context.Context, id primitive.ObjectID) (*model.Experiment, []types.ExperimentVariantResult, error) {
+	experiment, err := s.getExperiment(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]types.ExperimentVariantResult, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		result := types.ExperimentVariantResult{BundleId: variant.BundleId.Hex(), Label: variant.Label, Weight: variant.Weight}
+		bundle, err := s.bundleRepository.GetById(ctx, variant.BundleId)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, nil, err
+		}
+		if bundle != nil {
+			result.Downloads = bundle.Installed
+			result.Active = bundle.Active
+		}
+		result.Installs, result.Failures, err = s.deviceService.CountDeployOutcomes(ctx, experiment.EnvironmentId, variant.Label, experiment.CreatedAt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if attempts := result.Installs + result.Failures; attempts > 0 {
+			result.FailureRate = float64(result.Failures) * 100 / float64(attempts)
+		}
+		results = append(results, result)
+	}
+	return experiment, results, nil
+}
+
+func (s *experimentService) GetExperimentsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error) {
+	return s.experimentRepository.GetAllByEnvironmentId(ctx, environmentId)
+}
+
+// Conclude makes the winner the current bundle of the version for every device, so it has to be
+// outside the freeze windows of the environment unless overridden
+func (s *experimentService) Conclude(ctx context.Context, id primitive.ObjectID, winnerBundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Experiment, error) {
+	experiment, err := s.getExperiment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.Status != utils.EXPERIMENT_RUNNING {
+		return nil, ErrExperimentEnded
+	}
+	var winner *model.ExperimentVariant
+	for i := range experiment.Variants {
+		if experiment.Variants[i].BundleId == winnerBundleId {
+			winner = &experiment.Variants[i]
+		}
+	}
+	if winner == nil {
+		return nil, errors.New("the winner has to be one of the variants")
+	}
+	winnerBundle, err := s.bundleRepository.GetById(ctx, winnerBundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	if !winnerBundle.IsValid {
+		return nil, fmt.Errorf("bundle %s was disabled, it cannot win", winnerBundle.Label)
+	}
+	freezeWindow, err := s.releaseScheduleService.CheckFreeze(ctx, experiment.EnvironmentId, time.Now(), override)
+	if err != nil {
+		return nil, err
+	}
+	ended, err := s.experimentRepository.End(ctx, experiment.Id, utils.EXPERIMENT_CONCLUDED, winnerBundleId, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !ended {
+		return nil, ErrExperimentEnded
+	}
+	if _, err := s.versionRepository.UpdateCurrentBundleId(ctx, experiment.VersionId, winnerBundleId); err != nil {
+		return nil, err
+	}
+	if err := s.versionRepository.EndExperiment(ctx, experiment.VersionId, experiment.Id); err != nil {
+		return nil, err
+	}
+	experiment.Status = utils.EXPERIMENT_CONCLUDED
+	experiment.WinnerBundleId = winnerBundleId
+	experiment.EndedBy = actor
+	details := map[string]interface{}{"name": experiment.Name, "winner": winner.Label}
+	if freezeWindow != nil {
+		details["freezeOverrideReason"] = override.Reason
+		s.releaseScheduleService.RecordOverride(ctx, winnerBundle, freezeWindow, override, "conclude")
+	}
+	s.record(ctx, "Conclude", experiment, utils.AUDIT_ACTION_EXPERIMENT_CONCLUDED, actor, details)
+	return experiment, nil
+}
+
+// Stop ends the experiment without a winner, the current bundle of the version is served again
+func (s *experimentService) Stop(ctx context.Context, id primitive.ObjectID, actor string) (*model.Experiment, error) {
+	experiment, err := s.getExperiment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ended, err := s.experimentRepository.End(ctx, experiment.Id, utils.EXPERIMENT_STOPPED, primitive.NilObjectID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if !ended {
+		return nil, ErrExperimentEnded
+	}
+	if err := s.versionRepository.EndExperiment(ctx, experiment.VersionId, experiment.Id); err != nil {
+		return nil, err
+	}
+	experiment.Status = utils.EXPERIMENT_STOPPED
+	experiment.EndedBy = actor
+	s.record(ctx, "Stop", experiment, utils.AUDIT_ACTION_EXPERIMENT_STOPPED, actor, map[string]interface{}{"name": experiment.Name})
+	return experiment, nil
+}
+
+// AssignBundle returns the variant of the experiment running for the version that the device gets.
+// Nil when the device gets the current bundle: no experiment runs, the device sent no client unique
+// id, or its variant was disabled
+func (s *experimentService) AssignBundle(ctx context.Context, version *model.Version, clientUniqueId string) (*model.Bundle, error) {
+	if version.ExperimentId.IsZero() || clientUniqueId == "" {
+		return nil, nil
+	}
+	experiment, err := s.experimentRepository.GetById(ctx, version.ExperimentId)
+	if err != nil || experiment == nil || experiment.Status != utils.EXPERIMENT_RUNNING {
+		return nil, err
+	}
+	variant := assignVariant(experiment, clientUniqueId)
+	if variant == nil {
+		return nil, nil
+	}
+	bundle, err := s.bundleRepository.GetById(ctx, variant.BundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !bundle.IsValid {
+		return nil, nil
+	}
+	return bundle, nil
+}
+
+// assignVariant puts the device in one of 100 buckets by its client unique id and the experiment,
+// so a device always lands in the same cohort and cohorts of different experiments are independent
+func assignVariant(experiment *model.Experiment, clientUniqueId string) *model.ExperimentVariant {
+	hash := fnv.New32a()
+	hash.Write([]byte(experiment.Id.Hex() + ":" + clientUniqueId))
+	bucket := int(hash.Sum32() % 100)
+	for i := range experiment.Variants {
+		if bucket < experiment.Variants[i].Weight {
+			return &experiment.Variants[i]
+		}
+		bucket -= experiment.Variants[i].Weight
+	}
+	return nil
+}
+
+func (s *experimentService) getExperiment(ctx context.Context, id primitive.ObjectID) (*model.Experiment, error) {
+	experiment, err := s.experimentRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if experiment == nil {
+		return nil, errors.New("experiment not found")
+	}
+	return experiment, nil
+}
+
+// the experiment already changed, a failed audit write is logged and not returned
+func (s *experimentService) record(ctx context.Context, fn string, experiment *model.Experiment, action string, actor string, details map[string]interface{}) {
+	err := s.auditService.Record(ctx, &model.AuditEvent{
+		Action:        action,
+		Actor:         actor,
+		AppId:         experiment.AppId,
+		EnvironmentId: experiment.EnvironmentId,
+		Details:       details,
+	})
+	if err != nil {
+		logger.L.Error("In "+fn+": Error recording audit event", zap.String("action", action), zap.Error(err))
+	}
+}
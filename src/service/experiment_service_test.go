@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockExperimentService is a mock implementation of ExperimentService
+type MockExperimentService struct {
+	mock.Mock
+}
+
+func (m *MockExperimentService) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockExperimentService) StartExperiment(ctx context.Context, request *types.StartExperimentRequest, actor string, override *types.FreezeOverride) (*model.Experiment, error) {
+	args := m.Called(ctx, request, actor, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentService) GetExperiment(ctx context.Context, id primitive.ObjectID) (*model.Experiment, []types.ExperimentVariantResult, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*model.Experiment), args.Get(1).([]types.ExperimentVariantResult), args.Error(2)
+}
+
+func (m *MockExperimentService) GetExperimentsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error) {
+	args := m.Called(ctx, environmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentService) Conclude(ctx context.Context, id primitive.ObjectID, winnerBundleId primitive.ObjectID, actor string, override *types.FreezeOverride) (*model.Experiment, error) {
+	args := m.Called(ctx, id, winnerBundleId, actor, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentService) Stop(ctx context.Context, id primitive.ObjectID, actor string) (*model.Experiment, error) {
+	args := m.Called(ctx, id, actor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentService) AssignBundle(ctx context.Context, version *model.Version, clientUniqueId string) (*model.Bundle, error) {
+	args := m.Called(ctx, version, clientUniqueId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+// MockExperimentRepository is a mock implementation of ExperimentRepository
+type MockExperimentRepository struct {
+	mock.Mock
+}
+
+func (m *MockExperimentRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockExperimentRepository) Insert(ctx context.Context, experiment *model.Experiment) (*model.Experiment, error) {
+	args := m.Called(ctx, experiment)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Experiment, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentRepository) GetAllByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.Experiment, error) {
+	args := m.Called(ctx, environmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Experiment), args.Error(1)
+}
+
+func (m *MockExperimentRepository) End(ctx context.Context, id primitive.ObjectID, status string, winnerBundleId primitive.ObjectID, endedBy string) (bool, error) {
+	args := m.Called(ctx, id, status, winnerBundleId, endedBy)
+	return args.Bool(0), args.Error(1)
+}
+
+type experimentTestMocks struct {
+	experimentRepository   *MockExperimentRepository
+	versionRepository      *MockVersionRepository
+	bundleRepository       *MockBundleRepository
+	deviceService          *MockDeviceService
+	releaseScheduleService *MockReleaseScheduleService
+	auditService           *MockAuditService
+}
+
+func newExperimentTestService() (ExperimentService, *experimentTestMocks) {
+	mocks := &experimentTestMocks{
+		experimentRepository:   &MockExperimentRepository{},
+		versionRepository:      &MockVersionRepository{},
+		bundleRepository:       &MockBundleRepository{},
+		deviceService:          &MockDeviceService{},
+		releaseScheduleService: &MockReleaseScheduleService{},
+		auditService:           &MockAuditService{},
+	}
+	return NewExperimentService(mocks.experimentRepository, mocks.versionRepository, mocks.bundleRepository, mocks.deviceService, mocks.releaseScheduleService, mocks.auditService), mocks
+}
+
+func TestExperimentService_StartExperiment_Success(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), AppVersion: "1.0.0"}
+	control := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x1", IsValid: true}
+	candidate := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x2", IsValid: true}
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+	mocks.releaseScheduleService.On("CheckFreeze", ctx, version.EnvironmentId, mock.AnythingOfType("time.Time"), (*types.FreezeOverride)(nil)).Return(nil, nil)
+	mocks.bundleRepository.On("GetById", ctx, control.Id).Return(control, nil)
+	mocks.bundleRepository.On("GetById", ctx, candidate.Id).Return(candidate, nil)
+	experimentId := primitive.NewObjectID()
+	inserted := &model.Experiment{}
+	mocks.experimentRepository.On("Insert", ctx, mock.AnythingOfType("*model.Experiment")).Run(func(args mock.Arguments) {
+		*inserted = *args.Get(1).(*model.Experiment)
+		inserted.Id = experimentId
+	}).Return(inserted, nil)
+	mocks.versionRepository.On("StartExperiment", ctx, version.Id, experimentId).Return(true, nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "experiment.started" && event.Actor == "alice"
+	})).Return(nil)
+
+	// Execute
+	experiment, err := service.StartExperiment(ctx, &types.StartExperimentRequest{
+		VersionId: version.Id.Hex(),
+		Name:      "new checkout",
+		Variants:  []types.ExperimentVariantRequest{{BundleId: control.Id.Hex(), Weight: 50}, {BundleId: candidate.Id.Hex(), Weight: 50}},
+	}, "alice", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "running", experiment.Status)
+	assert.Equal(t, []model.ExperimentVariant{{BundleId: control.Id, Label: "v1x1", Weight: 50}, {BundleId: candidate.Id, Label: "v1x2", Weight: 50}}, experiment.Variants)
+	mocks.versionRepository.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestExperimentService_StartExperiment_Validation(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	version := &model.Version{Id: primitive.NewObjectID(), AppVersion: "1.0.0"}
+	control := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x1", IsValid: true}
+	candidate := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x2", IsValid: true}
+	disabled := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x3"}
+	otherVersion := &model.Bundle{Id: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), Label: "v2x1", IsValid: true}
+	for _, bundle := range []*model.Bundle{control, candidate, disabled, otherVersion} {
+		mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	}
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+	mocks.releaseScheduleService.On("CheckFreeze", ctx, version.EnvironmentId, mock.AnythingOfType("time.Time"), (*types.FreezeOverride)(nil)).Return(nil, nil)
+
+	cases := []struct {
+		variants []types.ExperimentVariantRequest
+		err      string
+	}{
+		{[]types.ExperimentVariantRequest{{BundleId: control.Id.Hex(), Weight: 50}, {BundleId: candidate.Id.Hex(), Weight: 40}}, "variant weights add up to 90, they have to add up to 100"},
+		{[]types.ExperimentVariantRequest{{BundleId: control.Id.Hex(), Weight: 50}, {BundleId: control.Id.Hex(), Weight: 50}}, "variant 2: bundle is already a variant"},
+		{[]types.ExperimentVariantRequest{{BundleId: control.Id.Hex(), Weight: 50}, {BundleId: disabled.Id.Hex(), Weight: 50}}, "variant 2: bundle v1x3 is not enabled"},
+		{[]types.ExperimentVariantRequest{{BundleId: otherVersion.Id.Hex(), Weight: 50}, {BundleId: control.Id.Hex(), Weight: 50}}, "variant 1: bundle v2x1 is not a bundle of version 1.0.0"},
+	}
+	for _, c := range cases {
+		// Execute
+		_, err := service.StartExperiment(ctx, &types.StartExperimentRequest{VersionId: version.Id.Hex(), Name: "test", Variants: c.variants}, "alice", nil)
+
+		// Assert
+		assert.EqualError(t, err, c.err)
+	}
+	mocks.experimentRepository.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func TestExperimentService_StartExperiment_AlreadyRunning(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	version := &model.Version{Id: primitive.NewObjectID(), ExperimentId: primitive.NewObjectID()}
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+
+	// Execute
+	_, err := service.StartExperiment(ctx, &types.StartExperimentRequest{VersionId: version.Id.Hex(), Name: "test"}, "alice", nil)
+
+	// Assert
+	assert.EqualError(t, err, "an experiment already runs for this version, conclude or stop it first")
+}
+
+func TestAssignVariant_DeterministicSplit(t *testing.T) {
+	experiment := &model.Experiment{
+		Id:       primitive.NewObjectID(),
+		Variants: []model.ExperimentVariant{{Label: "v1x1", Weight: 50}, {Label: "v1x2", Weight: 50}},
+	}
+	counts := map[string]int{}
+
+	// Execute
+	for i := 0; i < 2000; i++ {
+		clientUniqueId := fmt.Sprintf("device-%d", i)
+		variant := assignVariant(experiment, clientUniqueId)
+		assert.Equal(t, variant, assignVariant(experiment, clientUniqueId))
+		counts[variant.Label]++
+	}
+
+	// Assert
+	assert.InDelta(t, 1000, counts["v1x1"], 100)
+	assert.InDelta(t, 1000, counts["v1x2"], 100)
+}
+
+func TestExperimentService_AssignBundle(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	control := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1", IsValid: true}
+	candidate := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", IsValid: true}
+	experiment := &model.Experiment{
+		Id:       primitive.NewObjectID(),
+		Status:   "running",
+		Variants: []model.ExperimentVariant{{BundleId: control.Id, Label: "v1x1", Weight: 50}, {BundleId: candidate.Id, Label: "v1x2", Weight: 50}},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), ExperimentId: experiment.Id}
+	mocks.experimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+	mocks.bundleRepository.On("GetById", ctx, control.Id).Return(control, nil)
+	mocks.bundleRepository.On("GetById", ctx, candidate.Id).Return(candidate, nil)
+
+	// Execute
+	bundle, err := service.AssignBundle(ctx, version, "device-1")
+	assert.NoError(t, err)
+	anonymous, err := service.AssignBundle(ctx, version, "")
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, assignVariant(experiment, "device-1").BundleId, bundle.Id)
+	assert.Nil(t, anonymous)
+}
+
+func TestExperimentService_Conclude_MakesWinnerCurrent(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	winner := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", IsValid: true}
+	experiment := &model.Experiment{
+		Id:        primitive.NewObjectID(),
+		VersionId: primitive.NewObjectID(),
+		Status:    "running",
+		Variants:  []model.ExperimentVariant{{BundleId: primitive.NewObjectID(), Label: "v1x1", Weight: 50}, {BundleId: winner.Id, Label: "v1x2", Weight: 50}},
+	}
+	mocks.experimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+	mocks.bundleRepository.On("GetById", ctx, winner.Id).Return(winner, nil)
+	mocks.releaseScheduleService.On("CheckFreeze", ctx, experiment.EnvironmentId, mock.AnythingOfType("time.Time"), (*types.FreezeOverride)(nil)).Return(nil, nil)
+	mocks.experimentRepository.On("End", ctx, experiment.Id, "concluded", winner.Id, "alice").Return(true, nil)
+	mocks.versionRepository.On("UpdateCurrentBundleId", ctx, experiment.VersionId, winner.Id).Return(&model.Version{}, nil)
+	mocks.versionRepository.On("EndExperiment", ctx, experiment.VersionId, experiment.Id).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "experiment.concluded" && event.Details["winner"] == "v1x2"
+	})).Return(nil)
+
+	// Execute
+	concluded, err := service.Conclude(ctx, experiment.Id, winner.Id, "alice", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "concluded", concluded.Status)
+	assert.Equal(t, winner.Id, concluded.WinnerBundleId)
+	mocks.experimentRepository.AssertExpectations(t)
+	mocks.versionRepository.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestExperimentService_Conclude_Frozen(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	winner := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", IsValid: true}
+	experiment := &model.Experiment{
+		Id:            primitive.NewObjectID(),
+		EnvironmentId: primitive.NewObjectID(),
+		Status:        "running",
+		Variants:      []model.ExperimentVariant{{BundleId: primitive.NewObjectID(), Label: "v1x1", Weight: 50}, {BundleId: winner.Id, Label: "v1x2", Weight: 50}},
+	}
+	mocks.experimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+	mocks.bundleRepository.On("GetById", ctx, winner.Id).Return(winner, nil)
+	mocks.releaseScheduleService.On("CheckFreeze", ctx, experiment.EnvironmentId, mock.AnythingOfType("time.Time"), (*types.FreezeOverride)(nil)).Return(nil, ErrReleaseFrozen)
+
+	// Execute
+	_, err := service.Conclude(ctx, experiment.Id, winner.Id, "alice", nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReleaseFrozen)
+	mocks.experimentRepository.AssertNotCalled(t, "End", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mocks.versionRepository.AssertNotCalled(t, "UpdateCurrentBundleId", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExperimentService_StartExperiment_OverridesFreeze(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	control := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1", IsValid: true}
+	version := &model.Version{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), AppVersion: "1.0.0", CurrentBundleId: control.Id}
+	control.VersionId = version.Id
+	candidate := &model.Bundle{Id: primitive.NewObjectID(), VersionId: version.Id, Label: "v1x2", IsValid: true}
+	override := &types.FreezeOverride{By: "admin", Reason: "checkout conversion is down"}
+	window := &model.FreezeWindow{Name: "weekend"}
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+	mocks.releaseScheduleService.On("CheckFreeze", ctx, version.EnvironmentId, mock.AnythingOfType("time.Time"), override).Return(window, nil)
+	mocks.bundleRepository.On("GetById", ctx, control.Id).Return(control, nil)
+	mocks.bundleRepository.On("GetById", ctx, candidate.Id).Return(candidate, nil)
+	mocks.experimentRepository.On("Insert", ctx, mock.AnythingOfType("*model.Experiment")).Return(&model.Experiment{Id: primitive.NewObjectID()}, nil)
+	mocks.versionRepository.On("StartExperiment", ctx, version.Id, mock.AnythingOfType("primitive.ObjectID")).Return(true, nil)
+	mocks.releaseScheduleService.On("RecordOverride", ctx, candidate, window, override, "experiment").Return()
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "experiment.started" && event.Details["freezeOverrideReason"] == "checkout conversion is down"
+	})).Return(nil)
+
+	// Execute
+	_, err := service.StartExperiment(ctx, &types.StartExperimentRequest{
+		VersionId: version.Id.Hex(),
+		Name:      "new checkout",
+		Variants:  []types.ExperimentVariantRequest{{BundleId: control.Id.Hex(), Weight: 50}, {BundleId: candidate.Id.Hex(), Weight: 50}},
+	}, "admin", override)
+
+	// Assert
+	assert.NoError(t, err)
+	// only the candidate is newly released, the current bundle was out already
+	mocks.releaseScheduleService.AssertNumberOfCalls(t, "RecordOverride", 1)
+	mocks.releaseScheduleService.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestExperimentService_Conclude_WinnerNotVariant(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	experiment := &model.Experiment{Id: primitive.NewObjectID(), Status: "running", Variants: []model.ExperimentVariant{{BundleId: primitive.NewObjectID(), Weight: 100}}}
+	mocks.experimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+
+	// Execute
+	_, err := service.Conclude(ctx, experiment.Id, primitive.NewObjectID(), "alice", nil)
+
+	// Assert
+	assert.EqualError(t, err, "the winner has to be one of the variants")
+	mocks.experimentRepository.AssertNotCalled(t, "End", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestExperimentService_GetExperiment_VariantResults(t *testing.T) {
+	service, mocks := newExperimentTestService()
+	ctx := context.Background()
+
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2", Installed: 120, Active: 90}
+	experiment := &model.Experiment{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), Variants: []model.ExperimentVariant{{BundleId: bundle.Id, Label: "v1x2", Weight: 100}}}
+	mocks.experimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.deviceService.On("CountDeployOutcomes", ctx, experiment.EnvironmentId, "v1x2", experiment.CreatedAt).Return(int64(95), int64(5), nil)
+
+	// Execute
+	_, results, err := service.GetExperiment(ctx, experiment.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ExperimentVariantResult{{BundleId: bundle.Id.Hex(), Label: "v1x2", Weight: 100, Downloads: 120, Active: 90, Installs: 95, Failures: 5, FailureRate: 5}}, results)
+}
@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+// ObjectStorage is the bucket bundles are uploaded to
+type ObjectStorage interface {
+	ListFiles(ctx context.Context) ([]pkg.StorageObject, error)
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// GCService deletes stored bundles nothing needs anymore: uploads that never became a bundle and
+// bundles past the retention policy of their version
+type GCService interface {
+	Collect(ctx context.Context, dryRun bool) (*types.GCReport, error)
+	Run(ctx context.Context)
+}
+
+type gcService struct {
+	bundleRepository       repository.BundleRepository
+	versionRepository      repository.VersionRepository
+	bundleMetricRepository repository.BundleMetricRepository
+	experimentRepository   repository.ExperimentRepository
+	storage                ObjectStorage
+	policy                 *config.GCPolicy
+}
+
+func NewGCService(bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, bundleMetricRepository repository.BundleMetricRepository, experimentRepository repository.ExperimentRepository, storage ObjectStorage, policy *config.GCPolicy) GCService {
+	return &gcService{
+		bundleRepository:       bundleRepository,
+		versionRepository:      versionRepository,
+		bundleMetricRepository: bundleMetricRepository,
+		experimentRepository:   experimentRepository,
+		storage:                storage,
+		policy:                 policy,
+	}
+}
+
+// Collect deletes orphaned uploads older than the grace period and superseded bundles with their
+// files and metrics. With dryRun it only reports them
+func (s *gcService) Collect(ctx context.Context, dryRun bool) (*types.GCReport, error) {
+	report := &types.GCReport{DryRun: dryRun, Orphans: []types.GCObject{}, Superseded: []types.GCBundle{}}
+
+	// list the bucket before reading the bundles, a bundle created in between is then known
+	objects, err := s.storage.ListFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	downloadFiles, err := s.bundleRepository.GetAllDownloadFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool, len(downloadFiles))
+	for _, downloadFile := range downloadFiles {
+		referenced[downloadFile] = true
+	}
+	orphanedBefore := time.Now().Add(-s.policy.OrphanGracePeriod)
+	for _, object := range objects {
+		if referenced[object.Key] || object.LastModified.After(orphanedBefore) {
+			continue
+		}
+		if !dryRun {
+			if err := s.storage.DeleteFile(ctx, object.Key); err != nil {
+				return report, err
+			}
+		}
+		report.Orphans = append(report.Orphans, types.GCObject{Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+		report.ReclaimedBytes += object.Size
+	}
+
+	if s.policy.KeepBundles == 0 {
+		return report, nil
+	}
+	versions, err := s.versionRepository.GetAll(ctx)
+	if err != nil {
+		return report, err
+	}
+	for _, version := range versions {
+		bundles, err := s.bundleRepository.GetAllByVersionId(ctx, version.Id)
+		if err != nil {
+			return report, err
+		}
+		variants, err := s.runningVariants(ctx, version)
+		if err != nil {
+			return report, err
+		}
+		superseded := supersededBundles(version, bundles, s.policy.KeepBundles, variants)
+		if len(superseded) == 0 {
+			continue
+		}
+		if !dryRun {
+			if err := s.deleteBundles(ctx, superseded); err != nil {
+				return report, err
+			}
+		}
+		for _, bundle := range superseded {
+			report.Superseded = append(report.Superseded, types.GCBundle{
+				Id:           bundle.Id.Hex(),
+				VersionId:    version.Id.Hex(),
+				Label:        bundle.Label,
+				SequenceId:   bundle.SequenceId,
+				DownloadFile: bundle.DownloadFile,
+				Size:         bundle.Size,
+			})
+			report.ReclaimedBytes += bundle.Size
+		}
+	}
+	return report, nil
+}
+
+// Run collects every policy.Interval until the context is cancelled
+func (s *gcService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		report, err := s.Collect(ctx, false)
+		if err != nil {
+			logger.L.Error("In Run: Error collecting bundle garbage", zap.Error(err))
+		}
+		if report != nil {
+			logger.L.Info("In Run: Collected bundle garbage", zap.Int("orphans", len(report.Orphans)), zap.Int("superseded", len(report.Superseded)), zap.Int64("reclaimedBytes", report.ReclaimedBytes))
+		}
+	}
+}
+
+// the bundles go before their files, a file left behind by a failure is an orphan the next run deletes
+func (s *gcService) deleteBundles(ctx context.Context, bundles []*model.Bundle) error {
+	ids := make([]primitive.ObjectID, 0, len(bundles))
+	for _, bundle := range bundles {
+		ids = append(ids, bundle.Id)
+	}
+	if err := s.bundleMetricRepository.DeleteByBundleIds(ctx, ids); err != nil {
+		return err
+	}
+	if err := s.bundleRepository.DeleteByIds(ctx, ids); err != nil {
+		return err
+	}
+	for _, bundle := range bundles {
+		for _, key := range []string{bundle.DownloadFile, bundle.SourceMapFile} {
+			if key == "" {
+				continue
+			}
+			if err := s.storage.DeleteFile(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runningVariants returns the bundles of the experiment running for the version, devices in its
+// cohorts get them instead of the current bundle
+func (s *gcService) runningVariants(ctx context.Context, version *model.Version) (map[primitive.ObjectID]bool, error) {
+	variants := map[primitive.ObjectID]bool{}
+	if version.ExperimentId.IsZero() {
+		return variants, nil
+	}
+	experiment, err := s.experimentRepository.GetById(ctx, version.ExperimentId)
+	if err != nil {
+		return nil, err
+	}
+	if experiment == nil || experiment.Status != utils.EXPERIMENT_RUNNING {
+		return variants, nil
+	}
+	for _, variant := range experiment.Variants {
+		variants[variant.BundleId] = true
+	}
+	return variants, nil
+}
+
+// supersededBundles returns the bundles of a version past the newest keep, never its current bundle,
+// the bundle a rollback would go back to, a variant of its running experiment, a bundle devices
+// outside the targeting of those still fall back to, a bundle waiting for its schedule or an approval
+// nor an enabled mandatory bundle, devices that skipped it have to know it was mandatory
+func supersededBundles(version *model.Version, bundles []*model.Bundle, keep int, variants map[primitive.ObjectID]bool) []*model.Bundle {
+	sort.Slice(bundles, func(i, j int) bool { return bundles[i].SequenceId > bundles[j].SequenceId })
+	kept := map[primitive.ObjectID]bool{}
+	for _, bundle := range bundles {
+		if bundle.Id != version.CurrentBundleId && !variants[bundle.Id] {
+			continue
+		}
+		kept[bundle.Id] = true
+		if bundle.Id == version.CurrentBundleId {
+			if rollbackTarget := lastValidBefore(bundles, bundle.SequenceId); rollbackTarget != nil {
+				kept[rollbackTarget.Id] = true
+			}
+		}
+		// like ResolveBundle, walk back past targeted bundles to the first one every device matches
+		for served := bundle; served != nil && served.Targeting != nil; {
+			served = lastValidBefore(bundles, served.SequenceId)
+			if served != nil {
+				kept[served.Id] = true
+			}
+		}
+	}
+	superseded := make([]*model.Bundle, 0)
+	for i, bundle := range bundles {
+		if i < keep || kept[bundle.Id] || awaitingRelease(bundle) || (bundle.IsValid && bundle.IsMandatory) {
+			continue
+		}
+		superseded = append(superseded, bundle)
+	}
+	return superseded
+}
+
+// lastValidBefore returns the newest valid bundle released before sequenceId, bundles are sorted newest first
+func lastValidBefore(bundles []*model.Bundle, sequenceId int64) *model.Bundle {
+	for _, bundle := range bundles {
+		if bundle.IsValid && bundle.SequenceId < sequenceId {
+			return bundle
+		}
+	}
+	return nil
+}
+
+// awaitingRelease reports whether the scheduler or an approver may still make the bundle current
+func awaitingRelease(bundle *model.Bundle) bool {
+	return bundle.ScheduledAt != nil || (bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_PENDING)
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockObjectStorage is a mock implementation of ObjectStorage
+type MockObjectStorage struct {
+	mock.Mock
+}
+
+func (m *MockObjectStorage) ListFiles(ctx context.Context) ([]pkg.StorageObject, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]pkg.StorageObject), args.Error(1)
+}
+
+func (m *MockObjectStorage) DeleteFile(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func TestGCService_Collect_DeletesOldOrphans(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, &MockVersionRepository{}, &MockBundleMetricRepository{}, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: 24 * time.Hour})
+	ctx := context.Background()
+
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{
+		{Key: "released.zip", Size: 10, LastModified: time.Now().Add(-72 * time.Hour)},
+		{Key: "crashed.zip", Size: 20, LastModified: time.Now().Add(-72 * time.Hour)},
+		{Key: "uploading.zip", Size: 30, LastModified: time.Now().Add(-time.Minute)},
+	}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{"released.zip"}, nil)
+	mockStorage.On("DeleteFile", ctx, "crashed.zip").Return(nil)
+
+	// Execute
+	report, err := service.Collect(ctx, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, report.Orphans, 1)
+	assert.Equal(t, "crashed.zip", report.Orphans[0].Key)
+	assert.Equal(t, int64(20), report.ReclaimedBytes)
+	assert.Empty(t, report.Superseded)
+	mockStorage.AssertExpectations(t)
+	mockStorage.AssertNumberOfCalls(t, "DeleteFile", 1)
+}
+
+func TestGCService_Collect_KeepsCurrentAndRollbackTarget(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockBundleMetricRepository := &MockBundleMetricRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, mockBundleMetricRepository, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 1})
+	ctx := context.Background()
+
+	// v5 was disabled and rolled back to v4, v3 is invalid, so v2 is where the next rollback goes
+	bundles := []*model.Bundle{
+		{Id: primitive.NewObjectID(), Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip", Size: 1},
+		{Id: primitive.NewObjectID(), Label: "v2", SequenceId: 2, IsValid: true, DownloadFile: "v2.zip", Size: 2},
+		{Id: primitive.NewObjectID(), Label: "v3", SequenceId: 3, IsValid: false, DownloadFile: "v3.zip", Size: 3},
+		{Id: primitive.NewObjectID(), Label: "v4", SequenceId: 4, IsValid: true, DownloadFile: "v4.zip", Size: 4},
+		{Id: primitive.NewObjectID(), Label: "v5", SequenceId: 5, IsValid: false, DownloadFile: "v5.zip", Size: 5},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), CurrentBundleId: bundles[3].Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+
+	// Execute
+	report, err := service.Collect(ctx, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	labels := []string{}
+	for _, bundle := range report.Superseded {
+		labels = append(labels, bundle.Label)
+	}
+	assert.ElementsMatch(t, []string{"v3", "v1"}, labels)
+	assert.Equal(t, int64(4), report.ReclaimedBytes)
+	mockStorage.AssertNotCalled(t, "DeleteFile", mock.Anything, mock.Anything)
+	mockBundleRepository.AssertNotCalled(t, "DeleteByIds", mock.Anything, mock.Anything)
+}
+
+func TestGCService_Collect_DeletesSupersededBundles(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockBundleMetricRepository := &MockBundleMetricRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, mockBundleMetricRepository, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 2})
+	ctx := context.Background()
+
+	oldest := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip"}
+	bundles := []*model.Bundle{
+		oldest,
+		{Id: primitive.NewObjectID(), Label: "v2", SequenceId: 2, IsValid: true, DownloadFile: "v2.zip"},
+		{Id: primitive.NewObjectID(), Label: "v3", SequenceId: 3, IsValid: true, DownloadFile: "v3.zip"},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), CurrentBundleId: bundles[2].Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+	mockBundleMetricRepository.On("DeleteByBundleIds", ctx, []primitive.ObjectID{oldest.Id}).Return(nil)
+	mockBundleRepository.On("DeleteByIds", ctx, []primitive.ObjectID{oldest.Id}).Return(nil)
+	mockStorage.On("DeleteFile", ctx, "v1.zip").Return(nil)
+
+	// Execute
+	report, err := service.Collect(ctx, false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, report.Superseded, 1)
+	mockBundleMetricRepository.AssertExpectations(t)
+	mockBundleRepository.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestGCService_Collect_KeepsRunningExperimentVariants(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockExperimentRepository := &MockExperimentRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, &MockBundleMetricRepository{}, mockExperimentRepository, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 1})
+	ctx := context.Background()
+
+	// v1 is tested against the current v3, v2 is in no experiment
+	bundles := []*model.Bundle{
+		{Id: primitive.NewObjectID(), Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip"},
+		{Id: primitive.NewObjectID(), Label: "v2", SequenceId: 2, IsValid: false, DownloadFile: "v2.zip"},
+		{Id: primitive.NewObjectID(), Label: "v3", SequenceId: 3, IsValid: true, DownloadFile: "v3.zip"},
+	}
+	experiment := &model.Experiment{
+		Id:     primitive.NewObjectID(),
+		Status: utils.EXPERIMENT_RUNNING,
+		Variants: []model.ExperimentVariant{
+			{BundleId: bundles[0].Id, Label: "v1", Weight: 50},
+			{BundleId: bundles[2].Id, Label: "v3", Weight: 50},
+		},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), CurrentBundleId: bundles[2].Id, ExperimentId: experiment.Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+	mockExperimentRepository.On("GetById", ctx, experiment.Id).Return(experiment, nil)
+
+	// Execute
+	report, err := service.Collect(ctx, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, report.Superseded, 1)
+	assert.Equal(t, "v2", report.Superseded[0].Label)
+	mockExperimentRepository.AssertExpectations(t)
+}
+
+func TestGCService_Collect_KeepsScheduledAndPendingBundles(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, &MockBundleMetricRepository{}, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 1})
+	ctx := context.Background()
+
+	// v1 is current, v2 waits for its schedule, v3 for an approval and v4 was rejected
+	scheduledAt := time.Now().Add(time.Hour)
+	bundles := []*model.Bundle{
+		{Id: primitive.NewObjectID(), Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip"},
+		{Id: primitive.NewObjectID(), Label: "v2", SequenceId: 2, IsValid: false, DownloadFile: "v2.zip", ScheduledAt: &scheduledAt},
+		{Id: primitive.NewObjectID(), Label: "v3", SequenceId: 3, IsValid: false, DownloadFile: "v3.zip", Approval: &model.Approval{Status: utils.APPROVAL_PENDING}},
+		{Id: primitive.NewObjectID(), Label: "v4", SequenceId: 4, IsValid: false, DownloadFile: "v4.zip", Approval: &model.Approval{Status: utils.APPROVAL_REJECTED}},
+		{Id: primitive.NewObjectID(), Label: "v5", SequenceId: 5, IsValid: false, DownloadFile: "v5.zip", ScheduledAt: &scheduledAt},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), CurrentBundleId: bundles[0].Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+
+	// Execute
+	report, err := service.Collect(ctx, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, report.Superseded, 1)
+	assert.Equal(t, "v4", report.Superseded[0].Label)
+}
+
+func TestGCService_Collect_KeepsTargetingFallbacks(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, &MockBundleMetricRepository{}, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 1})
+	ctx := context.Background()
+
+	// v2 to v4 only target QA devices, every other device is still served v1
+	qaOnly := &model.Targeting{Allowlist: []string{"qa-device"}}
+	bundles := []*model.Bundle{
+		{Id: primitive.NewObjectID(), Label: "v0", SequenceId: 0, IsValid: true, DownloadFile: "v0.zip"},
+		{Id: primitive.NewObjectID(), Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip"},
+		{Id: primitive.NewObjectID(), Label: "v2", SequenceId: 2, IsValid: true, DownloadFile: "v2.zip", Targeting: qaOnly},
+		{Id: primitive.NewObjectID(), Label: "v3", SequenceId: 3, IsValid: true, DownloadFile: "v3.zip", Targeting: qaOnly},
+		{Id: primitive.NewObjectID(), Label: "v4", SequenceId: 4, IsValid: true, DownloadFile: "v4.zip", Targeting: qaOnly},
+	}
+	version := &model.Version{Id: primitive.NewObjectID(), CurrentBundleId: bundles[4].Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+
+	// Execute
+	report, err := service.Collect(ctx, true)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, report.Superseded, 1)
+	assert.Equal(t, "v0", report.Superseded[0].Label)
+}
+
+func TestGCService_Collect_KeepsMandatoryBundlesForSkippingDevices(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockBundleMetricRepository := &MockBundleMetricRepository{}
+	mockStorage := &MockObjectStorage{}
+	service := NewGCService(mockBundleRepository, mockVersionRepository, mockBundleMetricRepository, &MockExperimentRepository{}, mockStorage, &config.GCPolicy{OrphanGracePeriod: time.Hour, KeepBundles: 1})
+	ctx := context.Background()
+
+	// v2 was mandatory, a device still on the binary skipped it
+	environmentId, versionId := primitive.NewObjectID(), primitive.NewObjectID()
+	bundles := []*model.Bundle{
+		{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, Label: "v1", SequenceId: 1, IsValid: true, DownloadFile: "v1.zip"},
+		{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, Label: "v2", SequenceId: 2, IsValid: true, IsMandatory: true, DownloadFile: "v2.zip"},
+		{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, Label: "v3", SequenceId: 3, IsValid: true, DownloadFile: "v3.zip"},
+		{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, Label: "v4", SequenceId: 4, IsValid: true, DownloadFile: "v4.zip"},
+	}
+	current := bundles[3]
+	version := &model.Version{Id: versionId, CurrentBundleId: current.Id}
+	mockStorage.On("ListFiles", ctx).Return([]pkg.StorageObject{}, nil)
+	mockBundleRepository.On("GetAllDownloadFiles", ctx).Return([]string{}, nil)
+	mockVersionRepository.On("GetAll", ctx).Return([]*model.Version{version}, nil)
+	mockBundleRepository.On("GetAllByVersionId", ctx, version.Id).Return(bundles, nil)
+	mockBundleMetricRepository.On("DeleteByBundleIds", ctx, mock.Anything).Return(nil)
+	mockBundleRepository.On("DeleteByIds", ctx, mock.Anything).Return(nil)
+	mockStorage.On("DeleteFile", ctx, mock.Anything).Return(nil)
+
+	// Execute
+	_, err := service.Collect(ctx, false)
+	assert.NoError(t, err)
+
+	// the mandatory bundles left after the collection are what the update check finds
+	deleted := map[primitive.ObjectID]bool{}
+	for _, id := range mockBundleRepository.Calls[len(mockBundleRepository.Calls)-1].Arguments[1].([]primitive.ObjectID) {
+		deleted[id] = true
+	}
+	remaining := []*model.Bundle{}
+	for _, bundle := range bundles {
+		if !deleted[bundle.Id] && bundle.IsValid && bundle.IsMandatory && bundle.SequenceId < 4 {
+			remaining = append(remaining, bundle)
+		}
+	}
+	mockCheckRepository := &MockBundleRepository{}
+	mockCheckRepository.On("GetMandatoryBetween", ctx, environmentId, versionId, int64(0), int64(4)).Return(remaining, nil)
+	bundleService := NewBundleService(&MockAppService{}, &MockVersionService{}, &MockEnvironmentService{}, newPublishingWebhookService(), newOpenReleaseScheduleService(), &MockApprovalService{}, mockCheckRepository)
+	isMandatory, err := bundleService.IsMandatoryFrom(ctx, current, "", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, isMandatory)
+	assert.Len(t, deleted, 1)
+	mockStorage.AssertCalled(t, "DeleteFile", ctx, "v1.zip")
+	mockStorage.AssertNotCalled(t, "DeleteFile", ctx, "v2.zip")
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SwishHQ/spread/types"
+)
+
+// how long a single readiness check may take, probes usually time out after a few seconds
+var healthCheckTimeout = 2 * time.Second
+
+// HealthChecker is a dependency spread cannot serve requests without
+type HealthChecker interface {
+	Ping(ctx context.Context) error
+}
+
+type HealthService interface {
+	Readiness(ctx context.Context) (*types.HealthReport, bool)
+}
+
+type healthService struct {
+	checkers         map[string]HealthChecker
+	migrationService MigrationService
+}
+
+// NewHealthService checks the given dependencies by name, plus that every migration is applied
+func NewHealthService(checkers map[string]HealthChecker, migrationService MigrationService) HealthService {
+	return &healthService{checkers: checkers, migrationService: migrationService}
+}
+
+// Readiness runs every check concurrently and returns false when any of them failed
+func (s *healthService) Readiness(ctx context.Context) (*types.HealthReport, bool) {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	report := &types.HealthReport{Status: "ok", Checks: map[string]types.HealthCheck{}}
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	record := func(name string, start time.Time, details interface{}, err error) {
+		check := types.HealthCheck{Status: "ok", DurationMs: time.Since(start).Milliseconds(), Details: details}
+		if err != nil {
+			check.Status = "error"
+			check.Error = err.Error()
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		report.Checks[name] = check
+		if err != nil {
+			report.Status = "error"
+		}
+	}
+	for name, checker := range s.checkers {
+		wait.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wait.Done()
+			start := time.Now()
+			record(name, start, nil, checker.Ping(ctx))
+		}(name, checker)
+	}
+	wait.Add(1)
+	go func() {
+		defer wait.Done()
+		start := time.Now()
+		pending, err := s.migrationService.Pending(ctx)
+		if err == nil && len(pending) > 0 {
+			err = errors.New("migrations not applied")
+		}
+		var details interface{}
+		if len(pending) > 0 {
+			details = map[string]interface{}{"pending": pending}
+		}
+		record("migrations", start, details, err)
+	}()
+	wait.Wait()
+	return report, report.Status == "ok"
+}
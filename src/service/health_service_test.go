@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockHealthChecker is a mock implementation of HealthChecker
+type MockHealthChecker struct {
+	mock.Mock
+}
+
+func (m *MockHealthChecker) Ping(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func TestHealthService_Readiness_AllChecksPass(t *testing.T) {
+	mockMongo := &MockHealthChecker{}
+	mockStorage := &MockHealthChecker{}
+	mockMigrationRepo := &MockMigrationRepository{}
+	migrationService := NewMigrationService(mockMigrationRepo)
+	migrationService.Register("0001_test", func(ctx context.Context) error { return nil })
+	service := NewHealthService(map[string]HealthChecker{"mongo": mockMongo, "storage": mockStorage}, migrationService)
+
+	mockMongo.On("Ping", mock.Anything).Return(nil)
+	mockStorage.On("Ping", mock.Anything).Return(nil)
+	mockMigrationRepo.On("IsApplied", mock.Anything, "0001_test").Return(true, nil)
+
+	// Execute
+	report, ready := service.Readiness(context.Background())
+
+	// Assert
+	assert.True(t, ready)
+	assert.Equal(t, "ok", report.Status)
+	assert.Len(t, report.Checks, 3)
+	assert.Equal(t, "ok", report.Checks["migrations"].Status)
+}
+
+func TestHealthService_Readiness_MongoDown(t *testing.T) {
+	mockMongo := &MockHealthChecker{}
+	mockMigrationRepo := &MockMigrationRepository{}
+	migrationService := NewMigrationService(mockMigrationRepo)
+	migrationService.Register("0001_test", func(ctx context.Context) error { return nil })
+	service := NewHealthService(map[string]HealthChecker{"mongo": mockMongo}, migrationService)
+
+	mockMongo.On("Ping", mock.Anything).Return(errors.New("server selection timeout"))
+	mockMigrationRepo.On("IsApplied", mock.Anything, "0001_test").Return(false, errors.New("server selection timeout"))
+
+	// Execute
+	report, ready := service.Readiness(context.Background())
+
+	// Assert
+	assert.False(t, ready)
+	assert.Equal(t, "error", report.Status)
+	assert.Equal(t, "error", report.Checks["mongo"].Status)
+	assert.Equal(t, "server selection timeout", report.Checks["mongo"].Error)
+}
+
+func TestHealthService_Readiness_PendingMigrations(t *testing.T) {
+	mockMongo := &MockHealthChecker{}
+	mockMigrationRepo := &MockMigrationRepository{}
+	migrationService := NewMigrationService(mockMigrationRepo)
+	migrationService.Register("0001_test", func(ctx context.Context) error { return nil })
+	migrationService.Register("0002_test", func(ctx context.Context) error { return nil })
+	service := NewHealthService(map[string]HealthChecker{"mongo": mockMongo}, migrationService)
+
+	mockMongo.On("Ping", mock.Anything).Return(nil)
+	mockMigrationRepo.On("IsApplied", mock.Anything, "0001_test").Return(true, nil)
+	mockMigrationRepo.On("IsApplied", mock.Anything, "0002_test").Return(false, nil)
+
+	// Execute
+	report, ready := service.Readiness(context.Background())
+
+	// Assert
+	assert.False(t, ready)
+	assert.Equal(t, "ok", report.Checks["mongo"].Status)
+	assert.Equal(t, "migrations not applied", report.Checks["migrations"].Error)
+	assert.Equal(t, map[string]interface{}{"pending": []string{"0002_test"}}, report.Checks["migrations"].Details)
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
+)
+
+type LoginAttemptService interface {
+	LockedFor(ctx context.Context, username string) (time.Duration, error)
+	RecordFailure(ctx context.Context, username string) (time.Duration, error)
+	RecordSuccess(ctx context.Context, username string) error
+}
+
+type loginAttemptService struct {
+	store   pkg.RateLimitStore
+	lockout *config.LoginLockout
+}
+
+// NewLoginAttemptService locks a username out after lockout.Threshold failed sign ins, the
+// lockout lasts until lockout.Duration after the first of them. A threshold of 0 never locks
+func NewLoginAttemptService(store pkg.RateLimitStore, lockout *config.LoginLockout) LoginAttemptService {
+	return &loginAttemptService{store: store, lockout: lockout}
+}
+
+// LockedFor returns how long the username stays locked out, 0 when it may sign in
+func (s *loginAttemptService) LockedFor(ctx context.Context, username string) (time.Duration, error) {
+	if s.lockout.Threshold == 0 {
+		return 0, nil
+	}
+	failures, resetAt, err := s.store.Get(ctx, loginFailuresKey(username))
+	if err != nil {
+		return 0, err
+	}
+	if failures < s.lockout.Threshold {
+		return 0, nil
+	}
+	return time.Until(resetAt), nil
+}
+
+// RecordFailure counts a failed sign in and returns how long the username is now locked out
+func (s *loginAttemptService) RecordFailure(ctx context.Context, username string) (time.Duration, error) {
+	if s.lockout.Threshold == 0 {
+		return 0, nil
+	}
+	failures, resetAt, err := s.store.Hit(ctx, loginFailuresKey(username), s.lockout.Duration)
+	if err != nil {
+		return 0, err
+	}
+	if failures < s.lockout.Threshold {
+		return 0, nil
+	}
+	return time.Until(resetAt), nil
+}
+
+// RecordSuccess forgets the failed sign ins of the username
+func (s *loginAttemptService) RecordSuccess(ctx context.Context, username string) error {
+	if s.lockout.Threshold == 0 {
+		return nil
+	}
+	return s.store.Reset(ctx, loginFailuresKey(username))
+}
+
+// usernames are matched case-insensitively so changing the case does not reset the count
+func loginFailuresKey(username string) string {
+	return "login_failures:" + strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockRateLimitStore is a mock implementation of pkg.RateLimitStore
+type MockRateLimitStore struct {
+	mock.Mock
+}
+
+func (m *MockRateLimitStore) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error) {
+	args := m.Called(ctx, key, window)
+	return args.Int(0), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockRateLimitStore) Get(ctx context.Context, key string) (int, time.Time, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockRateLimitStore) Reset(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func TestLoginAttemptService_RecordFailure_LocksAfterThreshold(t *testing.T) {
+	service := NewLoginAttemptService(pkg.NewMemoryRateLimitStore(), &config.LoginLockout{Threshold: 3, Duration: 15 * time.Minute})
+	ctx := context.Background()
+
+	// Execute
+	for i := 0; i < 2; i++ {
+		lockedFor, err := service.RecordFailure(ctx, "admin")
+		assert.NoError(t, err)
+		assert.Zero(t, lockedFor)
+	}
+	lockedFor, err := service.RecordFailure(ctx, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.InDelta(t, float64(15*time.Minute), float64(lockedFor), float64(time.Second))
+	lockedFor, err = service.LockedFor(ctx, "Admin")
+	assert.NoError(t, err)
+	assert.Greater(t, lockedFor, time.Duration(0))
+	lockedFor, err = service.LockedFor(ctx, "someone-else")
+	assert.NoError(t, err)
+	assert.Zero(t, lockedFor)
+}
+
+func TestLoginAttemptService_RecordSuccess_ClearsFailures(t *testing.T) {
+	service := NewLoginAttemptService(pkg.NewMemoryRateLimitStore(), &config.LoginLockout{Threshold: 2, Duration: time.Minute})
+	ctx := context.Background()
+	_, _ = service.RecordFailure(ctx, "admin")
+
+	// Execute
+	err := service.RecordSuccess(ctx, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	lockedFor, err := service.RecordFailure(ctx, "admin")
+	assert.NoError(t, err)
+	assert.Zero(t, lockedFor)
+}
+
+func TestLoginAttemptService_LockedFor_WindowEnded(t *testing.T) {
+	service := NewLoginAttemptService(pkg.NewMemoryRateLimitStore(), &config.LoginLockout{Threshold: 1, Duration: 20 * time.Millisecond})
+	ctx := context.Background()
+	_, _ = service.RecordFailure(ctx, "admin")
+
+	// Execute
+	time.Sleep(30 * time.Millisecond)
+	lockedFor, err := service.LockedFor(ctx, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, lockedFor)
+}
+
+func TestLoginAttemptService_Disabled(t *testing.T) {
+	mockStore := &MockRateLimitStore{}
+	service := NewLoginAttemptService(mockStore, &config.LoginLockout{Threshold: 0})
+	ctx := context.Background()
+
+	// Execute
+	lockedFor, err := service.RecordFailure(ctx, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, lockedFor)
+	mockStore.AssertNotCalled(t, "Hit", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLoginAttemptService_LockedFor_StoreError(t *testing.T) {
+	mockStore := &MockRateLimitStore{}
+	service := NewLoginAttemptService(mockStore, &config.LoginLockout{Threshold: 5, Duration: time.Minute})
+	ctx := context.Background()
+	mockStore.On("Get", ctx, "login_failures:admin").Return(0, time.Time{}, errors.New("mongo unavailable"))
+
+	// Execute
+	lockedFor, err := service.LockedFor(ctx, "admin")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Zero(t, lockedFor)
+	mockStore.AssertExpectations(t)
+}
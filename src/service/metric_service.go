@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// the longest range a series can cover, keeps a response to a few hundred points
+var maxMetricRange = map[string]time.Duration{
+	utils.METRIC_GRANULARITY_HOUR: 31 * 24 * time.Hour,
+	utils.METRIC_GRANULARITY_DAY:  366 * 24 * time.Hour,
+}
+
+// the range of a series when the request does not give one
+var defaultMetricRange = map[string]time.Duration{
+	utils.METRIC_GRANULARITY_HOUR: 24 * time.Hour,
+	utils.METRIC_GRANULARITY_DAY:  30 * 24 * time.Hour,
+}
+
+type MetricService interface {
+	EnsureIndexes(ctx context.Context) error
+	RecordEvent(ctx context.Context, bundle *model.Bundle, metric string) error
+	RecordActiveDevices(ctx context.Context, bundleId primitive.ObjectID) error
+	GetSeries(ctx context.Context, request *types.MetricSeriesRequest) (*types.MetricSeriesResponse, error)
+}
+
+type metricService struct {
+	bundleService          BundleService
+	bundleMetricRepository repository.BundleMetricRepository
+}
+
+func NewMetricService(bundleService BundleService, bundleMetricRepository repository.BundleMetricRepository) MetricService {
+	return &metricService{bundleService: bundleService, bundleMetricRepository: bundleMetricRepository}
+}
+
+func (s *metricService) EnsureIndexes(ctx context.Context) error {
+	return s.bundleMetricRepository.EnsureIndexes(ctx)
+}
+
+// RecordEvent counts a download, install, failure or rollback of the bundle in the current buckets
+func (s *metricService) RecordEvent(ctx context.Context, bundle *model.Bundle, metric string) error {
+	switch metric {
+	case utils.METRIC_DOWNLOADS, utils.METRIC_INSTALLS, utils.METRIC_FAILURES, utils.METRIC_ROLLBACKS:
+	default:
+		return errors.New("unknown metric " + metric)
+	}
+	pkg.ReleaseEventsTotal.WithLabelValues(metric).Inc()
+	return s.bundleMetricRepository.Increment(ctx, bundle, metric, time.Now())
+}
+
+// RecordActiveDevices copies the current active count of the bundle into the current buckets
+func (s *metricService) RecordActiveDevices(ctx context.Context, bundleId primitive.ObjectID) error {
+	bundle, err := s.bundleService.GetBundleById(bundleId)
+	if err != nil {
+		return err
+	}
+	if bundle == nil {
+		return errors.New("bundle not found")
+	}
+	return s.bundleMetricRepository.SetActiveDevices(ctx, bundle, bundle.Active, time.Now())
+}
+
+// GetSeries returns one point per bucket between from and to, buckets without reports are zero.
+// Each bundle's active device count carries over from the last bucket that changed it, also from
+// before from, and a point is the sum over the bundles
+func (s *metricService) GetSeries(ctx context.Context, request *types.MetricSeriesRequest) (*types.MetricSeriesResponse, error) {
+	environmentId, err := primitive.ObjectIDFromHex(request.EnvironmentId)
+	if err != nil {
+		return nil, errors.New("invalid environment id")
+	}
+	granularity := request.Granularity
+	if granularity == "" {
+		granularity = utils.METRIC_GRANULARITY_HOUR
+	}
+	to := time.Now()
+	if request.To != "" {
+		if to, err = time.Parse(time.RFC3339, request.To); err != nil {
+			return nil, errors.New("invalid to, expected RFC3339")
+		}
+	}
+	from := to.Add(-defaultMetricRange[granularity])
+	if request.From != "" {
+		if from, err = time.Parse(time.RFC3339, request.From); err != nil {
+			return nil, errors.New("invalid from, expected RFC3339")
+		}
+	}
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if to.Sub(from) > maxMetricRange[granularity] {
+		return nil, errors.New("time range is too long for granularity " + granularity)
+	}
+	from = utils.MetricBucketStart(granularity, from)
+	to = utils.MetricBucketStart(granularity, to)
+
+	lastMetrics, err := s.bundleMetricRepository.GetLastActiveDevices(ctx, environmentId, request.Label, granularity, from)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := s.bundleMetricRepository.GetSeries(ctx, environmentId, request.Label, granularity, from, to)
+	if err != nil {
+		return nil, err
+	}
+	activeDevicesByBundle := make(map[primitive.ObjectID]int, len(lastMetrics))
+	for _, metric := range lastMetrics {
+		if metric.ActiveDevices != nil {
+			activeDevicesByBundle[metric.BundleId] = *metric.ActiveDevices
+		}
+	}
+	metricsByBucket := make(map[time.Time][]*model.BundleMetric)
+	for _, metric := range metrics {
+		bucket := metric.BucketStart.UTC()
+		metricsByBucket[bucket] = append(metricsByBucket[bucket], metric)
+	}
+
+	points := []types.MetricPoint{}
+	for bucket := from; !bucket.After(to); bucket = nextMetricBucket(granularity, bucket) {
+		point := types.MetricPoint{Timestamp: bucket}
+		for _, metric := range metricsByBucket[bucket] {
+			point.Downloads += metric.Downloads
+			point.Installs += metric.Installs
+			point.Failures += metric.Failures
+			point.Rollbacks += metric.Rollbacks
+			if metric.ActiveDevices != nil {
+				activeDevicesByBundle[metric.BundleId] = *metric.ActiveDevices
+			}
+		}
+		for _, activeDevices := range activeDevicesByBundle {
+			point.ActiveDevices += activeDevices
+		}
+		points = append(points, point)
+	}
+	return &types.MetricSeriesResponse{
+		EnvironmentId: request.EnvironmentId,
+		Label:         request.Label,
+		Granularity:   granularity,
+		From:          from,
+		To:            to,
+		Points:        points,
+	}, nil
+}
+
+func nextMetricBucket(granularity string, bucket time.Time) time.Time {
+	if granularity == utils.METRIC_GRANULARITY_DAY {
+		return bucket.AddDate(0, 0, 1)
+	}
+	return bucket.Add(time.Hour)
+}
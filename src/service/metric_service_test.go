@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockBundleMetricRepository is a mock implementation of BundleMetricRepository
+type MockBundleMetricRepository struct {
+	mock.Mock
+}
+
+func (m *MockBundleMetricRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockBundleMetricRepository) Increment(ctx context.Context, bundle *model.Bundle, metric string, at time.Time) error {
+	args := m.Called(ctx, bundle, metric, at)
+	return args.Error(0)
+}
+
+func (m *MockBundleMetricRepository) SetActiveDevices(ctx context.Context, bundle *model.Bundle, activeDevices int, at time.Time) error {
+	args := m.Called(ctx, bundle, activeDevices, at)
+	return args.Error(0)
+}
+
+func (m *MockBundleMetricRepository) GetSeries(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, from time.Time, to time.Time) ([]*model.BundleMetric, error) {
+	args := m.Called(ctx, environmentId, label, granularity, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.BundleMetric), args.Error(1)
+}
+
+func (m *MockBundleMetricRepository) GetLastActiveDevices(ctx context.Context, environmentId primitive.ObjectID, label string, granularity string, before time.Time) ([]*model.BundleMetric, error) {
+	args := m.Called(ctx, environmentId, label, granularity, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.BundleMetric), args.Error(1)
+}
+
+func (m *MockBundleMetricRepository) DeleteByBundleIds(ctx context.Context, bundleIds []primitive.ObjectID) error {
+	args := m.Called(ctx, bundleIds)
+	return args.Error(0)
+}
+
+func (m *MockBundleMetricRepository) DeleteByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) error {
+	args := m.Called(ctx, environmentId)
+	return args.Error(0)
+}
+
+func TestMetricService_RecordEvent_Success(t *testing.T) {
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(&MockBundleService{}, mockRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1"}
+	mockRepo.On("Increment", ctx, bundle, "failures", mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Execute
+	err := service.RecordEvent(ctx, bundle, "failures")
+
+	// Assert
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricService_RecordEvent_UnknownMetric(t *testing.T) {
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(&MockBundleService{}, mockRepo)
+
+	// Execute
+	err := service.RecordEvent(context.Background(), &model.Bundle{}, "active")
+
+	// Assert
+	assert.Error(t, err)
+	mockRepo.AssertNotCalled(t, "Increment", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestMetricService_RecordActiveDevices_CopiesBundleCount(t *testing.T) {
+	mockBundleService := &MockBundleService{}
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(mockBundleService, mockRepo)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x1", Active: 42}
+	mockBundleService.On("GetBundleById", bundle.Id).Return(bundle, nil)
+	mockRepo.On("SetActiveDevices", ctx, bundle, 42, mock.AnythingOfType("time.Time")).Return(nil)
+
+	// Execute
+	err := service.RecordActiveDevices(ctx, bundle.Id)
+
+	// Assert
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricService_GetSeries_FillsEmptyBuckets(t *testing.T) {
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(&MockBundleService{}, mockRepo)
+
+	ctx := context.Background()
+	environmentId := primitive.NewObjectID()
+	from := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 1, 13, 30, 0, 0, time.UTC)
+	bundleId := primitive.NewObjectID()
+	metrics := []*model.BundleMetric{
+		{BundleId: bundleId, BucketStart: from, Installs: 100, Failures: 40, ActiveDevices: activeDevices(60)},
+		{BundleId: bundleId, BucketStart: from.Add(2 * time.Hour), Installs: 10, Failures: 1, ActiveDevices: activeDevices(69)},
+		// a download without a change of the active count
+		{BundleId: bundleId, BucketStart: from.Add(3 * time.Hour), Downloads: 1},
+	}
+	mockRepo.On("GetLastActiveDevices", ctx, environmentId, "v1x2", "hour", from).Return([]*model.BundleMetric{}, nil)
+	mockRepo.On("GetSeries", ctx, environmentId, "v1x2", "hour", from, from.Add(3*time.Hour)).Return(metrics, nil)
+
+	// Execute
+	series, err := service.GetSeries(ctx, &types.MetricSeriesRequest{
+		EnvironmentId: environmentId.Hex(),
+		Label:         "v1x2",
+		From:          from.Format(time.RFC3339),
+		To:            to.Format(time.RFC3339),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "hour", series.Granularity)
+	assert.Len(t, series.Points, 4)
+	assert.Equal(t, 40, series.Points[0].Failures)
+	// the hour without reports has no events but keeps the active devices
+	assert.Equal(t, 0, series.Points[1].Installs)
+	assert.Equal(t, 60, series.Points[1].ActiveDevices)
+	assert.Equal(t, 69, series.Points[2].ActiveDevices)
+	assert.Equal(t, 69, series.Points[3].ActiveDevices)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricService_GetSeries_CarriesActiveDevicesPerBundle(t *testing.T) {
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(&MockBundleService{}, mockRepo)
+
+	ctx := context.Background()
+	environmentId := primitive.NewObjectID()
+	from := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	to := from.Add(3 * time.Hour)
+	oldBundleId := primitive.NewObjectID()
+	newBundleId := primitive.NewObjectID()
+	// the old bundle last changed before the range, devices move to the new bundle one hour at a time
+	lastMetrics := []*model.BundleMetric{
+		{BundleId: oldBundleId, BucketStart: from.Add(-5 * time.Hour), ActiveDevices: activeDevices(100)},
+	}
+	metrics := []*model.BundleMetric{
+		{BundleId: newBundleId, BucketStart: from.Add(time.Hour), Installs: 10, ActiveDevices: activeDevices(10)},
+		{BundleId: oldBundleId, BucketStart: from.Add(2 * time.Hour), ActiveDevices: activeDevices(90)},
+		{BundleId: newBundleId, BucketStart: from.Add(3 * time.Hour), Installs: 5, ActiveDevices: activeDevices(15)},
+	}
+	mockRepo.On("GetLastActiveDevices", ctx, environmentId, "", "hour", from).Return(lastMetrics, nil)
+	mockRepo.On("GetSeries", ctx, environmentId, "", "hour", from, to).Return(metrics, nil)
+
+	// Execute
+	series, err := service.GetSeries(ctx, &types.MetricSeriesRequest{
+		EnvironmentId: environmentId.Hex(),
+		From:          from.Format(time.RFC3339),
+		To:            to.Format(time.RFC3339),
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, series.Points, 4)
+	assert.Equal(t, 100, series.Points[0].ActiveDevices)
+	assert.Equal(t, 110, series.Points[1].ActiveDevices)
+	assert.Equal(t, 100, series.Points[2].ActiveDevices)
+	assert.Equal(t, 105, series.Points[3].ActiveDevices)
+	assert.Equal(t, 5, series.Points[3].Installs)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMetricService_GetSeries_RangeTooLong(t *testing.T) {
+	mockRepo := &MockBundleMetricRepository{}
+	service := NewMetricService(&MockBundleService{}, mockRepo)
+
+	// Execute
+	series, err := service.GetSeries(context.Background(), &types.MetricSeriesRequest{
+		EnvironmentId: primitive.NewObjectID().Hex(),
+		Granularity:   "hour",
+		From:          "2025-01-01T00:00:00Z",
+		To:            "2025-03-01T00:00:00Z",
+	})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, series)
+	assert.Equal(t, "time range is too long for granularity hour", err.Error())
+	mockRepo.AssertNotCalled(t, "GetSeries", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func activeDevices(count int) *int {
+	return &count
+}
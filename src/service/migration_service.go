@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/repository"
+	"go.uber.org/zap"
+)
+
+type MigrationService interface {
+	Register(name string, up func(ctx context.Context) error)
+	Run(ctx context.Context) error
+	Pending(ctx context.Context) ([]string, error)
+}
+
+type migration struct {
+	name string
+	up   func(ctx context.Context) error
+}
+
+type migrationService struct {
+	migrationRepository repository.MigrationRepository
+	migrations          []migration
+}
+
+func NewMigrationService(migrationRepository repository.MigrationRepository) MigrationService {
+	return &migrationService{migrationRepository: migrationRepository}
+}
+
+// Register adds a migration, migrations run in the order they are registered
+// and a name must never be reused once released
+func (s *migrationService) Register(name string, up func(ctx context.Context) error) {
+	s.migrations = append(s.migrations, migration{name: name, up: up})
+}
+
+// Run applies every registered migration that has not been applied yet
+func (s *migrationService) Run(ctx context.Context) error {
+	for _, m := range s.migrations {
+		applied, err := s.migrationRepository.IsApplied(ctx, m.name)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+		logger.L.Info("In Run: Applying migration", zap.String("name", m.name))
+		if err := m.up(ctx); err != nil {
+			logger.L.Error("In Run: Migration failed", zap.String("name", m.name), zap.Error(err))
+			return err
+		}
+		if err := s.migrationRepository.MarkApplied(ctx, m.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pending returns the names of registered migrations that have not been applied
+func (s *migrationService) Pending(ctx context.Context) ([]string, error) {
+	pending := make([]string, 0)
+	for _, m := range s.migrations {
+		applied, err := s.migrationRepository.IsApplied(ctx, m.name)
+		if err != nil {
+			return nil, err
+		}
+		if !applied {
+			pending = append(pending, m.name)
+		}
+	}
+	return pending, nil
+}
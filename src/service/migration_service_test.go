@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// MockMigrationRepository is a mock implementation of MigrationRepository
+type MockMigrationRepository struct {
+	mock.Mock
+}
+
+func (m *MockMigrationRepository) IsApplied(ctx context.Context, name string) (bool, error) {
+	args := m.Called(ctx, name)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMigrationRepository) MarkApplied(ctx context.Context, name string) error {
+	args := m.Called(ctx, name)
+	return args.Error(0)
+}
+
+func TestNewMigrationService(t *testing.T) {
+	mockRepo := &MockMigrationRepository{}
+	service := NewMigrationService(mockRepo)
+
+	assert.NotNil(t, service)
+	assert.IsType(t, &migrationService{}, service)
+}
+
+func TestMigrationService_Run_AppliesPendingInOrder(t *testing.T) {
+	mockRepo := &MockMigrationRepository{}
+	service := NewMigrationService(mockRepo)
+
+	ctx := context.Background()
+	var applied []string
+	service.Register("0001_first", func(ctx context.Context) error {
+		applied = append(applied, "0001_first")
+		return nil
+	})
+	service.Register("0002_second", func(ctx context.Context) error {
+		applied = append(applied, "0002_second")
+		return nil
+	})
+
+	mockRepo.On("IsApplied", ctx, "0001_first").Return(true, nil)
+	mockRepo.On("IsApplied", ctx, "0002_second").Return(false, nil)
+	mockRepo.On("MarkApplied", ctx, "0002_second").Return(nil)
+
+	// Execute
+	err := service.Run(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0002_second"}, applied)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMigrationService_Run_StopsOnFailure(t *testing.T) {
+	mockRepo := &MockMigrationRepository{}
+	service := NewMigrationService(mockRepo)
+
+	ctx := context.Background()
+	service.Register("0001_first", func(ctx context.Context) error {
+		return errors.New("migration error")
+	})
+	service.Register("0002_second", func(ctx context.Context) error {
+		t.Fatal("second migration should not run")
+		return nil
+	})
+
+	mockRepo.On("IsApplied", ctx, "0001_first").Return(false, nil)
+
+	// Execute
+	err := service.Run(ctx)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Equal(t, "migration error", err.Error())
+	mockRepo.AssertNotCalled(t, "MarkApplied", ctx, "0001_first")
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestMigrationService_Pending(t *testing.T) {
+	mockRepo := &MockMigrationRepository{}
+	service := NewMigrationService(mockRepo)
+
+	ctx := context.Background()
+	service.Register("0001_first", func(ctx context.Context) error { return nil })
+	service.Register("0002_second", func(ctx context.Context) error { return nil })
+
+	mockRepo.On("IsApplied", ctx, "0001_first").Return(true, nil)
+	mockRepo.On("IsApplied", ctx, "0002_second").Return(false, nil)
+
+	// Execute
+	pending, err := service.Pending(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0002_second"}, pending)
+
+	mockRepo.AssertExpectations(t)
+}
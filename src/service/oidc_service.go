@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// how long a user has to finish signing in at the identity provider
+var oidcSessionTTL = 10 * time.Minute
+
+type OIDCService interface {
+	BeginLogin(ctx context.Context) (string, string, error)
+	CompleteLogin(ctx context.Context, state string, browserState string, code string) (*types.TokenResponse, error)
+}
+
+type oidcService struct {
+	provider              pkg.OIDCProvider
+	oidcSessionRepository repository.OIDCSessionRepository
+	userService           UserService
+	roleMapping           map[string][]string
+}
+
+func NewOIDCService(provider pkg.OIDCProvider, oidcSessionRepository repository.OIDCSessionRepository, userService UserService, roleMapping map[string][]string) OIDCService {
+	return &oidcService{
+		provider:              provider,
+		oidcSessionRepository: oidcSessionRepository,
+		userService:           userService,
+		roleMapping:           roleMapping,
+	}
+}
+
+// BeginLogin stores a new sign in attempt and returns the identity provider URL to send the browser to,
+// and the state the browser has to keep to prove on the callback that it started the attempt
+func (s *oidcService) BeginLogin(ctx context.Context) (string, string, error) {
+	session := &model.OIDCSession{
+		State:        oauth2.GenerateVerifier(),
+		Nonce:        oauth2.GenerateVerifier(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		ExpiresAt:    time.Now().Add(oidcSessionTTL),
+	}
+	if _, err := s.oidcSessionRepository.Insert(ctx, session); err != nil {
+		return "", "", err
+	}
+	return s.provider.AuthCodeURL(session.State, session.Nonce, session.CodeVerifier), session.State, nil
+}
+
+// CompleteLogin redeems the callback of the identity provider and signs the user in. browserState is
+// the state kept by the browser that opened the callback, a callback minted for a sign in another
+// browser started is refused so nobody can be signed in to someone else's account
+func (s *oidcService) CompleteLogin(ctx context.Context, state string, browserState string, code string) (*types.TokenResponse, error) {
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, errors.New("sign in was not started in this browser")
+	}
+	session, err := s.oidcSessionRepository.ConsumeByState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, errors.New("invalid sign in state")
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, errors.New("sign in attempt expired")
+	}
+	identity, err := s.provider.Exchange(ctx, code, session.CodeVerifier, session.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	roles := s.mapGroupsToRoles(identity.Groups)
+	if len(roles) == 0 {
+		logger.L.Warn("In CompleteLogin: No role mapped for user groups", zap.String("username", identity.Username), zap.Strings("groups", identity.Groups))
+		return nil, errors.New("user is not a member of any group allowed to use spread")
+	}
+	return s.userService.LoginWithOIDC(ctx, identity, roles)
+}
+
+// mapGroupsToRoles returns the sorted, de-duplicated roles granted by the groups
+func (s *oidcService) mapGroupsToRoles(groups []string) []string {
+	granted := map[string]bool{}
+	for _, group := range groups {
+		for _, role := range s.roleMapping[group] {
+			granted[role] = true
+		}
+	}
+	roles := make([]string, 0, len(granted))
+	for role := range granted {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockOIDCProvider stands in for the identity provider
+type MockOIDCProvider struct {
+	mock.Mock
+}
+
+func (m *MockOIDCProvider) AuthCodeURL(state string, nonce string, codeVerifier string) string {
+	return "https://idp.example.com/authorize?" + url.Values{
+		"state":          {state},
+		"nonce":          {nonce},
+		"code_challenge": {codeVerifier},
+	}.Encode()
+}
+
+func (m *MockOIDCProvider) Exchange(ctx context.Context, code string, codeVerifier string, nonce string) (*pkg.OIDCIdentity, error) {
+	args := m.Called(ctx, code, codeVerifier, nonce)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pkg.OIDCIdentity), args.Error(1)
+}
+
+// MockOIDCSessionRepository is a mock implementation of OIDCSessionRepository
+type MockOIDCSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockOIDCSessionRepository) Insert(ctx context.Context, session *model.OIDCSession) (*model.OIDCSession, error) {
+	args := m.Called(ctx, session)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OIDCSession), args.Error(1)
+}
+
+func (m *MockOIDCSessionRepository) ConsumeByState(ctx context.Context, state string) (*model.OIDCSession, error) {
+	args := m.Called(ctx, state)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OIDCSession), args.Error(1)
+}
+
+func (m *MockOIDCSessionRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+var testRoleMapping = map[string][]string{
+	"spread-admins": {"admin"},
+	"spread-devs":   {"user"},
+}
+
+func TestOIDCService_BeginLogin_StoresSession(t *testing.T) {
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	service := NewOIDCService(&MockOIDCProvider{}, mockSessionRepo, nil, testRoleMapping)
+
+	ctx := context.Background()
+	mockSessionRepo.On("Insert", ctx, mock.AnythingOfType("*model.OIDCSession")).Return(&model.OIDCSession{}, nil)
+
+	// Execute
+	authURL, state, err := service.BeginLogin(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	session := mockSessionRepo.Calls[0].Arguments[1].(*model.OIDCSession)
+	assert.NotEmpty(t, session.State)
+	assert.NotEmpty(t, session.Nonce)
+	assert.GreaterOrEqual(t, len(session.CodeVerifier), 43)
+	assert.NotEqual(t, session.State, session.CodeVerifier)
+	assert.True(t, session.ExpiresAt.After(time.Now()))
+	parsedURL, _ := url.Parse(authURL)
+	assert.Equal(t, session.State, parsedURL.Query().Get("state"))
+	assert.Equal(t, session.State, state)
+
+	mockSessionRepo.AssertExpectations(t)
+}
+
+func TestOIDCService_CompleteLogin_Success(t *testing.T) {
+	mockProvider := &MockOIDCProvider{}
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	mockUserRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	userService := NewUserService(mockUserRepo, mockRefreshTokenRepo)
+	service := NewOIDCService(mockProvider, mockSessionRepo, userService, testRoleMapping)
+
+	ctx := context.Background()
+	session := &model.OIDCSession{State: "state", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute)}
+	identity := &pkg.OIDCIdentity{Subject: "idp-subject", Username: "jane", Groups: []string{"spread-devs", "spread-admins", "other"}}
+	existingUser := &model.User{Id: primitive.NewObjectID(), Username: "jane", Roles: []string{"admin", "user"}, OIDCSubject: "idp-subject"}
+
+	mockSessionRepo.On("ConsumeByState", ctx, "state").Return(session, nil)
+	mockProvider.On("Exchange", ctx, "code", "verifier", "nonce").Return(identity, nil)
+	mockUserRepo.On("GetByOIDCSubject", ctx, "idp-subject").Return(existingUser, nil)
+	mockRefreshTokenRepo.On("Insert", ctx, mock.AnythingOfType("*model.RefreshToken")).Return(&model.RefreshToken{}, nil)
+
+	// Execute
+	result, err := service.CompleteLogin(ctx, "state", "state", "code")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.IsType(t, &types.TokenResponse{}, result)
+	assert.NotEmpty(t, result.AccessToken)
+	// roles already match the groups so they are not rewritten
+	mockUserRepo.AssertNotCalled(t, "UpdateRoles", mock.Anything, mock.Anything, mock.Anything)
+
+	mockProvider.AssertExpectations(t)
+	mockSessionRepo.AssertExpectations(t)
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestOIDCService_CompleteLogin_UnknownState(t *testing.T) {
+	mockProvider := &MockOIDCProvider{}
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	service := NewOIDCService(mockProvider, mockSessionRepo, nil, testRoleMapping)
+
+	ctx := context.Background()
+	mockSessionRepo.On("ConsumeByState", ctx, "forged").Return(nil, nil)
+
+	// Execute
+	result, err := service.CompleteLogin(ctx, "forged", "forged", "code")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "invalid sign in state", err.Error())
+	mockProvider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_CompleteLogin_ExpiredSession(t *testing.T) {
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	service := NewOIDCService(&MockOIDCProvider{}, mockSessionRepo, nil, testRoleMapping)
+
+	ctx := context.Background()
+	session := &model.OIDCSession{State: "state", ExpiresAt: time.Now().Add(-time.Minute)}
+	mockSessionRepo.On("ConsumeByState", ctx, "state").Return(session, nil)
+
+	// Execute
+	result, err := service.CompleteLogin(ctx, "state", "state", "code")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "sign in attempt expired", err.Error())
+}
+
+func TestOIDCService_CompleteLogin_ExchangeError(t *testing.T) {
+	mockProvider := &MockOIDCProvider{}
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	service := NewOIDCService(mockProvider, mockSessionRepo, nil, testRoleMapping)
+
+	ctx := context.Background()
+	session := &model.OIDCSession{State: "state", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute)}
+	mockSessionRepo.On("ConsumeByState", ctx, "state").Return(session, nil)
+	mockProvider.On("Exchange", ctx, "code", "verifier", "nonce").Return(nil, errors.New("id_token nonce mismatch"))
+
+	// Execute
+	result, err := service.CompleteLogin(ctx, "state", "state", "code")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "id_token nonce mismatch", err.Error())
+}
+
+func TestOIDCService_CompleteLogin_NoMappedGroup(t *testing.T) {
+	mockProvider := &MockOIDCProvider{}
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	mockUserRepo := &MockUserRepository{}
+	service := NewOIDCService(mockProvider, mockSessionRepo, NewUserService(mockUserRepo, &MockRefreshTokenRepository{}), testRoleMapping)
+
+	ctx := context.Background()
+	session := &model.OIDCSession{State: "state", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: time.Now().Add(time.Minute)}
+	mockSessionRepo.On("ConsumeByState", ctx, "state").Return(session, nil)
+	mockProvider.On("Exchange", ctx, "code", "verifier", "nonce").Return(&pkg.OIDCIdentity{Subject: "idp-subject", Username: "jane", Groups: []string{"finance"}}, nil)
+
+	// Execute
+	result, err := service.CompleteLogin(ctx, "state", "state", "code")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "user is not a member of any group allowed to use spread", err.Error())
+	mockUserRepo.AssertNotCalled(t, "GetByOIDCSubject", mock.Anything, mock.Anything)
+}
+
+func TestOIDCService_CompleteLogin_StartedInAnotherBrowser(t *testing.T) {
+	mockProvider := &MockOIDCProvider{}
+	mockSessionRepo := &MockOIDCSessionRepository{}
+	service := NewOIDCService(mockProvider, mockSessionRepo, nil, testRoleMapping)
+
+	ctx := context.Background()
+
+	// Execute and Assert, a callback URL minted for someone else's sign in is opened in a browser
+	// that started none, or started another one
+	for _, browserState := range []string{"", "other-state"} {
+		result, err := service.CompleteLogin(ctx, "state", browserState, "code")
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		assert.Equal(t, "sign in was not started in this browser", err.Error())
+	}
+	// the session stays redeemable by the browser that started it
+	mockSessionRepo.AssertNotCalled(t, "ConsumeByState", mock.Anything, mock.Anything)
+	mockProvider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
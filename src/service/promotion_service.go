@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// PromotionStorage copies the files of a promoted bundle
+type PromotionStorage interface {
+	GetFile(ctx context.Context, key string) ([]byte, error)
+	UploadFileToR2(ctx context.Context, key string, file []byte) error
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// PromotionService releases a bundle of one environment to another environment of the same app,
+// staging to production for example
+type PromotionService interface {
+	Promote(ctx context.Context, bundleId primitive.ObjectID, request *types.PromoteBundleRequest, actor string, override *types.FreezeOverride) (*model.Bundle, error)
+}
+
+type promotionService struct {
+	appService         AppService
+	environmentService EnvironmentService
+	bundleService      BundleService
+	bundleRepository   repository.BundleRepository
+	versionRepository  repository.VersionRepository
+	webhookService     WebhookService
+	auditService       AuditService
+	storage            PromotionStorage
+}
+
+func NewPromotionService(appService AppService, environmentService EnvironmentService, bundleService BundleService, bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, webhookService WebhookService, auditService AuditService, storage PromotionStorage) PromotionService {
+	return &promotionService{
+		appService:         appService,
+		environmentService: environmentService,
+		bundleService:      bundleService,
+		bundleRepository:   bundleRepository,
+		versionRepository:  versionRepository,
+		webhookService:     webhookService,
+		auditService:       auditService,
+		storage:            storage,
+	}
+}
+
+// Promote releases the bundle to the target environment for the same app version, like a release
+// made with spread release: freeze windows and approvals of the target environment apply. The zip and
+// source map are copied, the bundles are deleted independently of each other
+func (s *promotionService) Promote(ctx context.Context, bundleId primitive.ObjectID, request *types.PromoteBundleRequest, actor string, override *types.FreezeOverride) (*model.Bundle, error) {
+	if s.storage == nil {
+		return nil, errors.New("bundle storage is not configured")
+	}
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	if bundle.ScheduledAt != nil || (bundle.Approval != nil && bundle.Approval.Status != utils.APPROVAL_APPROVED) {
+		return nil, errors.New("bundle " + bundle.Label + " is not released and cannot be promoted")
+	}
+	// disabled by hand or by the rollback policy, it must not reach another environment
+	if !bundle.IsValid {
+		return nil, errors.New("bundle " + bundle.Label + " is disabled and cannot be promoted")
+	}
+	if bundle.EnvironmentId.Hex() == request.EnvironmentId {
+		return nil, errors.New("bundle is already in the environment")
+	}
+	target, err := s.environmentService.GetEnvironmentByAppIdAndEnvironmentId(ctx, bundle.AppId, request.EnvironmentId)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil {
+		return nil, errors.New("environment not found")
+	}
+	app, err := s.appService.GetAppById(ctx, bundle.AppId.Hex())
+	if err != nil {
+		return nil, err
+	}
+	version, err := s.versionRepository.GetById(ctx, bundle.VersionId)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadFile, err := s.copyFile(ctx, bundle.DownloadFile, ".zip")
+	if err != nil {
+		return nil, err
+	}
+	copied := []string{downloadFile}
+	sourceMapFile := ""
+	if bundle.SourceMapFile != "" {
+		if sourceMapFile, err = s.copyFile(ctx, bundle.SourceMapFile, ".map"); err != nil {
+			s.deleteFiles(ctx, copied)
+			return nil, err
+		}
+		copied = append(copied, sourceMapFile)
+	}
+	description := request.Description
+	if description == "" {
+		description = bundle.Description
+	}
+	payload := &types.CreateNewBundleRequest{
+		AppName:       app.Name,
+		Environment:   target.Name,
+		DownloadFile:  downloadFile,
+		SourceMapFile: sourceMapFile,
+		Description:   description,
+		AppVersion:    version.AppVersion,
+		Size:          bundle.Size,
+		Hash:          bundle.Hash,
+		IsMandatory:   bundle.IsMandatory,
+	}
+	if override != nil {
+		payload.OverrideFreezeReason = override.Reason
+	}
+	promoted, err := s.bundleService.CreateNewBundle(payload, actor)
+	if err != nil {
+		s.deleteFiles(ctx, copied)
+		return nil, err
+	}
+
+	// release.created of the new bundle does not tell where it came from
+	err = s.webhookService.Publish(ctx, utils.WEBHOOK_EVENT_RELEASE_PROMOTED, promoted, map[string]interface{}{
+		"from":                bundle.Label,
+		"sourceEnvironmentId": bundle.EnvironmentId.Hex(),
+		"targetEnvironmentId": target.Id.Hex(),
+		"targetEnvironment":   target.Name,
+	})
+	if err != nil {
+		logger.L.Error("In Promote: Error publishing webhook event", zap.String("bundleId", promoted.Id.Hex()), zap.Error(err))
+	}
+	err = s.auditService.Record(ctx, &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_PROMOTED,
+		Actor:         actor,
+		AppId:         promoted.AppId,
+		EnvironmentId: promoted.EnvironmentId,
+		BundleId:      promoted.Id,
+		Details:       map[string]interface{}{"label": promoted.Label, "from": bundle.Label, "fromEnvironmentId": bundle.EnvironmentId.Hex()},
+	})
+	if err != nil {
+		logger.L.Error("In Promote: Error recording audit event", zap.String("bundleId", promoted.Id.Hex()), zap.Error(err))
+	}
+	return promoted, nil
+}
+
+func (s *promotionService) copyFile(ctx context.Context, key string, extension string) (string, error) {
+	content, err := s.storage.GetFile(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	copyKey := uuid.New().String() + extension
+	if err := s.storage.UploadFileToR2(ctx, copyKey, content); err != nil {
+		return "", err
+	}
+	return copyKey, nil
+}
+
+// copies of a failed promotion are not referenced by any bundle, the garbage collector would get them too
+func (s *promotionService) deleteFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.storage.DeleteFile(ctx, key); err != nil {
+			logger.L.Error("In Promote: Error deleting copied file", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
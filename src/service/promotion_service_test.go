@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type promotionMocks struct {
+	appService         *MockAppService
+	environmentService *MockEnvironmentService
+	bundleService      *MockBundleService
+	bundleRepository   *MockBundleRepository
+	versionRepository  *MockVersionRepository
+	webhookService     *MockWebhookService
+	auditService       *MockAuditService
+	storage            *MockSourceMapStorage
+}
+
+func newPromotionServiceWithMocks() (PromotionService, *promotionMocks) {
+	mocks := &promotionMocks{
+		appService:         &MockAppService{},
+		environmentService: &MockEnvironmentService{},
+		bundleService:      &MockBundleService{},
+		bundleRepository:   &MockBundleRepository{},
+		versionRepository:  &MockVersionRepository{},
+		webhookService:     &MockWebhookService{},
+		auditService:       &MockAuditService{},
+		storage:            &MockSourceMapStorage{},
+	}
+	return NewPromotionService(mocks.appService, mocks.environmentService, mocks.bundleService, mocks.bundleRepository, mocks.versionRepository, mocks.webhookService, mocks.auditService, mocks.storage), mocks
+}
+
+func newPromotedBundle() *model.Bundle {
+	return &model.Bundle{
+		Id:            primitive.NewObjectID(),
+		AppId:         primitive.NewObjectID(),
+		EnvironmentId: primitive.NewObjectID(),
+		VersionId:     primitive.NewObjectID(),
+		Label:         "v1x4",
+		Hash:          "abc",
+		Size:          2048,
+		Description:   "fix checkout",
+		DownloadFile:  "staging.zip",
+		SourceMapFile: "staging.map",
+		IsMandatory:   true,
+		IsValid:       true,
+	}
+}
+
+func TestPromotionService_Promote_Success(t *testing.T) {
+	service, mocks := newPromotionServiceWithMocks()
+	ctx := context.Background()
+
+	bundle := newPromotedBundle()
+	production := &model.Environment{Id: primitive.NewObjectID(), AppId: bundle.AppId, Name: "production"}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentService.On("GetEnvironmentByAppIdAndEnvironmentId", ctx, bundle.AppId, production.Id.Hex()).Return(production, nil)
+	mocks.appService.On("GetAppById", ctx, bundle.AppId.Hex()).Return(&model.App{Id: bundle.AppId, Name: "shop"}, nil)
+	mocks.versionRepository.On("GetById", ctx, bundle.VersionId).Return(&model.Version{Id: bundle.VersionId, AppVersion: "1.2.0"}, nil)
+	mocks.storage.On("GetFile", ctx, "staging.zip").Return([]byte("zip"), nil)
+	mocks.storage.On("GetFile", ctx, "staging.map").Return([]byte("map"), nil)
+	mocks.storage.On("UploadFileToR2", ctx, mock.MatchedBy(func(key string) bool { return strings.HasSuffix(key, ".zip") }), []byte("zip")).Return(nil)
+	mocks.storage.On("UploadFileToR2", ctx, mock.MatchedBy(func(key string) bool { return strings.HasSuffix(key, ".map") }), []byte("map")).Return(nil)
+	promoted := &model.Bundle{Id: primitive.NewObjectID(), AppId: bundle.AppId, EnvironmentId: production.Id, Label: "v1x1", IsMandatory: true}
+	var payload *types.CreateNewBundleRequest
+	mocks.bundleService.On("CreateNewBundle", mock.AnythingOfType("*types.CreateNewBundleRequest"), "alice").Run(func(args mock.Arguments) {
+		payload = args.Get(0).(*types.CreateNewBundleRequest)
+	}).Return(promoted, nil)
+	mocks.webhookService.On("Publish", ctx, utils.WEBHOOK_EVENT_RELEASE_PROMOTED, promoted, map[string]interface{}{
+		"from":                "v1x4",
+		"sourceEnvironmentId": bundle.EnvironmentId.Hex(),
+		"targetEnvironmentId": production.Id.Hex(),
+		"targetEnvironment":   "production",
+	}).Return(nil)
+	mocks.auditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == utils.AUDIT_ACTION_PROMOTED && event.Details["from"] == "v1x4"
+	})).Return(nil)
+
+	// Execute
+	result, err := service.Promote(ctx, bundle.Id, &types.PromoteBundleRequest{EnvironmentId: production.Id.Hex()}, "alice", &types.FreezeOverride{By: "alice", Reason: "hotfix"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, result.IsMandatory)
+	// mandatory from the moment it is released, not toggled afterwards
+	assert.True(t, payload.IsMandatory)
+	mocks.bundleService.AssertNotCalled(t, "ToggleMandatory", mock.Anything)
+	assert.Equal(t, "shop", payload.AppName)
+	assert.Equal(t, "production", payload.Environment)
+	assert.Equal(t, "1.2.0", payload.AppVersion)
+	assert.Equal(t, "abc", payload.Hash)
+	assert.Equal(t, "fix checkout", payload.Description)
+	assert.Equal(t, "hotfix", payload.OverrideFreezeReason)
+	assert.NotEqual(t, "staging.zip", payload.DownloadFile)
+	assert.True(t, strings.HasSuffix(payload.SourceMapFile, ".map"))
+	mocks.storage.AssertExpectations(t)
+	mocks.webhookService.AssertExpectations(t)
+	mocks.auditService.AssertExpectations(t)
+}
+
+func TestPromotionService_Promote_NotReleased(t *testing.T) {
+	service, mocks := newPromotionServiceWithMocks()
+	ctx := context.Background()
+
+	bundle := newPromotedBundle()
+	bundle.Approval = &model.Approval{Status: utils.APPROVAL_PENDING}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+
+	// Execute
+	_, err := service.Promote(ctx, bundle.Id, &types.PromoteBundleRequest{EnvironmentId: primitive.NewObjectID().Hex()}, "alice", nil)
+
+	// Assert
+	assert.EqualError(t, err, "bundle v1x4 is not released and cannot be promoted")
+	mocks.storage.AssertNotCalled(t, "GetFile", mock.Anything, mock.Anything)
+}
+
+func TestPromotionService_Promote_Disabled(t *testing.T) {
+	service, mocks := newPromotionServiceWithMocks()
+	ctx := context.Background()
+
+	// disabled by the rollback policy after failing on devices
+	bundle := newPromotedBundle()
+	bundle.IsValid = false
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+
+	// Execute
+	_, err := service.Promote(ctx, bundle.Id, &types.PromoteBundleRequest{EnvironmentId: primitive.NewObjectID().Hex()}, "alice", nil)
+
+	// Assert
+	assert.EqualError(t, err, "bundle v1x4 is disabled and cannot be promoted")
+	mocks.storage.AssertNotCalled(t, "GetFile", mock.Anything, mock.Anything)
+	mocks.bundleService.AssertNotCalled(t, "CreateNewBundle", mock.Anything, mock.Anything)
+}
+
+func TestPromotionService_Promote_CreateFailsDeletesCopies(t *testing.T) {
+	service, mocks := newPromotionServiceWithMocks()
+	ctx := context.Background()
+
+	bundle := newPromotedBundle()
+	bundle.SourceMapFile = ""
+	production := &model.Environment{Id: primitive.NewObjectID(), AppId: bundle.AppId, Name: "production"}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.environmentService.On("GetEnvironmentByAppIdAndEnvironmentId", ctx, bundle.AppId, production.Id.Hex()).Return(production, nil)
+	mocks.appService.On("GetAppById", ctx, bundle.AppId.Hex()).Return(&model.App{Id: bundle.AppId, Name: "shop"}, nil)
+	mocks.versionRepository.On("GetById", ctx, bundle.VersionId).Return(&model.Version{Id: bundle.VersionId, AppVersion: "1.2.0"}, nil)
+	mocks.storage.On("GetFile", ctx, "staging.zip").Return([]byte("zip"), nil)
+	var copyKey string
+	mocks.storage.On("UploadFileToR2", ctx, mock.AnythingOfType("string"), []byte("zip")).Run(func(args mock.Arguments) {
+		copyKey = args.String(1)
+	}).Return(nil)
+	mocks.bundleService.On("CreateNewBundle", mock.Anything, "alice").Return(nil, errors.New("release freeze in effect"))
+	mocks.storage.On("DeleteFile", ctx, mock.AnythingOfType("string")).Return(nil)
+
+	// Execute
+	_, err := service.Promote(ctx, bundle.Id, &types.PromoteBundleRequest{EnvironmentId: production.Id.Hex()}, "alice", nil)
+
+	// Assert
+	assert.EqualError(t, err, "release freeze in effect")
+	mocks.storage.AssertCalled(t, "DeleteFile", ctx, copyKey)
+}
@@ -0,0 +1,340 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// how often the scheduler looks for releases that are due
+var releaseSchedulerInterval = 30 * time.Second
+
+var ErrReleaseFrozen = errors.New("releases to this environment are frozen")
+
+// ReleaseScheduleService holds releases back during the freeze windows of their environment and
+// makes scheduled releases current once they are due
+type ReleaseScheduleService interface {
+	EnsureIndexes(ctx context.Context) error
+	CheckFreeze(ctx context.Context, environmentId primitive.ObjectID, at time.Time, override *types.FreezeOverride) (*model.FreezeWindow, error)
+	RecordOverride(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, operation string)
+	RecordScheduled(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, actor string)
+	ScheduleBundle(ctx context.Context, bundleId primitive.ObjectID, scheduledAt time.Time, override *types.FreezeOverride, actor string) (*model.Bundle, error)
+	CancelSchedule(ctx context.Context, bundleId primitive.ObjectID, actor string) error
+	ActivateDue(ctx context.Context) (int, error)
+	RunScheduler(ctx context.Context)
+}
+
+type releaseScheduleService struct {
+	bundleRepository      repository.BundleRepository
+	versionRepository     repository.VersionRepository
+	environmentRepository repository.EnvironmentRepository
+	webhookService        WebhookService
+	auditService          AuditService
+}
+
+func NewReleaseScheduleService(bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, environmentRepository repository.EnvironmentRepository, webhookService WebhookService, auditService AuditService) ReleaseScheduleService {
+	return &releaseScheduleService{
+		bundleRepository:      bundleRepository,
+		versionRepository:     versionRepository,
+		environmentRepository: environmentRepository,
+		webhookService:        webhookService,
+		auditService:          auditService,
+	}
+}
+
+func (s *releaseScheduleService) EnsureIndexes(ctx context.Context) error {
+	return s.bundleRepository.EnsureIndexes(ctx)
+}
+
+// CheckFreeze returns ErrReleaseFrozen when a freeze window of the environment is open at the given
+// time and there is no override. With an override it returns the window being overridden, which the
+// caller records once the release is made
+func (s *releaseScheduleService) CheckFreeze(ctx context.Context, environmentId primitive.ObjectID, at time.Time, override *types.FreezeOverride) (*model.FreezeWindow, error) {
+	environment, err := s.environmentRepository.GetById(ctx, environmentId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("environment not found")
+		}
+		return nil, err
+	}
+	window, endsAt := activeFreezeWindow(environment.FreezeWindows, at)
+	if window == nil {
+		return nil, nil
+	}
+	if override == nil || override.Reason == "" {
+		return nil, fmt.Errorf("%w: %s until %s, an admin can override it with a reason", ErrReleaseFrozen, freezeWindowName(window), endsAt.Format(time.RFC3339))
+	}
+	return window, nil
+}
+
+// RecordOverride records an admin releasing during a freeze window
+func (s *releaseScheduleService) RecordOverride(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, operation string) {
+	if window == nil || override == nil {
+		return
+	}
+	s.record(ctx, "RecordOverride", &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_FREEZE_OVERRIDDEN,
+		Actor:         override.By,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details: map[string]interface{}{
+			"label":     bundle.Label,
+			"window":    freezeWindowName(window),
+			"reason":    override.Reason,
+			"operation": operation,
+		},
+	})
+}
+
+// ScheduleBundle schedules a disabled bundle or moves its schedule. The time has to be outside the
+// freeze windows of the environment unless overridden
+func (s *releaseScheduleService) ScheduleBundle(ctx context.Context, bundleId primitive.ObjectID, scheduledAt time.Time, override *types.FreezeOverride, actor string) (*model.Bundle, error) {
+	if !scheduledAt.After(time.Now()) {
+		return nil, errors.New("scheduled time must be in the future")
+	}
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	if bundle.IsValid {
+		return nil, errors.New("bundle is already released")
+	}
+	if bundle.Approval != nil && bundle.Approval.Status == utils.APPROVAL_REJECTED {
+		return nil, ErrBundleRejected
+	}
+	window, err := s.CheckFreeze(ctx, bundle.EnvironmentId, scheduledAt, override)
+	if err != nil {
+		return nil, err
+	}
+	reason := ""
+	if window != nil {
+		reason = override.Reason
+	}
+	scheduled, err := s.bundleRepository.UpdateSchedule(ctx, bundle.Id, scheduledAt, reason)
+	if err != nil {
+		return nil, err
+	}
+	if !scheduled {
+		return nil, errors.New("bundle is already released")
+	}
+	bundle.ScheduledAt = &scheduledAt
+	bundle.FreezeOverrideReason = reason
+	s.RecordScheduled(ctx, bundle, window, override, actor)
+	return bundle, nil
+}
+
+// RecordScheduled records a release being scheduled and the freeze window it overrides, if any
+func (s *releaseScheduleService) RecordScheduled(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, actor string) {
+	s.record(ctx, "RecordScheduled", &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_RELEASE_SCHEDULED,
+		Actor:         actor,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details:       map[string]interface{}{"label": bundle.Label, "scheduledAt": bundle.ScheduledAt},
+	})
+	s.RecordOverride(ctx, bundle, window, override, "schedule")
+}
+
+// CancelSchedule leaves the bundle disabled, it can be scheduled again or enabled by hand
+func (s *releaseScheduleService) CancelSchedule(ctx context.Context, bundleId primitive.ObjectID, actor string) error {
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return errors.New("bundle not found")
+		}
+		return err
+	}
+	cancelled, err := s.bundleRepository.CancelSchedule(ctx, bundle.Id)
+	if err != nil {
+		return err
+	}
+	if !cancelled {
+		return errors.New("bundle is not scheduled")
+	}
+	s.record(ctx, "CancelSchedule", &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_RELEASE_SCHEDULE_CANCELLED,
+		Actor:         actor,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details:       map[string]interface{}{"label": bundle.Label},
+	})
+	return nil
+}
+
+// ActivateDue makes every scheduled release that is due current and returns how many were. A release
+// due during a freeze window waits for it to end, unless it was scheduled with an override
+func (s *releaseScheduleService) ActivateDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	bundles, err := s.bundleRepository.GetDueScheduled(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	activated := 0
+	for _, bundle := range bundles {
+		if ctx.Err() != nil {
+			break
+		}
+		// the bundle is released once it is approved, and never when rejected
+		if bundle.Approval != nil && bundle.Approval.Status != utils.APPROVAL_APPROVED {
+			continue
+		}
+		environment, err := s.environmentRepository.GetById(ctx, bundle.EnvironmentId)
+		if err == mongo.ErrNoDocuments {
+			// the environment was deleted, restoring it lets the release go out
+			continue
+		}
+		if err != nil {
+			return activated, err
+		}
+		if bundle.FreezeOverrideReason == "" {
+			if window, _ := activeFreezeWindow(environment.FreezeWindows, now); window != nil {
+				continue
+			}
+		}
+		activatedBundle, err := s.activate(ctx, bundle)
+		if err != nil {
+			return activated, err
+		}
+		if activatedBundle {
+			activated++
+		}
+	}
+	return activated, nil
+}
+
+// RunScheduler activates due releases until the context is cancelled
+func (s *releaseScheduleService) RunScheduler(ctx context.Context) {
+	ticker := time.NewTicker(releaseSchedulerInterval)
+	defer ticker.Stop()
+	for {
+		activated, err := s.ActivateDue(ctx)
+		if err != nil {
+			logger.L.Error("In RunScheduler: Error activating scheduled releases", zap.Error(err))
+		}
+		if activated > 0 {
+			logger.L.Info("In RunScheduler: Activated scheduled releases", zap.Int("activated", activated))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// activate claims the bundle so that only one server releases it, then makes it current
+func (s *releaseScheduleService) activate(ctx context.Context, bundle *model.Bundle) (bool, error) {
+	claimed, err := s.bundleRepository.ClaimScheduled(ctx, bundle.Id)
+	if err != nil || !claimed {
+		return false, err
+	}
+	scheduledAt := bundle.ScheduledAt
+	bundle.IsValid = true
+	bundle.ScheduledAt = nil
+	if err := makeCurrent(ctx, s.bundleRepository, s.versionRepository, bundle); err != nil {
+		return true, err
+	}
+	if err := s.webhookService.Publish(ctx, utils.WEBHOOK_EVENT_RELEASE_ENABLED, bundle, map[string]interface{}{"scheduledAt": scheduledAt}); err != nil {
+		logger.L.Error("In activate: Error publishing webhook event", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+	details := map[string]interface{}{"label": bundle.Label, "scheduledAt": scheduledAt}
+	if bundle.FreezeOverrideReason != "" {
+		details["freezeOverrideReason"] = bundle.FreezeOverrideReason
+	}
+	s.record(ctx, "activate", &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_RELEASE_ACTIVATED,
+		Actor:         utils.AUDIT_ACTOR_SYSTEM,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details:       details,
+	})
+	return true, nil
+}
+
+// makeCurrent makes a bundle released after it was created the current bundle of its version,
+// unless a newer bundle was released in the meantime
+func makeCurrent(ctx context.Context, bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, bundle *model.Bundle) error {
+	version, err := versionRepository.GetById(ctx, bundle.VersionId)
+	if err != nil || version == nil || version.CurrentBundleId == bundle.Id {
+		return err
+	}
+	if !version.CurrentBundleId.IsZero() {
+		currentBundle, err := bundleRepository.GetById(ctx, version.CurrentBundleId)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		if currentBundle != nil && currentBundle.SequenceId > bundle.SequenceId {
+			return nil
+		}
+	}
+	_, err = versionRepository.UpdateCurrentBundleId(ctx, version.Id, bundle.Id)
+	return err
+}
+
+// the release already happened, a failed audit write is logged and not returned
+func (s *releaseScheduleService) record(ctx context.Context, fn string, event *model.AuditEvent) {
+	if err := s.auditService.Record(ctx, event); err != nil {
+		logger.L.Error("In "+fn+": Error recording audit event", zap.String("action", event.Action), zap.Error(err))
+	}
+}
+
+// activeFreezeWindow returns the window open at the given time and when it closes. Windows were
+// validated when saved, one that no longer parses is skipped
+func activeFreezeWindow(windows []model.FreezeWindow, at time.Time) (*model.FreezeWindow, time.Time) {
+	const week = 7 * 24 * 60
+	for i := range windows {
+		window := &windows[i]
+		start, err := utils.ParseWeeklyTime(window.Start)
+		if err != nil {
+			continue
+		}
+		end, err := utils.ParseWeeklyTime(window.End)
+		if err != nil {
+			continue
+		}
+		location := time.UTC
+		if window.Timezone != "" {
+			if location, err = time.LoadLocation(window.Timezone); err != nil {
+				continue
+			}
+		}
+		local := at.In(location)
+		minute := int(local.Weekday())*24*60 + local.Hour()*60 + local.Minute()
+		open := minute >= start && minute < end
+		// the window runs over the end of the week
+		if start > end {
+			open = minute >= start || minute < end
+		}
+		if !open {
+			continue
+		}
+		untilEnd := (end - minute + week) % week
+		endsAt := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), 0, 0, location).Add(time.Duration(untilEnd) * time.Minute)
+		return window, endsAt
+	}
+	return nil, time.Time{}
+}
+
+func freezeWindowName(window *model.FreezeWindow) string {
+	if window.Name != "" {
+		return window.Name
+	}
+	return window.Start + " - " + window.End
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MockReleaseScheduleService is a mock implementation of ReleaseScheduleService
+type MockReleaseScheduleService struct {
+	mock.Mock
+}
+
+func (m *MockReleaseScheduleService) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockReleaseScheduleService) CheckFreeze(ctx context.Context, environmentId primitive.ObjectID, at time.Time, override *types.FreezeOverride) (*model.FreezeWindow, error) {
+	args := m.Called(ctx, environmentId, at, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.FreezeWindow), args.Error(1)
+}
+
+func (m *MockReleaseScheduleService) RecordOverride(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, operation string) {
+	m.Called(ctx, bundle, window, override, operation)
+}
+
+func (m *MockReleaseScheduleService) RecordScheduled(ctx context.Context, bundle *model.Bundle, window *model.FreezeWindow, override *types.FreezeOverride, actor string) {
+	m.Called(ctx, bundle, window, override, actor)
+}
+
+func (m *MockReleaseScheduleService) ScheduleBundle(ctx context.Context, bundleId primitive.ObjectID, scheduledAt time.Time, override *types.FreezeOverride, actor string) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, scheduledAt, override, actor)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockReleaseScheduleService) CancelSchedule(ctx context.Context, bundleId primitive.ObjectID, actor string) error {
+	args := m.Called(ctx, bundleId, actor)
+	return args.Error(0)
+}
+
+func (m *MockReleaseScheduleService) ActivateDue(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockReleaseScheduleService) RunScheduler(ctx context.Context) {
+	m.Called(ctx)
+}
+
+// newOpenReleaseScheduleService returns a schedule service for an environment without freeze windows
+func newOpenReleaseScheduleService() *MockReleaseScheduleService {
+	mockReleaseScheduleService := &MockReleaseScheduleService{}
+	mockReleaseScheduleService.On("CheckFreeze", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	mockReleaseScheduleService.On("RecordOverride", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	mockReleaseScheduleService.On("RecordScheduled", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	return mockReleaseScheduleService
+}
+
+type releaseScheduleTestMocks struct {
+	bundleRepository      *MockBundleRepository
+	versionRepository     *MockVersionRepository
+	environmentRepository *MockEnvironmentRepository
+	webhookService        *MockWebhookService
+	auditService          *MockAuditService
+}
+
+func newReleaseScheduleTestService() (ReleaseScheduleService, *releaseScheduleTestMocks) {
+	mocks := &releaseScheduleTestMocks{
+		bundleRepository:      &MockBundleRepository{},
+		versionRepository:     &MockVersionRepository{},
+		environmentRepository: &MockEnvironmentRepository{},
+		webhookService:        newPublishingWebhookService(),
+		auditService:          &MockAuditService{},
+	}
+	mocks.auditService.On("Record", mock.Anything, mock.Anything).Return(nil).Maybe()
+	service := NewReleaseScheduleService(mocks.bundleRepository, mocks.versionRepository, mocks.environmentRepository, mocks.webhookService, mocks.auditService)
+	return service, mocks
+}
+
+// no Production releases from Friday 16:00 to Monday 09:00
+var weekendFreeze = model.FreezeWindow{Name: "weekend", Start: "Fri 16:00", End: "Mon 09:00", Timezone: "UTC"}
+
+func TestActiveFreezeWindow_OverWeekend(t *testing.T) {
+	windows := []model.FreezeWindow{weekendFreeze}
+	// 2 January 2026 is a Friday
+	friday := time.Date(2026, time.January, 2, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		at     time.Time
+		frozen bool
+	}{
+		{friday.Add(15*time.Hour + 59*time.Minute), false},
+		{friday.Add(16 * time.Hour), true},
+		{friday.Add(36 * time.Hour), true},
+		{friday.Add(3*24*time.Hour + 8*time.Hour + 59*time.Minute), true},
+		{friday.Add(3*24*time.Hour + 9*time.Hour), false},
+		{friday.Add(5 * 24 * time.Hour), false},
+	}
+	for _, c := range cases {
+		// Execute
+		window, endsAt := activeFreezeWindow(windows, c.at)
+
+		// Assert
+		assert.Equal(t, c.frozen, window != nil, c.at.String())
+		if c.frozen {
+			assert.Equal(t, friday.Add(3*24*time.Hour+9*time.Hour), endsAt, c.at.String())
+		}
+	}
+}
+
+func TestReleaseScheduleService_CheckFreeze_FrozenWithoutOverride(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), Name: "Production", FreezeWindows: []model.FreezeWindow{weekendFreeze}}
+	saturday := time.Date(2026, time.January, 3, 12, 0, 0, 0, time.UTC)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+
+	// Execute
+	window, err := service.CheckFreeze(ctx, environment.Id, saturday, nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReleaseFrozen)
+	assert.Contains(t, err.Error(), "weekend until 2026-01-05T09:00:00Z")
+	assert.Nil(t, window)
+}
+
+func TestReleaseScheduleService_CheckFreeze_Override(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID(), FreezeWindows: []model.FreezeWindow{weekendFreeze}}
+	saturday := time.Date(2026, time.January, 3, 12, 0, 0, 0, time.UTC)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+
+	// Execute
+	window, err := service.CheckFreeze(ctx, environment.Id, saturday, &types.FreezeOverride{By: "admin", Reason: "checkout crash fix"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "weekend", window.Name)
+}
+
+func TestReleaseScheduleService_ActivateDue_MakesBundleCurrent(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	scheduledAt := time.Now().Add(-time.Minute)
+	environment := &model.Environment{Id: primitive.NewObjectID()}
+	current := &model.Bundle{Id: primitive.NewObjectID(), SequenceId: 1}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, VersionId: primitive.NewObjectID(), SequenceId: 2, Label: "v1x2", ScheduledAt: &scheduledAt}
+	version := &model.Version{Id: bundle.VersionId, CurrentBundleId: current.Id}
+
+	mocks.bundleRepository.On("GetDueScheduled", ctx, mock.Anything).Return([]*model.Bundle{bundle}, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("ClaimScheduled", ctx, bundle.Id).Return(true, nil)
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+	mocks.bundleRepository.On("GetById", ctx, current.Id).Return(current, nil)
+	mocks.versionRepository.On("UpdateCurrentBundleId", ctx, version.Id, bundle.Id).Return(version, nil)
+
+	// Execute
+	activated, err := service.ActivateDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, activated)
+	mocks.versionRepository.AssertExpectations(t)
+	mocks.webhookService.AssertCalled(t, "Publish", ctx, "release.enabled", bundle, mock.Anything)
+	mocks.auditService.AssertCalled(t, "Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "bundle.schedule_activated" && event.Actor == "system" && event.BundleId == bundle.Id
+	}))
+}
+
+func TestReleaseScheduleService_ActivateDue_WaitsForFreezeToEnd(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	now := time.Now().UTC()
+	codeFreeze := model.FreezeWindow{Name: "code freeze", Start: now.Add(-time.Hour).Format("Mon 15:04"), End: now.Add(time.Hour).Format("Mon 15:04"), Timezone: "UTC"}
+	environment := &model.Environment{Id: primitive.NewObjectID(), FreezeWindows: []model.FreezeWindow{codeFreeze}}
+	waiting := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environment.Id}
+	overridden := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environment.Id, VersionId: primitive.NewObjectID(), FreezeOverrideReason: "security fix"}
+	version := &model.Version{Id: overridden.VersionId}
+
+	mocks.bundleRepository.On("GetDueScheduled", ctx, mock.Anything).Return([]*model.Bundle{waiting, overridden}, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("ClaimScheduled", ctx, overridden.Id).Return(true, nil)
+	mocks.versionRepository.On("GetById", ctx, version.Id).Return(version, nil)
+	mocks.versionRepository.On("UpdateCurrentBundleId", ctx, version.Id, overridden.Id).Return(version, nil)
+
+	// Execute
+	activated, err := service.ActivateDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, activated)
+	mocks.bundleRepository.AssertNotCalled(t, "ClaimScheduled", ctx, waiting.Id)
+}
+
+func TestReleaseScheduleService_ActivateDue_ClaimedElsewhere(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	environment := &model.Environment{Id: primitive.NewObjectID()}
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environment.Id}
+
+	mocks.bundleRepository.On("GetDueScheduled", ctx, mock.Anything).Return([]*model.Bundle{bundle}, nil)
+	mocks.environmentRepository.On("GetById", ctx, environment.Id).Return(environment, nil)
+	mocks.bundleRepository.On("ClaimScheduled", ctx, bundle.Id).Return(false, nil)
+
+	// Execute
+	activated, err := service.ActivateDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Zero(t, activated)
+	mocks.versionRepository.AssertNotCalled(t, "UpdateCurrentBundleId", mock.Anything, mock.Anything, mock.Anything)
+	mocks.webhookService.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReleaseScheduleService_ScheduleBundle_Released(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), IsValid: true}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+
+	// Execute
+	scheduled, err := service.ScheduleBundle(ctx, bundle.Id, time.Now().Add(time.Hour), nil, "admin")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, scheduled)
+	mocks.bundleRepository.AssertNotCalled(t, "UpdateSchedule", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestReleaseScheduleService_CancelSchedule_NotScheduled(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID()}
+	mocks.bundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mocks.bundleRepository.On("CancelSchedule", ctx, bundle.Id).Return(false, nil)
+
+	// Execute
+	err := service.CancelSchedule(ctx, bundle.Id, "admin")
+
+	// Assert
+	assert.EqualError(t, err, "bundle is not scheduled")
+	mocks.auditService.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+}
+
+func TestReleaseScheduleService_CheckFreeze_EnvironmentNotFound(t *testing.T) {
+	service, mocks := newReleaseScheduleTestService()
+	ctx := context.Background()
+	environmentId := primitive.NewObjectID()
+	mocks.environmentRepository.On("GetById", ctx, environmentId).Return(nil, mongo.ErrNoDocuments)
+
+	// Execute
+	_, err := service.CheckFreeze(ctx, environmentId, time.Now(), nil)
+
+	// Assert
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrReleaseFrozen))
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/utils"
+	"go.uber.org/zap"
+)
+
+type RollbackPolicyService interface {
+	Evaluate(ctx context.Context, environment *model.Environment, bundle *model.Bundle) (bool, error)
+}
+
+type rollbackPolicyService struct {
+	deviceService  DeviceService
+	bundleService  BundleService
+	auditService   AuditService
+	webhookService WebhookService
+	notifier       pkg.Notifier
+}
+
+func NewRollbackPolicyService(deviceService DeviceService, bundleService BundleService, auditService AuditService, webhookService WebhookService, notifier pkg.Notifier) RollbackPolicyService {
+	return &rollbackPolicyService{
+		deviceService:  deviceService,
+		bundleService:  bundleService,
+		auditService:   auditService,
+		webhookService: webhookService,
+		notifier:       notifier,
+	}
+}
+
+// Evaluate rolls the bundle back when the failure rate of its installs within the policy window
+// crosses the threshold of the environment, true when this call rolled it back
+func (s *rollbackPolicyService) Evaluate(ctx context.Context, environment *model.Environment, bundle *model.Bundle) (bool, error) {
+	policy := environment.RollbackPolicy
+	if policy == nil || !policy.Enabled || !bundle.IsValid {
+		return false, nil
+	}
+	since := time.Now().Add(-time.Duration(policy.WindowMinutes) * time.Minute)
+	succeeded, failed, err := s.deviceService.CountDeployOutcomes(ctx, environment.Id, bundle.Label, since)
+	if err != nil {
+		return false, err
+	}
+	attempts := succeeded + failed
+	if attempts == 0 || attempts < policy.MinInstalls {
+		return false, nil
+	}
+	failureRate := float64(failed) * 100 / float64(attempts)
+	if failureRate <= policy.MaxFailureRate {
+		return false, nil
+	}
+
+	// several reports can cross the threshold together, only the one that disables the bundle goes on
+	currentBundle, rolledBack, err := s.bundleService.DisableAndRollback(ctx, bundle)
+	if !rolledBack {
+		return false, err
+	}
+	if err != nil {
+		// the bundle is disabled, still tell someone the version could not be moved back
+		logger.L.Error("In Evaluate: Error moving version back after disabling bundle", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+
+	details := map[string]interface{}{
+		"label":          bundle.Label,
+		"failureRate":    failureRate,
+		"failed":         failed,
+		"attempts":       attempts,
+		"maxFailureRate": policy.MaxFailureRate,
+		"windowMinutes":  policy.WindowMinutes,
+	}
+	message := fmt.Sprintf("spread disabled bundle %s in environment %s: %.1f%% of %d installs failed in the last %d minutes (limit %.1f%%)",
+		bundle.Label, environment.Name, failureRate, attempts, policy.WindowMinutes, policy.MaxFailureRate)
+	if currentBundle != nil {
+		details["rolledBackTo"] = currentBundle.Label
+		message += ", devices are moved back to " + currentBundle.Label
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	auditErr := s.auditService.Record(ctx, &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_AUTO_ROLLBACK,
+		Actor:         utils.AUDIT_ACTOR_SYSTEM,
+		AppId:         environment.AppId,
+		EnvironmentId: environment.Id,
+		BundleId:      bundle.Id,
+		Details:       details,
+	})
+	if auditErr != nil {
+		logger.L.Error("In Evaluate: Error recording audit event", zap.String("bundleId", bundle.Id.Hex()), zap.Error(auditErr))
+	}
+	disabledBundle := *bundle
+	disabledBundle.IsValid = false
+	if publishErr := s.webhookService.Publish(ctx, utils.WEBHOOK_EVENT_RELEASE_AUTO_ROLLBACK, &disabledBundle, details); publishErr != nil {
+		logger.L.Error("In Evaluate: Error publishing webhook event", zap.String("bundleId", bundle.Id.Hex()), zap.Error(publishErr))
+	}
+	if notifyErr := s.notifier.Notify(ctx, message); notifyErr != nil {
+		logger.L.Error("In Evaluate: Error sending notification", zap.String("bundleId", bundle.Id.Hex()), zap.Error(notifyErr))
+	}
+	return true, nil
+}
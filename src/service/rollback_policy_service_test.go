@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockAuditService is a mock implementation of AuditService
+type MockAuditService struct {
+	mock.Mock
+}
+
+func (m *MockAuditService) Record(ctx context.Context, event *model.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditService) GetEventsByEnvironmentId(ctx context.Context, environmentId primitive.ObjectID) ([]*model.AuditEvent, error) {
+	args := m.Called(ctx, environmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.AuditEvent), args.Error(1)
+}
+
+// MockNotifier is a mock implementation of pkg.Notifier
+type MockNotifier struct {
+	mock.Mock
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, message string) error {
+	args := m.Called(ctx, message)
+	return args.Error(0)
+}
+
+func newRollbackTestEnvironment() *model.Environment {
+	return &model.Environment{
+		Id:             primitive.NewObjectID(),
+		AppId:          primitive.NewObjectID(),
+		Name:           "production",
+		RollbackPolicy: &model.RollbackPolicy{Enabled: true, MaxFailureRate: 5, MinInstalls: 200, WindowMinutes: 30},
+	}
+}
+
+func TestRollbackPolicyService_Evaluate_RollsBackAboveThreshold(t *testing.T) {
+	mockDeviceService := &MockDeviceService{}
+	mockBundleService := &MockBundleService{}
+	mockAuditService := &MockAuditService{}
+	mockNotifier := &MockNotifier{}
+	service := NewRollbackPolicyService(mockDeviceService, mockBundleService, mockAuditService, newPublishingWebhookService(), mockNotifier)
+
+	ctx := context.Background()
+	environment := newRollbackTestEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", IsValid: true}
+	lastGoodBundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x2"}
+
+	mockDeviceService.On("CountDeployOutcomes", ctx, environment.Id, "v1x3", mock.Anything).Return(int64(180), int64(20), nil)
+	mockBundleService.On("DisableAndRollback", ctx, bundle).Return(lastGoodBundle, true, nil)
+	var event *model.AuditEvent
+	mockAuditService.On("Record", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		event = args.Get(1).(*model.AuditEvent)
+	}).Return(nil)
+	mockNotifier.On("Notify", ctx, mock.AnythingOfType("string")).Return(nil)
+
+	// Execute
+	rolledBack, err := service.Evaluate(ctx, environment, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, rolledBack)
+	assert.Equal(t, "bundle.auto_rollback", event.Action)
+	assert.Equal(t, "system", event.Actor)
+	assert.Equal(t, bundle.Id, event.BundleId)
+	assert.Equal(t, "v1x2", event.Details["rolledBackTo"])
+	assert.Equal(t, float64(10), event.Details["failureRate"])
+
+	mockBundleService.AssertExpectations(t)
+	mockNotifier.AssertExpectations(t)
+}
+
+func TestRollbackPolicyService_Evaluate_BelowMinInstalls(t *testing.T) {
+	mockDeviceService := &MockDeviceService{}
+	mockBundleService := &MockBundleService{}
+	service := NewRollbackPolicyService(mockDeviceService, mockBundleService, &MockAuditService{}, newPublishingWebhookService(), &MockNotifier{})
+
+	ctx := context.Background()
+	environment := newRollbackTestEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", IsValid: true}
+	mockDeviceService.On("CountDeployOutcomes", ctx, environment.Id, "v1x3", mock.Anything).Return(int64(50), int64(50), nil)
+
+	// Execute
+	rolledBack, err := service.Evaluate(ctx, environment, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, rolledBack)
+	mockBundleService.AssertNotCalled(t, "DisableAndRollback", mock.Anything, mock.Anything)
+}
+
+func TestRollbackPolicyService_Evaluate_AtThresholdKeepsBundle(t *testing.T) {
+	mockDeviceService := &MockDeviceService{}
+	mockBundleService := &MockBundleService{}
+	service := NewRollbackPolicyService(mockDeviceService, mockBundleService, &MockAuditService{}, newPublishingWebhookService(), &MockNotifier{})
+
+	ctx := context.Background()
+	environment := newRollbackTestEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", IsValid: true}
+	mockDeviceService.On("CountDeployOutcomes", ctx, environment.Id, "v1x3", mock.Anything).Return(int64(190), int64(10), nil)
+
+	// Execute
+	rolledBack, err := service.Evaluate(ctx, environment, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, rolledBack)
+	mockBundleService.AssertNotCalled(t, "DisableAndRollback", mock.Anything, mock.Anything)
+}
+
+func TestRollbackPolicyService_Evaluate_PolicyDisabled(t *testing.T) {
+	mockDeviceService := &MockDeviceService{}
+	service := NewRollbackPolicyService(mockDeviceService, &MockBundleService{}, &MockAuditService{}, newPublishingWebhookService(), &MockNotifier{})
+
+	environment := newRollbackTestEnvironment()
+	environment.RollbackPolicy.Enabled = false
+
+	// Execute
+	rolledBack, err := service.Evaluate(context.Background(), environment, &model.Bundle{Id: primitive.NewObjectID(), IsValid: true})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, rolledBack)
+	mockDeviceService.AssertNotCalled(t, "CountDeployOutcomes", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRollbackPolicyService_Evaluate_AlreadyRolledBackByConcurrentReport(t *testing.T) {
+	mockDeviceService := &MockDeviceService{}
+	mockBundleService := &MockBundleService{}
+	mockAuditService := &MockAuditService{}
+	mockNotifier := &MockNotifier{}
+	service := NewRollbackPolicyService(mockDeviceService, mockBundleService, mockAuditService, newPublishingWebhookService(), mockNotifier)
+
+	ctx := context.Background()
+	environment := newRollbackTestEnvironment()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", IsValid: true}
+	mockDeviceService.On("CountDeployOutcomes", ctx, environment.Id, "v1x3", mock.Anything).Return(int64(100), int64(100), nil)
+	mockBundleService.On("DisableAndRollback", ctx, bundle).Return(nil, false, nil)
+
+	// Execute
+	rolledBack, err := service.Evaluate(ctx, environment, bundle)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.False(t, rolledBack)
+	mockAuditService.AssertNotCalled(t, "Record", mock.Anything, mock.Anything)
+	mockNotifier.AssertNotCalled(t, "Notify", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// parsed maps kept in memory, crash reports of a release tend to come in bursts
+const sourceMapCacheSize = 8
+
+// a frame ends in file:line:column, after "at name (", "name@" or Hermes' "address at"
+var stackFrame = regexp.MustCompile(`^(\s*)(?:at\s+)?(?:(.*?)\s+\((?:address at\s+)?|(.*?)@|(?:address at\s+)?)([^\s()@]+):(\d+):(\d+)\)?\s*$`)
+
+// SourceMapStorage stores the source maps of bundles
+type SourceMapStorage interface {
+	UploadFileToR2(ctx context.Context, key string, file []byte) error
+	GetFile(ctx context.Context, key string) ([]byte, error)
+	DeleteFile(ctx context.Context, key string) error
+}
+
+// SourceMapService keeps the source map of every bundle and symbolicates crash reports with it
+type SourceMapService interface {
+	UploadSourceMap(ctx context.Context, bundleId primitive.ObjectID, content []byte) (*model.Bundle, error)
+	Symbolicate(ctx context.Context, environmentId primitive.ObjectID, request *types.SymbolicateRequest) (*types.SymbolicateResponse, error)
+}
+
+type sourceMapService struct {
+	bundleRepository repository.BundleRepository
+	storage          SourceMapStorage
+
+	mu    sync.Mutex
+	cache map[string]*pkg.SourceMap
+	order []string
+}
+
+func NewSourceMapService(bundleRepository repository.BundleRepository, storage SourceMapStorage) SourceMapService {
+	return &sourceMapService{bundleRepository: bundleRepository, storage: storage, cache: map[string]*pkg.SourceMap{}}
+}
+
+// UploadSourceMap attaches a source map to a bundle released without one, or replaces its map
+func (s *sourceMapService) UploadSourceMap(ctx context.Context, bundleId primitive.ObjectID, content []byte) (*model.Bundle, error) {
+	if s.storage == nil {
+		return nil, errors.New("bundle storage is not configured")
+	}
+	if _, err := pkg.ParseSourceMap(content); err != nil {
+		return nil, err
+	}
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	key := uuid.New().String() + ".map"
+	if err := s.storage.UploadFileToR2(ctx, key, content); err != nil {
+		return nil, err
+	}
+	updated, err := s.bundleRepository.UpdateSourceMapFile(ctx, bundle.Id, key)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("bundle not found")
+	}
+	// a map that is left behind is an orphan the garbage collector deletes
+	if bundle.SourceMapFile != "" {
+		if err := s.storage.DeleteFile(ctx, bundle.SourceMapFile); err != nil {
+			logger.L.Error("In UploadSourceMap: Error deleting replaced source map", zap.String("key", bundle.SourceMapFile), zap.Error(err))
+		}
+	}
+	bundle.SourceMapFile = key
+	return bundle, nil
+}
+
+// Symbolicate maps the frames of a stack trace thrown by the bundle with the label back to the
+// original sources. Lines that are not frames, and frames the map has nothing for, are kept as they are
+func (s *sourceMapService) Symbolicate(ctx context.Context, environmentId primitive.ObjectID, request *types.SymbolicateRequest) (*types.SymbolicateResponse, error) {
+	bundle, err := s.bundleRepository.GetByLabelAndEnvironmentId(ctx, request.Label, environmentId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	if bundle.SourceMapFile == "" {
+		return nil, errors.New("no source map was uploaded for " + bundle.Label)
+	}
+	sourceMap, err := s.getSourceMap(ctx, bundle.SourceMapFile)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &types.SymbolicateResponse{Label: bundle.Label, Frames: []types.SymbolicatedFrame{}}
+	lines := strings.Split(request.StackTrace, "\n")
+	for i, line := range lines {
+		frame, ok := symbolicateFrame(sourceMap, line)
+		if !ok {
+			continue
+		}
+		response.Frames = append(response.Frames, frame)
+		if frame.Symbolicated {
+			lines[i] = formatFrame(stackFrame.FindStringSubmatch(line)[1], frame)
+		}
+	}
+	response.StackTrace = strings.Join(lines, "\n")
+	return response, nil
+}
+
+func symbolicateFrame(sourceMap *pkg.SourceMap, line string) (types.SymbolicatedFrame, bool) {
+	match := stackFrame.FindStringSubmatch(line)
+	if match == nil {
+		return types.SymbolicatedFrame{}, false
+	}
+	frame := types.SymbolicatedFrame{Raw: strings.TrimSpace(line), File: match[4], Name: match[2] + match[3]}
+	frame.Line, _ = strconv.Atoi(match[5])
+	frame.Column, _ = strconv.Atoi(match[6])
+	position, found := sourceMap.Lookup(frame.Line, frame.Column)
+	if !found {
+		return frame, true
+	}
+	frame.Symbolicated = true
+	frame.Source = position.Source
+	frame.OriginalLine = position.Line
+	frame.OriginalColumn = position.Column
+	if position.Name != "" {
+		frame.Name = position.Name
+	}
+	return frame, true
+}
+
+func formatFrame(indent string, frame types.SymbolicatedFrame) string {
+	location := frame.Source + ":" + strconv.Itoa(frame.OriginalLine) + ":" + strconv.Itoa(frame.OriginalColumn)
+	if frame.Name == "" {
+		return indent + "at " + location
+	}
+	return indent + "at " + frame.Name + " (" + location + ")"
+}
+
+func (s *sourceMapService) getSourceMap(ctx context.Context, key string) (*pkg.SourceMap, error) {
+	s.mu.Lock()
+	sourceMap, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok {
+		pkg.CacheRequestsTotal.WithLabelValues("source_map", "hit").Inc()
+		return sourceMap, nil
+	}
+	pkg.CacheRequestsTotal.WithLabelValues("source_map", "miss").Inc()
+	if s.storage == nil {
+		return nil, errors.New("bundle storage is not configured")
+	}
+	content, err := s.storage.GetFile(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	sourceMap, err = pkg.ParseSourceMap(content)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.cache[key]; !ok {
+		if len(s.order) == sourceMapCacheSize {
+			delete(s.cache, s.order[0])
+			s.order = s.order[1:]
+		}
+		s.order = append(s.order, key)
+	}
+	s.cache[key] = sourceMap
+	return sourceMap, nil
+}
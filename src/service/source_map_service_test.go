@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// line 1 maps column 0 to App.tsx 1:0 and column 10 to crash at 42:4, line 2 column 0 to 43:0
+const testSourceMap = `{"version":3,"sources":["src/App.tsx"],"names":["crash"],"mappings":"AAAA,UAyCIA;AACJ"}`
+
+// MockSourceMapStorage is a mock implementation of SourceMapStorage
+type MockSourceMapStorage struct {
+	mock.Mock
+}
+
+func (m *MockSourceMapStorage) UploadFileToR2(ctx context.Context, key string, file []byte) error {
+	args := m.Called(ctx, key, file)
+	return args.Error(0)
+}
+
+func (m *MockSourceMapStorage) GetFile(ctx context.Context, key string) ([]byte, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *MockSourceMapStorage) DeleteFile(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func TestSourceMapService_Symbolicate(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockStorage := &MockSourceMapStorage{}
+	service := NewSourceMapService(mockBundleRepository, mockStorage)
+	ctx := context.Background()
+
+	environmentId := primitive.NewObjectID()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", SourceMapFile: "v1x3.map"}
+	mockBundleRepository.On("GetByLabelAndEnvironmentId", ctx, "v1x3", environmentId).Return(bundle, nil)
+	mockStorage.On("GetFile", ctx, "v1x3.map").Return([]byte(testSourceMap), nil).Once()
+	stackTrace := strings.Join([]string{
+		"TypeError: undefined is not a function",
+		"    at crash (address at index.android.bundle:1:15)",
+		"    at anonymous (index.android.bundle:2:5)",
+		"    at render@main.jsbundle:7:1",
+		"    at native",
+	}, "\n")
+	hits := testutil.ToFloat64(pkg.CacheRequestsTotal.WithLabelValues("source_map", "hit"))
+	misses := testutil.ToFloat64(pkg.CacheRequestsTotal.WithLabelValues("source_map", "miss"))
+
+	// Execute
+	response, err := service.Symbolicate(ctx, environmentId, &types.SymbolicateRequest{Label: "v1x3", StackTrace: stackTrace})
+	assert.NoError(t, err)
+	// the parsed map is cached
+	_, err = service.Symbolicate(ctx, environmentId, &types.SymbolicateRequest{Label: "v1x3", StackTrace: stackTrace})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, strings.Join([]string{
+		"TypeError: undefined is not a function",
+		"    at crash (src/App.tsx:42:4)",
+		"    at anonymous (src/App.tsx:43:0)",
+		"    at render@main.jsbundle:7:1",
+		"    at native",
+	}, "\n"), response.StackTrace)
+	assert.Len(t, response.Frames, 3)
+	assert.Equal(t, types.SymbolicatedFrame{
+		Raw: "at crash (address at index.android.bundle:1:15)", File: "index.android.bundle", Line: 1, Column: 15,
+		Symbolicated: true, Source: "src/App.tsx", OriginalLine: 42, OriginalColumn: 4, Name: "crash",
+	}, response.Frames[0])
+	assert.False(t, response.Frames[2].Symbolicated)
+	assert.Equal(t, "render", response.Frames[2].Name)
+	assert.Equal(t, misses+1, testutil.ToFloat64(pkg.CacheRequestsTotal.WithLabelValues("source_map", "miss")))
+	assert.Equal(t, hits+1, testutil.ToFloat64(pkg.CacheRequestsTotal.WithLabelValues("source_map", "hit")))
+	mockStorage.AssertExpectations(t)
+}
+
+func TestSourceMapService_Symbolicate_NoSourceMap(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	service := NewSourceMapService(mockBundleRepository, &MockSourceMapStorage{})
+	ctx := context.Background()
+
+	environmentId := primitive.NewObjectID()
+	mockBundleRepository.On("GetByLabelAndEnvironmentId", ctx, "v1x1", environmentId).Return(&model.Bundle{Label: "v1x1"}, nil)
+	mockBundleRepository.On("GetByLabelAndEnvironmentId", ctx, "v9x9", environmentId).Return(nil, mongo.ErrNoDocuments)
+
+	// Execute
+	_, noMapErr := service.Symbolicate(ctx, environmentId, &types.SymbolicateRequest{Label: "v1x1", StackTrace: "at a (index.android.bundle:1:1)"})
+	_, notFoundErr := service.Symbolicate(ctx, environmentId, &types.SymbolicateRequest{Label: "v9x9", StackTrace: "at a (index.android.bundle:1:1)"})
+
+	// Assert
+	assert.EqualError(t, noMapErr, "no source map was uploaded for v1x1")
+	assert.EqualError(t, notFoundErr, "bundle not found")
+}
+
+func TestSourceMapService_UploadSourceMap_ReplacesPreviousMap(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockStorage := &MockSourceMapStorage{}
+	service := NewSourceMapService(mockBundleRepository, mockStorage)
+	ctx := context.Background()
+
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3", SourceMapFile: "old.map"}
+	mockBundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockStorage.On("UploadFileToR2", ctx, mock.MatchedBy(func(key string) bool { return strings.HasSuffix(key, ".map") }), []byte(testSourceMap)).Return(nil)
+	mockBundleRepository.On("UpdateSourceMapFile", ctx, bundle.Id, mock.AnythingOfType("string")).Return(true, nil)
+	mockStorage.On("DeleteFile", ctx, "old.map").Return(nil)
+
+	// Execute
+	updated, err := service.UploadSourceMap(ctx, bundle.Id, []byte(testSourceMap))
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old.map", updated.SourceMapFile)
+	mockBundleRepository.AssertExpectations(t)
+	mockStorage.AssertExpectations(t)
+}
+
+func TestSourceMapService_UploadSourceMap_InvalidMap(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockStorage := &MockSourceMapStorage{}
+	service := NewSourceMapService(mockBundleRepository, mockStorage)
+
+	// Execute
+	_, err := service.UploadSourceMap(context.Background(), primitive.NewObjectID(), []byte(`{"version":3,"sources":[],"mappings":"AA!A"}`))
+
+	// Assert
+	assert.Error(t, err)
+	mockStorage.AssertNotCalled(t, "UploadFileToR2", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
+)
+
+// TargetingService limits bundles to some devices, QA devices in an allowlist or devices matching
+// rules on what the SDK sends on update checks
+type TargetingService interface {
+	UpdateTargeting(ctx context.Context, bundleId primitive.ObjectID, request *types.TargetingRequest, actor string, override *types.FreezeOverride) (*model.Bundle, error)
+	ResolveBundle(ctx context.Context, bundle *model.Bundle, device *types.DeviceAttributes) (*model.Bundle, error)
+}
+
+type targetingService struct {
+	bundleRepository       repository.BundleRepository
+	versionRepository      repository.VersionRepository
+	releaseScheduleService ReleaseScheduleService
+	auditService           AuditService
+}
+
+func NewTargetingService(bundleRepository repository.BundleRepository, versionRepository repository.VersionRepository, releaseScheduleService ReleaseScheduleService, auditService AuditService) TargetingService {
+	return &targetingService{
+		bundleRepository:       bundleRepository,
+		versionRepository:      versionRepository,
+		releaseScheduleService: releaseScheduleService,
+		auditService:           auditService,
+	}
+}
+
+// UpdateTargeting replaces the targeting of a bundle, devices that stop matching go back to the
+// bundle they would get without it on their next update check. Widening the targeting of the
+// current bundle releases it to more devices, so for that bundle it has to be outside the freeze
+// windows of the environment unless overridden
+func (s *targetingService) UpdateTargeting(ctx context.Context, bundleId primitive.ObjectID, request *types.TargetingRequest, actor string, override *types.FreezeOverride) (*model.Bundle, error) {
+	targeting, err := newTargeting(request)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := s.bundleRepository.GetById(ctx, bundleId)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("bundle not found")
+		}
+		return nil, err
+	}
+	var freezeWindow *model.FreezeWindow
+	if bundle.IsValid {
+		version, err := s.versionRepository.GetById(ctx, bundle.VersionId)
+		if err != nil {
+			return nil, err
+		}
+		if version != nil && version.CurrentBundleId == bundle.Id {
+			if freezeWindow, err = s.releaseScheduleService.CheckFreeze(ctx, bundle.EnvironmentId, time.Now(), override); err != nil {
+				return nil, err
+			}
+		}
+	}
+	updated, err := s.bundleRepository.UpdateTargeting(ctx, bundle.Id, targeting)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, errors.New("bundle not found")
+	}
+	bundle.Targeting = targeting
+	details := map[string]interface{}{"label": bundle.Label, "allowlist": 0, "rules": 0}
+	if targeting != nil {
+		details["allowlist"] = len(targeting.Allowlist)
+		details["rules"] = len(targeting.Rules)
+	}
+	if freezeWindow != nil {
+		details["freezeOverrideReason"] = override.Reason
+		s.releaseScheduleService.RecordOverride(ctx, bundle, freezeWindow, override, "targeting")
+	}
+	err = s.auditService.Record(ctx, &model.AuditEvent{
+		Action:        utils.AUDIT_ACTION_TARGETING_UPDATED,
+		Actor:         actor,
+		AppId:         bundle.AppId,
+		EnvironmentId: bundle.EnvironmentId,
+		BundleId:      bundle.Id,
+		Details:       details,
+	})
+	if err != nil {
+		logger.L.Error("In UpdateTargeting: Error recording audit event", zap.String("bundleId", bundle.Id.Hex()), zap.Error(err))
+	}
+	return bundle, nil
+}
+
+// ResolveBundle returns the bundle the device gets, the given one when it targets the device or
+// else the newest earlier valid bundle of the version that does. Nil when none does
+func (s *targetingService) ResolveBundle(ctx context.Context, bundle *model.Bundle, device *types.DeviceAttributes) (*model.Bundle, error) {
+	candidate := bundle
+	for candidate != nil {
+		if candidate.Targeting == nil || matchesTargeting(candidate.Targeting, device) {
+			return candidate, nil
+		}
+		previous, err := s.bundleRepository.GetLastValidBefore(ctx, candidate.EnvironmentId, candidate.VersionId, candidate.SequenceId)
+		if err != nil {
+			return nil, err
+		}
+		candidate = previous
+	}
+	return nil, nil
+}
+
+// newTargeting validates a targeting request, nil when it targets every device
+func newTargeting(request *types.TargetingRequest) (*model.Targeting, error) {
+	if request == nil {
+		return nil, nil
+	}
+	targeting := &model.Targeting{}
+	seen := map[string]bool{}
+	for _, clientUniqueId := range request.Allowlist {
+		clientUniqueId = strings.TrimSpace(clientUniqueId)
+		if clientUniqueId == "" || seen[clientUniqueId] {
+			continue
+		}
+		seen[clientUniqueId] = true
+		targeting.Allowlist = append(targeting.Allowlist, clientUniqueId)
+	}
+	for i, rule := range request.Rules {
+		if len(rule.Values) == 0 {
+			return nil, fmt.Errorf("targeting rule %d: values are required", i+1)
+		}
+		switch rule.Operator {
+		case utils.TARGETING_OPERATOR_IN, utils.TARGETING_OPERATOR_NOT_IN:
+		case utils.TARGETING_OPERATOR_GTE, utils.TARGETING_OPERATOR_LTE:
+			if rule.Attribute != utils.TARGETING_ATTRIBUTE_OS_VERSION {
+				return nil, fmt.Errorf("targeting rule %d: %s only compares os_version", i+1, rule.Operator)
+			}
+			if len(rule.Values) != 1 {
+				return nil, fmt.Errorf("targeting rule %d: %s takes a single version", i+1, rule.Operator)
+			}
+		default:
+			return nil, fmt.Errorf("targeting rule %d: unknown operator %q", i+1, rule.Operator)
+		}
+		switch rule.Attribute {
+		case utils.TARGETING_ATTRIBUTE_OS_VERSION:
+			for _, value := range rule.Values {
+				if _, ok := compareVersions(value, value); !ok {
+					return nil, fmt.Errorf("targeting rule %d: %q is not a version such as 17.4", i+1, value)
+				}
+			}
+		case utils.TARGETING_ATTRIBUTE_LOCALE, utils.TARGETING_ATTRIBUTE_TAG:
+		default:
+			return nil, fmt.Errorf("targeting rule %d: unknown attribute %q", i+1, rule.Attribute)
+		}
+		targeting.Rules = append(targeting.Rules, model.TargetingRule{Attribute: rule.Attribute, Operator: rule.Operator, Values: rule.Values})
+	}
+	if len(targeting.Allowlist) == 0 && len(targeting.Rules) == 0 {
+		return nil, nil
+	}
+	return targeting, nil
+}
+
+// matchesTargeting reports whether the device is in the allowlist, or there are rules and the
+// device matches all of them
+func matchesTargeting(targeting *model.Targeting, device *types.DeviceAttributes) bool {
+	if device == nil {
+		device = &types.DeviceAttributes{}
+	}
+	if device.ClientUniqueId != "" {
+		for _, clientUniqueId := range targeting.Allowlist {
+			if clientUniqueId == device.ClientUniqueId {
+				return true
+			}
+		}
+	}
+	if len(targeting.Rules) == 0 {
+		return false
+	}
+	for _, rule := range targeting.Rules {
+		if !matchesRule(rule, device) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesRule compares one attribute. A device that does not send its OS version or locale matches
+// no rule on it, one without tags matches not_in rules on tags
+func matchesRule(rule model.TargetingRule, device *types.DeviceAttributes) bool {
+	switch rule.Attribute {
+	case utils.TARGETING_ATTRIBUTE_OS_VERSION:
+		if device.OsVersion == "" {
+			return false
+		}
+		switch rule.Operator {
+		case utils.TARGETING_OPERATOR_GTE, utils.TARGETING_OPERATOR_LTE:
+			comparison, ok := compareVersions(device.OsVersion, rule.Values[0])
+			if !ok {
+				return false
+			}
+			if rule.Operator == utils.TARGETING_OPERATOR_GTE {
+				return comparison >= 0
+			}
+			return comparison <= 0
+		}
+		// "17" covers 17.4 and 17.4.1
+		return inValues(rule, func(value string) bool {
+			return device.OsVersion == value || strings.HasPrefix(device.OsVersion, value+".")
+		})
+	case utils.TARGETING_ATTRIBUTE_LOCALE:
+		if device.Locale == "" {
+			return false
+		}
+		// "en" covers en-US and en_GB, "de-AT" covers de_AT
+		locale := normalizeLocale(device.Locale)
+		return inValues(rule, func(value string) bool {
+			value = normalizeLocale(value)
+			return locale == value || strings.HasPrefix(locale, value+"-")
+		})
+	case utils.TARGETING_ATTRIBUTE_TAG:
+		return inValues(rule, func(value string) bool {
+			for _, tag := range device.Tags {
+				if tag == value {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return false
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ReplaceAll(strings.ToLower(locale), "_", "-")
+}
+
+// inValues applies an in or not_in rule, matches reports whether the device has one value
+func inValues(rule model.TargetingRule, matches func(value string) bool) bool {
+	found := false
+	for _, value := range rule.Values {
+		if matches(value) {
+			found = true
+			break
+		}
+	}
+	if rule.Operator == utils.TARGETING_OPERATOR_NOT_IN {
+		return !found
+	}
+	return found
+}
+
+// compareVersions compares dotted numeric versions, missing parts count as 0 so 17 equals 17.0.
+// False when either is not a version
+func compareVersions(a string, b string) (int, bool) {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := 0, 0
+		var err error
+		if i < len(aParts) {
+			if aPart, err = strconv.Atoi(aParts[i]); err != nil || aPart < 0 {
+				return 0, false
+			}
+		}
+		if i < len(bParts) {
+			if bPart, err = strconv.Atoi(bParts[i]); err != nil || bPart < 0 {
+				return 0, false
+			}
+		}
+		if aPart != bPart {
+			if aPart < bPart {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	return 0, true
+}
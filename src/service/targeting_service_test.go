@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockTargetingService is a mock implementation of TargetingService
+type MockTargetingService struct {
+	mock.Mock
+}
+
+func (m *MockTargetingService) UpdateTargeting(ctx context.Context, bundleId primitive.ObjectID, request *types.TargetingRequest, actor string, override *types.FreezeOverride) (*model.Bundle, error) {
+	args := m.Called(ctx, bundleId, request, actor, override)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func (m *MockTargetingService) ResolveBundle(ctx context.Context, bundle *model.Bundle, device *types.DeviceAttributes) (*model.Bundle, error) {
+	args := m.Called(ctx, bundle, device)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Bundle), args.Error(1)
+}
+
+func TestMatchesTargeting(t *testing.T) {
+	targeting := &model.Targeting{
+		Allowlist: []string{"qa-device"},
+		Rules: []model.TargetingRule{
+			{Attribute: "os_version", Operator: "gte", Values: []string{"16.4"}},
+			{Attribute: "locale", Operator: "in", Values: []string{"en", "de-AT"}},
+			{Attribute: "tag", Operator: "not_in", Values: []string{"enterprise"}},
+		},
+	}
+	cases := []struct {
+		name   string
+		device *types.DeviceAttributes
+		want   bool
+	}{
+		{"allowlisted device skips the rules", &types.DeviceAttributes{ClientUniqueId: "qa-device"}, true},
+		{"matches every rule", &types.DeviceAttributes{ClientUniqueId: "a", OsVersion: "17.0.1", Locale: "en-US"}, true},
+		{"locale language and region", &types.DeviceAttributes{OsVersion: "16.4", Locale: "de_AT"}, true},
+		{"os version too old", &types.DeviceAttributes{OsVersion: "16.3.9", Locale: "en"}, false},
+		{"locale not listed", &types.DeviceAttributes{OsVersion: "17", Locale: "fr-FR"}, false},
+		{"excluded tag", &types.DeviceAttributes{OsVersion: "17", Locale: "en", Tags: []string{"beta", "enterprise"}}, false},
+		{"no os version sent", &types.DeviceAttributes{Locale: "en"}, false},
+		{"no device", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, matchesTargeting(targeting, c.device))
+		})
+	}
+}
+
+func TestMatchesTargeting_AllowlistOnly(t *testing.T) {
+	targeting := &model.Targeting{Allowlist: []string{"qa-device"}}
+
+	assert.True(t, matchesTargeting(targeting, &types.DeviceAttributes{ClientUniqueId: "qa-device"}))
+	assert.False(t, matchesTargeting(targeting, &types.DeviceAttributes{ClientUniqueId: "customer-device"}))
+}
+
+func TestNewTargeting_Validation(t *testing.T) {
+	// Execute
+	targeting, err := newTargeting(&types.TargetingRequest{Allowlist: []string{" qa-1 ", "qa-1", ""}})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"qa-1"}, targeting.Allowlist)
+
+	targeting, err = newTargeting(&types.TargetingRequest{})
+	assert.NoError(t, err)
+	assert.Nil(t, targeting)
+
+	_, err = newTargeting(&types.TargetingRequest{Rules: []types.TargetingRuleRequest{{Attribute: "locale", Operator: "gte", Values: []string{"en"}}}})
+	assert.EqualError(t, err, "targeting rule 1: gte only compares os_version")
+
+	_, err = newTargeting(&types.TargetingRequest{Rules: []types.TargetingRuleRequest{{Attribute: "os_version", Operator: "in", Values: []string{"seventeen"}}}})
+	assert.Error(t, err)
+}
+
+func TestTargetingService_ResolveBundle_FallsBackToEarlierBundle(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	service := NewTargetingService(mockBundleRepository, &MockVersionRepository{}, &MockReleaseScheduleService{}, &MockAuditService{})
+	ctx := context.Background()
+
+	environmentId, versionId := primitive.NewObjectID(), primitive.NewObjectID()
+	dogfood := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 3, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	beta := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 2, Targeting: &model.Targeting{Rules: []model.TargetingRule{{Attribute: "tag", Operator: "in", Values: []string{"beta"}}}}}
+	stable := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: environmentId, VersionId: versionId, SequenceId: 1}
+	mockBundleRepository.On("GetLastValidBefore", ctx, environmentId, versionId, int64(3)).Return(beta, nil)
+	mockBundleRepository.On("GetLastValidBefore", ctx, environmentId, versionId, int64(2)).Return(stable, nil)
+
+	// Execute
+	qaBundle, err := service.ResolveBundle(ctx, dogfood, &types.DeviceAttributes{ClientUniqueId: "qa-device"})
+	assert.NoError(t, err)
+	betaBundle, err := service.ResolveBundle(ctx, dogfood, &types.DeviceAttributes{ClientUniqueId: "b", Tags: []string{"beta"}})
+	assert.NoError(t, err)
+	customerBundle, err := service.ResolveBundle(ctx, dogfood, &types.DeviceAttributes{ClientUniqueId: "c"})
+	assert.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, dogfood.Id, qaBundle.Id)
+	assert.Equal(t, beta.Id, betaBundle.Id)
+	assert.Equal(t, stable.Id, customerBundle.Id)
+}
+
+func TestTargetingService_ResolveBundle_NothingTargetsDevice(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	service := NewTargetingService(mockBundleRepository, &MockVersionRepository{}, &MockReleaseScheduleService{}, &MockAuditService{})
+	ctx := context.Background()
+
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), SequenceId: 1, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	mockBundleRepository.On("GetLastValidBefore", ctx, bundle.EnvironmentId, bundle.VersionId, int64(1)).Return(nil, nil)
+
+	// Execute
+	resolved, err := service.ResolveBundle(ctx, bundle, &types.DeviceAttributes{ClientUniqueId: "customer-device"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, resolved)
+}
+
+func TestTargetingService_UpdateTargeting_Success(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockAuditService := &MockAuditService{}
+	service := NewTargetingService(mockBundleRepository, &MockVersionRepository{}, &MockReleaseScheduleService{}, mockAuditService)
+	ctx := context.Background()
+
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), Label: "v1x3"}
+	expected := &model.Targeting{Allowlist: []string{"qa-device"}}
+	mockBundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockBundleRepository.On("UpdateTargeting", ctx, bundle.Id, expected).Return(true, nil)
+	mockAuditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "bundle.targeting_updated" && event.Actor == "alice"
+	})).Return(nil)
+
+	// Execute
+	updated, err := service.UpdateTargeting(ctx, bundle.Id, &types.TargetingRequest{Allowlist: []string{"qa-device"}}, "alice", nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, expected, updated.Targeting)
+	mockBundleRepository.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
+
+func TestTargetingService_UpdateTargeting_CurrentBundleFrozen(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockReleaseScheduleService := &MockReleaseScheduleService{}
+	service := NewTargetingService(mockBundleRepository, mockVersionRepository, mockReleaseScheduleService, &MockAuditService{})
+	ctx := context.Background()
+
+	// removing the allowlist of the current bundle releases it to every device
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), Label: "v1x3", IsValid: true, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	mockBundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockVersionRepository.On("GetById", ctx, bundle.VersionId).Return(&model.Version{Id: bundle.VersionId, CurrentBundleId: bundle.Id}, nil)
+	mockReleaseScheduleService.On("CheckFreeze", ctx, bundle.EnvironmentId, mock.AnythingOfType("time.Time"), (*types.FreezeOverride)(nil)).Return(nil, ErrReleaseFrozen)
+
+	// Execute
+	_, err := service.UpdateTargeting(ctx, bundle.Id, nil, "alice", nil)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrReleaseFrozen)
+	mockBundleRepository.AssertNotCalled(t, "UpdateTargeting", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTargetingService_UpdateTargeting_RecordsFreezeOverride(t *testing.T) {
+	mockBundleRepository := &MockBundleRepository{}
+	mockVersionRepository := &MockVersionRepository{}
+	mockReleaseScheduleService := &MockReleaseScheduleService{}
+	mockAuditService := &MockAuditService{}
+	service := NewTargetingService(mockBundleRepository, mockVersionRepository, mockReleaseScheduleService, mockAuditService)
+	ctx := context.Background()
+
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), EnvironmentId: primitive.NewObjectID(), VersionId: primitive.NewObjectID(), Label: "v1x3", IsValid: true, Targeting: &model.Targeting{Allowlist: []string{"qa-device"}}}
+	override := &types.FreezeOverride{By: "admin", Reason: "fixes the crash on login"}
+	window := &model.FreezeWindow{Name: "weekend"}
+	mockBundleRepository.On("GetById", ctx, bundle.Id).Return(bundle, nil)
+	mockVersionRepository.On("GetById", ctx, bundle.VersionId).Return(&model.Version{Id: bundle.VersionId, CurrentBundleId: bundle.Id}, nil)
+	mockReleaseScheduleService.On("CheckFreeze", ctx, bundle.EnvironmentId, mock.AnythingOfType("time.Time"), override).Return(window, nil)
+	mockBundleRepository.On("UpdateTargeting", ctx, bundle.Id, (*model.Targeting)(nil)).Return(true, nil)
+	mockReleaseScheduleService.On("RecordOverride", ctx, bundle, window, override, "targeting").Return()
+	mockAuditService.On("Record", ctx, mock.MatchedBy(func(event *model.AuditEvent) bool {
+		return event.Action == "bundle.targeting_updated" && event.Details["freezeOverrideReason"] == "fixes the crash on login"
+	})).Return(nil)
+
+	// Execute
+	updated, err := service.UpdateTargeting(ctx, bundle.Id, nil, "admin", override)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Nil(t, updated.Targeting)
+	mockReleaseScheduleService.AssertExpectations(t)
+	mockAuditService.AssertExpectations(t)
+}
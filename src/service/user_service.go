@@ -3,30 +3,47 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
+	"time"
 
 	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/src/repository"
 	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService interface {
 	Create(user *types.CreateUserRequest) (*model.User, error)
-	Login(user *types.LoginUserRequest) (*string, error)
+	Login(user *types.LoginUserRequest) (*types.TokenResponse, error)
+	LoginWithOIDC(ctx context.Context, identity *pkg.OIDCIdentity, roles []string) (*types.TokenResponse, error)
+	RefreshToken(refreshToken string) (*types.TokenResponse, error)
+	Logout(refreshToken string) error
 	GetUser(id string) (*model.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*model.User, error)
 	Count(ctx context.Context) (int64, error)
 }
 
+// errors of a password sign in with wrong credentials, they count towards the login lockout
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
 type userService struct {
-	userRepository repository.UserRepository
+	userRepository         repository.UserRepository
+	refreshTokenRepository repository.RefreshTokenRepository
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
-	return &userService{userRepository: userRepository}
+func NewUserService(userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository) UserService {
+	return &userService{userRepository: userRepository, refreshTokenRepository: refreshTokenRepository}
 }
 
 func (s *userService) Create(user *types.CreateUserRequest) (*model.User, error) {
@@ -55,28 +72,151 @@ func (s *userService) Create(user *types.CreateUserRequest) (*model.User, error)
 	return createdUser, nil
 }
 
-func (s *userService) Login(user *types.LoginUserRequest) (*string, error) {
+func (s *userService) Login(user *types.LoginUserRequest) (*types.TokenResponse, error) {
 	existingUser, err := s.userRepository.GetByUsername(context.Background(), user.Username)
 	if err != nil {
 		return nil, err
 	}
 	if existingUser == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
+	}
+	if existingUser.OIDCSubject != "" {
+		return nil, errors.New("user must sign in with single sign-on")
 	}
 
 	// compare password
 	err = bcrypt.CompareHashAndPassword([]byte(existingUser.Password), []byte(user.Password))
 	if err != nil {
-		return nil, errors.New("invalid password")
+		return nil, ErrInvalidPassword
 	}
+	return s.issueTokens(context.Background(), existingUser.Id)
+}
+
+// LoginWithOIDC signs in the user the identity provider vouched for, creating the user on
+// first sign in. Roles always follow the identity provider groups so access granted or
+// revoked there takes effect on the next sign in
+func (s *userService) LoginWithOIDC(ctx context.Context, identity *pkg.OIDCIdentity, roles []string) (*types.TokenResponse, error) {
+	existingUser, err := s.userRepository.GetByOIDCSubject(ctx, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser == nil {
+		// never attach an identity to a local account by name, that would let the identity
+		// provider take over password accounts
+		userWithSameName, err := s.userRepository.GetByUsername(ctx, identity.Username)
+		if err != nil {
+			return nil, err
+		}
+		if userWithSameName != nil {
+			return nil, errors.New("username is already taken by a local user")
+		}
+		createdUser, err := s.userRepository.Insert(ctx, &model.User{
+			Username:    identity.Username,
+			Roles:       roles,
+			IsValid:     true,
+			OIDCSubject: identity.Subject,
+		})
+		if err != nil {
+			return nil, err
+		}
+		logger.L.Info("In LoginWithOIDC: Provisioned user", zap.String("username", createdUser.Username), zap.Strings("roles", roles))
+		return s.issueTokens(ctx, createdUser.Id)
+	}
+	if !slices.Equal(existingUser.Roles, roles) {
+		if err := s.userRepository.UpdateRoles(ctx, existingUser.Id, roles); err != nil {
+			return nil, err
+		}
+	}
+	return s.issueTokens(ctx, existingUser.Id)
+}
+
+// RefreshToken exchanges a refresh token for a new access token and refresh token,
+// the presented refresh token is revoked so every refresh token is single use
+func (s *userService) RefreshToken(refreshToken string) (*types.TokenResponse, error) {
+	ctx := context.Background()
+	storedToken, err := s.refreshTokenRepository.GetByTokenHash(ctx, utils.HashKey(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if storedToken == nil {
+		return nil, errors.New("invalid refresh token")
+	}
+	// a revoked token being presented again means it was copied, end every session of the user
+	if storedToken.RevokedAt != nil {
+		return nil, s.revokeReusedToken(ctx, storedToken)
+	}
+	if time.Now().After(storedToken.ExpiresAt) {
+		return nil, errors.New("refresh token expired")
+	}
+	revoked, err := s.refreshTokenRepository.Revoke(ctx, storedToken.Id)
+	if err != nil {
+		return nil, err
+	}
+	// another refresh with the same token revoked it first, the token was used twice
+	if !revoked {
+		return nil, s.revokeReusedToken(ctx, storedToken)
+	}
+	return s.issueTokens(ctx, storedToken.UserId)
+}
+
+func (s *userService) revokeReusedToken(ctx context.Context, storedToken *model.RefreshToken) error {
+	logger.L.Warn("In RefreshToken: Revoked refresh token reused, revoking all sessions", zap.String("userId", storedToken.UserId.Hex()))
+	if err := s.refreshTokenRepository.RevokeAllByUserId(ctx, storedToken.UserId); err != nil {
+		return err
+	}
+	return errors.New("invalid refresh token")
+}
+
+// Logout revokes the refresh token of a session, the access token expires on its own
+func (s *userService) Logout(refreshToken string) error {
+	ctx := context.Background()
+	storedToken, err := s.refreshTokenRepository.GetByTokenHash(ctx, utils.HashKey(refreshToken))
+	if err != nil {
+		return err
+	}
+	if storedToken == nil {
+		return errors.New("invalid refresh token")
+	}
+	_, err = s.refreshTokenRepository.Revoke(ctx, storedToken.Id)
+	return err
+}
+
+// issueTokens creates a short lived access token and a refresh token stored server side
+func (s *userService) issueTokens(ctx context.Context, userId primitive.ObjectID) (*types.TokenResponse, error) {
+	accessTokenTTL, err := time.ParseDuration(config.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	refreshTokenTTL, err := time.ParseDuration(config.RefreshTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 	// create a access token
 	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"id": existingUser.Id,
+		"id":  userId.Hex(),
+		"sub": userId.Hex(),
+		"iss": config.TokenIssuer,
+		"iat": now.Unix(),
+		"exp": now.Add(accessTokenTTL).Unix(),
 	}).SignedString([]byte(config.TokenSecret))
 	if err != nil {
 		return nil, err
 	}
-	return &accessToken, nil
+	refreshToken := utils.GenerateRefreshToken()
+	_, err = s.refreshTokenRepository.Insert(ctx, &model.RefreshToken{
+		UserId:    userId,
+		TokenHash: utils.HashKey(refreshToken),
+		ExpiresAt: now.Add(refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
 }
 
 func (s *userService) GetUser(id string) (*model.User, error) {
@@ -97,6 +237,11 @@ func (s *userService) GetUser(id string) (*model.User, error) {
 	return user, nil
 }
 
+// GetUserByUsername returns nil when no user has the username
+func (s *userService) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
+	return s.userRepository.GetByUsername(ctx, username)
+}
+
 func (s *userService) Count(ctx context.Context) (int64, error) {
 	return s.userRepository.Count(ctx)
 }
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/SwishHQ/spread/config"
+	"github.com/SwishHQ/spread/pkg"
 	"github.com/SwishHQ/spread/src/model"
 	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -46,14 +48,58 @@ func (m *MockUserRepository) GetById(ctx context.Context, id primitive.ObjectID)
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) GetByOIDCSubject(ctx context.Context, subject string) (*model.User, error) {
+	args := m.Called(ctx, subject)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockUserRepository) UpdateRoles(ctx context.Context, id primitive.ObjectID, roles []string) error {
+	args := m.Called(ctx, id, roles)
+	return args.Error(0)
+}
+
 func (m *MockUserRepository) Count(ctx context.Context) (int64, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(int64), args.Error(1)
 }
 
+// MockRefreshTokenRepository is a mock implementation of RefreshTokenRepository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Insert(ctx context.Context, refreshToken *model.RefreshToken) (*model.RefreshToken, error) {
+	args := m.Called(ctx, refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) Revoke(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeAllByUserId(ctx context.Context, userId primitive.ObjectID) error {
+	args := m.Called(ctx, userId)
+	return args.Error(0)
+}
+
 func TestNewUserService(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	assert.NotNil(t, service)
 	assert.IsType(t, &userService{}, service)
@@ -61,7 +107,7 @@ func TestNewUserService(t *testing.T) {
 
 func TestUserService_Create_Success(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	createRequest := &types.CreateUserRequest{
@@ -100,7 +146,7 @@ func TestUserService_Create_Success(t *testing.T) {
 
 func TestUserService_Create_UserAlreadyExists(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	createRequest := &types.CreateUserRequest{
@@ -129,7 +175,7 @@ func TestUserService_Create_UserAlreadyExists(t *testing.T) {
 
 func TestUserService_Create_GetByUsernameError(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	createRequest := &types.CreateUserRequest{
@@ -154,7 +200,7 @@ func TestUserService_Create_GetByUsernameError(t *testing.T) {
 
 func TestUserService_Create_InsertError(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	createRequest := &types.CreateUserRequest{
@@ -182,7 +228,8 @@ func TestUserService_Create_InsertError(t *testing.T) {
 
 func TestUserService_Login_Success(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
 
 	ctx := context.Background()
 	loginRequest := &types.LoginUserRequest{
@@ -201,6 +248,7 @@ func TestUserService_Login_Success(t *testing.T) {
 		Roles:    []string{"user"},
 	}
 	mockRepo.On("GetByUsername", ctx, "testuser").Return(existingUser, nil)
+	mockRefreshTokenRepo.On("Insert", ctx, mock.AnythingOfType("*model.RefreshToken")).Return(&model.RefreshToken{}, nil)
 
 	// Execute
 	result, err := service.Login(loginRequest)
@@ -208,21 +256,285 @@ func TestUserService_Login_Success(t *testing.T) {
 	// Assert
 	assert.NoError(t, err)
 	assert.NotNil(t, result)
-	assert.NotEmpty(t, *result)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEmpty(t, result.RefreshToken)
+	assert.Greater(t, result.ExpiresIn, int64(0))
 
-	// Verify the token is valid
-	token, parseErr := jwt.Parse(*result, func(token *jwt.Token) (interface{}, error) {
+	// Verify the token is valid and carries expiry and issuer claims
+	token, parseErr := jwt.Parse(result.AccessToken, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.TokenSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithIssuer(config.TokenIssuer), jwt.WithExpirationRequired())
 	assert.NoError(t, parseErr)
 	assert.True(t, token.Valid)
+	claims := token.Claims.(jwt.MapClaims)
+	assert.Equal(t, existingUser.Id.Hex(), claims["id"])
+	assert.NotNil(t, claims["iat"])
+
+	// Only the hash of the refresh token should be stored
+	storedToken := mockRefreshTokenRepo.Calls[0].Arguments[1].(*model.RefreshToken)
+	assert.Equal(t, existingUser.Id, storedToken.UserId)
+	assert.Equal(t, utils.HashKey(result.RefreshToken), storedToken.TokenHash)
+	assert.True(t, storedToken.ExpiresAt.After(time.Now()))
+
+	mockRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_Login_OIDCUserRejected(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
+
+	ctx := context.Background()
+	existingUser := &model.User{
+		Id:          primitive.NewObjectID(),
+		Username:    "sso.user",
+		OIDCSubject: "idp-subject",
+	}
+	mockRepo.On("GetByUsername", ctx, "sso.user").Return(existingUser, nil)
+
+	// Execute
+	result, err := service.Login(&types.LoginUserRequest{Username: "sso.user", Password: ""})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "user must sign in with single sign-on", err.Error())
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUserService_LoginWithOIDC_ProvisionsNewUser(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	identity := &pkg.OIDCIdentity{Subject: "idp-subject", Username: "jane", Groups: []string{"spread-admins"}}
+	createdUser := &model.User{Id: primitive.NewObjectID(), Username: "jane", Roles: []string{"admin"}, OIDCSubject: "idp-subject"}
+
+	mockRepo.On("GetByOIDCSubject", ctx, "idp-subject").Return(nil, nil)
+	mockRepo.On("GetByUsername", ctx, "jane").Return(nil, nil)
+	mockRepo.On("Insert", ctx, mock.MatchedBy(func(user *model.User) bool {
+		return user.Username == "jane" && user.OIDCSubject == "idp-subject" && user.Password == "" && user.Roles[0] == "admin"
+	})).Return(createdUser, nil)
+	mockRefreshTokenRepo.On("Insert", ctx, mock.AnythingOfType("*model.RefreshToken")).Return(&model.RefreshToken{}, nil)
+
+	// Execute
+	result, err := service.LoginWithOIDC(ctx, identity, []string{"admin"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotEmpty(t, result.AccessToken)
+	storedToken := mockRefreshTokenRepo.Calls[0].Arguments[1].(*model.RefreshToken)
+	assert.Equal(t, createdUser.Id, storedToken.UserId)
+
+	mockRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_LoginWithOIDC_SyncsRolesOfExistingUser(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	existingUser := &model.User{Id: primitive.NewObjectID(), Username: "jane", Roles: []string{"admin"}, OIDCSubject: "idp-subject"}
+
+	mockRepo.On("GetByOIDCSubject", ctx, "idp-subject").Return(existingUser, nil)
+	mockRepo.On("UpdateRoles", ctx, existingUser.Id, []string{"user"}).Return(nil)
+	mockRefreshTokenRepo.On("Insert", ctx, mock.AnythingOfType("*model.RefreshToken")).Return(&model.RefreshToken{}, nil)
+
+	// Execute
+	result, err := service.LoginWithOIDC(ctx, &pkg.OIDCIdentity{Subject: "idp-subject", Username: "jane"}, []string{"user"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+
+	mockRepo.AssertExpectations(t)
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_LoginWithOIDC_LocalUsernameTaken(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
+
+	ctx := context.Background()
+	localUser := &model.User{Id: primitive.NewObjectID(), Username: "jane", Password: "hash"}
+
+	mockRepo.On("GetByOIDCSubject", ctx, "idp-subject").Return(nil, nil)
+	mockRepo.On("GetByUsername", ctx, "jane").Return(localUser, nil)
+
+	// Execute
+	result, err := service.LoginWithOIDC(ctx, &pkg.OIDCIdentity{Subject: "idp-subject", Username: "jane"}, []string{"admin"})
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "username is already taken by a local user", err.Error())
+	mockRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestUserService_RefreshToken_Success(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	refreshToken := "spr_valid"
+	storedToken := &model.RefreshToken{
+		Id:        primitive.NewObjectID(),
+		UserId:    primitive.NewObjectID(),
+		TokenHash: utils.HashKey(refreshToken),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey(refreshToken)).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("Revoke", ctx, storedToken.Id).Return(true, nil)
+	mockRefreshTokenRepo.On("Insert", ctx, mock.AnythingOfType("*model.RefreshToken")).Return(&model.RefreshToken{}, nil)
+
+	// Execute
+	result, err := service.RefreshToken(refreshToken)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NotNil(t, result)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEqual(t, refreshToken, result.RefreshToken)
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_RefreshToken_Expired(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	refreshToken := "spr_expired"
+	storedToken := &model.RefreshToken{
+		Id:        primitive.NewObjectID(),
+		UserId:    primitive.NewObjectID(),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey(refreshToken)).Return(storedToken, nil)
+
+	// Execute
+	result, err := service.RefreshToken(refreshToken)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "refresh token expired", err.Error())
+	mockRefreshTokenRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_RefreshToken_ReusedRevokedTokenRevokesAllSessions(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	refreshToken := "spr_revoked"
+	revokedAt := time.Now().Add(-time.Minute)
+	storedToken := &model.RefreshToken{
+		Id:        primitive.NewObjectID(),
+		UserId:    primitive.NewObjectID(),
+		ExpiresAt: time.Now().Add(time.Hour),
+		RevokedAt: &revokedAt,
+	}
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey(refreshToken)).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("RevokeAllByUserId", ctx, storedToken.UserId).Return(nil)
+
+	// Execute
+	result, err := service.RefreshToken(refreshToken)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "invalid refresh token", err.Error())
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_RefreshToken_ConcurrentReuseRevokesAllSessions(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	refreshToken := "spr_raced"
+	// both refreshes read the token before either revoked it, this one lost the race
+	storedToken := &model.RefreshToken{
+		Id:        primitive.NewObjectID(),
+		UserId:    primitive.NewObjectID(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey(refreshToken)).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("Revoke", ctx, storedToken.Id).Return(false, nil)
+	mockRefreshTokenRepo.On("RevokeAllByUserId", ctx, storedToken.UserId).Return(nil)
+
+	// Execute
+	result, err := service.RefreshToken(refreshToken)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "invalid refresh token", err.Error())
+	mockRefreshTokenRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_RefreshToken_Unknown(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey("spr_unknown")).Return(nil, nil)
+
+	// Execute
+	result, err := service.RefreshToken("spr_unknown")
+
+	// Assert
+	assert.Error(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "invalid refresh token", err.Error())
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
+func TestUserService_Logout_Success(t *testing.T) {
+	mockRepo := &MockUserRepository{}
+	mockRefreshTokenRepo := &MockRefreshTokenRepository{}
+	service := NewUserService(mockRepo, mockRefreshTokenRepo)
+
+	ctx := context.Background()
+	refreshToken := "spr_session"
+	storedToken := &model.RefreshToken{
+		Id:        primitive.NewObjectID(),
+		UserId:    primitive.NewObjectID(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	mockRefreshTokenRepo.On("GetByTokenHash", ctx, utils.HashKey(refreshToken)).Return(storedToken, nil)
+	mockRefreshTokenRepo.On("Revoke", ctx, storedToken.Id).Return(true, nil)
+
+	// Execute
+	err := service.Logout(refreshToken)
+
+	// Assert
+	assert.NoError(t, err)
+
+	mockRefreshTokenRepo.AssertExpectations(t)
+}
+
 func TestUserService_Login_UserNotFound(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	loginRequest := &types.LoginUserRequest{
@@ -246,7 +558,7 @@ func TestUserService_Login_UserNotFound(t *testing.T) {
 
 func TestUserService_Login_InvalidPassword(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	loginRequest := &types.LoginUserRequest{
@@ -279,7 +591,7 @@ func TestUserService_Login_InvalidPassword(t *testing.T) {
 
 func TestUserService_Login_GetByUsernameError(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	loginRequest := &types.LoginUserRequest{
@@ -303,7 +615,7 @@ func TestUserService_Login_GetByUsernameError(t *testing.T) {
 
 func TestUserService_GetUser_Success(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	userID := primitive.NewObjectID()
@@ -331,7 +643,7 @@ func TestUserService_GetUser_Success(t *testing.T) {
 
 func TestUserService_GetUser_InvalidID(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	// Execute with invalid ID
 	result, err := service.GetUser("invalid-id")
@@ -345,7 +657,7 @@ func TestUserService_GetUser_InvalidID(t *testing.T) {
 
 func TestUserService_GetUser_UserNotFound(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	userID := primitive.NewObjectID()
@@ -366,7 +678,7 @@ func TestUserService_GetUser_UserNotFound(t *testing.T) {
 
 func TestUserService_GetUser_RepositoryError(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	userID := primitive.NewObjectID()
@@ -388,7 +700,7 @@ func TestUserService_GetUser_RepositoryError(t *testing.T) {
 
 func TestUserService_Count_Success(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 	expectedCount := int64(5)
@@ -408,7 +720,7 @@ func TestUserService_Count_Success(t *testing.T) {
 
 func TestUserService_Count_Error(t *testing.T) {
 	mockRepo := &MockUserRepository{}
-	service := NewUserService(mockRepo)
+	service := NewUserService(mockRepo, &MockRefreshTokenRepository{})
 
 	ctx := context.Background()
 
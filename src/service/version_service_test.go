@@ -82,6 +82,65 @@ func (m *MockVersionRepository) GetByEnvironmentAndVersion(ctx context.Context,
 	return args.Get(0).(*model.Version), args.Error(1)
 }
 
+func (m *MockVersionRepository) MarkDeleted(ctx context.Context, id primitive.ObjectID, at time.Time) (bool, error) {
+	args := m.Called(ctx, id, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVersionRepository) MarkDeletedByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, at time.Time) error {
+	args := m.Called(ctx, environmentIds, at)
+	return args.Error(0)
+}
+
+func (m *MockVersionRepository) Restore(ctx context.Context, id primitive.ObjectID, deletedAt time.Time) (bool, error) {
+	args := m.Called(ctx, id, deletedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVersionRepository) RestoreByEnvironmentIds(ctx context.Context, environmentIds []primitive.ObjectID, deletedAt time.Time) error {
+	args := m.Called(ctx, environmentIds, deletedAt)
+	return args.Error(0)
+}
+
+func (m *MockVersionRepository) GetDeletedById(ctx context.Context, id primitive.ObjectID) (*model.Version, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Version), args.Error(1)
+}
+
+func (m *MockVersionRepository) GetAllDeleted(ctx context.Context, before time.Time) ([]*model.Version, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Version), args.Error(1)
+}
+
+func (m *MockVersionRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockVersionRepository) GetAll(ctx context.Context) ([]*model.Version, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Version), args.Error(1)
+}
+
+func (m *MockVersionRepository) StartExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id, experimentId)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVersionRepository) EndExperiment(ctx context.Context, id primitive.ObjectID, experimentId primitive.ObjectID) error {
+	args := m.Called(ctx, id, experimentId)
+	return args.Error(0)
+}
+
 func TestNewVersionService(t *testing.T) {
 	mockRepo := &MockVersionRepository{}
 	service := NewVersionService(mockRepo)
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SwishHQ/spread/logger"
+	"github.com/SwishHQ/spread/pkg"
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/src/repository"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+var (
+	// how often the dispatcher looks for due deliveries
+	webhookPollInterval = 5 * time.Second
+	// how long a claimed delivery is hidden from other dispatchers, longer than the request timeout
+	webhookDeliveryLease  = time.Minute
+	webhookRequestTimeout = 10 * time.Second
+	// a delivery is given up after this many attempts, the wait between attempts doubles
+	// from webhookRetryBaseDelay up to webhookRetryMaxDelay, about 4 hours in total
+	webhookMaxAttempts    = 8
+	webhookRetryBaseDelay = 30 * time.Second
+	webhookRetryMaxDelay  = time.Hour
+	// how many deliveries the delivery log returns at most
+	webhookDeliveryLimit int64 = 100
+)
+
+type WebhookService interface {
+	EnsureIndexes(ctx context.Context) error
+	SubscribePromotedToEnabled(ctx context.Context) error
+	CreateWebhook(ctx context.Context, appId string, request *types.CreateWebhookRequest, createdBy string) (*model.Webhook, error)
+	GetWebhooksByAppId(ctx context.Context, appId string) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, webhookId primitive.ObjectID, request *types.UpdateWebhookRequest) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookId primitive.ObjectID) error
+	DeleteWebhooksByAppId(ctx context.Context, appId primitive.ObjectID) error
+	GetDeliveries(ctx context.Context, webhookId primitive.ObjectID) ([]*model.WebhookDelivery, error)
+	Publish(ctx context.Context, event string, bundle *model.Bundle, data map[string]interface{}) error
+	DispatchDue(ctx context.Context) (int, error)
+	RunDispatcher(ctx context.Context)
+}
+
+type webhookService struct {
+	appService                AppService
+	webhookRepository         repository.WebhookRepository
+	webhookDeliveryRepository repository.WebhookDeliveryRepository
+	httpClient                *http.Client
+	// lets webhooks point at http urls and private addresses, receivers inside the network
+	allowPrivateTargets bool
+}
+
+func NewWebhookService(appService AppService, webhookRepository repository.WebhookRepository, webhookDeliveryRepository repository.WebhookDeliveryRepository, allowPrivateTargets bool) WebhookService {
+	httpClient := pkg.NewPublicHTTPClient(webhookRequestTimeout)
+	if allowPrivateTargets {
+		httpClient = &http.Client{Timeout: webhookRequestTimeout}
+	}
+	return &webhookService{
+		appService:                appService,
+		webhookRepository:         webhookRepository,
+		webhookDeliveryRepository: webhookDeliveryRepository,
+		httpClient:                httpClient,
+		allowPrivateTargets:       allowPrivateTargets,
+	}
+}
+
+func (s *webhookService) EnsureIndexes(ctx context.Context) error {
+	if err := s.webhookRepository.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	return s.webhookDeliveryRepository.EnsureIndexes(ctx)
+}
+
+// SubscribePromotedToEnabled keeps webhooks that listened to release.promoted for enabled releases
+// getting them, enabling a release used to be published as release.promoted
+func (s *webhookService) SubscribePromotedToEnabled(ctx context.Context) error {
+	count, err := s.webhookRepository.AddEvent(ctx, utils.WEBHOOK_EVENT_RELEASE_PROMOTED, utils.WEBHOOK_EVENT_RELEASE_ENABLED)
+	if err != nil {
+		return err
+	}
+	logger.L.Info("In SubscribePromotedToEnabled: Subscribed webhooks to release.enabled", zap.Int64("count", count))
+	return nil
+}
+
+// CreateWebhook subscribes a URL to events of the app, a secret is generated when none is given
+func (s *webhookService) CreateWebhook(ctx context.Context, appId string, request *types.CreateWebhookRequest, createdBy string) (*model.Webhook, error) {
+	app, err := s.appService.GetAppById(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTarget(ctx, request.Url); err != nil {
+		return nil, err
+	}
+	secret := request.Secret
+	if secret == "" {
+		secret = utils.GenerateWebhookSecret()
+	}
+	events := request.Events
+	if events == nil {
+		events = []string{}
+	}
+	return s.webhookRepository.Insert(ctx, &model.Webhook{
+		AppId:     app.Id,
+		Url:       request.Url,
+		Secret:    secret,
+		Events:    events,
+		Enabled:   true,
+		CreatedBy: createdBy,
+	})
+}
+
+func (s *webhookService) GetWebhooksByAppId(ctx context.Context, appId string) ([]*model.Webhook, error) {
+	app, err := s.appService.GetAppById(ctx, appId)
+	if err != nil {
+		return nil, err
+	}
+	return s.webhookRepository.GetAllByAppId(ctx, app.Id)
+}
+
+func (s *webhookService) UpdateWebhook(ctx context.Context, webhookId primitive.ObjectID, request *types.UpdateWebhookRequest) (*model.Webhook, error) {
+	if err := s.checkTarget(ctx, request.Url); err != nil {
+		return nil, err
+	}
+	events := request.Events
+	if events == nil {
+		events = []string{}
+	}
+	webhook, err := s.webhookRepository.Update(ctx, webhookId, request.Url, events, request.Enabled)
+	if err != nil {
+		return nil, err
+	}
+	if webhook == nil {
+		return nil, errors.New("webhook not found")
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes the webhook with its delivery log, pending deliveries are dropped
+func (s *webhookService) DeleteWebhook(ctx context.Context, webhookId primitive.ObjectID) error {
+	deleted, err := s.webhookRepository.Delete(ctx, webhookId)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return errors.New("webhook not found")
+	}
+	return s.webhookDeliveryRepository.DeleteByWebhookId(ctx, webhookId)
+}
+
+// DeleteWebhooksByAppId removes the subscriptions of a purged app with their deliveries, the app
+// itself is already gone so it is not looked up
+func (s *webhookService) DeleteWebhooksByAppId(ctx context.Context, appId primitive.ObjectID) error {
+	webhooks, err := s.webhookRepository.GetAllByAppId(ctx, appId)
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		if err := s.DeleteWebhook(ctx, webhook.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) GetDeliveries(ctx context.Context, webhookId primitive.ObjectID) ([]*model.WebhookDelivery, error) {
+	return s.webhookDeliveryRepository.GetAllByWebhookId(ctx, webhookId, webhookDeliveryLimit)
+}
+
+// Publish queues the event for every webhook of the bundle's app subscribed to it,
+// the dispatcher sends them so a slow receiver never holds up a release
+func (s *webhookService) Publish(ctx context.Context, event string, bundle *model.Bundle, data map[string]interface{}) error {
+	webhooks, err := s.webhookRepository.GetSubscribed(ctx, bundle.AppId, event)
+	if err != nil {
+		return err
+	}
+	if len(webhooks) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(&types.WebhookPayload{
+		Event:         event,
+		OccurredAt:    time.Now().UTC(),
+		AppId:         bundle.AppId.Hex(),
+		EnvironmentId: bundle.EnvironmentId.Hex(),
+		VersionId:     bundle.VersionId.Hex(),
+		Release: types.WebhookRelease{
+			Id:          bundle.Id.Hex(),
+			Label:       bundle.Label,
+			SequenceId:  bundle.SequenceId,
+			Description: bundle.Description,
+			IsMandatory: bundle.IsMandatory,
+			IsValid:     bundle.IsValid,
+			CreatedBy:   bundle.CreatedBy,
+		},
+		Data: data,
+	})
+	if err != nil {
+		return err
+	}
+	for _, webhook := range webhooks {
+		_, err := s.webhookDeliveryRepository.Insert(ctx, &model.WebhookDelivery{
+			WebhookId:     webhook.Id,
+			AppId:         webhook.AppId,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        utils.WEBHOOK_DELIVERY_PENDING,
+			NextAttemptAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DispatchDue sends every delivery that is due and returns how many were attempted. Cancelling
+// the context stops it between deliveries, the one in flight is finished and recorded
+func (s *webhookService) DispatchDue(ctx context.Context) (int, error) {
+	attempted := 0
+	for ctx.Err() == nil {
+		delivery, err := s.webhookDeliveryRepository.ClaimDue(ctx, time.Now(), webhookDeliveryLease)
+		if err != nil {
+			return attempted, err
+		}
+		if delivery == nil {
+			return attempted, nil
+		}
+		attempted++
+		s.deliver(context.WithoutCancel(ctx), delivery)
+	}
+	return attempted, nil
+}
+
+// RunDispatcher sends due deliveries until the context is cancelled
+func (s *webhookService) RunDispatcher(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.DispatchDue(ctx); err != nil {
+			logger.L.Error("In RunDispatcher: Error dispatching webhook deliveries", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *webhookService) deliver(ctx context.Context, delivery *model.WebhookDelivery) {
+	responseStatus, err := s.send(ctx, delivery)
+	status := utils.WEBHOOK_DELIVERY_SUCCEEDED
+	errorMessage := ""
+	nextAttemptAt := delivery.NextAttemptAt
+	if err != nil {
+		errorMessage = err.Error()
+		status = utils.WEBHOOK_DELIVERY_FAILED
+		if delivery.Attempts < webhookMaxAttempts {
+			status = utils.WEBHOOK_DELIVERY_PENDING
+			nextAttemptAt = time.Now().Add(webhookRetryDelay(delivery.Attempts))
+		}
+		logger.L.Warn("In deliver: Webhook delivery failed", zap.String("deliveryId", delivery.Id.Hex()), zap.Int("attempts", delivery.Attempts), zap.Error(err))
+	}
+	if err := s.webhookDeliveryRepository.RecordAttempt(ctx, delivery.Id, status, responseStatus, errorMessage, nextAttemptAt); err != nil {
+		logger.L.Error("In deliver: Error recording webhook delivery attempt", zap.String("deliveryId", delivery.Id.Hex()), zap.Error(err))
+	}
+}
+
+// send POSTs the stored payload, any response outside 2xx is a failure
+func (s *webhookService) send(ctx context.Context, delivery *model.WebhookDelivery) (int, error) {
+	webhook, err := s.webhookRepository.GetById(ctx, delivery.WebhookId)
+	if err != nil {
+		return 0, err
+	}
+	if webhook == nil {
+		return 0, errors.New("webhook was deleted")
+	}
+	// webhooks created before targets were checked, or whose host moved to a private address
+	if err := s.checkTarget(ctx, webhook.Url); err != nil {
+		return 0, err
+	}
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "spread-webhooks")
+	request.Header.Set("X-Spread-Event", delivery.Event)
+	request.Header.Set("X-Spread-Delivery", delivery.Id.Hex())
+	request.Header.Set("X-Spread-Signature", utils.SignWebhookPayload(webhook.Secret, body))
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// checkTarget refuses urls the dispatcher must not send to, so the server cannot be made to reach
+// into its own network
+func (s *webhookService) checkTarget(ctx context.Context, url string) error {
+	if s.allowPrivateTargets {
+		return nil
+	}
+	if err := pkg.CheckPublicURL(ctx, url); err != nil {
+		return errors.New("webhook url is not allowed: " + err.Error())
+	}
+	return nil
+}
+
+// webhookRetryDelay is the wait after the given failed attempt, 30s, 1m, 2m, ... capped at an hour
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBaseDelay
+	for i := 1; i < attempts && delay < webhookRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMaxDelay {
+		return webhookRetryMaxDelay
+	}
+	return delay
+}
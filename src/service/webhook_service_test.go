@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SwishHQ/spread/src/model"
+	"github.com/SwishHQ/spread/types"
+	"github.com/SwishHQ/spread/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MockWebhookService is a mock implementation of WebhookService
+type MockWebhookService struct {
+	mock.Mock
+}
+
+func (m *MockWebhookService) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) SubscribePromotedToEnabled(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) CreateWebhook(ctx context.Context, appId string, request *types.CreateWebhookRequest, createdBy string) (*model.Webhook, error) {
+	args := m.Called(ctx, appId, request, createdBy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) GetWebhooksByAppId(ctx context.Context, appId string) ([]*model.Webhook, error) {
+	args := m.Called(ctx, appId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) UpdateWebhook(ctx context.Context, webhookId primitive.ObjectID, request *types.UpdateWebhookRequest) (*model.Webhook, error) {
+	args := m.Called(ctx, webhookId, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookService) DeleteWebhook(ctx context.Context, webhookId primitive.ObjectID) error {
+	args := m.Called(ctx, webhookId)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) GetDeliveries(ctx context.Context, webhookId primitive.ObjectID) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookService) Publish(ctx context.Context, event string, bundle *model.Bundle, data map[string]interface{}) error {
+	args := m.Called(ctx, event, bundle, data)
+	return args.Error(0)
+}
+
+func (m *MockWebhookService) DispatchDue(ctx context.Context) (int, error) {
+	args := m.Called(ctx)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockWebhookService) RunDispatcher(ctx context.Context) {
+	m.Called(ctx)
+}
+
+func (m *MockWebhookService) DeleteWebhooksByAppId(ctx context.Context, appId primitive.ObjectID) error {
+	args := m.Called(ctx, appId)
+	return args.Error(0)
+}
+
+// newPublishingWebhookService accepts any event, for tests that do not look at webhooks
+func newPublishingWebhookService() *MockWebhookService {
+	mockWebhookService := &MockWebhookService{}
+	mockWebhookService.On("Publish", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	return mockWebhookService
+}
+
+// MockWebhookRepository is a mock implementation of WebhookRepository
+type MockWebhookRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockWebhookRepository) Insert(ctx context.Context, webhook *model.Webhook) (*model.Webhook, error) {
+	args := m.Called(ctx, webhook)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetById(ctx context.Context, id primitive.ObjectID) (*model.Webhook, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetAllByAppId(ctx context.Context, appId primitive.ObjectID) ([]*model.Webhook, error) {
+	args := m.Called(ctx, appId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) GetSubscribed(ctx context.Context, appId primitive.ObjectID, event string) ([]*model.Webhook, error) {
+	args := m.Called(ctx, appId, event)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) AddEvent(ctx context.Context, subscribedTo string, event string) (int64, error) {
+	args := m.Called(ctx, subscribedTo, event)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockWebhookRepository) Update(ctx context.Context, id primitive.ObjectID, url string, events []string, enabled bool) (*model.Webhook, error) {
+	args := m.Called(ctx, id, url, events, enabled)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Webhook), args.Error(1)
+}
+
+func (m *MockWebhookRepository) Delete(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+// MockWebhookDeliveryRepository is a mock implementation of WebhookDeliveryRepository
+type MockWebhookDeliveryRepository struct {
+	mock.Mock
+}
+
+func (m *MockWebhookDeliveryRepository) EnsureIndexes(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockWebhookDeliveryRepository) Insert(ctx context.Context, delivery *model.WebhookDelivery) (*model.WebhookDelivery, error) {
+	args := m.Called(ctx, delivery)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) ClaimDue(ctx context.Context, now time.Time, lease time.Duration) (*model.WebhookDelivery, error) {
+	args := m.Called(ctx, now, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, status string, responseStatus int, errorMessage string, nextAttemptAt time.Time) error {
+	args := m.Called(ctx, id, status, responseStatus, errorMessage, nextAttemptAt)
+	return args.Error(0)
+}
+
+func (m *MockWebhookDeliveryRepository) GetAllByWebhookId(ctx context.Context, webhookId primitive.ObjectID, limit int64) ([]*model.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookId, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockWebhookDeliveryRepository) DeleteByWebhookId(ctx context.Context, webhookId primitive.ObjectID) error {
+	args := m.Called(ctx, webhookId)
+	return args.Error(0)
+}
+
+func TestWebhookService_CreateWebhook_GeneratesSecret(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockWebhookRepo := &MockWebhookRepository{}
+	service := NewWebhookService(mockAppService, mockWebhookRepo, &MockWebhookDeliveryRepository{}, false)
+
+	ctx := context.Background()
+	app := &model.App{Id: primitive.NewObjectID()}
+	mockAppService.On("GetAppById", ctx, app.Id.Hex()).Return(app, nil)
+	var webhook *model.Webhook
+	mockWebhookRepo.On("Insert", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		webhook = args.Get(1).(*model.Webhook)
+	}).Return(&model.Webhook{}, nil)
+
+	// Execute
+	_, err := service.CreateWebhook(ctx, app.Id.Hex(), &types.CreateWebhookRequest{Url: "https://hooks.example.com/spread"}, "admin")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, app.Id, webhook.AppId)
+	assert.True(t, webhook.Enabled)
+	assert.True(t, strings.HasPrefix(webhook.Secret, utils.WEBHOOK_SECRET_PREFIX))
+	assert.Equal(t, []string{}, webhook.Events)
+}
+
+func TestWebhookService_Publish_QueuesDeliveryPerSubscription(t *testing.T) {
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, false)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), AppId: primitive.NewObjectID(), Label: "v1x2", IsValid: true}
+	webhooks := []*model.Webhook{{Id: primitive.NewObjectID(), AppId: bundle.AppId}, {Id: primitive.NewObjectID(), AppId: bundle.AppId}}
+	mockWebhookRepo.On("GetSubscribed", ctx, bundle.AppId, "release.promoted").Return(webhooks, nil)
+	var deliveries []*model.WebhookDelivery
+	mockDeliveryRepo.On("Insert", ctx, mock.Anything).Run(func(args mock.Arguments) {
+		deliveries = append(deliveries, args.Get(1).(*model.WebhookDelivery))
+	}).Return(&model.WebhookDelivery{}, nil)
+
+	// Execute
+	err := service.Publish(ctx, "release.promoted", bundle, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 2)
+	assert.Equal(t, webhooks[1].Id, deliveries[1].WebhookId)
+	assert.Equal(t, "pending", deliveries[0].Status)
+	var payload types.WebhookPayload
+	assert.NoError(t, json.Unmarshal([]byte(deliveries[0].Payload), &payload))
+	assert.Equal(t, "release.promoted", payload.Event)
+	assert.Equal(t, "v1x2", payload.Release.Label)
+	assert.Equal(t, bundle.AppId.Hex(), payload.AppId)
+}
+
+func TestWebhookService_Publish_NoSubscriptions(t *testing.T) {
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, false)
+
+	ctx := context.Background()
+	bundle := &model.Bundle{Id: primitive.NewObjectID(), AppId: primitive.NewObjectID()}
+	mockWebhookRepo.On("GetSubscribed", ctx, bundle.AppId, "release.created").Return([]*model.Webhook{}, nil)
+
+	// Execute
+	err := service.Publish(ctx, "release.created", bundle, nil)
+
+	// Assert
+	assert.NoError(t, err)
+	mockDeliveryRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func TestWebhookService_DispatchDue_SendsSignedPayload(t *testing.T) {
+	var signature, event string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Spread-Signature")
+		event = r.Header.Get("X-Spread-Event")
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, true)
+
+	ctx := context.Background()
+	webhook := &model.Webhook{Id: primitive.NewObjectID(), Url: server.URL, Secret: "spw_test-secret"}
+	delivery := &model.WebhookDelivery{Id: primitive.NewObjectID(), WebhookId: webhook.Id, Event: "release.created", Payload: `{"event":"release.created"}`, Attempts: 1}
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(delivery, nil).Once()
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(nil, nil).Once()
+	mockWebhookRepo.On("GetById", mock.Anything, webhook.Id).Return(webhook, nil)
+	mockDeliveryRepo.On("RecordAttempt", mock.Anything, delivery.Id, "succeeded", http.StatusNoContent, "", mock.Anything).Return(nil)
+
+	// Execute
+	attempted, err := service.DispatchDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+	assert.Equal(t, "release.created", event)
+	assert.Equal(t, delivery.Payload, string(body))
+	assert.Equal(t, utils.SignWebhookPayload("spw_test-secret", body), signature)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_DispatchDue_RetriesWithBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, true)
+
+	ctx := context.Background()
+	webhook := &model.Webhook{Id: primitive.NewObjectID(), Url: server.URL, Secret: "spw_test-secret"}
+	delivery := &model.WebhookDelivery{Id: primitive.NewObjectID(), WebhookId: webhook.Id, Payload: "{}", Attempts: 3}
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(delivery, nil).Once()
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(nil, nil).Once()
+	mockWebhookRepo.On("GetById", mock.Anything, webhook.Id).Return(webhook, nil)
+	var nextAttemptAt time.Time
+	mockDeliveryRepo.On("RecordAttempt", mock.Anything, delivery.Id, "pending", http.StatusBadGateway, "webhook responded with status 502", mock.Anything).
+		Run(func(args mock.Arguments) { nextAttemptAt = args.Get(5).(time.Time) }).
+		Return(nil)
+
+	// Execute
+	before := time.Now()
+	_, err := service.DispatchDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.WithinDuration(t, before.Add(2*time.Minute), nextAttemptAt, 5*time.Second)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestWebhookService_DispatchDue_GivesUpAfterLastAttempt(t *testing.T) {
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, false)
+
+	ctx := context.Background()
+	delivery := &model.WebhookDelivery{Id: primitive.NewObjectID(), WebhookId: primitive.NewObjectID(), Payload: "{}", Attempts: webhookMaxAttempts}
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(delivery, nil).Once()
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(nil, nil).Once()
+	mockWebhookRepo.On("GetById", mock.Anything, delivery.WebhookId).Return(nil, errors.New("connection refused"))
+	mockDeliveryRepo.On("RecordAttempt", mock.Anything, delivery.Id, "failed", 0, "connection refused", mock.Anything).Return(nil)
+
+	// Execute
+	_, err := service.DispatchDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	mockDeliveryRepo.AssertExpectations(t)
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+	assert.Equal(t, time.Hour, webhookRetryDelay(20))
+}
+
+func TestWebhookService_CreateWebhook_RejectsPrivateTargets(t *testing.T) {
+	mockAppService := &MockAppService{}
+	mockWebhookRepo := &MockWebhookRepository{}
+	service := NewWebhookService(mockAppService, mockWebhookRepo, &MockWebhookDeliveryRepository{}, false)
+
+	ctx := context.Background()
+	app := &model.App{Id: primitive.NewObjectID()}
+	mockAppService.On("GetAppById", ctx, app.Id.Hex()).Return(app, nil)
+
+	// Execute and Assert
+	for _, url := range []string{
+		"http://hooks.example.com/spread",
+		"https://127.0.0.1/spread",
+		"https://localhost:8080/spread",
+		"https://10.0.0.5/spread",
+		"https://192.168.1.10/spread",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]/spread",
+		"https://[fd00:ec2::254]/spread",
+	} {
+		_, err := service.CreateWebhook(ctx, app.Id.Hex(), &types.CreateWebhookRequest{Url: url}, "admin")
+		assert.Error(t, err, url)
+	}
+	mockWebhookRepo.AssertNotCalled(t, "Insert", mock.Anything, mock.Anything)
+}
+
+func TestWebhookService_UpdateWebhook_RejectsPrivateTargets(t *testing.T) {
+	mockWebhookRepo := &MockWebhookRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, &MockWebhookDeliveryRepository{}, false)
+
+	// Execute
+	_, err := service.UpdateWebhook(context.Background(), primitive.NewObjectID(), &types.UpdateWebhookRequest{Url: "https://172.16.0.1/spread", Enabled: true})
+
+	// Assert
+	assert.EqualError(t, err, "webhook url is not allowed: 172.16.0.1 is not a public address")
+	mockWebhookRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWebhookService_DispatchDue_RefusesPrivateTargets(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	mockWebhookRepo := &MockWebhookRepository{}
+	mockDeliveryRepo := &MockWebhookDeliveryRepository{}
+	service := NewWebhookService(&MockAppService{}, mockWebhookRepo, mockDeliveryRepo, false)
+
+	// stored before targets were checked
+	ctx := context.Background()
+	webhook := &model.Webhook{Id: primitive.NewObjectID(), Url: server.URL, Secret: "spw_test-secret"}
+	delivery := &model.WebhookDelivery{Id: primitive.NewObjectID(), WebhookId: webhook.Id, Payload: "{}", Attempts: webhookMaxAttempts}
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(delivery, nil).Once()
+	mockDeliveryRepo.On("ClaimDue", ctx, mock.Anything, webhookDeliveryLease).Return(nil, nil).Once()
+	mockWebhookRepo.On("GetById", mock.Anything, webhook.Id).Return(webhook, nil)
+	mockDeliveryRepo.On("RecordAttempt", mock.Anything, delivery.Id, "failed", 0, "webhook url is not allowed: url must use https", mock.Anything).Return(nil)
+
+	// Execute
+	_, err := service.DispatchDue(ctx)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 0, requests)
+	mockDeliveryRepo.AssertExpectations(t)
+}
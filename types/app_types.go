@@ -4,3 +4,7 @@ type CreateAppRequest struct {
 	AppName string `json:"appName" validate:"required"`
 	OS      string `json:"os" validate:"required"`
 }
+
+type RenameAppRequest struct {
+	AppName string `json:"appName" validate:"required"`
+}
@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type CreateNewBundleRequest struct {
 	AppName      string `json:"appName" validate:"required"`
 	Environment  string `json:"environment" validate:"required"`
@@ -8,6 +10,16 @@ type CreateNewBundleRequest struct {
 	AppVersion   string `json:"appVersion" validate:"required"`
 	Size         int64  `json:"size" validate:"required"`
 	Hash         string `json:"hash" validate:"required"`
+	// release at this time instead of now
+	ScheduledAt *time.Time `json:"scheduledAt"`
+	// lets the release through a freeze window, only honored for admins
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+	// limits the release to some devices
+	Targeting *TargetingRequest `json:"targeting"`
+	// the source map uploaded with the bundle
+	SourceMapFile string `json:"sourceMapFile"`
+	// devices are forced to install the release
+	IsMandatory bool `json:"isMandatory"`
 }
 
 type RollbackRequest struct {
@@ -15,3 +27,61 @@ type RollbackRequest struct {
 	EnvironmentId string `json:"environmentId" validate:"required"`
 	VersionId     string `json:"versionId" validate:"required"`
 }
+
+type UpdateDescriptionRequest struct {
+	Description string `json:"description"`
+}
+
+// PromoteBundleRequest copies a bundle to another environment of its app
+type PromoteBundleRequest struct {
+	EnvironmentId string `json:"environmentId" validate:"required"`
+	// the description of the promoted bundle when empty
+	Description          string `json:"description"`
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+type ToggleActiveRequest struct {
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+type ScheduleBundleRequest struct {
+	ScheduledAt          time.Time `json:"scheduledAt" validate:"required"`
+	OverrideFreezeReason string    `json:"overrideFreezeReason"`
+}
+
+// FreezeOverride is an admin releasing during a freeze window
+type FreezeOverride struct {
+	By     string
+	Reason string
+}
+
+type ApprovalDecisionRequest struct {
+	Comment              string `json:"comment"`
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+type RejectBundleRequest struct {
+	Comment string `json:"comment" validate:"required"`
+}
+
+type ApprovalCommentRequest struct {
+	Text string `json:"text" validate:"required"`
+}
+
+// TargetingRequest sets who gets a bundle, an empty allowlist and no rules remove the targeting
+type TargetingRequest struct {
+	Allowlist []string               `json:"allowlist"`
+	Rules     []TargetingRuleRequest `json:"rules" validate:"dive"`
+}
+
+type UpdateTargetingRequest struct {
+	TargetingRequest
+	// lets the targeting of the current bundle change during a freeze window, only honored for admins
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+type TargetingRuleRequest struct {
+	Attribute string   `json:"attribute" validate:"required,oneof=os_version locale tag"`
+	Operator  string   `json:"operator" validate:"required,oneof=in not_in gte lte"`
+	Values    []string `json:"values" validate:"required,min=1"`
+}
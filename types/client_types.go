@@ -15,6 +15,15 @@ type UpdateInfo struct {
 	Rollout                int    `json:"rollout"`
 }
 
+// DeviceAttributes are what the SDK tells about the device on an update check, release targeting
+// rules are evaluated against them
+type DeviceAttributes struct {
+	ClientUniqueId string
+	OsVersion      string
+	Locale         string
+	Tags           []string
+}
+
 type ReportStatusDeployRequest struct {
 	AppVersion                string  `json:"app_version"`
 	DeploymentKey             string  `json:"deployment_key"`
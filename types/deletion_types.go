@@ -0,0 +1,31 @@
+package types
+
+import "time"
+
+// DeletionImpact counts what deleting an app, environment or version takes with it
+type DeletionImpact struct {
+	Environments int `json:"environments"`
+	Versions     int `json:"versions"`
+	Bundles      int `json:"bundles"`
+}
+
+// DeletionConfirmation is returned before a delete, the token must be sent back to delete
+type DeletionConfirmation struct {
+	Resource          string         `json:"resource"`
+	Id                string         `json:"id"`
+	Name              string         `json:"name"`
+	Impact            DeletionImpact `json:"impact"`
+	ConfirmationToken string         `json:"confirmationToken"`
+	ExpiresAt         time.Time      `json:"expiresAt"`
+}
+
+// DeletedResource is an app, environment or version that can still be restored
+type DeletedResource struct {
+	Resource      string    `json:"resource"`
+	Id            string    `json:"id"`
+	Name          string    `json:"name"`
+	AppId         string    `json:"appId,omitempty"`
+	EnvironmentId string    `json:"environmentId,omitempty"`
+	DeletedAt     time.Time `json:"deletedAt"`
+	PurgeAt       time.Time `json:"purgeAt"`
+}
@@ -4,3 +4,37 @@ type CreateEnvironmentRequest struct {
 	EnvironmentName string `json:"environmentName" validate:"required"`
 	AppName         string `json:"appName" validate:"required"`
 }
+
+type RotateEnvironmentKeyRequest struct {
+	// how long the previous key keeps working, defaults to DEPLOYMENT_KEY_GRACE_PERIOD
+	GracePeriodHours *int `json:"gracePeriodHours" validate:"omitempty,gte=0"`
+}
+
+type UpdateRollbackPolicyRequest struct {
+	Enabled bool `json:"enabled"`
+	// percent of install attempts that may fail, e.g. 5
+	MaxFailureRate float64 `json:"maxFailureRate" validate:"gt=0,lte=100"`
+	MinInstalls    int64   `json:"minInstalls" validate:"gte=1"`
+	WindowMinutes  int     `json:"windowMinutes" validate:"gte=1"`
+}
+
+type RenameEnvironmentRequest struct {
+	EnvironmentName string `json:"environmentName" validate:"required"`
+}
+
+type FreezeWindowRequest struct {
+	Name     string `json:"name"`
+	Start    string `json:"start" validate:"required"`
+	End      string `json:"end" validate:"required"`
+	Timezone string `json:"timezone"`
+}
+
+type UpdateFreezeWindowsRequest struct {
+	Windows []FreezeWindowRequest `json:"windows" validate:"dive"`
+}
+
+type UpdateApprovalPolicyRequest struct {
+	Required bool `json:"required"`
+	// role an approver needs, defaults to admin
+	ApproverRole string `json:"approverRole"`
+}
@@ -0,0 +1,34 @@
+package types
+
+type StartExperimentRequest struct {
+	VersionId string                     `json:"versionId" validate:"required"`
+	Name      string                     `json:"name" validate:"required"`
+	Variants  []ExperimentVariantRequest `json:"variants" validate:"required,min=2,dive"`
+	// lets the experiment start during a freeze window, only honored for admins
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+// ExperimentVariantRequest is a bundle of the version and the percentage of devices that get it,
+// the weights of an experiment add up to 100
+type ExperimentVariantRequest struct {
+	BundleId string `json:"bundleId" validate:"required"`
+	Weight   int    `json:"weight" validate:"required,min=1,max=99"`
+}
+
+type ConcludeExperimentRequest struct {
+	WinnerBundleId string `json:"winnerBundleId" validate:"required"`
+	// lets the winner go out during a freeze window, only honored for admins
+	OverrideFreezeReason string `json:"overrideFreezeReason"`
+}
+
+// ExperimentVariantResult is how a variant did since the experiment started
+type ExperimentVariantResult struct {
+	BundleId    string  `json:"bundleId"`
+	Label       string  `json:"label"`
+	Weight      int     `json:"weight"`
+	Downloads   int     `json:"downloads"`
+	Active      int     `json:"active"`
+	Installs    int64   `json:"installs"`
+	Failures    int64   `json:"failures"`
+	FailureRate float64 `json:"failureRate"`
+}
@@ -0,0 +1,28 @@
+package types
+
+import "time"
+
+// GCReport lists what a garbage collection deleted, or would delete on a dry run
+type GCReport struct {
+	DryRun         bool       `json:"dryRun"`
+	Orphans        []GCObject `json:"orphans"`
+	Superseded     []GCBundle `json:"superseded"`
+	ReclaimedBytes int64      `json:"reclaimedBytes"`
+}
+
+// GCObject is an uploaded zip no bundle points at
+type GCObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// GCBundle is a bundle past the retention policy of its version
+type GCBundle struct {
+	Id           string `json:"id"`
+	VersionId    string `json:"versionId"`
+	Label        string `json:"label"`
+	SequenceId   int64  `json:"sequenceId"`
+	DownloadFile string `json:"downloadFile"`
+	Size         int64  `json:"size"`
+}
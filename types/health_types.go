@@ -0,0 +1,14 @@
+package types
+
+// HealthReport is the body of /readyz, Status is "ok" only when every check is
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks"`
+}
+
+type HealthCheck struct {
+	Status     string      `json:"status"`
+	DurationMs int64       `json:"durationMs"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+}
@@ -0,0 +1,29 @@
+package types
+
+import "time"
+
+type MetricSeriesRequest struct {
+	EnvironmentId string `query:"environmentId" validate:"required"`
+	Label         string `query:"label"`
+	Granularity   string `query:"granularity" validate:"omitempty,oneof=hour day"`
+	From          string `query:"from"`
+	To            string `query:"to"`
+}
+
+type MetricPoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Downloads     int       `json:"downloads"`
+	Installs      int       `json:"installs"`
+	Failures      int       `json:"failures"`
+	Rollbacks     int       `json:"rollbacks"`
+	ActiveDevices int       `json:"activeDevices"`
+}
+
+type MetricSeriesResponse struct {
+	EnvironmentId string        `json:"environmentId"`
+	Label         string        `json:"label,omitempty"`
+	Granularity   string        `json:"granularity"`
+	From          time.Time     `json:"from"`
+	To            time.Time     `json:"to"`
+	Points        []MetricPoint `json:"points"`
+}
@@ -0,0 +1,26 @@
+package types
+
+type SymbolicateRequest struct {
+	Label      string `json:"label" validate:"required"`
+	StackTrace string `json:"stackTrace" validate:"required"`
+}
+
+// SymbolicatedFrame is a frame of the stack trace, lines are 1-based and columns 0-based like
+// metro-symbolicate reads and writes them
+type SymbolicatedFrame struct {
+	Raw            string `json:"raw"`
+	File           string `json:"file"`
+	Line           int    `json:"line"`
+	Column         int    `json:"column"`
+	Symbolicated   bool   `json:"symbolicated"`
+	Source         string `json:"source,omitempty"`
+	OriginalLine   int    `json:"originalLine,omitempty"`
+	OriginalColumn int    `json:"originalColumn,omitempty"`
+	Name           string `json:"name,omitempty"`
+}
+
+type SymbolicateResponse struct {
+	Label      string              `json:"label"`
+	StackTrace string              `json:"stackTrace"`
+	Frames     []SymbolicatedFrame `json:"frames"`
+}
@@ -0,0 +1,37 @@
+package types
+
+import "time"
+
+// the event filter of a webhook, empty subscribes to every event
+type CreateWebhookRequest struct {
+	Url    string   `json:"url" validate:"required,url"`
+	Secret string   `json:"secret" validate:"omitempty,min=16"`
+	Events []string `json:"events" validate:"dive,oneof=release.created release.promoted release.enabled release.rolled_back release.disabled release.mandatory_changed release.auto_rollback release.approval_requested release.approved release.rejected"`
+}
+
+type UpdateWebhookRequest struct {
+	Url     string   `json:"url" validate:"required,url"`
+	Events  []string `json:"events" validate:"dive,oneof=release.created release.promoted release.enabled release.rolled_back release.disabled release.mandatory_changed release.auto_rollback release.approval_requested release.approved release.rejected"`
+	Enabled bool     `json:"enabled"`
+}
+
+// WebhookPayload is the JSON body POSTed to a webhook
+type WebhookPayload struct {
+	Event         string                 `json:"event"`
+	OccurredAt    time.Time              `json:"occurredAt"`
+	AppId         string                 `json:"appId"`
+	EnvironmentId string                 `json:"environmentId"`
+	VersionId     string                 `json:"versionId"`
+	Release       WebhookRelease         `json:"release"`
+	Data          map[string]interface{} `json:"data,omitempty"`
+}
+
+type WebhookRelease struct {
+	Id          string `json:"id"`
+	Label       string `json:"label"`
+	SequenceId  int64  `json:"sequenceId"`
+	Description string `json:"description"`
+	IsMandatory bool   `json:"isMandatory"`
+	IsValid     bool   `json:"isValid"`
+	CreatedBy   string `json:"createdBy"`
+}
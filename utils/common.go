@@ -2,12 +2,15 @@ package utils
 
 import (
 	"archive/zip"
+	"crypto/hmac"
 	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
+	"math/big"
 	"os"
 	"path/filepath"
 	"sort"
@@ -17,20 +20,129 @@ import (
 )
 
 // generate a random auth key
-// how it works:
-// 1. seed the random number generator
-// 2. create a character array
-// 3. create a byte array
-// 4. for each element in the byte array, generate a random character
-// 5. return the byte array as a string
+// example: "spk_3kT9qLm..."
 func GenerateAuthKey() string {
-	rand.Seed(time.Now().UnixNano())
-	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 32)
+	return generateSecureKey(AUTH_KEY_PREFIX)
+}
+
+// generate a random deployment key for an environment
+// example: "spd_Xa82LpQ..."
+func GenerateDeploymentKey() string {
+	return generateSecureKey(DEPLOYMENT_KEY_PREFIX)
+}
+
+// generate a random refresh token for a dashboard session
+// example: "spr_9dKa0Lz..."
+func GenerateRefreshToken() string {
+	return generateSecureKey(REFRESH_TOKEN_PREFIX)
+}
+
+// generate a random secret webhook payloads are signed with
+// example: "spw_4fGh2Kq..."
+func GenerateWebhookSecret() string {
+	return generateSecureKey(WEBHOOK_SECRET_PREFIX)
+}
+
+// how it works:
+// 1. read SECURE_KEY_LENGTH random indexes into the character array from crypto/rand
+// 2. prepend the prefix so the kind of key is recognisable (and greppable by secret scanners)
+// crypto/rand only fails when the OS entropy source is broken, in which case we must not hand out keys
+func generateSecureKey(prefix string) string {
+	chars := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	max := big.NewInt(int64(len(chars)))
+	b := make([]byte, SECURE_KEY_LENGTH)
 	for i := range b {
-		b[i] = chars[rand.Intn(len(chars))]
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			log.Panic("failed to read random bytes: ", err)
+		}
+		b[i] = chars[n.Int64()]
+	}
+	return prefix + string(b)
+}
+
+// AUTH_KEY_PREFIX_LENGTH is the number of leading characters of an auth key
+// kept in plaintext so a key can be identified without revealing it
+const AUTH_KEY_PREFIX_LENGTH = 12
+
+// given a secret key (auth key, refresh token), return the sha256 hash that is stored in place of the key
+// example: "ABC" -> "b5d4045c3f466fa91fe2cc6abe79232a1a57cdf104f7a26e716e0a1e2789df78"
+// our keys are random and long, so a fast unsalted hash is enough and lets us look keys up by hash
+func HashKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// given a webhook secret and the payload body, return the value of the X-Spread-Signature header
+// example: "sha256=5d41402abc4b2a76b9719d911017c592..."
+// receivers recompute the HMAC over the raw body with their copy of the secret and compare
+func SignWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// given a secret, what is confirmed ("app:<id>") and when the confirmation expires, return a token
+// that proves the caller saw what a destructive action will do before asking for it
+// example: "1735689600.9f86d081884c7d659a2feaa0c55ad015..."
+func SignConfirmationToken(secret string, subject string, expiresAt time.Time) string {
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(subject + "|" + expiry))
+	return expiry + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyConfirmationToken reports whether the token was signed for the subject and has not expired
+func VerifyConfirmationToken(secret string, subject string, token string, now time.Time) bool {
+	expiry, _, found := strings.Cut(token, ".")
+	if !found {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+	expected := SignConfirmationToken(secret, subject, time.Unix(expiresAt, 0))
+	return hmac.Equal([]byte(token), []byte(expected))
+}
+
+// given a day and a time of the week such as "Fri 16:00", return the minutes since Sunday 00:00
+// example: "Mon 09:00" -> 1980
+func ParseWeeklyTime(value string) (int, error) {
+	day, clock, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found {
+		return 0, fmt.Errorf("invalid time %q, expected a day and a time such as Fri 16:00", value)
+	}
+	weekday := -1
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(day, d.String()[:3]) || strings.EqualFold(day, d.String()) {
+			weekday = int(d)
+		}
+	}
+	at, err := time.Parse("15:04", strings.TrimSpace(clock))
+	if weekday < 0 || err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected a day and a time such as Fri 16:00", value)
 	}
-	return string(b)
+	return weekday*24*60 + at.Hour()*60 + at.Minute(), nil
+}
+
+// HasRole reports whether role is one of roles
+func HasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// given an auth key, return its non-secret display prefix
+// example: "spk_ABCDEFGHIJKL" -> "spk_ABCDEFGH"
+func AuthKeyPrefix(key string) string {
+	if len(key) <= AUTH_KEY_PREFIX_LENGTH {
+		return key
+	}
+	return key[:AUTH_KEY_PREFIX_LENGTH]
 }
 
 // Zip function creates a zip file from a given source directory.
@@ -81,6 +193,54 @@ func Zip(src_dir string, zip_file_name string) {
 	})
 }
 
+// Unzip extracts a zip file into dest_dir, entries that would land outside of it are refused
+func Unzip(zip_file_name string, dest_dir string) error {
+	archive, err := zip.OpenReader(zip_file_name)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	dest, err := filepath.Abs(dest_dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range archive.File {
+		path := filepath.Join(dest, filepath.FromSlash(strings.ReplaceAll(entry.Name, "\\", "/")))
+		if path != dest && !strings.HasPrefix(path, dest+string(os.PathSeparator)) {
+			return fmt.Errorf("zip entry %s is outside of the archive", entry.Name)
+		}
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, os.ModePerm); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		if err := extractZipEntry(entry, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipEntry(entry *zip.File, path string) error {
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, entry.Mode().Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
+
 // given a version string, return a number
 // example: 1.2.3 -> 10203
 // how it works:
@@ -161,3 +321,12 @@ func GetBaseBucketUrl(env string) string {
 	}
 	return DEV_BASE_BUCKET_URL
 }
+
+// returns the start of the hour or day (UTC) that contains t, metrics are bucketed by it
+func MetricBucketStart(granularity string, t time.Time) time.Time {
+	t = t.UTC()
+	if granularity == METRIC_GRANULARITY_DAY {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
@@ -6,3 +6,114 @@ var (
 )
 
 var BASE_BUNDLE_SEQUENCE_ID = 1
+
+// auth keys authorize releases, deployment keys identify an environment to the SDK
+// and refresh tokens renew dashboard sessions
+var (
+	AUTH_KEY_PREFIX       = "spk_"
+	DEPLOYMENT_KEY_PREFIX = "spd_"
+	REFRESH_TOKEN_PREFIX  = "spr_"
+	WEBHOOK_SECRET_PREFIX = "spw_"
+	SECURE_KEY_LENGTH     = 40
+)
+
+// deployment statuses reported by the SDK
+var (
+	DEPLOYMENT_SUCCEEDED = "DeploymentSucceeded"
+	DEPLOYMENT_FAILED    = "DeploymentFailed"
+)
+
+// release metrics, counted into hourly and daily buckets of a bundle
+var (
+	METRIC_DOWNLOADS        = "downloads"
+	METRIC_INSTALLS         = "installs"
+	METRIC_FAILURES         = "failures"
+	METRIC_ROLLBACKS        = "rollbacks"
+	METRIC_GRANULARITY_HOUR = "hour"
+	METRIC_GRANULARITY_DAY  = "day"
+)
+
+// audit events, actions are "<resource>.<what happened>"
+var (
+	AUDIT_ACTOR_SYSTEM         = "system"
+	AUDIT_ACTION_AUTO_ROLLBACK = "bundle.auto_rollback"
+	// prefixed with the resource, "app.deleted" or "version.purged"
+	AUDIT_ACTION_DELETED  = "deleted"
+	AUDIT_ACTION_RESTORED = "restored"
+	AUDIT_ACTION_PURGED   = "purged"
+	// scheduled releases and release freezes
+	AUDIT_ACTION_RELEASE_SCHEDULED          = "bundle.scheduled"
+	AUDIT_ACTION_RELEASE_SCHEDULE_CANCELLED = "bundle.schedule_cancelled"
+	AUDIT_ACTION_RELEASE_ACTIVATED          = "bundle.schedule_activated"
+	AUDIT_ACTION_FREEZE_OVERRIDDEN          = "bundle.freeze_overridden"
+	// two-person approval of releases
+	AUDIT_ACTION_APPROVAL_REQUESTED = "bundle.approval_requested"
+	AUDIT_ACTION_APPROVED           = "bundle.approved"
+	AUDIT_ACTION_REJECTED           = "bundle.rejected"
+	AUDIT_ACTION_APPROVAL_COMMENTED = "bundle.approval_commented"
+	AUDIT_ACTION_TARGETING_UPDATED  = "bundle.targeting_updated"
+	AUDIT_ACTION_PROMOTED           = "bundle.promoted"
+	// experiments serving several bundles of a version
+	AUDIT_ACTION_EXPERIMENT_STARTED   = "experiment.started"
+	AUDIT_ACTION_EXPERIMENT_CONCLUDED = "experiment.concluded"
+	AUDIT_ACTION_EXPERIMENT_STOPPED   = "experiment.stopped"
+)
+
+// roles of dashboard users
+var (
+	ROLE_ADMIN = "admin"
+)
+
+// resources that can be renamed, deleted and restored
+var (
+	RESOURCE_APP         = "app"
+	RESOURCE_ENVIRONMENT = "environment"
+	RESOURCE_VERSION     = "version"
+)
+
+// release lifecycle events sent to webhook subscriptions
+var (
+	WEBHOOK_EVENT_RELEASE_CREATED            = "release.created"
+	WEBHOOK_EVENT_RELEASE_PROMOTED           = "release.promoted"
+	WEBHOOK_EVENT_RELEASE_ENABLED            = "release.enabled"
+	WEBHOOK_EVENT_RELEASE_ROLLED_BACK        = "release.rolled_back"
+	WEBHOOK_EVENT_RELEASE_DISABLED           = "release.disabled"
+	WEBHOOK_EVENT_RELEASE_MANDATORY_CHANGED  = "release.mandatory_changed"
+	WEBHOOK_EVENT_RELEASE_AUTO_ROLLBACK      = "release.auto_rollback"
+	WEBHOOK_EVENT_RELEASE_APPROVAL_REQUESTED = "release.approval_requested"
+	WEBHOOK_EVENT_RELEASE_APPROVED           = "release.approved"
+	WEBHOOK_EVENT_RELEASE_REJECTED           = "release.rejected"
+)
+
+// states of the approval of a release
+var (
+	APPROVAL_PENDING  = "pending"
+	APPROVAL_APPROVED = "approved"
+	APPROVAL_REJECTED = "rejected"
+)
+
+// device attributes and comparisons of release targeting rules
+var (
+	TARGETING_ATTRIBUTE_OS_VERSION = "os_version"
+	TARGETING_ATTRIBUTE_LOCALE     = "locale"
+	TARGETING_ATTRIBUTE_TAG        = "tag"
+	TARGETING_OPERATOR_IN          = "in"
+	TARGETING_OPERATOR_NOT_IN      = "not_in"
+	TARGETING_OPERATOR_GTE         = "gte"
+	TARGETING_OPERATOR_LTE         = "lte"
+)
+
+// states of an experiment, a concluded one made its winner current and a stopped one left the
+// current bundle as it was
+var (
+	EXPERIMENT_RUNNING   = "running"
+	EXPERIMENT_CONCLUDED = "concluded"
+	EXPERIMENT_STOPPED   = "stopped"
+)
+
+// states of a webhook delivery, pending ones are retried until they succeed or run out of attempts
+var (
+	WEBHOOK_DELIVERY_PENDING   = "pending"
+	WEBHOOK_DELIVERY_SUCCEEDED = "succeeded"
+	WEBHOOK_DELIVERY_FAILED    = "failed"
+)
@@ -1,6 +1,12 @@
 package utils
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
 
 type Response[T any] struct {
 	Success bool `json:"success"`
@@ -33,9 +39,34 @@ func UnauthorizedResponse(c *fiber.Ctx, message string) error {
 	})
 }
 
+func ForbiddenResponse(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
+// TooManyRequestsResponse tells the client to come back after retryAfter, rounded up to whole seconds
+func TooManyRequestsResponse(c *fiber.Ctx, message string, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
+
 func ValidationErrorResponse(c *fiber.Ctx, errors []string) error {
 	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 		"success": false,
 		"message": errors,
 	})
 }
+
+// ServiceUnavailableResponse tells the client the server cannot take the request yet, retry after retryAfter
+func ServiceUnavailableResponse(c *fiber.Ctx, message string, retryAfter time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+	return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+		"success": false,
+		"message": message,
+	})
+}
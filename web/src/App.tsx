@@ -9,6 +9,7 @@ import { navigationService } from './api/navigation';
 
 // Import components
 import LoginView from './views/auth/LoginView';
+import SsoCallbackView from './views/auth/SsoCallbackView';
 import AppView from './views/dashboard/apps/AppView';
 import VersionView from './views/dashboard/versions/VersionView';
 import BundleView from './views/dashboard/bundles/BundleView';
@@ -54,6 +55,7 @@ const App: React.FC = () => {
                                 {/* Public routes */}
                                 <Route path="/" element={<LoginView />} />
                                 <Route path="/login" element={<LoginView />} />
+                                <Route path="/login/sso" element={<SsoCallbackView />} />
 
                                 {/* Protected dashboard routes */}
                                 <Route element={<ProtectedRoute />}>
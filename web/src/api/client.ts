@@ -37,16 +37,58 @@ client.interceptors.request.use(
   }
 );
 
+// Access tokens are short lived, a single in-flight refresh is shared by
+// every request that fails with 401 while it is running
+let refreshPromise: Promise<string | null> | null = null;
+
+const refreshAccessToken = async (): Promise<string | null> => {
+  const refreshToken = localStorage.getItem("refresh_token");
+  if (!refreshToken) {
+    return null;
+  }
+  try {
+    const response = await axios.post(
+      `${client.defaults.baseURL}/token/refresh`,
+      { refresh_token: refreshToken }
+    );
+    const { access_token, refresh_token } = response.data.data;
+    localStorage.setItem("token", access_token);
+    localStorage.setItem("refresh_token", refresh_token);
+    return access_token;
+  } catch {
+    return null;
+  }
+};
+
 // Response interceptor
 client.interceptors.response.use(
   (response) => {
     return response;
   },
-  (error: AxiosError) => {
+  async (error: AxiosError) => {
+    const originalRequest = error.config as
+      | (AxiosRequestConfig & { _retry?: boolean })
+      | undefined;
+
     // Handle common error scenarios like 401 (unauthorized)
     if (error.response?.status === 401) {
+      // Try once to renew the access token with the refresh token
+      if (originalRequest && !originalRequest._retry) {
+        originalRequest._retry = true;
+        refreshPromise = refreshPromise ?? refreshAccessToken();
+        const token = await refreshPromise;
+        refreshPromise = null;
+        if (token) {
+          originalRequest.headers = {
+            ...originalRequest.headers,
+            Authorization: `Bearer ${token}`,
+          };
+          return client(originalRequest);
+        }
+      }
       // Clear local storage and redirect to login
       localStorage.removeItem("token");
+      localStorage.removeItem("refresh_token");
       navigationService.navigate(ROUTES.LOGIN);
     }
 
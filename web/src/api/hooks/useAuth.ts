@@ -3,6 +3,7 @@ import { apiRequest } from "../client";
 import { LoginRequest, LoginResponse, ApiResponse } from "../../types/api";
 import { navigationService, ROUTES } from "../navigation";
 import useAuthStore from "../../store/authStore";
+import { revokeSession } from "../services/authService";
 
 // User type from API response
 interface UserResponse {
@@ -41,6 +42,7 @@ const useAuth = () => {
         // Store token in localStorage
         const token = response.data.access_token;
         localStorage.setItem("token", token);
+        localStorage.setItem("refresh_token", response.data.refresh_token);
 
         // Update auth store
         setToken(token);
@@ -71,7 +73,7 @@ const useAuth = () => {
 
   // Logout function
   const logout = (): void => {
-    localStorage.removeItem("token");
+    revokeSession();
     // Navigate to login page using the navigation service
     navigationService.navigate(ROUTES.LOGIN);
   };
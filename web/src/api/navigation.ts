@@ -14,6 +14,7 @@ export const navigationService = {
 // Path constants to avoid string literals
 export const ROUTES = {
   LOGIN: "/login",
+  SSO_CALLBACK: "/login/sso",
   DASHBOARD: "/dashboard",
   // Add more routes as needed
 };
@@ -3,7 +3,7 @@ import { apiRequest } from "../client";
 export interface AuthKey {
   id: string;
   name: string;
-  key: string;
+  prefix: string;
   isValid: boolean;
   createdBy: string;
   createdAt: string;
@@ -1,4 +1,4 @@
-import { apiRequest } from "../client";
+import client, { apiRequest } from "../client";
 import useAuthStore from "../../store/authStore";
 
 // Type for user data from API
@@ -57,10 +57,45 @@ export const validateToken = async (): Promise<boolean> => {
   }
 };
 
+/**
+ * Revokes the refresh token on the server and clears the stored tokens
+ */
+export const revokeSession = (): void => {
+  const refreshToken = localStorage.getItem("refresh_token");
+  if (refreshToken) {
+    // fire and forget, the local session ends either way
+    apiRequest({
+      method: "POST",
+      url: "/logout",
+      data: { refresh_token: refreshToken },
+    });
+  }
+  localStorage.removeItem("token");
+  localStorage.removeItem("refresh_token");
+};
+
 /**
  * Logs the user out by clearing token and auth state
  */
 export const logout = (): void => {
-  localStorage.removeItem("token");
+  revokeSession();
   useAuthStore.getState().reset();
 };
+
+/**
+ * Tells whether the server offers single sign-on
+ */
+export const isSsoEnabled = async (): Promise<boolean> => {
+  const response = await apiRequest<{ enabled: boolean }>({
+    method: "GET",
+    url: "/oidc/config",
+  });
+  return !!(response.success && response.data?.enabled);
+};
+
+/**
+ * Sends the browser to the identity provider, it comes back on the SSO callback route
+ */
+export const startSsoLogin = (): void => {
+  window.location.href = `${client.defaults.baseURL ?? ""}/oidc/login`;
+};
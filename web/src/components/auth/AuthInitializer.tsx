@@ -21,6 +21,7 @@ const AuthInitializer: React.FC<AuthInitializerProps> = ({ children }) => {
     const handleLogout = () => {
         // Remove token from localStorage
         localStorage.removeItem('token'); // Adjust key name if different
+        localStorage.removeItem('refresh_token');
         // Redirect to login page
         navigate(ROUTES.LOGIN, { replace: true });
     };
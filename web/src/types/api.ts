@@ -13,6 +13,8 @@ export interface LoginRequest {
 
 export interface LoginResponse {
   access_token: string;
+  refresh_token: string;
+  expires_in: number;
 }
 
 export interface AuthState {
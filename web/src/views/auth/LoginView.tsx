@@ -8,6 +8,7 @@ import { loginSchema, LoginFormValues } from '../../schemas'
 import { useAuth } from '../../api'
 import { ROUTES } from '../../api/navigation'
 import useAuthStore from '../../store/authStore'
+import { isSsoEnabled, startSsoLogin } from '../../api/services/authService'
 
 const LoginView = () => {
     const navigate = useNavigate()
@@ -19,6 +20,7 @@ const LoginView = () => {
         password: '',
     })
     const [formErrors, setFormErrors] = useState<Record<string, string>>({})
+    const [ssoEnabled, setSsoEnabled] = useState(false)
 
     // Redirect to dashboard if already authenticated
     useEffect(() => {
@@ -29,6 +31,10 @@ const LoginView = () => {
         }
     }, [navigate])
 
+    useEffect(() => {
+        isSsoEnabled().then(setSsoEnabled)
+    }, [])
+
     const handleChange = (e: React.ChangeEvent<HTMLInputElement>) => {
         const { name, value } = e.target
         setFormValues({
@@ -131,6 +137,17 @@ const LoginView = () => {
                     >
                         {loading ? 'Logging in...' : 'Login'}
                     </Button>
+                    {ssoEnabled && (
+                        <Button
+                            type="button"
+                            width={"100%"}
+                            mt={2}
+                            height={"40px"}
+                            onClick={startSsoLogin}
+                        >
+                            Login with SSO
+                        </Button>
+                    )}
                 </Box>
                 <Box mt={2}>
                     <Text fontSize={"14px"} color={"#999999"}>Baked with 💚 by Swish</Text>
@@ -0,0 +1,55 @@
+import { useEffect, useState } from 'react'
+import { Box, Text } from 'rebass/styled-components'
+import { useNavigate } from 'react-router-dom'
+import { validateToken } from '../../api/services/authService'
+import { ROUTES } from '../../api/navigation'
+
+/**
+ * Landing page after single sign-on, the server passes the tokens (or an error)
+ * in the URL fragment so they never reach server logs
+ */
+const SsoCallbackView = () => {
+    const navigate = useNavigate()
+    const [error, setError] = useState<string | null>(null)
+
+    useEffect(() => {
+        const params = new URLSearchParams(window.location.hash.slice(1))
+        // drop the tokens from the address bar and history
+        window.history.replaceState(null, '', window.location.pathname)
+
+        const ssoError = params.get('error')
+        const accessToken = params.get('access_token')
+        const refreshToken = params.get('refresh_token')
+        if (ssoError || !accessToken || !refreshToken) {
+            setError(ssoError ?? 'Single sign-on failed. Please try again.')
+            return
+        }
+
+        localStorage.setItem('token', accessToken)
+        localStorage.setItem('refresh_token', refreshToken)
+        validateToken().then((isAuthenticated) => {
+            if (isAuthenticated) {
+                navigate(ROUTES.DASHBOARD, { replace: true })
+            } else {
+                setError('Single sign-on failed. Please try again.')
+            }
+        })
+    }, [navigate])
+
+    return (
+        <Box backgroundColor={"#fafafa"} height={"100%"} display={"flex"} justifyContent={"center"} alignItems={"center"} flexDirection={"column"}>
+            {error ? (
+                <>
+                    <Text color="red" fontSize={16}>{error}</Text>
+                    <Text mt={2} fontSize={14} color={"#666"} sx={{ cursor: 'pointer', textDecoration: 'underline' }} onClick={() => navigate(ROUTES.LOGIN, { replace: true })}>
+                        Back to login
+                    </Text>
+                </>
+            ) : (
+                <Text fontSize={16} color={"#666"}>Signing you in...</Text>
+            )}
+        </Box>
+    )
+}
+
+export default SsoCallbackView
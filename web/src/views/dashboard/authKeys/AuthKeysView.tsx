@@ -1,7 +1,7 @@
 import React, { useState } from 'react';
 import { Box, Text, Flex } from 'rebass/styled-components';
 import { toast } from 'react-toastify';
-import { FaPlus, FaArrowLeft, FaEye, FaEyeSlash, FaCopy } from 'react-icons/fa6';
+import { FaPlus, FaArrowLeft, FaCopy } from 'react-icons/fa6';
 import { useNavigate } from 'react-router-dom';
 import { useAuthKeys } from '../../../api/hooks/useAuthKeys';
 import Button from '../../../components/primitives/Button';
@@ -113,22 +113,18 @@ const CreateAuthKeyModal: React.FC<CreateAuthKeyModalProps> = ({ isOpen, onClose
 const AuthKeysView: React.FC = () => {
     const { authKeys, loading, createAuthKey } = useAuthKeys();
     const [showModal, setShowModal] = useState(false);
-    const [showKeys, setShowKeys] = useState<Record<string, boolean>>({});
+    // the plaintext key is only returned once, right after it is created
+    const [createdKey, setCreatedKey] = useState<string | null>(null);
     const navigate = useNavigate();
 
     const handleCreateAuthKey = async (name: string) => {
         const newKey = await createAuthKey(name);
         if (newKey) {
             toast.success('Auth key created successfully!');
-            // Show the key for the newly created auth key
-            setShowKeys(prev => ({ ...prev, [newKey]: true }));
+            setCreatedKey(newKey);
         }
     };
 
-    const toggleKeyVisibility = (key: string) => {
-        setShowKeys(prev => ({ ...prev, [key]: !prev[key] }));
-    };
-
     const copyToClipboard = (text: string) => {
         navigator.clipboard.writeText(text);
         toast.success('Auth key copied to clipboard!');
@@ -317,6 +313,46 @@ const AuthKeysView: React.FC = () => {
                     </Button>
                 </Flex>
 
+                {createdKey && (
+                    <Box mb={3} p={3} sx={{ backgroundColor: '#fff8e1', border: '1px solid #ffe082', borderRadius: '8px' }}>
+                        <Text fontSize="14px" fontWeight="bold" mb={1}>
+                            Copy your new auth key now. It will not be shown again.
+                        </Text>
+                        <Flex alignItems="center" justifyContent="space-between">
+                            <Text fontSize="14px" fontFamily="monospace" sx={{ wordBreak: 'break-all' }}>
+                                {createdKey}
+                            </Text>
+                            <Flex ml={2}>
+                                <Box
+                                    as="button"
+                                    mr={1}
+                                    sx={{
+                                        display: 'flex',
+                                        alignItems: 'center',
+                                        justifyContent: 'center',
+                                        height: '32px',
+                                        width: '32px',
+                                        border: '1px solid #e0e0e0',
+                                        borderRadius: '4px',
+                                        bg: 'transparent',
+                                        cursor: 'pointer',
+                                        '&:hover': {
+                                            bg: '#f0f0f0'
+                                        }
+                                    }}
+                                    onClick={() => copyToClipboard(createdKey)}
+                                    title="Copy to clipboard"
+                                >
+                                    <FaCopy size={14} />
+                                </Box>
+                                <Button onClick={() => setCreatedKey(null)}>
+                                    Done
+                                </Button>
+                            </Flex>
+                        </Flex>
+                    </Box>
+                )}
+
                 {authKeys.length === 0 ? (
                     <Box
                         p={4}
@@ -380,72 +416,14 @@ const AuthKeysView: React.FC = () => {
                                     </Text>
                                 </Flex>
 
-                                {/* Auth Key Display with Masking */}
+                                {/* Only the non-secret prefix of a key is known after creation */}
                                 <Box mt={3} p={2} sx={{ backgroundColor: '#f8f9fa', borderRadius: '4px' }}>
-                                    <Flex alignItems="center" justifyContent="space-between">
-                                        <Box flex={1}>
-                                            <Text fontSize="12px" color="#666" mb={1}>
-                                                Auth Key
-                                            </Text>
-                                            <Text
-                                                fontSize="14px"
-                                                fontFamily="monospace"
-                                                sx={{
-                                                    wordBreak: 'break-all',
-                                                    userSelect: showKeys[authKey.key] ? 'text' : 'none'
-                                                }}
-                                            >
-                                                {showKeys[authKey.key] ? authKey.key : '••••••••••••••••••••••••••••••••'}
-                                            </Text>
-                                        </Box>
-                                        <Flex ml={2}>
-                                            <Box
-                                                as="button"
-                                                mr={1}
-                                                sx={{
-                                                    display: 'flex',
-                                                    alignItems: 'center',
-                                                    justifyContent: 'center',
-                                                    height: '32px',
-                                                    width: '32px',
-                                                    border: '1px solid #e0e0e0',
-                                                    borderRadius: '4px',
-                                                    bg: 'transparent',
-                                                    cursor: 'pointer',
-                                                    '&:hover': {
-                                                        bg: '#f0f0f0'
-                                                    }
-                                                }}
-                                                onClick={() => toggleKeyVisibility(authKey.key)}
-                                                title={showKeys[authKey.key] ? 'Hide key' : 'Show key'}
-                                            >
-                                                {showKeys[authKey.key] ? <FaEyeSlash size={14} /> : <FaEye size={14} />}
-                                            </Box>
-                                            {showKeys[authKey.key] && (
-                                                <Box
-                                                    as="button"
-                                                    sx={{
-                                                        display: 'flex',
-                                                        alignItems: 'center',
-                                                        justifyContent: 'center',
-                                                        height: '32px',
-                                                        width: '32px',
-                                                        border: '1px solid #e0e0e0',
-                                                        borderRadius: '4px',
-                                                        bg: 'transparent',
-                                                        cursor: 'pointer',
-                                                        '&:hover': {
-                                                            bg: '#f0f0f0'
-                                                        }
-                                                    }}
-                                                    onClick={() => copyToClipboard(authKey.key)}
-                                                    title="Copy to clipboard"
-                                                >
-                                                    <FaCopy size={14} />
-                                                </Box>
-                                            )}
-                                        </Flex>
-                                    </Flex>
+                                    <Text fontSize="12px" color="#666" mb={1}>
+                                        Auth Key
+                                    </Text>
+                                    <Text fontSize="14px" fontFamily="monospace" sx={{ wordBreak: 'break-all' }}>
+                                        {authKey.prefix}••••••••••••••••••••••••
+                                    </Text>
                                 </Box>
                             </Box>
                         ))}